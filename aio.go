@@ -0,0 +1,321 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "stdint.h"
+#include "errno.h"
+#include "rados/librados.h"
+
+extern void goAioCallback(rados_completion_t, void *);
+*/
+import "C"
+
+import (
+    "fmt"
+    "sync"
+    "time"
+    "unsafe"
+)
+
+// Completion tracks an asynchronous RADOS operation started by one of the
+// Context Aio functions. librados keeps using the memory behind an
+// operation until it completes, so that memory is allocated in C and owned
+// by the Completion until Release() is called.
+type Completion struct {
+    comp C.rados_completion_t
+    op   string
+    name string
+
+    c     *Context // Statistics
+    kind  opKind
+    start time.Time
+    size  int
+
+    namespace string // Auditing of writes and removals
+    auditOp   string
+
+    wop *WriteOp          // Write operation, guarded by the seal
+    cop C.rados_write_op_t
+
+    buf    unsafe.Pointer // Read buffer
+    n      int            // Bytes read
+    wbuf   unsafe.Pointer // Write buffer
+    psize  *C.uint64_t    // Stat results
+    pmtime *C.time_t
+
+    waited bool
+    ret    C.int
+    err    error
+
+    id         uintptr // Callback registration (see OnComplete())
+    mutex      sync.Mutex
+    complete   bool
+    completed  time.Time
+    onComplete func(cp *Completion)
+}
+
+// newCompletion is a utility function that creates the librados completion
+// for an asynchronous operation op of the given kind on the named object.
+func (c *Context) newCompletion(kind opKind, op, name string) (*Completion, error) {
+    if c.closed() {
+        return nil, closedError("aio " + op + " " + name)
+    }
+
+    cp := &Completion{op: op, name: name, c: c, kind: kind, start: time.Now(), namespace: c.namespace}
+
+    carg := cp.register()
+    if cerr := C.rados_aio_create_completion(carg, C.rados_callback_t(C.goAioCallback), nil, &cp.comp); cerr < 0 {
+        cp.unregister()
+        return nil, fmt.Errorf("RADOS aio create completion: %w", radosErrno(cerr))
+    }
+
+    return cp, nil
+}
+
+// AioRead starts reading up to length bytes from the named object in the
+// pool referenced by the given context at the byte offset off. The data
+// is available from Data() once Wait() has returned.
+func (c *Context) AioRead(name string, length int, off int64) (*Completion, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cp, err := c.newCompletion(opRead, "read", name)
+    if err != nil {
+        return nil, err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    cp.buf = C.malloc(C.size_t(length))
+
+    cerr := c.call(opRead, "rados_aio_read", name, func() C.int {
+        return C.rados_aio_read(c.ctx, cname, cp.comp, (*C.char)(cp.buf), C.size_t(length), C.uint64_t(off))
+    })
+    if cerr < 0 {
+        cp.release()
+        return nil, fmt.Errorf("RADOS aio read %s: %w", name, radosErrno(cerr))
+    }
+
+    return cp, nil
+}
+
+// AioStat starts retrieving information about the named object in the pool
+// referenced by the given context. The information is available from
+// Size() and ModTime() once Wait() has returned.
+func (c *Context) AioStat(name string) (*Completion, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cp, err := c.newCompletion(opStat, "stat", name)
+    if err != nil {
+        return nil, err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    cp.psize = (*C.uint64_t)(C.malloc(C.size_t(unsafe.Sizeof(C.uint64_t(0)))))
+    cp.pmtime = (*C.time_t)(C.malloc(C.size_t(unsafe.Sizeof(C.time_t(0)))))
+
+    cerr := c.call(opStat, "rados_aio_stat", name, func() C.int {
+        return C.rados_aio_stat(c.ctx, cname, cp.comp, cp.psize, cp.pmtime)
+    })
+    if cerr < 0 {
+        cp.release()
+        return nil, fmt.Errorf("RADOS aio stat %s: %w", name, radosErrno(cerr))
+    }
+
+    return cp, nil
+}
+
+// AioWriteFull starts writing data to the named object in the pool
+// referenced by the given context, replacing any existing data like Put().
+// The data is copied, so the caller may reuse it once AioWriteFull has
+// returned.
+func (c *Context) AioWriteFull(name string, data []byte) (*Completion, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cp, err := c.newCompletion(opWrite, "write", name)
+    if err != nil {
+        c.audit("put", name, &err)
+        return nil, err
+    }
+    cp.auditOp = "put"
+
+    // The data is copied to C memory, which the write operation uses if
+    // it is performed again by Wait().
+    cp.wbuf = C.CBytes(data)
+    cp.size = len(data)
+
+    cp.wop = NewWriteOp()
+    cp.wop.WriteFull(unsafe.Slice((*byte)(cp.wbuf), len(data)))
+
+    if cerr := cp.operate(); cerr < 0 {
+        cp.release()
+        err = fmt.Errorf("RADOS aio write %s: %w", name, radosErrno(cerr))
+        c.audit("put", name, &err)
+        return nil, err
+    }
+
+    return cp, nil
+}
+
+// AioRemove starts deleting the named object from the pool referenced by
+// the given context. Unlike Remove(), it always deletes the object, even
+// in trash mode.
+func (c *Context) AioRemove(name string) (*Completion, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cp, err := c.newCompletion(opRemove, "remove", name)
+    if err != nil {
+        c.audit("remove", name, &err)
+        return nil, err
+    }
+    cp.auditOp = "remove"
+
+    cp.wop = NewWriteOp()
+    cp.wop.Remove()
+
+    if cerr := cp.operate(); cerr < 0 {
+        cp.release()
+        err = fmt.Errorf("RADOS aio remove %s: %w", name, radosErrno(cerr))
+        c.audit("remove", name, &err)
+        return nil, err
+    }
+
+    return cp, nil
+}
+
+// operate is a utility function that starts performing the write
+// operation of the completion, guarded by the seal of the object.
+func (cp *Completion) operate() C.int {
+    cname := C.CString(cp.name)
+    defer C.free(unsafe.Pointer(cname))
+
+    cp.cop = cp.wop.build(true)
+
+    return cp.c.call(cp.kind, "rados_aio_write_op_operate", cp.name, func() C.int {
+        return C.rados_aio_write_op_operate(cp.cop, cp.c.ctx, cp.comp, cname, nil, C.int(cp.c.opFlags))
+    })
+}
+
+// Wait blocks until the asynchronous operation has completed and returns
+// its error, if any. Wait may be called more than once.
+func (cp *Completion) Wait() error {
+    if cp.waited {
+        return cp.err
+    }
+    if cp.comp == nil {
+        return closedError("aio " + cp.op + " " + cp.name)
+    }
+
+    C.rados_aio_wait_for_complete(cp.comp)
+    cp.waited = true
+
+    cerr := C.rados_aio_get_return_value(cp.comp)
+
+    if cp.wop != nil {
+        cerr = cp.wop.sealResult(cerr)
+    }
+    cp.ret = cerr
+
+    if cp.kind == opRead {
+        cp.size = int(cerr)
+    }
+    cp.c.record(cp.kind, cp.name, cp.start, cerr, cp.size)
+
+    if cerr < 0 && cp.wbuf != nil {
+        cp.err = cp.c.writeError("aio "+cp.op, cp.name, cerr)
+    } else if cerr < 0 {
+        cp.err = fmt.Errorf("RADOS aio %s %s: %w", cp.op, cp.name, radosErrno(cerr))
+    } else if cp.buf != nil {
+        cp.n = int(cerr)
+    }
+
+    // Writes and removals are audited once their result is known
+    if cp.auditOp != "" {
+        cp.c.auditIn(cp.namespace, cp.auditOp, cp.name, &cp.err)
+    }
+
+    return cp.err
+}
+
+// Data returns a copy of the data read by a completed AioRead operation.
+func (cp *Completion) Data() []byte {
+    if cp.buf == nil {
+        return nil
+    }
+
+    return C.GoBytes(cp.buf, C.int(cp.n))
+}
+
+// Size returns the object size retrieved by a completed AioStat operation.
+func (cp *Completion) Size() int64 {
+    if cp.psize == nil {
+        return 0
+    }
+
+    return int64(*cp.psize)
+}
+
+// ModTime returns the object modification time retrieved by a completed
+// AioStat operation.
+func (cp *Completion) ModTime() time.Time {
+    if cp.pmtime == nil {
+        return time.Time{}
+    }
+
+    return time.Unix(int64(*cp.pmtime), int64(0))
+}
+
+// Version returns the version of the object after the completed
+// operation.
+func (cp *Completion) Version() uint64 {
+    if cp.comp == nil {
+        return 0
+    }
+
+    return uint64(C.rados_aio_get_version(cp.comp))
+}
+
+// Release frees the resources held by the completion. If the operation is
+// still in progress, Release waits for it to complete first. Releasing a
+// completion again does nothing.
+func (cp *Completion) Release() error {
+    if cp.comp == nil {
+        return nil
+    }
+
+    cp.Wait()
+    cp.release()
+
+    return nil
+}
+
+// release frees the librados completion and any C memory used by the
+// operation.
+func (cp *Completion) release() {
+    cp.unregister()
+    C.rados_aio_release(cp.comp)
+    cp.comp = nil
+
+    if cp.wop != nil {
+        C.rados_release_write_op(cp.cop)
+        cp.wop.Release()
+        cp.wop = nil
+    }
+
+    C.free(cp.buf)
+    C.free(cp.wbuf)
+    C.free(unsafe.Pointer(cp.psize))
+    C.free(unsafe.Pointer(cp.pmtime))
+    cp.buf, cp.wbuf, cp.psize, cp.pmtime = nil, nil, nil, nil
+}
@@ -0,0 +1,224 @@
+package rados
+
+import (
+    "container/heap"
+    "errors"
+    "math"
+    "sort"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// analyzeSizeBounds are the upper bounds (exclusive) of the buckets of the
+// size histograms of pool analyses, after the bucket of empty objects.
+var analyzeSizeBounds = []int64{
+    1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10,
+    1 << 20, 4 << 20, 16 << 20, 64 << 20, 256 << 20,
+    1 << 30, math.MaxInt64,
+}
+
+// SizeBucket counts the objects whose size is in [Min, Max).
+type SizeBucket struct {
+    Min     int64
+    Max     int64
+    Objects uint64
+    Bytes   uint64
+}
+
+// PrefixUsage counts the objects whose names share a prefix.
+type PrefixUsage struct {
+    Objects uint64
+    Bytes   uint64
+}
+
+// PoolAnalysis describes what is consuming space in a pool (see
+// AnalyzePool()).
+type PoolAnalysis struct {
+    Objects uint64
+    Bytes   uint64
+
+    // Sizes is the size histogram of the objects. The first bucket holds
+    // the empty objects, and the next ones have growing bounds, from 1 KB
+    // up to 1 GB and more.
+    Sizes []SizeBucket
+
+    // Prefixes counts the objects by prefix: the part of their names up to
+    // the first delimiter, included (the whole name if there is none).
+    Prefixes map[string]PrefixUsage
+
+    Errors map[ListEntry]error
+}
+
+// AnalyzeOptions configure a pool analysis.
+type AnalyzeOptions struct {
+    // Prefix restricts the analysis to the objects whose names start with
+    // it.
+    Prefix string
+
+    // Delimiter separates the prefix of the names of the objects that
+    // are counted together ("/" if empty).
+    Delimiter string
+
+    // Concurrency is the number of objects in flight (1 if 0).
+    Concurrency int
+}
+
+// AnalyzePool scans the objects in the pool referenced by the given
+// context and reports their size histogram and their number and bytes by
+// name prefix, to understand what is consuming space in the pool. Only the
+// objects in the namespace of the context are analyzed, unless it is
+// AllNamespaces, in which case objects with the same prefix in different
+// namespaces are counted together. Objects removed during the scan are
+// ignored. The returned error is only set if the analysis could not
+// proceed at all (e.g., because listing the pool failed).
+func (c *Context) AnalyzePool(opts *AnalyzeOptions) (*PoolAnalysis, error) {
+    if opts == nil {
+        opts = &AnalyzeOptions{}
+    }
+
+    delimiter := opts.Delimiter
+    if delimiter == "" {
+        delimiter = "/"
+    }
+
+    analysis := &PoolAnalysis{
+        Sizes:    make([]SizeBucket, len(analyzeSizeBounds)+1),
+        Prefixes: make(map[string]PrefixUsage),
+        Errors:   make(map[ListEntry]error),
+    }
+
+    analysis.Sizes[0] = SizeBucket{Min: 0, Max: 1}
+    for i, max := range analyzeSizeBounds {
+        analysis.Sizes[i+1] = SizeBucket{Min: analysis.Sizes[i].Max, Max: max}
+    }
+
+    var mutex sync.Mutex
+
+    errs, err := c.scanObjects(opts.Prefix, opts.Concurrency, func(ctx *Context, entry ListEntry) error {
+        info, err := ctx.Stat(entry.Name)
+        if errors.Is(err, syscall.ENOENT) {
+            return nil
+        } else if err != nil {
+            return err
+        }
+
+        size := info.Size()
+
+        prefix := entry.Name
+        if i := strings.Index(entry.Name, delimiter); i >= 0 {
+            prefix = entry.Name[:i+len(delimiter)]
+        }
+
+        mutex.Lock()
+        defer mutex.Unlock()
+
+        analysis.Objects++
+        analysis.Bytes += uint64(size)
+
+        bucket := 0
+        for size >= analysis.Sizes[bucket].Max {
+            bucket++
+        }
+        analysis.Sizes[bucket].Objects++
+        analysis.Sizes[bucket].Bytes += uint64(size)
+
+        usage := analysis.Prefixes[prefix]
+        usage.Objects++
+        usage.Bytes += uint64(size)
+        analysis.Prefixes[prefix] = usage
+
+        return nil
+    })
+
+    for entry, err := range errs {
+        analysis.Errors[entry] = err
+    }
+
+    return analysis, err
+}
+
+// LargeObject describes an object found by LargestObjects().
+type LargeObject struct {
+    Name      string
+    Namespace string
+    Size      int64
+    ModTime   time.Time
+}
+
+// LargestObjectsReport is the outcome of a search for the largest objects
+// of a pool (see LargestObjects()).
+type LargestObjectsReport struct {
+    Objects []LargeObject // Largest first
+    Errors  map[ListEntry]error
+}
+
+// largeObjects is a min-heap of objects by size, which keeps the largest
+// objects seen so far.
+type largeObjects []LargeObject
+
+func (h largeObjects) Len() int            { return len(h) }
+func (h largeObjects) Less(i, j int) bool  { return h[i].Size < h[j].Size }
+func (h largeObjects) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *largeObjects) Push(x interface{}) { *h = append(*h, x.(LargeObject)) }
+
+func (h *largeObjects) Pop() interface{} {
+    old := *h
+    x := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return x
+}
+
+// LargestObjects returns the n largest objects in the pool referenced by
+// the given context, largest first, stating up to concurrency objects at
+// a time, for cleanup and capacity investigations. Only the objects in the
+// namespace of the context are considered, unless it is AllNamespaces.
+// Objects removed during the scan are ignored. The returned error is only
+// set if the scan could not proceed at all (e.g., because listing the pool
+// failed).
+func (c *Context) LargestObjects(n, concurrency int) (*LargestObjectsReport, error) {
+    var mutex sync.Mutex
+    var largest largeObjects
+
+    errs, err := c.scanObjects("", concurrency, func(ctx *Context, entry ListEntry) error {
+        info, err := ctx.Stat(entry.Name)
+        if errors.Is(err, syscall.ENOENT) {
+            return nil
+        } else if err != nil {
+            return err
+        }
+
+        object := LargeObject{
+            Name:      entry.Name,
+            Namespace: entry.Namespace,
+            Size:      info.Size(),
+            ModTime:   info.ModTime(),
+        }
+
+        mutex.Lock()
+        defer mutex.Unlock()
+
+        switch {
+        case n <= 0:
+        case len(largest) < n:
+            heap.Push(&largest, object)
+        case object.Size > largest[0].Size:
+            largest[0] = object
+            heap.Fix(&largest, 0)
+        }
+
+        return nil
+    })
+
+    sort.Slice(largest, func(i, j int) bool {
+        return largest[i].Size > largest[j].Size
+    })
+
+    report := &LargestObjectsReport{Objects: largest, Errors: make(map[ListEntry]error)}
+    for entry, err := range errs {
+        report.Errors[entry] = err
+    }
+
+    return report, err
+}
@@ -0,0 +1,78 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+)
+
+// ErrCapExceeded is returned by AppendCapped() when the append would make
+// the object larger than its cap.
+var ErrCapExceeded = errors.New("RADOS object size cap exceeded")
+
+// AppendCapped appends data to the named object in the pool referenced by
+// the given context like Append(), unless the object would then be larger
+// than maxSize bytes, in which case nothing is written and AppendCapped
+// fails with an error wrapping ErrCapExceeded, e.g., to bound per-tenant
+// log objects. The append is guarded by the version of the object at the
+// size check, so concurrent appends cannot exceed the cap together; they
+// are retried until they fit or are refused.
+func (c *Context) AppendCapped(name string, data []byte, maxSize int64) (err error) {
+    defer c.audit("append", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    for {
+        stat, err := c.AioStat(name)
+        if err != nil {
+            return err
+        }
+
+        stat.Wait()
+        cerr, size, version := stat.ret, stat.Size(), stat.Version()
+        stat.Release()
+
+        if cerr < 0 && cerr != -C.ENOENT {
+            return fmt.Errorf("RADOS append %s: %w", name, radosErrno(cerr))
+        }
+        exists := cerr == 0
+
+        if size+int64(len(data)) > maxSize {
+            return fmt.Errorf("RADOS append %s: %d + %d bytes over %d: %w", name, size, len(data), maxSize, ErrCapExceeded)
+        }
+
+        op := NewWriteOp()
+
+        if exists {
+            op.AssertVersion(version)
+        } else {
+            op.Create(true)
+        }
+        op.Write(data, size)
+
+        cerr = c.operate(name, op, nil)
+        op.Release()
+
+        switch {
+        case cerr == -C.ERANGE || cerr == -C.EOVERFLOW || cerr == -C.EEXIST || cerr == -C.ENOENT:
+            // Someone else modified the object in the meantime
+            continue
+        case cerr < 0:
+            return c.writeError("append", name, cerr)
+        }
+
+        return nil
+    }
+}
+
+// AppendCapped wraps the Context-based AppendCapped function for the given
+// object. It fails with ErrImmutable if the object has been sealed.
+func (o *Object) AppendCapped(data []byte, maxSize int64) error {
+    return o.c.AppendCapped(o.name, data, maxSize)
+}
@@ -0,0 +1,255 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "syscall"
+    "time"
+)
+
+// archiveXattr is the extended attribute in which the stub of an archived
+// object records the location of its data (see Archive()).
+const archiveXattr = "rados.go.archive"
+
+// ArchiveLocation is where the data of an archived object is stored.
+type ArchiveLocation struct {
+    Pool      string    `json:"pool"`
+    Namespace string    `json:"namespace"`
+    Name      string    `json:"name"`
+    Size      int64     `json:"size"`
+    Time      time.Time `json:"time"` // When the object was archived
+}
+
+// Archive moves the data of the named object in the pool referenced by the
+// given context to the pool referenced by cold (e.g., an erasure-coded pool
+// on cheaper media), in the namespace of cold, and replaces the original
+// with an empty stub recording where the data went. Get() transparently
+// follows stubs; other reads see the empty stub. The extended attributes
+// and omap keys of the object are copied, and kept on the stub as well.
+//
+// The archived copy is named after the ID of the pool, the namespace, the
+// name and the version of the object, and is created exclusively, so
+// Archive never replaces an existing object of the cold pool: it fails
+// with an error wrapping syscall.EEXIST instead. The stub replaces the
+// original only if it was not modified while its data was copied,
+// otherwise Archive fails with an error wrapping ErrConflict and removes
+// the copy. Writing to a stub with anything else than Unarchive() leaves
+// it pointing at stale data, and removing it leaves the archived copy
+// behind.
+func (c *Context) Archive(name string, cold *Context) (err error) {
+    defer c.audit("archive", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    if loc, err := c.ArchiveLocation(name); err != nil {
+        return err
+    } else if loc != nil {
+        return fmt.Errorf("RADOS archive %s: already archived in pool %s", name, loc.Pool)
+    }
+
+    version, cerr, err := c.objectVersion(name)
+    if err != nil {
+        return err
+    }
+    if cerr < 0 {
+        return fmt.Errorf("RADOS archive %s: %w", name, radosErrno(cerr))
+    }
+
+    poolID, err := c.PoolID()
+    if err != nil {
+        return err
+    }
+    archived := fmt.Sprintf("%d.%q.%q.%d", poolID, c.namespace, name, version)
+
+    size, err := copyObject(c, name, cold, archived, true, nil)
+    if err != nil {
+        if !errors.Is(err, syscall.EEXIST) {
+            // Don't leave a partial copy behind
+            cold.remove(archived)
+        }
+        return err
+    }
+
+    manifest, err := json.Marshal(&ArchiveLocation{
+        Pool:      cold.Pool,
+        Namespace: cold.namespace,
+        Name:      archived,
+        Size:      size,
+        Time:      time.Now().UTC(),
+    })
+    if err != nil {
+        cold.remove(archived)
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.AssertVersion(version)
+    op.WriteFull(nil)
+    op.SetXattr(archiveXattr, manifest)
+
+    if cerr = c.operate(name, op, nil); cerr < 0 {
+        // Don't leave a copy of data that is not archived behind
+        cold.remove(archived)
+
+        if err = conflictError(name, version, true, cerr); err != nil {
+            return err
+        }
+        return c.writeError("archive", name, cerr)
+    }
+
+    return nil
+}
+
+// ArchiveLocation returns where the data of the named object in the pool
+// referenced by the given context is archived, or nil if the object is
+// not an archive stub.
+func (c *Context) ArchiveLocation(name string) (*ArchiveLocation, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    manifest, cerr := c.getXattr(name, archiveXattr)
+
+    switch {
+    case cerr == -C.ENODATA:
+        return nil, nil
+    case cerr < 0:
+        return nil, fmt.Errorf("RADOS archive location %s: %w", name, radosErrno(cerr))
+    }
+
+    loc := &ArchiveLocation{}
+    if err := json.Unmarshal(manifest, loc); err != nil {
+        return nil, fmt.Errorf("RADOS archive location %s: %w", name, err)
+    }
+
+    return loc, nil
+}
+
+// Unarchive moves the data of the named archived object in the pool
+// referenced by the given context back from the archive (see Archive()),
+// replacing the stub, and removes the archived copy. It fails with an
+// error wrapping ErrConflict if the stub is modified while the data is
+// read back.
+func (c *Context) Unarchive(name string) (err error) {
+    defer c.audit("unarchive", name, &err)
+
+    version, cerr, err := c.objectVersion(name)
+    if err != nil {
+        return err
+    }
+    if cerr < 0 {
+        return fmt.Errorf("RADOS unarchive %s: %w", name, radosErrno(cerr))
+    }
+
+    loc, err := c.ArchiveLocation(name)
+    if err != nil {
+        return err
+    } else if loc == nil {
+        return fmt.Errorf("RADOS unarchive %s: not archived", name)
+    }
+
+    cold, err := c.archiveContext(loc)
+    if err != nil {
+        return err
+    }
+    defer cold.Release()
+
+    data, err := getArchive(cold, name, loc)
+    if err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.AssertVersion(version)
+    op.WriteFull(data)
+    op.RmXattr(archiveXattr)
+
+    if cerr = c.operate(name, op, nil); cerr < 0 {
+        if err = conflictError(name, version, true, cerr); err != nil {
+            return err
+        }
+        return c.writeError("unarchive", name, cerr)
+    }
+
+    return cold.Remove(loc.Name)
+}
+
+// getArchived is a utility function that returns the data of the named
+// object if it is an archive stub, and whether it is one.
+func (c *Context) getArchived(name string) ([]byte, bool, error) {
+    loc, err := c.ArchiveLocation(name)
+    if err != nil || loc == nil {
+        return nil, false, err
+    }
+
+    cold, err := c.archiveContext(loc)
+    if err != nil {
+        return nil, true, err
+    }
+    defer cold.Release()
+
+    data, err := getArchive(cold, name, loc)
+
+    return data, true, err
+}
+
+// getArchive is a utility function that reads the archived copy of the
+// named object from the given cold context, checking that it has the size
+// recorded in its location.
+func getArchive(cold *Context, name string, loc *ArchiveLocation) ([]byte, error) {
+    data, err := cold.Get(loc.Name)
+    if err != nil {
+        return nil, err
+    }
+
+    if int64(len(data)) != loc.Size {
+        return nil, fmt.Errorf("RADOS archive %s: archived copy %s has %d bytes instead of %d",
+            name, loc.Name, len(data), loc.Size)
+    }
+
+    return data, nil
+}
+
+// archiveContext is a utility function that returns a new context for the
+// pool and namespace of an archive location.
+func (c *Context) archiveContext(loc *ArchiveLocation) (*Context, error) {
+    cold, err := c.rados.NewContext(loc.Pool)
+    if err != nil {
+        return nil, fmt.Errorf("RADOS archive %s: %w", loc.Name, err)
+    }
+
+    if err = cold.SetNamespace(loc.Namespace); err != nil {
+        cold.Release()
+        return nil, err
+    }
+
+    return cold, nil
+}
+
+// Archive wraps the Context-based Archive function for the given object.
+func (o *Object) Archive(cold *Context) error {
+    return o.c.Archive(o.name, cold)
+}
+
+// ArchiveLocation wraps the Context-based ArchiveLocation function for the
+// given object.
+func (o *Object) ArchiveLocation() (*ArchiveLocation, error) {
+    return o.c.ArchiveLocation(o.name)
+}
+
+// Unarchive wraps the Context-based Unarchive function for the given object.
+func (o *Object) Unarchive() error {
+    return o.c.Unarchive(o.name)
+}
@@ -0,0 +1,215 @@
+package rados
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// AuditEntry records a mutating call made through a cluster handle with an
+// audit sink (see Rados.SetAuditSink()).
+type AuditEntry struct {
+    Time      time.Time `json:"time"`
+    Client    string    `json:"client"`   // CephX entity name, e.g., "client.admin"
+    Instance  uint64    `json:"instance"` // ID of the cluster handle (see Rados.InstanceID())
+    Op        string    `json:"op"`       // E.g., "put", "remove" or "lock"
+    Pool      string    `json:"pool"`
+    Namespace string    `json:"namespace,omitempty"`
+    Object    string    `json:"object"`
+    Error     string    `json:"error,omitempty"` // Error of the call, if it failed
+}
+
+// AuditSink receives the audit entries of a cluster handle. Audit is
+// called synchronously after each audited call, possibly from several
+// goroutines at once, so it should be quick and safe for concurrent use.
+type AuditSink interface {
+    Audit(entry AuditEntry) error
+}
+
+// auditor holds the audit settings of a cluster handle.
+type auditor struct {
+    sinks  []auditSink
+    client string
+}
+
+// auditSink is an audit sink along with its error handler.
+type auditSink struct {
+    sink    AuditSink
+    onError func(err error)
+}
+
+// SetAuditSink makes the given RADOS cluster handle record the mutating
+// calls made through its contexts to sink, for compliance-sensitive
+// deployments: Put (and its variants, including PutWithChecksum, PutJSON
+// and PutGob), WriteAt, Append, Truncate, Touch, Remove, Operate, SetXattr,
+// SetTags, IncrCounter, Exec, the index and transaction mutations, the
+// ring buffer writes, the asynchronous writes and removals (and so PutMany
+// and StartDelete), the copies made by CopyPool, RestoreTrash and
+// ImportObject, the trash purges, Archive and Unarchive, the pool snapshot
+// operations, and the lock operations (LockExclusive, LockShared, Unlock
+// and BreakLock). Failed calls are recorded too. If the sink fails,
+// onError, if not nil, is called with the error; the audited call itself
+// is not affected. SetAuditSink replaces the sinks added before, and a
+// nil sink disables auditing.
+//
+// SetAuditSink must be called before the handle is used by other
+// goroutines.
+func (r *Rados) SetAuditSink(sink AuditSink, onError func(err error)) error {
+    r.audit = nil
+    if sink == nil {
+        return nil
+    }
+
+    return r.AddAuditSink(sink, onError)
+}
+
+// AddAuditSink makes the given RADOS cluster handle record its mutating
+// calls to sink like SetAuditSink(), in addition to the sinks already set,
+// e.g., to feed a replication journal alongside a compliance log. Each
+// entry is passed to the sinks in the order they were added; a failing
+// sink doesn't prevent the others from getting the entry.
+//
+// AddAuditSink must be called before the handle is used by other
+// goroutines.
+func (r *Rados) AddAuditSink(sink AuditSink, onError func(err error)) error {
+    if sink == nil {
+        return fmt.Errorf("RADOS add audit sink: nil sink")
+    }
+
+    a := &auditor{}
+    if r.audit != nil {
+        a.client = r.audit.client
+        a.sinks = append(a.sinks, r.audit.sinks...)
+    } else {
+        client, err := r.ConfGet("name")
+        if err != nil {
+            return err
+        }
+        a.client = client
+    }
+
+    a.sinks = append(a.sinks, auditSink{sink: sink, onError: onError})
+    r.audit = a
+
+    return nil
+}
+
+// audit is a utility function that records the call op on the named
+// object, which returned *err, to the audit sink of the cluster handle of
+// the given context, if any.
+func (c *Context) audit(op, name string, err *error) {
+    c.auditIn(c.namespace, op, name, err)
+}
+
+// auditIn is a utility function that records the call op like audit(), for
+// the named object in the given namespace, e.g., the namespace the context
+// was in when an asynchronous operation was started.
+func (c *Context) auditIn(namespace, op, name string, err *error) {
+    a := c.rados.audit
+    if a == nil {
+        return
+    }
+
+    entry := AuditEntry{
+        Time:      time.Now(),
+        Client:    a.client,
+        Instance:  c.rados.InstanceID(),
+        Op:        op,
+        Pool:      c.Pool,
+        Namespace: namespace,
+        Object:    name,
+    }
+    if *err != nil {
+        entry.Error = (*err).Error()
+    }
+
+    for _, s := range a.sinks {
+        if serr := s.sink.Audit(entry); serr != nil && s.onError != nil {
+            s.onError(serr)
+        }
+    }
+}
+
+// writerAuditSink writes audit entries as JSON lines.
+type writerAuditSink struct {
+    mutex sync.Mutex
+    w     io.Writer
+}
+
+// NewWriterAuditSink returns an audit sink that writes each entry to w as
+// a line of JSON, e.g., to append them to a log file.
+func NewWriterAuditSink(w io.Writer) AuditSink {
+    return &writerAuditSink{w: w}
+}
+
+func (s *writerAuditSink) Audit(entry AuditEntry) error {
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+
+    s.mutex.Lock()
+    defer s.mutex.Unlock()
+
+    _, err = s.w.Write(append(line, '\n'))
+
+    return err
+}
+
+// objectAuditSink appends audit entries to a RADOS object.
+type objectAuditSink struct {
+    c    *Context
+    name string
+}
+
+// NewObjectAuditSink returns an audit sink that appends each entry to the
+// named object in the pool referenced by the given context as a line of
+// JSON. The appends themselves are not audited.
+func NewObjectAuditSink(c *Context, name string) AuditSink {
+    return &objectAuditSink{c: c, name: name}
+}
+
+func (s *objectAuditSink) Audit(entry AuditEntry) error {
+    line, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+
+    return s.c.appendObject(s.name, append(line, '\n'))
+}
+
+// webhookAuditSink posts audit entries to a URL.
+type webhookAuditSink struct {
+    url    string
+    client *http.Client
+}
+
+// NewWebhookAuditSink returns an audit sink that POSTs each entry as JSON
+// to url, and fails unless it gets a 2xx response within timeout.
+func NewWebhookAuditSink(url string, timeout time.Duration) AuditSink {
+    return &webhookAuditSink{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *webhookAuditSink) Audit(entry AuditEntry) error {
+    body, err := json.Marshal(entry)
+    if err != nil {
+        return err
+    }
+
+    resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return err
+    }
+    io.Copy(io.Discard, resp.Body)
+    resp.Body.Close()
+
+    if resp.StatusCode/100 != 2 {
+        return fmt.Errorf("RADOS audit webhook %s: %s", s.url, resp.Status)
+    }
+
+    return nil
+}
@@ -0,0 +1,95 @@
+package rados
+
+import (
+    "strconv"
+)
+
+// AutoscaleMode is the mode of the placement group autoscaler for a pool.
+type AutoscaleMode string
+
+// Autoscaler modes.
+const (
+    AutoscaleOn   AutoscaleMode = "on"   // Adjust the number of PGs
+    AutoscaleOff  AutoscaleMode = "off"  // Leave the number of PGs alone
+    AutoscaleWarn AutoscaleMode = "warn" // Raise a health warning instead
+)
+
+// AutoscaleStatus is the placement group autoscaler status of a pool, as
+// reported by `ceph osd pool autoscale-status`.
+type AutoscaleStatus struct {
+    Pool                 string        `json:"pool_name"`
+    Mode                 AutoscaleMode `json:"pg_autoscale_mode"`
+    LogicalUsed          uint64        `json:"logical_used"`
+    RawUsedRate          float64       `json:"raw_used_rate"`
+    TargetBytes          uint64        `json:"target_bytes"`
+    TargetRatio          float64       `json:"target_ratio"`
+    EffectiveTargetRatio float64       `json:"effective_target_ratio"`
+    CapacityRatio        float64       `json:"capacity_ratio"`
+    Bias                 float64       `json:"bias"`
+    PGNum                int           `json:"pg_num_target"`
+    PGNumIdeal           int           `json:"pg_num_ideal"`
+    PGNumFinal           int           `json:"pg_num_final"`
+    WouldAdjust          bool          `json:"would_adjust"`
+    Bulk                 bool          `json:"bulk"`
+}
+
+// AutoscaleMode returns the placement group autoscaler mode of the named
+// pool.
+func (r *Rados) AutoscaleMode(pool string) (AutoscaleMode, error) {
+    var mode AutoscaleMode
+    err := r.poolGet(pool, "pg_autoscale_mode", &mode)
+
+    return mode, err
+}
+
+// SetAutoscaleMode sets the placement group autoscaler mode of the named
+// pool.
+func (r *Rados) SetAutoscaleMode(pool string, mode AutoscaleMode) error {
+    return r.poolSet(pool, "pg_autoscale_mode", string(mode))
+}
+
+// TargetSizeRatio returns the share of the cluster capacity the named pool
+// is expected to use, which the autoscaler uses to size the pool before it
+// fills up.
+func (r *Rados) TargetSizeRatio(pool string) (float64, error) {
+    var ratio float64
+    err := r.poolGet(pool, "target_size_ratio", &ratio)
+
+    return ratio, err
+}
+
+// SetTargetSizeRatio sets the share of the cluster capacity the named pool
+// is expected to use. A ratio of 0 clears it.
+func (r *Rados) SetTargetSizeRatio(pool string, ratio float64) error {
+    return r.poolSet(pool, "target_size_ratio", strconv.FormatFloat(ratio, 'g', -1, 64))
+}
+
+// PGNum returns the number of placement groups of the named pool.
+func (r *Rados) PGNum(pool string) (int, error) {
+    var n int
+    err := r.poolGet(pool, "pg_num", &n)
+
+    return n, err
+}
+
+// SetPGNum sets the number of placement groups of the named pool. The
+// cluster moves to the new number gradually; with the autoscaler on, it
+// may change the number again.
+func (r *Rados) SetPGNum(pool string, n int) error {
+    return r.poolSet(pool, "pg_num", strconv.Itoa(n))
+}
+
+// AutoscaleStatus returns the placement group autoscaler status of all
+// the pools, as computed by the pg_autoscaler manager module.
+func (r *Rados) AutoscaleStatus() ([]AutoscaleStatus, error) {
+    var status []AutoscaleStatus
+
+    err := r.mgrCommandJSON(map[string]interface{}{
+        "prefix": "osd pool autoscale-status",
+    }, &status)
+    if err != nil {
+        return nil, err
+    }
+
+    return status, nil
+}
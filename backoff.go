@@ -0,0 +1,170 @@
+package rados
+
+import (
+    "errors"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// Default settings of backoffs.
+const (
+    defaultBackoffMin     = 10 * time.Millisecond
+    defaultBackoffMax     = 5 * time.Second
+    defaultBackoffRetries = 5
+)
+
+// BackoffEvent reports a change of the delay applied by a backoff.
+type BackoffEvent struct {
+    Delay   time.Duration // New delay before each operation
+    Latency time.Duration // Latency of the operation that changed the delay
+    Err     error         // Error of the operation, if it failed
+    Slow    bool          // Whether the operation was slower than SlowOp
+}
+
+// BackoffOptions configure a backoff.
+type BackoffOptions struct {
+    // Min and Max bound the delay applied while the cluster is struggling
+    // (10 milliseconds and 5 seconds if 0).
+    Min time.Duration
+    Max time.Duration
+
+    // SlowOp is the latency above which an operation is considered slow,
+    // and counts as a sign of overload. 0 only takes errors into account.
+    SlowOp time.Duration
+
+    // MaxRetries is the number of times an operation that failed because
+    // the cluster is busy is retried (5 if 0, none if negative).
+    MaxRetries int
+
+    // OnEvent, if not nil, is called whenever the delay changes.
+    OnEvent func(event BackoffEvent)
+}
+
+// Backoff slows a bulk job down while the cluster is overloaded, so the
+// job eases off a struggling cluster instead of making its overload
+// worse. It doubles the delay it applies before each operation (see
+// Wait()) every time an operation fails with EAGAIN or EBUSY or is slow,
+// and halves it every time one succeeds quickly, down to no delay at all.
+// A Backoff is safe for concurrent use, so it can be shared by the workers
+// of a job, or by several jobs.
+type Backoff struct {
+    opts BackoffOptions
+
+    mutex sync.Mutex
+    delay time.Duration
+}
+
+// NewBackoff returns a backoff with the given options.
+func NewBackoff(opts BackoffOptions) *Backoff {
+    if opts.Min <= 0 {
+        opts.Min = defaultBackoffMin
+    }
+    if opts.Max <= 0 {
+        opts.Max = defaultBackoffMax
+    }
+    if opts.MaxRetries == 0 {
+        opts.MaxRetries = defaultBackoffRetries
+    }
+
+    return &Backoff{opts: opts}
+}
+
+// IsBusy reports whether err means the cluster is too busy to serve the
+// operation right now (EAGAIN or EBUSY), so it may succeed later.
+func IsBusy(err error) bool {
+    return errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY)
+}
+
+// Delay returns the current delay of the backoff.
+func (b *Backoff) Delay() time.Duration {
+    if b == nil {
+        return 0
+    }
+
+    b.mutex.Lock()
+    defer b.mutex.Unlock()
+
+    return b.delay
+}
+
+// Wait sleeps for the current delay of the backoff, if any. It should be
+// called before starting each operation. A nil backoff never waits.
+func (b *Backoff) Wait() {
+    if delay := b.Delay(); delay > 0 {
+        time.Sleep(delay)
+    }
+}
+
+// Observe adjusts the delay of the backoff to the outcome of an operation
+// that took latency and returned err.
+func (b *Backoff) Observe(latency time.Duration, err error) {
+    if b == nil {
+        return
+    }
+
+    slow := b.opts.SlowOp > 0 && latency > b.opts.SlowOp
+
+    b.mutex.Lock()
+    delay := b.delay
+    switch {
+    case IsBusy(err) || slow:
+        delay *= 2
+        if delay < b.opts.Min {
+            delay = b.opts.Min
+        }
+        if delay > b.opts.Max {
+            delay = b.opts.Max
+        }
+    case err == nil:
+        delay /= 2
+        if delay < b.opts.Min {
+            delay = 0
+        }
+    }
+    changed := delay != b.delay
+    b.delay = delay
+    b.mutex.Unlock()
+
+    if changed && b.opts.OnEvent != nil {
+        b.opts.OnEvent(BackoffEvent{Delay: delay, Latency: latency, Err: err, Slow: slow})
+    }
+}
+
+// SetBackoff makes the bulk operations of the given context (GetMany(),
+// PutMany(), RemoveAll(), VerifyPool(), AnalyzePool(), PurgeExpiredTrash()
+// and the delete jobs without a backoff of their own) slow down while the
+// cluster is overloaded, and retry the operations that failed because it
+// was busy. A nil backoff, the default, disables it.
+func (c *Context) SetBackoff(b *Backoff) {
+    c.backoff = b
+}
+
+// run is a utility function that runs fn, one operation of a bulk job,
+// under the given backoff: it waits for the delay of the backoff first,
+// adjusts the delay to the latency and error of fn, and runs fn again
+// while the cluster is busy and the retries are not exhausted.
+func (b *Backoff) run(fn func() error) error {
+    for attempts := 1; ; attempts++ {
+        b.Wait()
+
+        start := time.Now()
+        err := fn()
+        b.Observe(time.Since(start), err)
+
+        if !b.Retry(attempts, err) {
+            return err
+        }
+    }
+}
+
+// Retry reports whether an operation that failed with err after the given
+// number of attempts should be retried, i.e., whether the cluster was busy
+// and the retries are not exhausted. A nil backoff never retries.
+func (b *Backoff) Retry(attempts int, err error) bool {
+    if b == nil {
+        return false
+    }
+
+    return IsBusy(err) && attempts <= b.opts.MaxRetries
+}
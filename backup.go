@@ -0,0 +1,236 @@
+package rados
+
+import (
+    "errors"
+    "strings"
+    "time"
+)
+
+const (
+    // backupLock is the name of the lock held by the active backup
+    // scheduler of a pool.
+    backupLock = "rados.go.backup"
+
+    // backupTimeFormat is the format of the times in the names of backup
+    // snapshots.
+    backupTimeFormat = "20060102T150405Z"
+)
+
+// BackupOptions configure a backup scheduler (see ScheduleBackups()).
+type BackupOptions struct {
+    // Interval between two snapshots.
+    Interval time.Duration
+
+    // Prefix of the names of the snapshots taken by the scheduler, which
+    // are followed by the UTC time of the snapshot. Only the snapshots
+    // with this prefix are pruned. Defaults to "backup-".
+    Prefix string
+
+    // Retention policy: the KeepLast most recent snapshots are kept, as
+    // well as the most recent snapshot of each of the last KeepHourly
+    // hours and KeepDaily days (in UTC) that have one. The most recent
+    // snapshot is always kept.
+    KeepLast   int
+    KeepHourly int
+    KeepDaily  int
+
+    // LockObject is the object locked by the active scheduler, so that
+    // only one of the schedulers of a pool (e.g., one per replica of a
+    // service) takes snapshots at a time. Defaults to "rados.go.backup".
+    LockObject string
+
+    // OnSnapshot, if not nil, is called after each snapshot taken.
+    OnSnapshot func(snap PoolSnap)
+
+    // OnPrune, if not nil, is called after each snapshot removed.
+    OnPrune func(snap PoolSnap)
+
+    // OnError, if not nil, is called when taking or pruning snapshots
+    // fails.
+    OnError func(err error)
+}
+
+// BackupScheduler takes periodic snapshots of a pool in the background and
+// prunes the old ones (see ScheduleBackups()). A scheduler must be stopped
+// with Close() when it is no longer needed.
+type BackupScheduler struct {
+    c      *Context
+    opts   BackupOptions
+    cookie string
+    loop   *loop
+}
+
+// ScheduleBackups starts taking a snapshot of the pool referenced by the
+// given context every opts.Interval, and removing the snapshots that fall
+// out of the retention policy of opts, for automated point-in-time
+// protection of the pool. Pool snapshots cannot be taken of pools using
+// self-managed snapshots (e.g., RBD pools).
+//
+// Several schedulers may run for the same pool: the active one holds an
+// exclusive lock on opts.LockObject, which it renews at each interval, and
+// the others take over when it stops renewing it for two intervals. A
+// scheduler doesn't take a snapshot if the most recent one is less than
+// half an interval old, so a takeover doesn't take extra snapshots, while
+// a tick that comes a little early doesn't skip one. Callbacks are
+// called from the goroutine of the scheduler, one at a time.
+func (c *Context) ScheduleBackups(opts BackupOptions) (*BackupScheduler, error) {
+    l, err := newLoop("schedule backups", opts.Interval)
+    if err != nil {
+        return nil, err
+    }
+    if opts.Prefix == "" {
+        opts.Prefix = "backup-"
+    }
+    if opts.LockObject == "" {
+        opts.LockObject = backupLock
+    }
+
+    clone, err := c.Clone()
+    if err != nil {
+        return nil, err
+    }
+
+    s := &BackupScheduler{
+        c:      clone,
+        opts:   opts,
+        cookie: newLockCookie(),
+        loop:   l,
+    }
+
+    l.start(s.run)
+
+    return s, nil
+}
+
+// run is a utility function that runs a backup, reporting errors to the
+// callback of the scheduler.
+func (s *BackupScheduler) run() {
+    if err := s.backup(); err != nil && s.opts.OnError != nil {
+        s.opts.OnError(err)
+    }
+}
+
+// backup is a utility function that takes a snapshot if one is due and
+// prunes the old snapshots, if the scheduler holds the lock.
+func (s *BackupScheduler) backup() error {
+    err := s.c.LockExclusive(s.opts.LockObject, backupLock, &LockOptions{
+        Cookie:      s.cookie,
+        Description: "backup scheduler",
+        Duration:    2 * s.opts.Interval,
+        Flags:       LockMayRenew,
+    })
+    if errors.Is(err, ErrLocked) {
+        // Another scheduler is active
+        return nil
+    } else if err != nil {
+        return err
+    }
+
+    snaps, err := s.backups()
+    if err != nil {
+        return err
+    }
+
+    now := time.Now().UTC()
+
+    // Snapshot times have a one-second resolution, and ticks are not
+    // exactly one interval apart, so snapshots are due a bit early.
+    due := s.opts.Interval - s.opts.Interval/2
+
+    if len(snaps) == 0 || now.Sub(snaps[len(snaps)-1].Time) >= due {
+        name := s.opts.Prefix + now.Format(backupTimeFormat)
+        if err = s.c.CreateSnap(name); err != nil {
+            return err
+        }
+
+        if snaps, err = s.backups(); err != nil {
+            return err
+        }
+
+        if s.opts.OnSnapshot != nil {
+            for _, snap := range snaps {
+                if snap.Name == name {
+                    s.opts.OnSnapshot(snap)
+                }
+            }
+        }
+    }
+
+    for _, snap := range pruneSnaps(snaps, s.opts) {
+        if err = s.c.RemoveSnap(snap.Name); err != nil {
+            return err
+        }
+
+        if s.opts.OnPrune != nil {
+            s.opts.OnPrune(snap)
+        }
+    }
+
+    return nil
+}
+
+// backups is a utility function that returns the snapshots taken by the
+// scheduler, oldest first.
+func (s *BackupScheduler) backups() ([]PoolSnap, error) {
+    snaps, err := s.c.Snaps()
+    if err != nil {
+        return nil, err
+    }
+
+    var backups []PoolSnap
+    for _, snap := range snaps {
+        if strings.HasPrefix(snap.Name, s.opts.Prefix) {
+            backups = append(backups, snap)
+        }
+    }
+
+    return backups, nil
+}
+
+// pruneSnaps is a utility function that returns the snapshots of snaps,
+// sorted oldest first, that fall out of the retention policy of opts.
+func pruneSnaps(snaps []PoolSnap, opts BackupOptions) []PoolSnap {
+    keep := make(map[uint64]bool)
+    hours := make(map[time.Time]bool)
+    days := make(map[time.Time]bool)
+
+    for i := len(snaps) - 1; i >= 0; i-- {
+        snap := snaps[i]
+        t := snap.Time.UTC()
+
+        if i == len(snaps)-1 || len(snaps)-1-i < opts.KeepLast {
+            keep[snap.ID] = true
+        }
+
+        if hour := t.Truncate(time.Hour); !hours[hour] && len(hours) < opts.KeepHourly {
+            hours[hour] = true
+            keep[snap.ID] = true
+        }
+
+        if day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC); !days[day] && len(days) < opts.KeepDaily {
+            days[day] = true
+            keep[snap.ID] = true
+        }
+    }
+
+    var prune []PoolSnap
+    for _, snap := range snaps {
+        if !keep[snap.ID] {
+            prune = append(prune, snap)
+        }
+    }
+
+    return prune
+}
+
+// Close stops the scheduler, waiting for a running backup to finish, and
+// releases its lock so another scheduler can take over right away.
+// Closing it again does nothing.
+func (s *BackupScheduler) Close() error {
+    return s.loop.close(func() error {
+        // The lock is not held if another scheduler is active
+        s.c.Unlock(s.opts.LockObject, backupLock, s.cookie)
+
+        return s.c.Release()
+    })
+}
@@ -0,0 +1,169 @@
+package rados
+
+import (
+    "errors"
+    "sync"
+)
+
+// ErrSkipped is reported by PutMany for objects that were not written
+// because an earlier write failed.
+var ErrSkipped = errors.New("RADOS skipped after earlier failure")
+
+// GetMany reads all the data in the named objects in the pool referenced
+// by the given context, keeping up to concurrency reads in flight at a
+// time. The data of each object that was read successfully is returned in
+// the first map, and the error for each object that could not be read is
+// returned in the second map.
+func (c *Context) GetMany(names []string, concurrency int) (map[string][]byte, map[string]error) {
+    type result struct {
+        name string
+        data []byte
+        err  error
+    }
+
+    results := make(chan result)
+
+    forEach(names, concurrency, func(name string) {
+        var data []byte
+        err := c.backoff.run(func() (err error) {
+            data, err = c.aioGet(name)
+            return err
+        })
+        results <- result{name, data, err}
+    }, func() {
+        close(results)
+    })
+
+    data := make(map[string][]byte)
+    errs := make(map[string]error)
+    for r := range results {
+        if r.err != nil {
+            errs[r.name] = r.err
+        } else {
+            data[r.name] = r.data
+        }
+    }
+
+    return data, errs
+}
+
+// PutMany writes the given data to the named objects in the pool
+// referenced by the given context like Put(), keeping up to concurrency
+// writes in flight at a time. The error for each object that could not be
+// written is returned in the map, which is empty if all writes succeeded.
+//
+// If stopOnError is true, no new writes are started once a write has
+// failed, and the objects that were not written are reported with
+// ErrSkipped. Otherwise every object is attempted.
+func (c *Context) PutMany(objects map[string][]byte, concurrency int, stopOnError bool) map[string]error {
+    names := make([]string, 0, len(objects))
+    for name := range objects {
+        names = append(names, name)
+    }
+
+    var mutex sync.Mutex
+    var failed bool
+    errs := make(map[string]error)
+    finished := make(chan bool)
+
+    forEach(names, concurrency, func(name string) {
+        mutex.Lock()
+        skip := stopOnError && failed
+        mutex.Unlock()
+
+        err := ErrSkipped
+        if !skip {
+            err = c.backoff.run(func() error {
+                return c.aioPut(name, objects[name])
+            })
+        }
+
+        if err != nil {
+            mutex.Lock()
+            errs[name] = err
+            failed = true
+            mutex.Unlock()
+        }
+    }, func() {
+        close(finished)
+    })
+
+    <-finished
+
+    return errs
+}
+
+// aioPut is a utility function that writes data to the named object
+// using an asynchronous operation.
+func (c *Context) aioPut(name string, data []byte) error {
+    write, err := c.AioWriteFull(name, data)
+    if err != nil {
+        return err
+    }
+    defer write.Release()
+
+    return write.Wait()
+}
+
+// aioGet is a utility function that reads all the data in the named object
+// using asynchronous operations.
+func (c *Context) aioGet(name string) ([]byte, error) {
+    stat, err := c.AioStat(name)
+    if err != nil {
+        return nil, err
+    }
+    defer stat.Release()
+
+    if err = stat.Wait(); err != nil {
+        return nil, err
+    }
+
+    if stat.Size() == 0 {
+        // Return an empty slice
+        return make([]byte, 0), nil
+    }
+
+    read, err := c.AioRead(name, int(stat.Size()), 0)
+    if err != nil {
+        return nil, err
+    }
+    defer read.Release()
+
+    if err = read.Wait(); err != nil {
+        return nil, err
+    }
+
+    return read.Data(), nil
+}
+
+// forEach is a utility function that calls fn for each of the given names
+// from concurrency goroutines, and calls done once all the calls have
+// returned. forEach does not wait for the calls itself.
+func forEach(names []string, concurrency int, fn func(name string), done func()) {
+    if concurrency < 1 {
+        concurrency = 1
+    }
+
+    work := make(chan string)
+    var wg sync.WaitGroup
+
+    for i := 0; i < concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for name := range work {
+                fn(name)
+            }
+        }()
+    }
+
+    go func() {
+        for _, name := range names {
+            work <- name
+        }
+        close(work)
+
+        wg.Wait()
+        done()
+    }()
+}
@@ -0,0 +1,94 @@
+package rados
+
+import (
+    "strings"
+    "time"
+)
+
+// BlocklistEntry is a client address blocklisted by the cluster. The OSDs
+// refuse all operations from blocklisted clients.
+type BlocklistEntry struct {
+    Addr  string
+    Until time.Time
+}
+
+// Blocklist returns the client addresses currently blocklisted by the
+// cluster.
+func (r *Rados) Blocklist() ([]BlocklistEntry, error) {
+    var entries []struct {
+        Addr  string `json:"addr"`
+        Until string `json:"until"`
+    }
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd blocklist ls",
+    }, &entries)
+    if err != nil {
+        return nil, err
+    }
+
+    blocklist := make([]BlocklistEntry, len(entries))
+    for i, entry := range entries {
+        blocklist[i].Addr = entry.Addr
+        blocklist[i].Until, _ = time.Parse("2006-01-02T15:04:05.999999-0700", entry.Until)
+    }
+
+    return blocklist, nil
+}
+
+// BlocklistAdd blocklists the client address addr (as returned by
+// ClientAddrs()) for the given duration, or for the default duration of
+// the cluster (one hour) if expire is 0. Once the OSDs have the new OSD
+// map, they refuse all operations from the client, which fences it off
+// (see Fence()).
+func (r *Rados) BlocklistAdd(addr string, expire time.Duration) error {
+    cmd := map[string]interface{}{
+        "prefix":      "osd blocklist",
+        "blocklistop": "add",
+        "addr":        trimAddrType(addr),
+    }
+    if expire > 0 {
+        cmd["expire"] = expire.Seconds()
+    }
+
+    return r.monCommandJSON(cmd, nil)
+}
+
+// BlocklistRemove removes the client address addr from the blocklist.
+func (r *Rados) BlocklistRemove(addr string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix":      "osd blocklist",
+        "blocklistop": "rm",
+        "addr":        trimAddrType(addr),
+    }, nil)
+}
+
+// blocklisted is a utility function that reports whether the client
+// address addr is covered by the given blocklist entries. Entries with a
+// nonce of 0 cover all the clients at that IP address and port.
+func blocklisted(blocklist []BlocklistEntry, addr string) bool {
+    addr = trimAddrType(addr)
+    ip, _, _ := strings.Cut(addr, "/")
+
+    for _, entry := range blocklist {
+        blocked := trimAddrType(entry.Addr)
+
+        if blocked == addr || blocked == ip+"/0" {
+            return true
+        }
+    }
+
+    return false
+}
+
+// trimAddrType is a utility function that strips the protocol type
+// (e.g., "v1:" or "any:") from a client address.
+func trimAddrType(addr string) string {
+    for _, prefix := range []string{"v1:", "v2:", "any:"} {
+        if strings.HasPrefix(addr, prefix) {
+            return addr[len(prefix):]
+        }
+    }
+
+    return addr
+}
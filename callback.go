@@ -0,0 +1,261 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdint.h"
+#include "rados/librados.h"
+
+extern void goAioCallback(rados_completion_t, void *);
+
+// callbackArg turns the ID of a completion into the argument of its
+// callback, so a late callback never dereferences freed memory.
+static inline void *callbackArg(uintptr_t id) {
+    return (void *)id;
+}
+*/
+import "C"
+
+import (
+    "fmt"
+    "runtime"
+    "sync"
+    "time"
+    "unsafe"
+)
+
+// CallbackOverflow selects what happens when an asynchronous operation
+// completes while the queue of the callback pool of the cluster handle is
+// full (see Rados.SetCallbackPool()).
+type CallbackOverflow int
+
+const (
+    // CallbackBlock makes the librados thread reporting the completion
+    // wait for room in the queue, which slows librados down until the
+    // callbacks catch up.
+    CallbackBlock CallbackOverflow = iota
+
+    // CallbackSpawn runs the callback on a new goroutine, outside of the
+    // pool.
+    CallbackSpawn
+)
+
+// Default settings of callback pools.
+const (
+    defaultCallbackQueue = 256
+)
+
+// callbackPool runs the callbacks of asynchronous operations (see
+// Completion.OnComplete()) on a bounded set of goroutines, so user code
+// never runs on librados threads.
+type callbackPool struct {
+    queue    chan func()
+    overflow CallbackOverflow
+    done     chan struct{}
+}
+
+// callbacks holds the callback pool of a cluster handle, which is started
+// on first use.
+type callbacks struct {
+    mutex    sync.Mutex
+    pool     *callbackPool
+    workers  int
+    queue    int
+    overflow CallbackOverflow
+    closed   bool
+}
+
+var (
+    completionsMutex sync.Mutex
+    completions      = make(map[uintptr]*Completion)
+    nextCompletionID uintptr
+)
+
+// SetCallbackPool configures the pool of goroutines the callbacks of the
+// asynchronous operations started through the contexts of the given
+// cluster handle run on (see Completion.OnComplete()): workers goroutines
+// take the callbacks from a queue of the given length, and overflow
+// selects what happens when the queue is full. By default, there are as
+// many workers as GOMAXPROCS, the queue holds 256 callbacks and
+// CallbackBlock is used. SetCallbackPool fails once a callback has been
+// registered.
+func (r *Rados) SetCallbackPool(workers, queue int, overflow CallbackOverflow) error {
+    if workers <= 0 || queue < 0 {
+        return fmt.Errorf("RADOS set callback pool: invalid size %d/%d", workers, queue)
+    }
+
+    cb := r.callbacks
+    cb.mutex.Lock()
+    defer cb.mutex.Unlock()
+
+    if cb.pool != nil {
+        return fmt.Errorf("RADOS set callback pool: pool already started")
+    }
+
+    cb.workers, cb.queue, cb.overflow = workers, queue, overflow
+
+    return nil
+}
+
+// callbackPool is a utility function that returns the callback pool of the
+// given cluster handle, starting it if needed. It returns nil once the
+// handle has been released.
+func (r *Rados) callbackPool() *callbackPool {
+    cb := r.callbacks
+    cb.mutex.Lock()
+    defer cb.mutex.Unlock()
+
+    if cb.pool == nil && !cb.closed {
+        workers, queue := cb.workers, cb.queue
+        if workers == 0 {
+            workers, queue = runtime.GOMAXPROCS(0), defaultCallbackQueue
+        }
+
+        cb.pool = &callbackPool{
+            queue:    make(chan func(), queue),
+            overflow: cb.overflow,
+            done:     make(chan struct{}),
+        }
+
+        for i := 0; i < workers; i++ {
+            go cb.pool.run()
+        }
+    }
+
+    return cb.pool
+}
+
+// close is a utility function that stops the callback pool, if it was
+// started. Callbacks still queued are not run.
+func (cb *callbacks) close() {
+    cb.mutex.Lock()
+    defer cb.mutex.Unlock()
+
+    if cb.pool != nil {
+        close(cb.pool.done)
+    }
+    cb.closed = true
+}
+
+// run is a utility function that runs the callbacks of the queue until the
+// pool is stopped.
+func (p *callbackPool) run() {
+    for {
+        select {
+        case fn := <-p.queue:
+            fn()
+        case <-p.done:
+            return
+        }
+    }
+}
+
+// dispatch is a utility function that queues fn to run on the pool,
+// applying the overflow policy of the pool if the queue is full.
+func (p *callbackPool) dispatch(fn func()) {
+    select {
+    case p.queue <- fn:
+        return
+    case <-p.done:
+        return
+    default:
+    }
+
+    if p.overflow == CallbackSpawn {
+        go fn()
+        return
+    }
+
+    select {
+    case p.queue <- fn:
+    case <-p.done:
+    }
+}
+
+// register is a utility function that adds the completion to the registry
+// of completions, and returns the argument of its callback.
+func (cp *Completion) register() unsafe.Pointer {
+    completionsMutex.Lock()
+    defer completionsMutex.Unlock()
+
+    nextCompletionID++
+    cp.id = nextCompletionID
+    completions[cp.id] = cp
+
+    return C.callbackArg(C.uintptr_t(cp.id))
+}
+
+// unregister is a utility function that removes the completion from the
+// registry of completions.
+func (cp *Completion) unregister() {
+    completionsMutex.Lock()
+    defer completionsMutex.Unlock()
+
+    delete(completions, cp.id)
+}
+
+//export goAioCallback
+func goAioCallback(comp C.rados_completion_t, arg unsafe.Pointer) {
+    completionsMutex.Lock()
+    cp := completions[uintptr(arg)]
+    completionsMutex.Unlock()
+
+    if cp == nil {
+        return
+    }
+
+    cp.mutex.Lock()
+    cp.complete = true
+    cp.completed = time.Now()
+    fn := cp.onComplete
+    cp.mutex.Unlock()
+
+    if fn != nil {
+        cp.dispatch(fn)
+    }
+}
+
+// latency is a utility function that returns how long the asynchronous
+// operation took, up to its completion as reported to its callback, so
+// that the time spent before Wait() was called doesn't count.
+func (cp *Completion) latency() time.Duration {
+    cp.mutex.Lock()
+    defer cp.mutex.Unlock()
+
+    // librados may report the completion to Wait() before the callback
+    if cp.completed.IsZero() {
+        return time.Since(cp.start)
+    }
+
+    return cp.completed.Sub(cp.start)
+}
+
+// OnComplete registers fn to be called once the asynchronous operation
+// has completed, or right away if it already has. fn runs on the callback
+// pool of the cluster handle (see Rados.SetCallbackPool()), not on a
+// librados thread, so it may block, e.g., to call Wait() (which returns
+// immediately) and Release(), or to start other operations, but a slow
+// callback delays the others queued behind it. Only one callback can be
+// registered per completion, and the completion must not be released
+// before it is called, except by the callback itself.
+func (cp *Completion) OnComplete(fn func(cp *Completion)) {
+    cp.mutex.Lock()
+    complete := cp.complete
+    cp.onComplete = fn
+    cp.mutex.Unlock()
+
+    if complete {
+        cp.dispatch(fn)
+    }
+}
+
+// dispatch is a utility function that queues fn on the callback pool.
+func (cp *Completion) dispatch(fn func(cp *Completion)) {
+    pool := cp.c.rados.callbackPool()
+    if pool == nil {
+        return
+    }
+
+    pool.dispatch(func() {
+        fn(cp)
+    })
+}
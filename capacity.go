@@ -0,0 +1,157 @@
+package rados
+
+import (
+    "sort"
+    "time"
+)
+
+// CapacityAlert reports that the used share of the capacity of the
+// cluster, or of a pool, crossed a threshold (see Rados.MonitorCapacity()).
+type CapacityAlert struct {
+    Pool      string  // Pool, or the empty string for the whole cluster
+    Threshold float64 // Threshold crossed
+    Used      float64 // Share of the capacity used, between 0 and 1
+    Rising    bool    // Whether usage went above (or back below) the threshold
+}
+
+// CapacityMonitorOptions configure a capacity monitor.
+type CapacityMonitorOptions struct {
+    // Interval between two refreshes of the statistics.
+    Interval time.Duration
+
+    // Thresholds, as shares of the capacity between 0 and 1 (e.g., 0.75
+    // and 0.9).
+    Thresholds []float64
+
+    // Pools to monitor in addition to the whole cluster. The share used
+    // by a pool is relative to the space it could use (its usage plus the
+    // space still available to it), as reported by `ceph df`.
+    Pools []string
+
+    // OnAlert is called for each threshold crossed, in either direction.
+    OnAlert func(alert CapacityAlert)
+
+    // OnError, if not nil, is called when refreshing the statistics fails.
+    OnError func(err error)
+}
+
+// CapacityMonitor watches the capacity used by a cluster and its pools in
+// the background (see Rados.MonitorCapacity()). A monitor must be stopped
+// with Close() when it is no longer needed.
+type CapacityMonitor struct {
+    r     *Rados
+    opts  CapacityMonitorOptions
+    level map[string]int // Number of thresholds reached, by pool
+    loop  *loop
+}
+
+// MonitorCapacity starts refreshing the statistics of the cluster and the
+// given pools every opts.Interval, calling opts.OnAlert whenever the used
+// share of their capacity crosses one of opts.Thresholds, so services can
+// warn (or stop accepting data) before a pool or the cluster fills up.
+// Usage already above thresholds when the monitor starts is reported as
+// rising alerts on the first refresh. Callbacks are called from the
+// goroutine of the monitor, one at a time.
+func (r *Rados) MonitorCapacity(opts CapacityMonitorOptions) (*CapacityMonitor, error) {
+    l, err := newLoop("monitor capacity", opts.Interval)
+    if err != nil {
+        return nil, err
+    }
+
+    thresholds := append([]float64(nil), opts.Thresholds...)
+    sort.Float64s(thresholds)
+    opts.Thresholds = thresholds
+
+    m := &CapacityMonitor{
+        r:     r,
+        opts:  opts,
+        level: make(map[string]int),
+        loop:  l,
+    }
+
+    l.start(m.refresh)
+
+    return m, nil
+}
+
+// refresh is a utility function that refreshes the statistics and calls
+// the callbacks of the monitor for the thresholds crossed since the last
+// refresh.
+func (m *CapacityMonitor) refresh() {
+    usage, err := m.r.capacityUsage()
+    if err != nil {
+        if m.opts.OnError != nil {
+            m.opts.OnError(err)
+        }
+        return
+    }
+
+    m.check("", usage[""])
+    for _, pool := range m.opts.Pools {
+        if used, ok := usage[pool]; ok {
+            m.check(pool, used)
+        }
+    }
+}
+
+// check is a utility function that compares the used share of the
+// capacity of pool with the thresholds, and calls OnAlert for each
+// threshold crossed.
+func (m *CapacityMonitor) check(pool string, used float64) {
+    level := 0
+    for level < len(m.opts.Thresholds) && used >= m.opts.Thresholds[level] {
+        level++
+    }
+
+    last := m.level[pool]
+    m.level[pool] = level
+
+    if m.opts.OnAlert == nil {
+        return
+    }
+
+    for i := last; i < level; i++ {
+        m.opts.OnAlert(CapacityAlert{Pool: pool, Threshold: m.opts.Thresholds[i], Used: used, Rising: true})
+    }
+    for i := last - 1; i >= level; i-- {
+        m.opts.OnAlert(CapacityAlert{Pool: pool, Threshold: m.opts.Thresholds[i], Used: used, Rising: false})
+    }
+}
+
+// Close stops the monitor, waiting for a running refresh to finish.
+// Closing it again does nothing.
+func (m *CapacityMonitor) Close() error {
+    return m.loop.close(nil)
+}
+
+// capacityUsage is a utility function that returns the used share of the
+// capacity of the cluster (under the empty string) and of each pool, as
+// reported by `ceph df`.
+func (r *Rados) capacityUsage() (map[string]float64, error) {
+    var df struct {
+        Stats struct {
+            UsedRatio float64 `json:"total_used_raw_ratio"`
+        } `json:"stats"`
+        Pools []struct {
+            Name  string `json:"name"`
+            Stats struct {
+                PercentUsed float64 `json:"percent_used"`
+            } `json:"stats"`
+        } `json:"pools"`
+    }
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "df",
+    }, &df)
+    if err != nil {
+        return nil, err
+    }
+
+    usage := map[string]float64{"": df.Stats.UsedRatio}
+    for _, pool := range df.Pools {
+        // Despite its name, percent_used is a ratio
+        usage[pool.Name] = pool.Stats.PercentUsed
+    }
+
+    return usage, nil
+}
@@ -0,0 +1,197 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "encoding/binary"
+    "fmt"
+    "time"
+    "unsafe"
+)
+
+// Exec calls the method method of the object class class on the named
+// object in the pool referenced by the given context, passing it in, and
+// returns the output of the method. The input and output are encoded the
+// way the object class expects.
+//
+// A method whose output exceeds the initial buffer is called again with a
+// bigger one, so methods that modify the object and return large outputs
+// should not be called through Exec. Exec is not guarded by the seal of the
+// object (see Object.Seal()): methods that modify the object should be
+// called through a WriteOp (see WriteOp.Exec()).
+func (c *Context) Exec(name, class, method string, in []byte) (out []byte, err error) {
+    defer c.audit("exec", name, &err)
+
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    out, cerr := c.exec(name, class, method, in)
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS exec %s %s.%s: %w", name, class, method, radosErrno(cerr))
+    }
+
+    return out, nil
+}
+
+// exec is a utility function that calls an object class method like
+// Exec() and returns its output along with the raw librados result.
+func (c *Context) exec(name, class, method string, in []byte) ([]byte, C.int) {
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    cclass := C.CString(class)
+    defer C.free(unsafe.Pointer(cclass))
+    cmethod := C.CString(method)
+    defer C.free(unsafe.Pointer(cmethod))
+
+    cin, cinlen := byteSliceToBuffer(in)
+
+    var buf []byte
+    bufSize := 256 // Initial guess at amount of space we need
+
+    // rados_exec() fails with ERANGE if the output doesn't fit in our
+    // buffer, in which case we retry with a bigger one.
+    for {
+        buf = make([]byte, bufSize)
+        cdata, cdatalen := byteSliceToBuffer(buf)
+
+        start := time.Now()
+        cerr := c.call(opOther, "rados_exec", name, func() C.int {
+            return C.rados_exec(c.ctx, cname, cclass, cmethod, cin, cinlen, cdata, cdatalen)
+        })
+        c.record(opOther, name, start, cerr, 0)
+
+        if cerr == -C.ERANGE {
+            bufSize *= 2
+            continue
+        } else if cerr < 0 {
+            return nil, cerr
+        }
+
+        return buf[:cerr], 0
+    }
+}
+
+// Exec adds a call of the method method of the object class class, passing
+// it in, to the operation. The output of the method is discarded.
+func (op *WriteOp) Exec(class, method string, in []byte) {
+    cprval := op.newResult()
+
+    op.add(func(cop C.rados_write_op_t) {
+        cclass := C.CString(class)
+        defer C.free(unsafe.Pointer(cclass))
+        cmethod := C.CString(method)
+        defer C.free(unsafe.Pointer(cmethod))
+
+        cin, cinlen := byteSliceToBuffer(in)
+
+        C.rados_write_op_exec(cop, cclass, cmethod, cin, cinlen, cprval)
+    })
+}
+
+// Exec wraps the Context-based Exec function for the given object.
+func (o *Object) Exec(class, method string, in []byte) ([]byte, error) {
+    return o.c.Exec(o.name, class, method, in)
+}
+
+// appendUint32 is a utility function that appends v to buf in the RADOS
+// wire format (little-endian).
+func appendUint32(buf []byte, v uint32) []byte {
+    return binary.LittleEndian.AppendUint32(buf, v)
+}
+
+// appendUint64 is a utility function that appends v to buf in the RADOS
+// wire format (little-endian).
+func appendUint64(buf []byte, v uint64) []byte {
+    return binary.LittleEndian.AppendUint64(buf, v)
+}
+
+// appendStruct is a utility function that appends the encoded struct body
+// to buf, preceded by the header of versioned RADOS structures (version,
+// compatible version and length) for version 1 of the structure.
+func appendStruct(buf []byte, body []byte) []byte {
+    buf = append(buf, 1, 1)
+    return appendEncoded(buf, body)
+}
+
+// appendEncoded is a utility function that appends data to buf in the
+// RADOS wire format for strings (a little-endian 32-bit length followed by
+// the data), which is how object classes expect most of their input.
+func appendEncoded(buf []byte, data []byte) []byte {
+    var length [4]byte
+    binary.LittleEndian.PutUint32(length[:], uint32(len(data)))
+
+    buf = append(buf, length[:]...)
+    return append(buf, data...)
+}
+
+// decoder is a utility type that decodes data in the RADOS wire format.
+// Decoding errors are sticky: once the data runs out, all further values
+// decode as zero and ok() returns false.
+type decoder struct {
+    buf []byte
+    bad bool
+}
+
+// take is a utility function that consumes the next n bytes.
+func (d *decoder) take(n uint64) []byte {
+    if d.bad || uint64(len(d.buf)) < n {
+        d.bad = true
+        return nil
+    }
+
+    data := d.buf[:n]
+    d.buf = d.buf[n:]
+
+    return data
+}
+
+// uint8 decodes a byte.
+func (d *decoder) uint8() uint8 {
+    if data := d.take(1); data != nil {
+        return data[0]
+    }
+    return 0
+}
+
+// uint32 decodes a little-endian 32-bit integer.
+func (d *decoder) uint32() uint32 {
+    if data := d.take(4); data != nil {
+        return binary.LittleEndian.Uint32(data)
+    }
+    return 0
+}
+
+// uint64 decodes a little-endian 64-bit integer.
+func (d *decoder) uint64() uint64 {
+    if data := d.take(8); data != nil {
+        return binary.LittleEndian.Uint64(data)
+    }
+    return 0
+}
+
+// bytes decodes a string (a little-endian 32-bit length followed by the
+// data).
+func (d *decoder) bytes() []byte {
+    return d.take(uint64(d.uint32()))
+}
+
+// structBody decodes the header of a versioned RADOS structure and returns
+// a decoder for its body.
+func (d *decoder) structBody() *decoder {
+    d.take(2) // Version and compatible version
+    body := d.take(uint64(d.uint32()))
+
+    return &decoder{buf: body, bad: d.bad}
+}
+
+// ok returns true if all the values decoded so far were complete.
+func (d *decoder) ok() bool {
+    return !d.bad
+}
@@ -0,0 +1,193 @@
+package rados
+
+import (
+    "fmt"
+    "sync"
+)
+
+// sharedCluster tracks the users of a cluster handle in the registry of
+// shared handles. Each user gets its own copy of the handle r, which it
+// releases once.
+type sharedCluster struct {
+    r    *Rados
+    key  options
+    refs int
+}
+
+var (
+    clustersMutex sync.Mutex
+    clusters      = make(map[options]*sharedCluster)
+)
+
+// DefaultCluster returns a cluster handle shared by all the callers in the
+// process that pass the same options, so that several libraries talking to
+// the same cluster use a single connection instead of each opening their
+// own (which costs monitor sessions, OSD connections and memory). The
+// connection is created by the first call, and every call must be matched
+// by a call to Release() on the returned handle; the connection is closed
+// when the last user releases it. Each call returns a distinct handle on
+// the shared connection, so releasing a handle more than once doesn't
+// drop the references of the other users.
+//
+// Shared handles must not be reconfigured by their users, since the
+// changes would affect every other user.
+func DefaultCluster(opts ...Option) (*Rados, error) {
+    var o options
+    for _, opt := range opts {
+        opt(&o)
+    }
+
+    clustersMutex.Lock()
+    defer clustersMutex.Unlock()
+
+    if shared, ok := clusters[o]; ok {
+        shared.refs++
+        return shared.handle(), nil
+    }
+
+    r, err := newRados(o)
+    if err != nil {
+        return nil, err
+    }
+
+    r.shared = &sharedCluster{r: r, key: o, refs: 1}
+    clusters[o] = r.shared
+
+    return r.shared.handle(), nil
+}
+
+// handle is a utility function that returns a new handle on the shared
+// connection for a user.
+func (shared *sharedCluster) handle() *Rados {
+    r := *shared.r
+    return &r
+}
+
+// release drops a reference to the shared handle, and returns true if it
+// was the last one, in which case the handle is removed from the registry
+// and must be shut down.
+func (shared *sharedCluster) release() bool {
+    clustersMutex.Lock()
+    defer clustersMutex.Unlock()
+
+    if shared.refs--; shared.refs > 0 {
+        return false
+    }
+
+    if clusters[shared.key] == shared {
+        delete(clusters, shared.key)
+    }
+
+    return true
+}
+
+// ClusterConfig describes how to connect to a cluster registered under a
+// logical name (see RegisterCluster()).
+type ClusterConfig struct {
+    ConfigFile  string   // Configuration file, or "" for the default paths
+    User        string   // CephX user (e.g., "rgw" for client.rgw), or "" for admin
+    ClusterName string   // Name of the cluster, or "" for "ceph"
+    Options     []Option // Additional options
+}
+
+// NamedCluster refers to a cluster registered under a logical name (see
+// Cluster()).
+type NamedCluster struct {
+    name string
+}
+
+// registeredCluster is a cluster in the registry of named clusters, along
+// with its handle once connected.
+type registeredCluster struct {
+    config ClusterConfig
+    r      *Rados
+}
+
+var (
+    namedMutex    sync.Mutex
+    namedClusters = make(map[string]*registeredCluster)
+)
+
+// RegisterCluster registers the configuration of a cluster under the
+// given logical name (e.g., "primary" or "dr-site"), so that a process
+// talking to several clusters can address them by name (see Cluster()).
+// The cluster is connected to on first use.
+func RegisterCluster(name string, config ClusterConfig) error {
+    namedMutex.Lock()
+    defer namedMutex.Unlock()
+
+    if _, ok := namedClusters[name]; ok {
+        return fmt.Errorf("RADOS cluster %s already registered", name)
+    }
+
+    namedClusters[name] = &registeredCluster{config: config}
+
+    return nil
+}
+
+// UnregisterCluster removes the named cluster from the registry and
+// closes its connection, if any. The contexts created from it must have
+// been released.
+func UnregisterCluster(name string) error {
+    namedMutex.Lock()
+    rc, ok := namedClusters[name]
+    delete(namedClusters, name)
+    namedMutex.Unlock()
+
+    if !ok {
+        return fmt.Errorf("RADOS cluster %s not registered", name)
+    }
+
+    if rc.r != nil {
+        return rc.r.Release()
+    }
+
+    return nil
+}
+
+// Cluster returns a reference to the cluster registered under the given
+// logical name, e.g., Cluster("dr-site").NewContext(pool).
+func Cluster(name string) *NamedCluster {
+    return &NamedCluster{name: name}
+}
+
+// Rados returns the handle of the named cluster, connecting to it if this
+// is its first use. The handle is owned by the registry and must not be
+// released; see UnregisterCluster().
+func (nc *NamedCluster) Rados() (*Rados, error) {
+    namedMutex.Lock()
+    defer namedMutex.Unlock()
+
+    rc, ok := namedClusters[nc.name]
+    if !ok {
+        return nil, fmt.Errorf("RADOS cluster %s not registered", nc.name)
+    }
+
+    if rc.r == nil {
+        opts := []Option{
+            WithConfigFile(rc.config.ConfigFile),
+            WithUser(rc.config.User),
+            WithClusterName(rc.config.ClusterName),
+        }
+
+        r, err := NewWithOptions(append(opts, rc.config.Options...)...)
+        if err != nil {
+            return nil, fmt.Errorf("RADOS cluster %s: %w", nc.name, err)
+        }
+
+        rc.r = r
+    }
+
+    return rc.r, nil
+}
+
+// NewContext creates a new RADOS IO context for the given pool of the
+// named cluster (see Rados.NewContext()).
+func (nc *NamedCluster) NewContext(pool string) (*Context, error) {
+    r, err := nc.Rados()
+    if err != nil {
+        return nil, err
+    }
+
+    return r.NewContext(pool)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "text/tabwriter"
+
+    "github.com/mrkvm/rados.go"
+)
+
+func init() {
+    commands["analyze"] = command{
+        summary: "report what is consuming space in a pool",
+        run:     analyze,
+    }
+}
+
+// analyze runs the analyze command, which prints the size histogram and
+// the largest prefixes found by Context.AnalyzePool().
+func analyze(r *rados.Rados, args []string) error {
+    flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+    pool := flags.String("pool", "", "`pool` to analyze (required)")
+    namespace := flags.String("namespace", "", "`namespace` to analyze (\"*\" for all)")
+    opts := &rados.AnalyzeOptions{}
+    flags.StringVar(&opts.Prefix, "prefix", "", "only analyze the objects whose names start with `prefix`")
+    flags.StringVar(&opts.Delimiter, "delimiter", "/", "`delimiter` ending the prefixes counted together")
+    flags.IntVar(&opts.Concurrency, "concurrency", 16, "`number` of objects in flight")
+    top := flags.Int("top", 20, "`number` of prefixes to print, largest first (all if 0)")
+    flags.Parse(args)
+
+    if *pool == "" {
+        return errors.New("-pool is required")
+    }
+
+    ctx, err := openContext(r, *pool, *namespace)
+    if err != nil {
+        return err
+    }
+    defer ctx.Release()
+
+    analysis, err := ctx.AnalyzePool(opts)
+    if err != nil {
+        return err
+    }
+
+    w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+    fmt.Fprintln(w, "SIZE\tOBJECTS\tBYTES\t")
+    for _, bucket := range analysis.Sizes {
+        fmt.Fprintf(w, "%s\t%d\t%d\t\n", sizeRange(bucket), bucket.Objects, bucket.Bytes)
+    }
+    fmt.Fprintf(w, "total\t%d\t%d\t\n", analysis.Objects, analysis.Bytes)
+    w.Flush()
+
+    prefixes := make([]string, 0, len(analysis.Prefixes))
+    for prefix := range analysis.Prefixes {
+        prefixes = append(prefixes, prefix)
+    }
+    sort.Slice(prefixes, func(i, j int) bool {
+        a, b := analysis.Prefixes[prefixes[i]], analysis.Prefixes[prefixes[j]]
+        if a.Bytes != b.Bytes {
+            return a.Bytes > b.Bytes
+        }
+        return prefixes[i] < prefixes[j]
+    })
+    if *top > 0 && len(prefixes) > *top {
+        prefixes = prefixes[:*top]
+    }
+
+    fmt.Println()
+    w = tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+    fmt.Fprintln(w, "PREFIX\tOBJECTS\tBYTES")
+    for _, prefix := range prefixes {
+        usage := analysis.Prefixes[prefix]
+        fmt.Fprintf(w, "%q\t%d\t%d\n", prefix, usage.Objects, usage.Bytes)
+    }
+    w.Flush()
+
+    for entry, err := range analysis.Errors {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", entryName(entry), err)
+    }
+
+    return nil
+}
+
+// sizeRange is a utility function that returns a readable description of
+// the sizes counted by a bucket of a size histogram.
+func sizeRange(bucket rados.SizeBucket) string {
+    switch {
+    case bucket.Max == 1:
+        return "empty"
+    case bucket.Max == math.MaxInt64:
+        return ">= " + sizeString(bucket.Min)
+    }
+
+    return sizeString(bucket.Min) + " - " + sizeString(bucket.Max)
+}
+
+// sizeString is a utility function that formats a size in bytes with a
+// binary unit.
+func sizeString(size int64) string {
+    for _, unit := range []string{"B", "KiB", "MiB", "GiB"} {
+        if size < 1024 || size%1024 != 0 || unit == "GiB" {
+            return fmt.Sprintf("%d %s", size, unit)
+        }
+        size /= 1024
+    }
+
+    return ""
+}
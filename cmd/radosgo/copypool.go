@@ -0,0 +1,58 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+
+    "github.com/mrkvm/rados.go"
+)
+
+func init() {
+    commands["copy-pool"] = command{
+        summary: "copy all the objects of a pool into another pool",
+        run:     copyPool,
+    }
+}
+
+// copyPool runs the copy-pool command, which copies a pool with
+// Rados.CopyPool(), reporting its progress on stderr.
+func copyPool(r *rados.Rados, args []string) error {
+    flags := flag.NewFlagSet("copy-pool", flag.ExitOnError)
+    flags.Usage = func() {
+        fmt.Fprintf(os.Stderr, "usage: radosgo copy-pool [flags] <source pool> <destination pool>\n")
+        flags.PrintDefaults()
+    }
+    opts := &rados.CopyPoolOptions{}
+    flags.IntVar(&opts.Concurrency, "concurrency", 16, "`number` of objects copied at the same time")
+    quiet := flags.Bool("q", false, "don't report the progress")
+    flags.Parse(args)
+
+    if flags.NArg() != 2 {
+        flags.Usage()
+        os.Exit(2)
+    }
+
+    if !*quiet {
+        opts.Progress = func(progress rados.CopyProgress) {
+            fmt.Fprintf(os.Stderr, "\r%d/%d objects, %d bytes, %d failed, %.0f bytes/s",
+                progress.Objects, progress.Total, progress.Bytes, progress.Failed, progress.Rate)
+        }
+    }
+
+    errs, err := r.CopyPool(flags.Arg(0), flags.Arg(1), opts)
+    if !*quiet {
+        fmt.Fprintln(os.Stderr)
+    }
+
+    for entry, err := range errs {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", entryName(entry), err)
+    }
+
+    if err == nil && len(errs) > 0 {
+        err = errors.New("some objects could not be copied")
+    }
+
+    return err
+}
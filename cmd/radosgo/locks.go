@@ -0,0 +1,77 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "text/tabwriter"
+    "time"
+
+    "github.com/mrkvm/rados.go"
+)
+
+func init() {
+    commands["sweep-locks"] = command{
+        summary: "report, and break, the locks left behind by dead clients",
+        run:     sweepLocks,
+    }
+}
+
+// sweepLocks runs the sweep-locks command, which prints the locks found by
+// Context.SweepLocks().
+func sweepLocks(r *rados.Rados, args []string) error {
+    flags := flag.NewFlagSet("sweep-locks", flag.ExitOnError)
+    pool := flags.String("pool", "", "`pool` to sweep (required)")
+    namespace := flags.String("namespace", "", "`namespace` to sweep (\"*\" for all)")
+    opts := &rados.SweepOptions{}
+    flags.StringVar(&opts.Prefix, "prefix", "", "only sweep the objects whose names start with `prefix`")
+    flags.BoolVar(&opts.BreakStale, "break-stale", false, "break the locks of blocklisted clients")
+    flags.BoolVar(&opts.Watched, "watched", false, "flag the locks of clients not watching the object as gone")
+    flags.BoolVar(&opts.BreakGone, "break-gone", false, "break the locks flagged as gone (with -watched)")
+    flags.IntVar(&opts.Concurrency, "concurrency", 16, "`number` of objects in flight")
+    flags.Parse(args)
+
+    if *pool == "" {
+        return errors.New("-pool is required")
+    }
+
+    ctx, err := openContext(r, *pool, *namespace)
+    if err != nil {
+        return err
+    }
+    defer ctx.Release()
+
+    reports, err := ctx.SweepLocks(opts)
+
+    w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+    fmt.Fprintln(w, "NAMESPACE\tOBJECT\tLOCK\tTYPE\tCLIENT\tCOOKIE\tADDRESS\tEXPIRES\tSTATE")
+
+    for _, report := range reports {
+        typ := "shared"
+        if report.Exclusive {
+            typ = "exclusive"
+        }
+
+        expires := "never"
+        if !report.Expiration.IsZero() {
+            expires = time.Until(report.Expiration).Round(time.Second).String()
+        }
+
+        state := "held"
+        switch {
+        case report.Broken:
+            state = "broken"
+        case report.Stale:
+            state = "stale"
+        case report.Gone:
+            state = "gone"
+        }
+
+        fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", report.Namespace, report.Object, report.Lock,
+            typ, report.Locker.Client, report.Locker.Cookie, report.Locker.Addr, expires, state)
+    }
+    w.Flush()
+
+    return err
+}
@@ -0,0 +1,126 @@
+// Command radosgo runs the administration tools of the rados package
+// against a Ceph cluster.
+//
+// Usage:
+//
+//     radosgo [-c ceph.conf] [-id user] <command> [arguments]
+//
+// The commands are:
+//
+//     analyze      report what is consuming space in a pool
+//     copy-pool    copy all the objects of a pool into another pool
+//     sweep-locks  report, and break, the locks left behind by dead clients
+//     verify-pool  verify the objects of a pool against their stored checksums
+//
+// Run "radosgo <command> -h" for the arguments of a command. The cluster
+// configuration honors the environment variables of the Ceph command-line
+// tools (CEPH_CONF, CEPH_KEYRING and CEPH_ARGS).
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "sort"
+
+    "github.com/mrkvm/rados.go"
+)
+
+// command is a subcommand of radosgo.
+type command struct {
+    summary string
+    run     func(r *rados.Rados, args []string) error
+}
+
+// commands are the subcommands of radosgo, by name. Each of them
+// registers itself from the init function of its file.
+var commands = make(map[string]command)
+
+func main() {
+    configFile := flag.String("c", "", "Ceph configuration `file`")
+    user := flag.String("id", "", "Ceph `user` to connect as (admin by default)")
+    flag.Usage = usage
+    flag.Parse()
+
+    if flag.NArg() < 1 {
+        usage()
+        os.Exit(2)
+    }
+
+    cmd, ok := commands[flag.Arg(0)]
+    if !ok {
+        fmt.Fprintf(os.Stderr, "radosgo: unknown command %q\n", flag.Arg(0))
+        usage()
+        os.Exit(2)
+    }
+
+    opts := []rados.Option{rados.WithEnvironment()}
+    if *configFile != "" {
+        opts = append(opts, rados.WithConfigFile(*configFile))
+    }
+    if *user != "" {
+        opts = append(opts, rados.WithUser(*user))
+    }
+
+    r, err := rados.NewWithOptions(opts...)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "radosgo: %v\n", err)
+        os.Exit(1)
+    }
+
+    err = cmd.run(r, flag.Args()[1:])
+    r.Release()
+
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "radosgo %s: %v\n", flag.Arg(0), err)
+        os.Exit(1)
+    }
+}
+
+// usage is a utility function that prints the usage of radosgo and its
+// commands.
+func usage() {
+    fmt.Fprintf(os.Stderr, "usage: radosgo [-c ceph.conf] [-id user] <command> [arguments]\n\n")
+    flag.PrintDefaults()
+
+    names := make([]string, 0, len(commands))
+    for name := range commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    fmt.Fprintf(os.Stderr, "\ncommands:\n")
+    for _, name := range names {
+        fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+    }
+}
+
+// openContext is a utility function that returns a context for the named
+// pool and namespace, where "*" stands for all the namespaces.
+func openContext(r *rados.Rados, pool, namespace string) (*rados.Context, error) {
+    ctx, err := r.NewContext(pool)
+    if err != nil {
+        return nil, err
+    }
+
+    if namespace == "*" {
+        namespace = rados.AllNamespaces
+    }
+
+    if err = ctx.SetNamespace(namespace); err != nil {
+        ctx.Release()
+        return nil, err
+    }
+
+    return ctx, nil
+}
+
+// entryName is a utility function that returns the name of the object of
+// a listing entry, qualified by its namespace if it has one.
+func entryName(entry rados.ListEntry) string {
+    if entry.Namespace == "" {
+        return entry.Name
+    }
+
+    return entry.Namespace + "/" + entry.Name
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+
+    "github.com/mrkvm/rados.go"
+)
+
+func init() {
+    commands["verify-pool"] = command{
+        summary: "verify the objects of a pool against their stored checksums",
+        run:     verifyPool,
+    }
+}
+
+// verifyPool runs the verify-pool command, which prints the report of
+// Context.VerifyPool(). It fails if any object is corrupted or could not be
+// verified, so it can run from periodic integrity audits.
+func verifyPool(r *rados.Rados, args []string) error {
+    flags := flag.NewFlagSet("verify-pool", flag.ExitOnError)
+    pool := flags.String("pool", "", "`pool` to verify (required)")
+    namespace := flags.String("namespace", "", "`namespace` to verify (\"*\" for all)")
+    prefix := flags.String("prefix", "", "only verify the objects whose names start with `prefix`")
+    concurrency := flags.Int("concurrency", 16, "`number` of objects in flight")
+    flags.Parse(args)
+
+    if *pool == "" {
+        return errors.New("-pool is required")
+    }
+
+    ctx, err := openContext(r, *pool, *namespace)
+    if err != nil {
+        return err
+    }
+    defer ctx.Release()
+
+    report, err := ctx.VerifyPool(*prefix, *concurrency)
+    if err != nil {
+        return err
+    }
+
+    for _, entry := range report.Corrupted {
+        fmt.Printf("corrupted: %s\n", entryName(entry))
+    }
+    for _, entry := range report.Missing {
+        fmt.Printf("missing: %s\n", entryName(entry))
+    }
+    for _, entry := range report.NoChecksum {
+        fmt.Printf("no checksum: %s\n", entryName(entry))
+    }
+    for entry, err := range report.Errors {
+        fmt.Fprintf(os.Stderr, "%s: %v\n", entryName(entry), err)
+    }
+
+    fmt.Printf("%d verified, %d corrupted, %d missing, %d without checksum, %d errors\n", report.Verified,
+        len(report.Corrupted), len(report.Missing), len(report.NoChecksum), len(report.Errors))
+
+    if len(report.Corrupted) > 0 || len(report.Errors) > 0 {
+        return errors.New("verification failed")
+    }
+
+    return nil
+}
@@ -0,0 +1,154 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "bytes"
+    "encoding/gob"
+    "encoding/json"
+    "errors"
+    "fmt"
+)
+
+// ErrContentType is returned when decoding an object recorded with a
+// different content type.
+var ErrContentType = errors.New("RADOS unexpected content type")
+
+// contentTypeXattr is the extended attribute recording the content type of
+// an object written by PutJSON() or PutGob().
+const contentTypeXattr = "rados.go.content-type"
+
+// Content types recorded by PutJSON() and PutGob().
+const (
+    ContentTypeJSON = "application/json"
+    ContentTypeGob  = "application/x-gob"
+)
+
+// PutJSON writes the JSON encoding of v to the named object in the pool
+// referenced by the given context like Put(), and records its content type
+// in an extended attribute of the object in the same atomic operation.
+func (c *Context) PutJSON(name string, v interface{}) error {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return fmt.Errorf("RADOS put json %s: %w", name, err)
+    }
+
+    return c.putTyped(name, data, ContentTypeJSON)
+}
+
+// GetJSON reads the named object in the pool referenced by the given
+// context and decodes its data from JSON into v. It fails with an error
+// wrapping ErrContentType if the object was recorded with another content
+// type; objects without a recorded content type are decoded as is.
+func (c *Context) GetJSON(name string, v interface{}) error {
+    data, err := c.getTyped(name, ContentTypeJSON)
+    if err != nil {
+        return err
+    }
+
+    if err = json.Unmarshal(data, v); err != nil {
+        return fmt.Errorf("RADOS get json %s: %w", name, err)
+    }
+
+    return nil
+}
+
+// PutGob writes the gob encoding of v to the named object in the pool
+// referenced by the given context like PutJSON().
+func (c *Context) PutGob(name string, v interface{}) error {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+        return fmt.Errorf("RADOS put gob %s: %w", name, err)
+    }
+
+    return c.putTyped(name, buf.Bytes(), ContentTypeGob)
+}
+
+// GetGob reads the named object in the pool referenced by the given
+// context and decodes its gob-encoded data into v, like GetJSON().
+func (c *Context) GetGob(name string, v interface{}) error {
+    data, err := c.getTyped(name, ContentTypeGob)
+    if err != nil {
+        return err
+    }
+
+    if err = gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+        return fmt.Errorf("RADOS get gob %s: %w", name, err)
+    }
+
+    return nil
+}
+
+// ContentType returns the content type recorded for the named object in
+// the pool referenced by the given context, or the empty string if it has
+// none.
+func (c *Context) ContentType(name string) (string, error) {
+    if err := checkName(name); err != nil {
+        return "", err
+    }
+
+    value, cerr := c.getXattr(name, contentTypeXattr)
+
+    switch {
+    case cerr == -C.ENODATA:
+        return "", nil
+    case cerr < 0:
+        return "", fmt.Errorf("RADOS content type %s: %w", name, radosErrno(cerr))
+    }
+
+    return string(value), nil
+}
+
+// putTyped is a utility function that writes data to the named object and
+// records its content type.
+func (c *Context) putTyped(name string, data []byte, contentType string) error {
+    return c.PutWithXattrs(name, data, map[string][]byte{
+        contentTypeXattr: []byte(contentType),
+    })
+}
+
+// getTyped is a utility function that reads the data of the named object,
+// after checking that its recorded content type, if any, is contentType.
+// The content type and the data are read separately, so an object
+// replaced in between is not detected.
+func (c *Context) getTyped(name string, contentType string) ([]byte, error) {
+    recorded, err := c.ContentType(name)
+    if err != nil {
+        return nil, err
+    }
+
+    if recorded != "" && recorded != contentType {
+        return nil, fmt.Errorf("RADOS get %s: %s instead of %s: %w", name, recorded, contentType, ErrContentType)
+    }
+
+    return c.Get(name)
+}
+
+// PutJSON wraps the Context-based PutJSON function for the given object.
+func (o *Object) PutJSON(v interface{}) error {
+    return o.c.PutJSON(o.name, v)
+}
+
+// GetJSON wraps the Context-based GetJSON function for the given object.
+func (o *Object) GetJSON(v interface{}) error {
+    return o.c.GetJSON(o.name, v)
+}
+
+// PutGob wraps the Context-based PutGob function for the given object.
+func (o *Object) PutGob(v interface{}) error {
+    return o.c.PutGob(o.name, v)
+}
+
+// GetGob wraps the Context-based GetGob function for the given object.
+func (o *Object) GetGob(v interface{}) error {
+    return o.c.GetGob(o.name, v)
+}
+
+// ContentType wraps the Context-based ContentType function for the given
+// object.
+func (o *Object) ContentType() (string, error) {
+    return o.c.ContentType(o.name)
+}
@@ -0,0 +1,151 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "stdint.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "syscall"
+)
+
+// cmpextMaxErrno is the largest errno. A failed extent comparison returns
+// -(cmpextMaxErrno + i), where i is the offset of the first mismatch from
+// the start of the compared extent.
+const cmpextMaxErrno = 4095
+
+// cmpextMaxLen is the largest number of bytes compared by an extent
+// comparison, so that its results stay above cerrClosed and cerrSealed.
+const cmpextMaxLen = math.MaxInt32 - cmpextMaxErrno - 1
+
+// compareChunkSize is the number of bytes read at a time from each object
+// when comparing objects, unless a smaller maximum chunk size is set.
+const compareChunkSize = 4 << 20
+
+// Compare compares the object aName in the pool referenced by a with the
+// object bName in the pool referenced by b, which may be in different
+// pools or even clusters. It returns whether the objects have the same
+// contents, and if not, the offset of the first byte that differs; an
+// object that is a prefix of the other differs at the end of the shorter
+// one. The objects are read in chunks, so objects of any size can be
+// compared without loading them in memory, which lets migration tools
+// verify copies.
+func Compare(a *Context, aName string, b *Context, bName string) (equal bool, firstDiff int64, err error) {
+    aObj := a.object(aName)
+    bObj := b.object(bName)
+
+    chunk := compareChunkSize
+    for _, size := range []int{aObj.chunkSize(), bObj.chunkSize()} {
+        if size > 0 && size < chunk {
+            chunk = size
+        }
+    }
+
+    aBuf := make([]byte, chunk)
+    bBuf := make([]byte, chunk)
+    var off int64
+
+    for {
+        aN, aErr := aObj.read(aBuf, off, nil)
+        if aErr != nil && aErr != io.EOF {
+            return false, 0, aErr
+        }
+
+        bN, bErr := bObj.read(bBuf, off, nil)
+        if bErr != nil && bErr != io.EOF {
+            return false, 0, bErr
+        }
+
+        n := aN
+        if bN < n {
+            n = bN
+        }
+
+        for i := 0; i < n; i++ {
+            if aBuf[i] != bBuf[i] {
+                return false, off + int64(i), nil
+            }
+        }
+
+        if aN != bN {
+            return false, off + int64(n), nil
+        }
+
+        if aErr == io.EOF || bErr == io.EOF {
+            // Both objects ended at the same offset
+            return true, -1, nil
+        }
+
+        off += int64(n)
+    }
+}
+
+// MismatchError is returned by CompareAndWrite() when the data of the
+// object differs from the expected data. It wraps ErrComparisonFailed.
+type MismatchError struct {
+    Name   string
+    Offset int64 // Offset in the object of the first byte that differs
+}
+
+func (e *MismatchError) Error() string {
+    return fmt.Sprintf("RADOS compare and write %s: mismatch at offset %d: %s", e.Name, e.Offset, ErrComparisonFailed)
+}
+
+func (e *MismatchError) Unwrap() error {
+    return ErrComparisonFailed
+}
+
+// CompareAndWrite replaces the bytes of the given RADOS object at the byte
+// offset off with replace, provided they are equal to expect, in a single
+// atomic operation, for slot or record-based data structures stored in
+// single objects. If they differ, nothing is written and CompareAndWrite
+// returns a *MismatchError with the offset of the first byte that differs.
+// Bytes past the end of the object compare as zeroes. CompareAndWrite
+// fails with ErrImmutable if the object has been sealed, and with an error
+// wrapping syscall.EINVAL if expect is 2 GB or longer.
+func (o *Object) CompareAndWrite(off int64, expect, replace []byte) (err error) {
+    defer o.c.audit("write", o.name, &err)
+
+    if err := checkName(o.name); err != nil {
+        return err
+    }
+
+    if len(expect) > cmpextMaxLen {
+        return fmt.Errorf("RADOS compare and write %s: comparing %d bytes: %w", o.name, len(expect), syscall.EINVAL)
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.cmpExt(expect, off)
+    op.Write(replace, off)
+
+    cerr := o.c.operate(o.name, op, nil)
+    if i := -int64(cerr) - cmpextMaxErrno; i >= 0 && i < cmpextMaxLen {
+        return &MismatchError{Name: o.name, Offset: off + i}
+    } else if cerr < 0 {
+        return o.c.writeError("compare and write", o.name, cerr)
+    }
+
+    return nil
+}
+
+// cmpExt is a utility function that adds a guard to the operation that
+// makes it fail unless the bytes of the object at the byte offset off are
+// equal to expect. A failed guard makes the operation fail with
+// -cmpextMaxErrno minus the offset of the first byte that differs within
+// expect.
+func (op *WriteOp) cmpExt(expect []byte, off int64) {
+    cprval := op.newResult()
+
+    op.add(func(cop C.rados_write_op_t) {
+        cexpect, cexpectlen := byteSliceToBuffer(expect)
+        C.rados_write_op_cmpext(cop, cexpect, cexpectlen, C.uint64_t(off), cprval)
+    })
+}
@@ -0,0 +1,248 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "syscall"
+)
+
+// ErrConflict is returned when an object was changed by another writer
+// during a read-modify-write cycle, so the cycle's write was not applied.
+// Errors wrapping it are *ConflictError values describing the conflict.
+var ErrConflict = errors.New("RADOS concurrent modification")
+
+// ConflictKind classifies the change that made a read-modify-write cycle
+// fail.
+type ConflictKind int
+
+const (
+    // ConflictModified means the object was written by another writer:
+    // applying the write would have lost their update.
+    ConflictModified ConflictKind = iota
+
+    // ConflictDeleted means the object was removed by another writer.
+    ConflictDeleted
+
+    // ConflictCreated means the object, which did not exist when it was
+    // read, was created by another writer.
+    ConflictCreated
+)
+
+func (kind ConflictKind) String() string {
+    switch kind {
+    case ConflictModified:
+        return "lost update"
+    case ConflictDeleted:
+        return "concurrent delete"
+    case ConflictCreated:
+        return "concurrent create"
+    }
+
+    return fmt.Sprintf("ConflictKind(%d)", int(kind))
+}
+
+// ConflictError describes a failed read-modify-write cycle. It wraps
+// ErrConflict.
+type ConflictError struct {
+    Kind    ConflictKind
+    Name    string // Object
+    Version uint64 // Version of the object when it was read, or 0 if it didn't exist
+}
+
+func (e *ConflictError) Error() string {
+    return fmt.Sprintf("RADOS %s %s (read at version %d): %s", e.Kind, e.Name, e.Version, ErrConflict)
+}
+
+func (e *ConflictError) Unwrap() error {
+    return ErrConflict
+}
+
+// conflictError is a utility function that returns the error for a write
+// of the named object guarded by the given version (or by its absence if
+// exists is false) which failed with cerr: a *ConflictError if the guard
+// failed, or nil if cerr is not such a failure.
+func conflictError(name string, version uint64, exists bool, cerr C.int) error {
+    var kind ConflictKind
+
+    switch {
+    case cerr == -C.ENOENT && exists:
+        kind = ConflictDeleted
+    case cerr == -C.EEXIST && !exists:
+        kind = ConflictCreated
+    case (cerr == -C.ERANGE || cerr == -C.EOVERFLOW) && exists:
+        // The object is newer (ERANGE) or was removed and created again
+        // (EOVERFLOW) since it was read.
+        kind = ConflictModified
+    default:
+        return nil
+    }
+
+    return &ConflictError{Kind: kind, Name: name, Version: version}
+}
+
+// readVersioned is a utility function that reads all the data in the
+// named object along with the version of the object the data was read at,
+// and whether the object exists.
+func (c *Context) readVersioned(name string) ([]byte, uint64, bool, error) {
+    for {
+        info, err := c.Stat(name)
+        if errors.Is(err, syscall.ENOENT) {
+            return nil, 0, false, nil
+        } else if err != nil {
+            return nil, 0, false, err
+        }
+
+        // Read one more byte than needed, to tell whether the object
+        // grew since the stat.
+        size := int(info.Size())
+
+        cp, err := c.AioRead(name, size+1, 0)
+        if err != nil {
+            return nil, 0, false, err
+        }
+
+        err = cp.Wait()
+        data, version := cp.Data(), cp.Version()
+        cp.Release()
+
+        switch {
+        case errors.Is(err, syscall.ENOENT):
+            return nil, 0, false, nil
+        case err != nil:
+            return nil, 0, false, err
+        case len(data) <= size:
+            return data, version, true, nil
+        }
+    }
+}
+
+// Update performs a read-modify-write cycle on the named object in the
+// pool referenced by the given context: it reads the data of the object,
+// calls fn with it (exists is false if the object does not exist), and
+// replaces the data with the result of fn, provided no other writer
+// changed the object in the meantime. Otherwise, the write is not applied
+// and Update returns a *ConflictError classifying the conflict, on which
+// strategies can be built, e.g., retrying (merging the changes in fn):
+//
+//     for {
+//         err := ctx.Update("config", merge)
+//         if !errors.Is(err, rados.ErrConflict) {
+//             return err
+//         }
+//     }
+//
+// An error returned by fn aborts the cycle and is returned as is.
+func (c *Context) Update(name string, fn func(data []byte, exists bool) ([]byte, error)) (err error) {
+    defer c.audit("update", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    data, version, exists, err := c.readVersioned(name)
+    if err != nil {
+        return err
+    }
+
+    if data, err = fn(data, exists); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    if exists {
+        op.AssertVersion(version)
+    } else {
+        op.Create(true)
+    }
+    op.WriteFull(data)
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        if err := conflictError(name, version, exists, cerr); err != nil {
+            return err
+        }
+        return c.writeError("update", name, cerr)
+    }
+
+    return nil
+}
+
+// GetV reads all the data in the named object in the pool referenced by
+// the given context like Get(), along with the version of the object the
+// data was read at, to be passed to PutIfVersion().
+func (c *Context) GetV(name string) ([]byte, uint64, error) {
+    if err := checkName(name); err != nil {
+        return nil, 0, err
+    }
+
+    data, version, exists, err := c.readVersioned(name)
+    if err != nil {
+        return nil, 0, err
+    }
+
+    if !exists {
+        return nil, 0, fmt.Errorf("RADOS get %s: %w", name, radosErrno(-C.ENOENT))
+    }
+
+    return data, version, nil
+}
+
+// PutIfVersion writes data to the named object in the pool referenced by
+// the given context like Put(), provided the object is still at version
+// (as returned by GetV()), which makes a compare-and-swap of the whole
+// object. A version of 0 requires the object not to exist. If the object
+// changed, the data is not written and PutIfVersion returns a
+// *ConflictError (see Update()).
+//
+// The data is written in a single operation, regardless of the maximum
+// chunk size of the context.
+func (c *Context) PutIfVersion(name string, data []byte, version uint64) (err error) {
+    defer c.audit("put", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    exists := version != 0
+    if exists {
+        op.AssertVersion(version)
+    } else {
+        op.Create(true)
+    }
+    op.WriteFull(data)
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        if err := conflictError(name, version, exists, cerr); err != nil {
+            return err
+        }
+        return c.writeError("put", name, cerr)
+    }
+
+    return nil
+}
+
+// Update wraps the Context-based Update function for the given object. It
+// fails with ErrImmutable if the object has been sealed.
+func (o *Object) Update(fn func(data []byte, exists bool) ([]byte, error)) error {
+    return o.c.Update(o.name, fn)
+}
+
+// GetV wraps the Context-based GetV function for the given object.
+func (o *Object) GetV() ([]byte, uint64, error) {
+    return o.c.GetV(o.name)
+}
+
+// PutIfVersion wraps the Context-based PutIfVersion function for the given
+// object. It fails with ErrImmutable if the object has been sealed.
+func (o *Object) PutIfVersion(data []byte, version uint64) error {
+    return o.c.PutIfVersion(o.name, data, version)
+}
@@ -3,51 +3,280 @@ package rados
 /*
 #cgo LDFLAGS: -lrados
 #include "stdlib.h"
+#include "errno.h"
 #include "rados/librados.h"
 */
 import "C"
 
 import (
+    "context"
     "fmt"
+    "strings"
+    "time"
     "unsafe"
 )
 
+// AllNamespaces can be passed to Context.SetNamespace() to make object
+// listings cover the objects in all namespaces of the pool.
+const AllNamespaces = "\x01"
+
 // Context represents a RADOS IO context for the pool Pool.
+//
+// A Context is bound to the namespace and locator key last set on it. To
+// use different settings in one goroutine without affecting others that
+// share the Context, Clone() it first.
 type Context struct {
-    Pool string
-    ctx  C.rados_ioctx_t
+    Pool  string
+    ctx   C.rados_ioctx_t
+    rados *Rados
+
+    namespace    string
+    locator      string
+    maxChunkSize int
+    opFlags      OpFlags
+    fadvise      FadviseFlags
+
+    trashRetention time.Duration
+
+    progress         func(progress TransferProgress)
+    progressInterval time.Duration
+
+    parallelGetThreshold   int64
+    parallelGetParallelism int
+
+    slowOpThreshold time.Duration
+    onSlowOp        func(op SlowOp)
+
+    backoff *Backoff
+
+    profileCtx    context.Context
+    profileLabels *[nOpKinds]context.Context
+
+    stats contextStats
 }
 
 // NewContext creates a new RADOS IO context for a given pool, which used to
-// do IO operations. The pool must exist (see Rados.PoolCreate()).
+// do IO operations. The pool must exist (see Rados.PoolCreate()), or
+// NewContext fails with an error wrapping ErrPoolNotFound. The context
+// uses the read policy of the cluster handle (see WithReadPolicy()).
 func (r *Rados) NewContext(pool string) (*Context, error) {
     if r.rados == nil {
-        return nil, fmt.Errorf("RADOS not connected")
+        return nil, closedError("new ioctx for pool " + pool)
     }
 
     cpool := C.CString(pool)
     defer C.free(unsafe.Pointer(cpool))
 
-    c := &Context{Pool: pool}
+    c := &Context{Pool: pool, rados: r}
 
     if cerr := C.rados_ioctx_create(r.rados, cpool, &c.ctx); cerr < 0 {
-        return nil, fmt.Errorf("RADOS new ioctx for pool %s: %s",
-            strerror(cerr))
+        return nil, poolError("new ioctx for pool", pool, cerr)
     }
+    c.SetReadPolicy(r.opts.readPolicy)
 
     return c, nil
 }
 
-// Release this RADOS IO context.
+// Clone creates a new RADOS IO context for the same pool as the given
+// context, with the same namespace, locator key, flags, trash mode,
+// progress reports, throughput window, parallel gets, slow operation
+// reports, backoff and profile labels. Changing the settings of the clone
+// does not affect the original context, and vice versa.
+func (c *Context) Clone() (*Context, error) {
+    clone, err := c.rados.NewContext(c.Pool)
+    if err != nil {
+        return nil, err
+    }
+
+    if err = clone.SetNamespace(c.namespace); err != nil {
+        clone.Release()
+        return nil, err
+    }
+
+    clone.SetLocatorKey(c.locator)
+    clone.SetMaxChunkSize(c.maxChunkSize)
+    clone.SetOpFlags(c.opFlags)
+    clone.SetFadvise(c.fadvise)
+    clone.SetTrash(c.trashRetention)
+    clone.SetProgress(c.progress, c.progressInterval)
+    clone.SetStatsWindow(c.StatsWindow())
+    clone.SetParallelGet(c.parallelGetThreshold, c.parallelGetParallelism)
+    clone.SetSlowOps(c.slowOpThreshold, c.onSlowOp)
+    clone.SetBackoff(c.backoff)
+    clone.SetProfileLabels(c.profileCtx)
+
+    return clone, nil
+}
+
+// SetNamespace sets the namespace used for all subsequent operations on
+// the given context. Objects in different namespaces of a pool are
+// independent, even if they have the same name. The empty string selects
+// the default namespace.
+func (c *Context) SetNamespace(namespace string) error {
+    if namespace != AllNamespaces && strings.IndexByte(namespace, 0) >= 0 {
+        return fmt.Errorf("RADOS namespace %q: %w", namespace, ErrInvalidName)
+    }
+    if c.closed() {
+        return closedError("set namespace")
+    }
+
+    cnamespace := C.CString(namespace)
+    defer C.free(unsafe.Pointer(cnamespace))
+
+    C.rados_ioctx_set_namespace(c.ctx, cnamespace)
+    c.namespace = namespace
+
+    return nil
+}
+
+// SetLocatorKey sets the key used instead of the object name to determine
+// the placement of objects for all subsequent operations on the given
+// context, so that objects with the same locator key are stored together.
+// The empty string restores placement by object name.
+func (c *Context) SetLocatorKey(key string) {
+    if c.closed() {
+        return
+    }
+
+    ckey := C.CString(key)
+    defer C.free(unsafe.Pointer(ckey))
+
+    C.rados_ioctx_locator_set_key(c.ctx, ckey)
+    c.locator = key
+}
+
+// Release this RADOS IO context. Operations on the context fail with an
+// error wrapping ErrClosed once it, or the cluster handle it was created
+// from, has been released, and releasing it again does nothing.
 //
 // TODO: track all uncompleted async operations before calling
 // rados_ioctx_destroy(), because it doesn't do that itself.
 func (c *Context) Release() error {
-    C.rados_ioctx_destroy(c.ctx)
+    if c.ctx == nil {
+        return nil
+    }
+
+    // The context went away with its cluster handle if the handle was
+    // released first.
+    if c.rados.rados != nil {
+        C.rados_ioctx_destroy(c.ctx)
+    }
+    c.ctx = nil
 
     return nil
 }
 
+// closed is a utility function that reports whether the given context, or
+// the cluster handle it was created from, has been released.
+func (c *Context) closed() bool {
+    return c.ctx == nil || c.rados.rados == nil
+}
+
+// PoolID returns the ID of the pool referenced by the given context.
+func (c *Context) PoolID() (int64, error) {
+    if c.closed() {
+        return 0, closedError("pool id")
+    }
+
+    var id C.int64_t
+    c.call(opOther, "rados_ioctx_get_id", "", func() C.int {
+        id = C.rados_ioctx_get_id(c.ctx)
+        return 0
+    })
+
+    return int64(id), nil
+}
+
+// PoolName returns the name of the pool referenced by the given context,
+// as known to librados. Unlike Pool, it reflects the renames of the pool
+// since the context was created.
+func (c *Context) PoolName() (string, error) {
+    name, cerr := c.ioctxString("rados_ioctx_get_pool_name", func(buf *C.char, maxlen C.unsigned) C.int {
+        return C.rados_ioctx_get_pool_name(c.ctx, buf, maxlen)
+    })
+    if cerr < 0 {
+        return "", fmt.Errorf("RADOS pool name: %w", radosErrno(cerr))
+    }
+
+    return name, nil
+}
+
+// Namespace returns the namespace used by the given context, as known to
+// librados (see SetNamespace()).
+func (c *Context) Namespace() (string, error) {
+    namespace, cerr := c.ioctxString("rados_ioctx_get_namespace", func(buf *C.char, maxlen C.unsigned) C.int {
+        return C.rados_ioctx_get_namespace(c.ctx, buf, maxlen)
+    })
+    if cerr < 0 {
+        return "", fmt.Errorf("RADOS namespace: %w", radosErrno(cerr))
+    }
+
+    return namespace, nil
+}
+
+// LastVersion returns the version of the object after the last operation
+// on the given context, or 0 if it was released. The result is only
+// meaningful if no other goroutine uses the context at the same time.
+func (c *Context) LastVersion() uint64 {
+    if c.closed() {
+        return 0
+    }
+
+    var version C.uint64_t
+    c.call(opOther, "rados_get_last_version", "", func() C.int {
+        version = C.rados_get_last_version(c.ctx)
+        return 0
+    })
+
+    return uint64(version)
+}
+
+// ioctxString is a utility function that returns the string retrieved by
+// get, a librados function that fails with ERANGE if the string doesn't
+// fit in the buffer it is given.
+func (c *Context) ioctxString(function string, get func(buf *C.char, maxlen C.unsigned) C.int) (string, C.int) {
+    if c.closed() {
+        return "", cerrClosed
+    }
+
+    for bufSize := 256; ; bufSize *= 2 {
+        buf := make([]byte, bufSize)
+        cbuf, cbuflen := byteSliceToBuffer(buf)
+
+        cerr := c.call(opOther, function, "", func() C.int {
+            return get(cbuf, C.unsigned(cbuflen))
+        })
+        if cerr == -C.ERANGE {
+            continue
+        } else if cerr < 0 {
+            return "", cerr
+        }
+
+        return C.GoStringN(cbuf, C.int(cerr)), 0
+    }
+}
+
+// SetMaxChunkSize sets the maximum number of bytes transferred by a single
+// RADOS operation when reading or writing objects through the given
+// context (Get, Put, ReadAt and WriteAt). Larger transfers are split into
+// several operations, which bounds the memory used by librados and the
+// OSDs for each operation and keeps one large transfer from monopolizing
+// an OSD. A size of 0, the default, means transfers are not split.
+//
+// The OSDs reject writes larger than the osd_max_write_size configuration
+// option (90 MB by default), so objects bigger than that can only be
+// written with a maximum chunk size at or below it. The limit can also be
+// set for individual objects (see Object.SetMaxChunkSize()).
+func (c *Context) SetMaxChunkSize(size int) {
+    c.maxChunkSize = size
+}
+
+// MaxChunkSize returns the maximum number of bytes transferred by a single
+// RADOS operation on the given context, or 0 if transfers are not split.
+func (c *Context) MaxChunkSize() int {
+    return c.maxChunkSize
+}
+
 // PoolInfo provides usage information about a pool
 type PoolInfo struct {
     BytesUsed                uint64
@@ -69,8 +298,15 @@ type PoolInfo struct {
 func (c *Context) PoolStat() (*PoolInfo, error) {
     var pstat C.struct_rados_pool_stat_t
 
-    if cerr := C.rados_ioctx_pool_stat(c.ctx, &pstat); cerr < 0 {
-        return nil, fmt.Errorf("RADOS pool stat: %s", strerror(cerr))
+    if c.closed() {
+        return nil, closedError("pool stat")
+    }
+
+    cerr := c.call(opStat, "rados_ioctx_pool_stat", "", func() C.int {
+        return C.rados_ioctx_pool_stat(c.ctx, &pstat)
+    })
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS pool stat: %w", radosErrno(cerr))
     }
 
     info := &PoolInfo{
@@ -90,3 +326,71 @@ func (c *Context) PoolStat() (*PoolInfo, error) {
 
     return info, nil
 }
+
+// CheckQuota reports whether writing size more bytes to a new object in
+// the pool referenced by the given context would be refused because the
+// pool or cluster is full. It returns a SpaceError wrapping
+// ErrQuotaExceeded if the pool is at (or the write would take it over) its
+// quota, and one wrapping ErrNoSpace if the pool is flagged full.
+//
+// CheckQuota is an optional, best-effort check based on the current pool
+// statistics that lets uploaders fail fast before sending any data.
+func (c *Context) CheckQuota(size int64) error {
+    var pools []struct {
+        Name  string `json:"pool_name"`
+        Flags string `json:"flags_names"`
+    }
+
+    err := c.rados.monCommandJSON(map[string]interface{}{
+        "prefix": "osd pool ls",
+        "detail": "detail",
+    }, &pools)
+    if err != nil {
+        return err
+    }
+
+    for _, pool := range pools {
+        if pool.Name != c.Pool {
+            continue
+        }
+
+        for _, flag := range strings.Split(pool.Flags, ",") {
+            switch flag {
+            case "full_quota":
+                return &SpaceError{Op: "check quota", Pool: c.Pool, Err: ErrQuotaExceeded}
+            case "full":
+                return &SpaceError{Op: "check quota", Pool: c.Pool, Err: ErrNoSpace}
+            }
+        }
+    }
+
+    var quota struct {
+        MaxObjects uint64 `json:"quota_max_objects"`
+        MaxBytes   uint64 `json:"quota_max_bytes"`
+    }
+
+    err = c.rados.monCommandJSON(map[string]interface{}{
+        "prefix": "osd pool get-quota",
+        "pool":   c.Pool,
+    }, &quota)
+    if err != nil {
+        return err
+    }
+
+    if quota.MaxObjects == 0 && quota.MaxBytes == 0 {
+        // No quota set
+        return nil
+    }
+
+    info, err := c.PoolStat()
+    if err != nil {
+        return err
+    }
+
+    if (quota.MaxBytes > 0 && info.BytesUsed+uint64(size) > quota.MaxBytes) ||
+        (quota.MaxObjects > 0 && info.NObjects+1 > quota.MaxObjects) {
+        return &SpaceError{Op: "check quota", Pool: c.Pool, Err: ErrQuotaExceeded}
+    }
+
+    return nil
+}
@@ -0,0 +1,272 @@
+package rados
+
+import (
+    "io"
+    "sync"
+    "time"
+)
+
+// copyChunkSize is the number of bytes copied at a time when copying
+// objects, unless a smaller maximum chunk size is set.
+const copyChunkSize = 4 << 20
+
+// CopyPoolOptions are the settings of a pool copy (see CopyPool()).
+type CopyPoolOptions struct {
+    // Concurrency is the number of objects copied at the same time. It
+    // defaults to 1.
+    Concurrency int
+
+    // Progress, if not nil, is called after each object is copied (or
+    // fails to be). Calls are serialized.
+    Progress func(progress CopyProgress)
+
+    // Backoff, if not nil, slows the copy down while the cluster is
+    // overloaded, and retries the objects that failed to be copied
+    // because it was busy (see Backoff).
+    Backoff *Backoff
+}
+
+// CopyProgress reports the progress of a pool copy.
+type CopyProgress struct {
+    Objects uint64 // Objects copied
+    Bytes   uint64 // Bytes of object data copied
+    Failed  uint64 // Objects that could not be copied
+    Rate    float64 // Bytes of object data copied per second
+
+    // Total is the number of objects in the source pool when the copy
+    // started, as reported by the pool statistics. It is an estimate.
+    Total uint64
+}
+
+// CopyPool copies all the objects of the pool src into the existing pool
+// dst, preserving their data, extended attributes, omap keys, namespaces
+// and locator keys. Objects that already exist in dst are replaced.
+// Snapshots and omap headers are not copied.
+//
+// The error for each object that could not be copied is returned in the
+// map. The returned error is only set if the copy could not proceed at
+// all (e.g., because listing the source pool failed).
+func (r *Rados) CopyPool(src, dst string, opts *CopyPoolOptions) (map[ListEntry]error, error) {
+    if opts == nil {
+        opts = &CopyPoolOptions{}
+    }
+
+    concurrency := opts.Concurrency
+    if concurrency < 1 {
+        concurrency = 1
+    }
+
+    lister, err := r.NewContext(src)
+    if err != nil {
+        return nil, err
+    }
+    defer lister.Release()
+
+    if err = lister.SetNamespace(AllNamespaces); err != nil {
+        return nil, err
+    }
+
+    info, err := lister.PoolStat()
+    if err != nil {
+        return nil, err
+    }
+
+    iter, err := lister.ListObjects()
+    if err != nil {
+        return nil, err
+    }
+    defer iter.Close()
+
+    contexts := r.NewContextPool(concurrency)
+    defer contexts.Close()
+
+    var mutex sync.Mutex
+    var wg sync.WaitGroup
+    progress := CopyProgress{Total: info.NObjects}
+    start := time.Now()
+    errs := make(map[ListEntry]error)
+    entries := make(chan ListEntry)
+
+    for i := 0; i < concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+
+            for entry := range entries {
+                var n int64
+                err := opts.Backoff.run(func() (err error) {
+                    n, err = copyPoolObject(contexts, src, dst, entry)
+                    return err
+                })
+
+                mutex.Lock()
+                if err != nil {
+                    errs[entry] = err
+                    progress.Failed++
+                } else {
+                    progress.Objects++
+                    progress.Bytes += uint64(n)
+                }
+                progress.Rate = float64(progress.Bytes) / time.Since(start).Seconds()
+
+                if opts.Progress != nil {
+                    opts.Progress(progress)
+                }
+                mutex.Unlock()
+            }
+        }()
+    }
+
+    for iter.Next() {
+        entries <- iter.Entry()
+    }
+    close(entries)
+    wg.Wait()
+
+    return errs, iter.Err()
+}
+
+// copyPoolObject is a utility function that copies the given object from
+// the pool src to the pool dst, using contexts from the given pool.
+func copyPoolObject(contexts *ContextPool, src, dst string, entry ListEntry) (int64, error) {
+    srcCtx, err := contexts.Get(src, entry.Namespace)
+    if err != nil {
+        return 0, err
+    }
+    defer contexts.Put(srcCtx)
+
+    dstCtx, err := contexts.Get(dst, entry.Namespace)
+    if err != nil {
+        return 0, err
+    }
+    defer contexts.Put(dstCtx)
+
+    if entry.Locator != "" {
+        srcCtx.SetLocatorKey(entry.Locator)
+        dstCtx.SetLocatorKey(entry.Locator)
+    }
+
+    return copyObject(srcCtx, entry.Name, dstCtx, entry.Name, false, nil)
+}
+
+// copyObject is a utility function that copies the data, extended
+// attributes and omap keys of the named object referenced by src to the
+// object dstName referenced by dst, replacing it if it exists, or failing
+// with EEXIST if exclusive is set. If edit is not nil, it is called with
+// the extended attributes to write, which it may modify. The progress of
+// the copy is reported like the reads of src (see SetProgress()). It
+// returns the number of bytes of data copied.
+func copyObject(src *Context, name string, dst *Context, dstName string, exclusive bool,
+    edit func(xattrs map[string][]byte)) (size int64, err error) {
+
+    defer dst.audit("copy", dstName, &err)
+
+    if err := checkName(name); err != nil {
+        return 0, err
+    }
+    if err := checkName(dstName); err != nil {
+        return 0, err
+    }
+
+    xattrs, err := src.GetXattrs(name)
+    if err != nil {
+        return 0, err
+    }
+    if edit != nil {
+        edit(xattrs)
+    }
+
+    // Start from scratch, so that no stale extended attributes or omap
+    // keys are left behind.
+    if !exclusive {
+        if _, err = dst.Stat(dstName); err == nil {
+            if err = dst.remove(dstName); err != nil {
+                return 0, err
+            }
+        }
+    }
+
+    info, err := src.Stat(name)
+    if err != nil {
+        return 0, err
+    }
+
+    srcObj := src.object(name)
+    dstObj := dst.object(dstName)
+    progress := src.newTransfer(name, info.Size())
+
+    chunk := copyChunkSize
+    for _, size := range []int{srcObj.chunkSize(), dstObj.chunkSize()} {
+        if size > 0 && size < chunk {
+            chunk = size
+        }
+    }
+
+    // The first chunk of data is written along with the extended
+    // attributes, which creates the object even if it is empty.
+    buf := make([]byte, chunk)
+    var off int64
+
+    for {
+        n, rerr := srcObj.read(buf, off, progress)
+        if rerr != nil && rerr != io.EOF {
+            return off, rerr
+        }
+
+        op := NewWriteOp()
+
+        if off == 0 {
+            op.Create(exclusive)
+            for xattr, value := range xattrs {
+                op.SetXattr(xattr, value)
+            }
+        }
+
+        if n > 0 {
+            op.Write(buf[:n], off)
+        }
+
+        cerr := dst.operate(dstName, op, nil)
+        op.Release()
+
+        if cerr < 0 {
+            return off, dst.writeError("copy", dstName, cerr)
+        }
+
+        off += int64(n)
+
+        if rerr == io.EOF {
+            break
+        }
+    }
+
+    // Copy the omap keys in batches
+    iter := src.OmapIter(name, "")
+    batch := make(map[string][]byte)
+
+    for {
+        more := iter.Next()
+        if more {
+            batch[iter.Key()] = iter.Value()
+        }
+
+        if len(batch) >= omapBatchSize || (!more && len(batch) > 0) {
+            op := NewWriteOp()
+            op.OmapSet(batch)
+            cerr := dst.operate(dstName, op, nil)
+            op.Release()
+
+            if cerr < 0 {
+                return off, dst.writeError("copy", dstName, cerr)
+            }
+
+            batch = make(map[string][]byte)
+        }
+
+        if !more {
+            break
+        }
+    }
+
+    return off, iter.Err()
+}
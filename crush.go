@@ -0,0 +1,91 @@
+package rados
+
+import (
+    "fmt"
+    "strings"
+    "syscall"
+)
+
+// CrushRuleType is the type of pool a CRUSH rule places data for.
+type CrushRuleType int
+
+// CRUSH rule types.
+const (
+    CrushRuleReplicated CrushRuleType = 1
+    CrushRuleErasure    CrushRuleType = 3
+)
+
+// CrushStep is a step of a CRUSH rule, e.g., taking a root of the CRUSH
+// hierarchy or choosing a number of buckets of a type below it.
+type CrushStep struct {
+    Op       string `json:"op"`        // E.g., "take", "chooseleaf_firstn" or "emit"
+    Item     int    `json:"item"`      // Bucket taken by "take" steps
+    ItemName string `json:"item_name"` // Name of the bucket taken by "take" steps
+    Num      int    `json:"num"`       // Number of buckets chosen (0 for all replicas)
+    Type     string `json:"type"`      // Bucket type chosen, e.g., "host"
+}
+
+// CrushRule is a CRUSH rule, which determines where the placement groups
+// of the pools using it are stored.
+type CrushRule struct {
+    ID    int           `json:"rule_id"`
+    Name  string        `json:"rule_name"`
+    Type  CrushRuleType `json:"type"`
+    Steps []CrushStep   `json:"steps"`
+}
+
+// Root returns the name of the CRUSH bucket the rule takes replicas from,
+// or the empty string if it has no "take" step.
+func (rule *CrushRule) Root() string {
+    for _, step := range rule.Steps {
+        if step.Op == "take" {
+            return step.ItemName
+        }
+    }
+
+    return ""
+}
+
+// FailureDomain returns the type of CRUSH bucket the rule spreads replicas
+// across (e.g., "host" or "rack"), or the empty string if it has no
+// "choose" step.
+func (rule *CrushRule) FailureDomain() string {
+    for _, step := range rule.Steps {
+        if strings.HasPrefix(step.Op, "choose") {
+            return step.Type
+        }
+    }
+
+    return ""
+}
+
+// CrushRules returns the CRUSH rules of the cluster.
+func (r *Rados) CrushRules() ([]CrushRule, error) {
+    var rules []CrushRule
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd crush rule dump",
+    }, &rules)
+    if err != nil {
+        return nil, err
+    }
+
+    return rules, nil
+}
+
+// CrushRule returns the named CRUSH rule. It returns an error wrapping
+// syscall.ENOENT if the rule does not exist.
+func (r *Rados) CrushRule(name string) (*CrushRule, error) {
+    rules, err := r.CrushRules()
+    if err != nil {
+        return nil, err
+    }
+
+    for i := range rules {
+        if rules[i].Name == name {
+            return &rules[i], nil
+        }
+    }
+
+    return nil, fmt.Errorf("RADOS crush rule %s: %w", name, syscall.ENOENT)
+}
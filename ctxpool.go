@@ -0,0 +1,125 @@
+package rados
+
+import (
+    "sync"
+)
+
+// contextKey identifies the contexts of a ContextPool that can be used
+// interchangeably.
+type contextKey struct {
+    pool      string
+    namespace string
+}
+
+// ContextPool keeps RADOS IO contexts around for reuse, so that
+// applications that need a context per request don't pay for creating and
+// destroying one every time. Contexts are handed out with Get() and
+// returned with Put(). A ContextPool is safe for concurrent use.
+//
+//     pool := conn.NewContextPool(16)
+//     defer pool.Close()
+//     ...
+//     ctx, err := pool.Get("data", "")
+//     ...
+//     defer pool.Put(ctx)
+type ContextPool struct {
+    r       *Rados
+    maxIdle int
+
+    mutex  sync.Mutex
+    idle   map[contextKey][]*Context
+    closed bool
+}
+
+// NewContextPool returns a pool of IO contexts created from the given
+// cluster handle. At most maxIdle unused contexts are kept for each pool
+// and namespace; contexts returned beyond that are released.
+func (r *Rados) NewContextPool(maxIdle int) *ContextPool {
+    return &ContextPool{
+        r:       r,
+        maxIdle: maxIdle,
+        idle:    make(map[contextKey][]*Context),
+    }
+}
+
+// Get returns a context for the given pool and namespace, reusing an idle
+// one if available and creating a new one otherwise. The context must be
+// handed back with Put() when it is no longer needed, and must not be used
+// afterwards.
+func (p *ContextPool) Get(pool, namespace string) (*Context, error) {
+    key := contextKey{pool: pool, namespace: namespace}
+
+    p.mutex.Lock()
+    if contexts := p.idle[key]; len(contexts) > 0 {
+        c := contexts[len(contexts)-1]
+        p.idle[key] = contexts[:len(contexts)-1]
+        p.mutex.Unlock()
+
+        return c, nil
+    }
+    p.mutex.Unlock()
+
+    c, err := p.r.NewContext(pool)
+    if err != nil {
+        return nil, err
+    }
+
+    if err = c.SetNamespace(namespace); err != nil {
+        c.Release()
+        return nil, err
+    }
+
+    return c, nil
+}
+
+// Put hands a context obtained from Get() back to the pool. Settings
+// changed on the context other than its namespace (locator key, maximum
+// chunk size, flags, trash mode, progress reports, throughput window,
+// parallel gets, slow operation reports, backoff, profile labels) are
+// reset before it is reused.
+func (p *ContextPool) Put(c *Context) {
+    if c.locator != "" {
+        c.SetLocatorKey("")
+    }
+    c.SetMaxChunkSize(0)
+    c.SetOpFlags(0)
+    c.SetReadPolicy(c.rados.opts.readPolicy)
+    c.SetTrash(0)
+    c.SetFadvise(0)
+    c.SetProgress(nil, 0)
+    c.SetStatsWindow(0)
+    c.SetParallelGet(0, 0)
+    c.SetSlowOps(0, nil)
+    c.SetBackoff(nil)
+    c.SetProfileLabels(nil)
+
+    key := contextKey{pool: c.Pool, namespace: c.namespace}
+
+    p.mutex.Lock()
+    if !p.closed && len(p.idle[key]) < p.maxIdle {
+        p.idle[key] = append(p.idle[key], c)
+        p.mutex.Unlock()
+        return
+    }
+    p.mutex.Unlock()
+
+    c.Release()
+}
+
+// Close releases all the idle contexts of the pool. Contexts handed back
+// with Put() after Close are released immediately.
+func (p *ContextPool) Close() error {
+    p.mutex.Lock()
+    idle := p.idle
+    p.idle = make(map[contextKey][]*Context)
+    p.closed = true
+    p.mutex.Unlock()
+
+    for _, contexts := range idle {
+        for _, c := range contexts {
+            c.Release()
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,246 @@
+package rados
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+)
+
+var (
+    // ErrPoolDeleteNotAllowed is returned by DeletePoolWithOptions() when
+    // the deletion was not explicitly allowed (see
+    // DeleteOptions.AllowPoolDelete).
+    ErrPoolDeleteNotAllowed = errors.New("RADOS pool deletion not allowed")
+
+    // ErrPoolNotEmpty is returned by DeletePoolWithOptions() for pools
+    // that still hold objects when DeleteOptions.RefuseNonEmpty is set,
+    // and by the pool operations the cluster refuses with ENOTEMPTY.
+    ErrPoolNotEmpty = errors.New("RADOS pool not empty")
+)
+
+// monAllowPoolDelete is the monitor option that must be enabled for pools
+// to be deleted.
+const monAllowPoolDelete = "mon_allow_pool_delete"
+
+// DeleteOptions configure bulk deletions.
+type DeleteOptions struct {
+    // DryRun makes the deletion only report what it would delete, without
+    // deleting anything, so mass cleanups can be previewed.
+    DryRun bool
+
+    // Concurrency is the number of objects processed at a time (1 if 0).
+    Concurrency int
+
+    // AllowPoolDelete must be set for DeletePoolWithOptions() to delete a
+    // pool, so that pools are never deleted by mistake.
+    AllowPoolDelete bool
+
+    // EnablePoolDelete makes DeletePoolWithOptions() enable the
+    // mon_allow_pool_delete option of the monitors for the time of the
+    // deletion if it is disabled, instead of failing. If the option
+    // cannot be restored afterwards, the error is returned along with the
+    // report of the deleted pool.
+    EnablePoolDelete bool
+
+    // RefuseNonEmpty makes DeletePoolWithOptions() fail with
+    // ErrPoolNotEmpty instead of deleting pools that still hold objects.
+    RefuseNonEmpty bool
+}
+
+// DeleteReport describes what a bulk deletion deleted, or would have
+// deleted in dry-run mode.
+type DeleteReport struct {
+    DryRun  bool
+    Objects uint64              // Objects deleted (or to delete)
+    Bytes   uint64              // Bytes of data deleted (or to delete)
+    Names   []ListEntry         // Objects deleted (or to delete), when known
+    Errors  map[ListEntry]error // Objects that could not be deleted
+}
+
+// add is a utility function that accounts for the deletion of the given
+// object of size bytes in the report.
+func (report *DeleteReport) add(entry ListEntry, size int64) {
+    report.Objects++
+    report.Bytes += uint64(size)
+    report.Names = append(report.Names, entry)
+}
+
+// RemoveAll removes the objects in the pool referenced by the given
+// context whose names start with prefix (all the objects for an empty
+// prefix), in all the namespaces covered by the context (see
+// AllNamespaces). It returns a report of the objects removed, along with
+// the error that stopped the listing, if any. Removals go through the
+// trash if trash mode is enabled on the context (see SetTrash()), and
+// objects already in the trash are left alone.
+func (c *Context) RemoveAll(prefix string, opts *DeleteOptions) (*DeleteReport, error) {
+    if opts == nil {
+        opts = &DeleteOptions{}
+    }
+
+    var mutex sync.Mutex
+    report := &DeleteReport{DryRun: opts.DryRun}
+
+    errs, err := c.scanObjects(prefix, opts.Concurrency, func(ctx *Context, entry ListEntry) error {
+        if entry.Namespace == trashNamespace && c.namespace != trashNamespace {
+            return nil
+        }
+
+        info, err := ctx.Stat(entry.Name)
+        if err != nil {
+            return err
+        }
+
+        if !opts.DryRun {
+            ctx.SetTrash(c.trashRetention)
+            if err = ctx.Remove(entry.Name); err != nil {
+                return err
+            }
+        }
+
+        mutex.Lock()
+        report.add(entry, info.Size())
+        mutex.Unlock()
+
+        return nil
+    })
+    report.Errors = errs
+
+    return report, err
+}
+
+// DeletePoolWithOptions deletes the named pool like DeletePool(), and
+// returns a report of the objects and bytes it held (as last reported by
+// the pool statistics, without names). Unlike DeletePool(), it refuses to
+// delete the pool unless opts.AllowPoolDelete is set, and can refuse to
+// delete pools that are not empty (see DeleteOptions). In dry-run mode the
+// pool is left alone, but the checks are still made.
+func (r *Rados) DeletePoolWithOptions(pool string, opts *DeleteOptions) (_ *DeleteReport, err error) {
+    if opts == nil {
+        opts = &DeleteOptions{}
+    }
+
+    if !opts.AllowPoolDelete && !opts.DryRun {
+        return nil, fmt.Errorf("RADOS pool delete %s: %w", pool, ErrPoolDeleteNotAllowed)
+    }
+
+    c, err := r.NewContext(pool)
+    if err != nil {
+        return nil, err
+    }
+
+    info, err := c.PoolStat()
+    if err == nil && opts.RefuseNonEmpty {
+        err = c.checkEmpty()
+    }
+    c.Release()
+    if err != nil {
+        return nil, err
+    }
+
+    report := &DeleteReport{
+        DryRun:  opts.DryRun,
+        Objects: info.NObjects,
+        Bytes:   info.BytesUsed,
+    }
+
+    if opts.DryRun {
+        return report, nil
+    }
+
+    if opts.EnablePoolDelete {
+        restore, rerr := r.enablePoolDelete()
+        if rerr != nil {
+            return nil, rerr
+        }
+        defer func() {
+            if rerr := restore(); rerr != nil && err == nil {
+                err = fmt.Errorf("RADOS pool delete %s: restoring %s: %w", pool, monAllowPoolDelete, rerr)
+            }
+        }()
+    }
+
+    if err = r.deletePool(pool); err != nil {
+        return nil, err
+    }
+
+    return report, nil
+}
+
+// checkEmpty is a utility function that fails with ErrPoolNotEmpty if the
+// pool referenced by the given context holds any object, in any namespace.
+// The objects are listed, since the pool statistics lag behind writes.
+func (c *Context) checkEmpty() error {
+    lister, err := c.Clone()
+    if err != nil {
+        return err
+    }
+    defer lister.Release()
+
+    if err = lister.SetNamespace(AllNamespaces); err != nil {
+        return err
+    }
+
+    iter, err := lister.ListObjects()
+    if err != nil {
+        return err
+    }
+    defer iter.Close()
+
+    if iter.Next() {
+        return fmt.Errorf("RADOS pool delete %s: %w", c.Pool, ErrPoolNotEmpty)
+    }
+
+    return iter.Err()
+}
+
+// enablePoolDelete is a utility function that enables the
+// mon_allow_pool_delete option of the monitors if it is disabled. It
+// returns a function that restores the option, by removing the setting
+// from the configuration database so that the previous value applies.
+func (r *Rados) enablePoolDelete() (func() error, error) {
+    var allowed interface{}
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "config get",
+        "who":    "mon",
+        "key":    monAllowPoolDelete,
+    }, &allowed)
+    if err != nil {
+        return nil, err
+    }
+
+    if allowed == true || allowed == "true" {
+        return func() error { return nil }, nil
+    }
+
+    if err = r.setMonConfig(monAllowPoolDelete, "true"); err != nil {
+        return nil, err
+    }
+
+    return func() error {
+        return r.removeMonConfig(monAllowPoolDelete)
+    }, nil
+}
+
+// setMonConfig is a utility function that sets the named configuration
+// option of the monitors in the configuration database of the cluster,
+// like `ceph config set mon`.
+func (r *Rados) setMonConfig(option, value string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix": "config set",
+        "who":    "mon",
+        "name":   option,
+        "value":  value,
+    }, nil)
+}
+
+// removeMonConfig is a utility function that removes the named
+// configuration option of the monitors from the configuration database of
+// the cluster, like `ceph config rm mon`.
+func (r *Rados) removeMonConfig(option string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix": "config rm",
+        "who":    "mon",
+        "name":   option,
+    }, nil)
+}
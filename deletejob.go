@@ -0,0 +1,315 @@
+package rados
+
+import (
+    "sync"
+    "time"
+)
+
+// DeleteProgress describes the progress of a delete job.
+type DeleteProgress struct {
+    Total   int           // Objects to delete
+    Done    int           // Objects deleted
+    Failed  int           // Objects that could not be deleted
+    Rate    float64       // Objects processed per second while running
+    Elapsed time.Duration // Time spent running, pauses excluded
+}
+
+// DeleteJobOptions configure a delete job.
+type DeleteJobOptions struct {
+    // Concurrency is the number of removals in flight (1 if 0).
+    Concurrency int
+
+    // OnProgress, if not nil, is called from the goroutine of the job
+    // every Interval while the job runs, and once when it ends.
+    OnProgress func(progress DeleteProgress)
+
+    // Interval between two calls of OnProgress (1 second if 0).
+    Interval time.Duration
+
+    // Backoff, if not nil, slows the job down while the cluster is
+    // overloaded, and retries the removals that failed because it was
+    // busy (see Backoff). It defaults to the backoff of the context (see
+    // SetBackoff()).
+    Backoff *Backoff
+}
+
+// DeleteJob deletes a list of objects in the background with asynchronous
+// operations (see Context.StartDelete()). It can be paused and resumed,
+// and must be waited for with Wait() or stopped with Cancel().
+type DeleteJob struct {
+    c       *Context
+    entries []ListEntry
+    opts    DeleteJobOptions
+
+    mutex    sync.Mutex
+    resumed  *sync.Cond
+    paused   bool
+    canceled bool
+    progress DeleteProgress
+    started  time.Time // Start of the current running period, or zero
+    report   *DeleteReport
+    done     chan struct{}
+}
+
+// StartDelete starts deleting the named objects from the namespace of the
+// given context in the background, keeping up to opts.Concurrency
+// removals in flight, so huge datasets can be decommissioned without
+// paying the latency of each removal. Objects that don't exist are counted
+// as failures. Removals bypass trash mode (see SetTrash()).
+func (c *Context) StartDelete(names []string, opts DeleteJobOptions) (*DeleteJob, error) {
+    entries := make([]ListEntry, len(names))
+    for i, name := range names {
+        entries[i] = ListEntry{Name: name, Namespace: c.namespace, Locator: c.locator}
+    }
+
+    return c.StartDeleteEntries(entries, opts)
+}
+
+// StartDeleteEntries starts deleting the given objects from the pool
+// referenced by the given context in the background like StartDelete(),
+// with each object in the namespace and locator key of its entry, e.g.,
+// as returned by an object listing.
+func (c *Context) StartDeleteEntries(entries []ListEntry, opts DeleteJobOptions) (*DeleteJob, error) {
+    if opts.Concurrency < 1 {
+        opts.Concurrency = 1
+    }
+    if opts.Interval <= 0 {
+        opts.Interval = time.Second
+    }
+    if opts.Backoff == nil {
+        opts.Backoff = c.backoff
+    }
+
+    // The namespace and locator key of the job's context change with each
+    // object, which doesn't affect removals already in flight.
+    ctx, err := c.Clone()
+    if err != nil {
+        return nil, err
+    }
+
+    job := &DeleteJob{
+        c:        ctx,
+        entries:  entries,
+        opts:     opts,
+        progress: DeleteProgress{Total: len(entries)},
+        report:   &DeleteReport{Errors: make(map[ListEntry]error)},
+        done:     make(chan struct{}),
+    }
+    job.resumed = sync.NewCond(&job.mutex)
+
+    go job.run()
+
+    return job, nil
+}
+
+// run is a utility function that runs the job until all the objects have
+// been processed or the job is canceled.
+func (job *DeleteJob) run() {
+    defer close(job.done)
+    defer job.c.Release()
+
+    stopReports := make(chan struct{})
+    reported := make(chan struct{})
+    go job.reportProgress(stopReports, reported)
+
+    // removal is an attempt at removing an object
+    type removal struct {
+        entry    ListEntry
+        attempts int
+        cp       *Completion
+    }
+
+    var pending, retries []removal
+
+    job.mutex.Lock()
+    job.started = time.Now()
+    job.mutex.Unlock()
+
+    for next := 0; next < len(job.entries) || len(retries) > 0 || len(pending) > 0; {
+        // While the job is paused, the removals in flight are still
+        // reaped; it only blocks once none are left.
+        if len(pending) < job.opts.Concurrency && (next < len(job.entries) || len(retries) > 0) &&
+            job.wait(len(pending) == 0) {
+            var r removal
+            if len(retries) > 0 {
+                r, retries = retries[0], retries[1:]
+            } else {
+                r = removal{entry: job.entries[next]}
+                next++
+            }
+
+            job.opts.Backoff.Wait()
+
+            cp, err := job.submit(r.entry)
+            if err != nil {
+                job.record(r.entry, err)
+                continue
+            }
+
+            r.attempts++
+            r.cp = cp
+            pending = append(pending, r)
+            continue
+        }
+
+        if len(pending) == 0 {
+            break // Canceled
+        }
+
+        r := pending[0]
+        pending = pending[1:]
+
+        err := r.cp.Wait()
+        job.opts.Backoff.Observe(r.cp.latency(), err)
+        r.cp.Release()
+
+        if job.opts.Backoff.Retry(r.attempts, err) {
+            retries = append(retries, r)
+            continue
+        }
+
+        job.record(r.entry, err)
+    }
+
+    job.mutex.Lock()
+    job.stopClock()
+    job.mutex.Unlock()
+
+    close(stopReports)
+    <-reported
+}
+
+// wait is a utility function that blocks while the job is paused, if
+// block is set. It returns false if the job was canceled, or is paused and
+// block is not set.
+func (job *DeleteJob) wait(block bool) bool {
+    job.mutex.Lock()
+    defer job.mutex.Unlock()
+
+    if job.paused && !job.canceled {
+        if !block {
+            return false
+        }
+
+        job.stopClock()
+        for job.paused && !job.canceled {
+            job.resumed.Wait()
+        }
+        job.started = time.Now()
+    }
+
+    return !job.canceled
+}
+
+// stopClock is a utility function that adds the current running period to
+// the time spent running by the job. It must be called with the mutex of
+// the job held.
+func (job *DeleteJob) stopClock() {
+    job.progress.Elapsed += time.Since(job.started)
+    job.started = time.Time{}
+}
+
+// submit is a utility function that starts removing the given object.
+func (job *DeleteJob) submit(entry ListEntry) (*Completion, error) {
+    if err := job.c.SetNamespace(entry.Namespace); err != nil {
+        return nil, err
+    }
+    job.c.SetLocatorKey(entry.Locator)
+
+    return job.c.AioRemove(entry.Name)
+}
+
+// record is a utility function that accounts for the removal of the given
+// object, which failed if err is not nil.
+func (job *DeleteJob) record(entry ListEntry, err error) {
+    job.mutex.Lock()
+    defer job.mutex.Unlock()
+
+    if err != nil {
+        job.progress.Failed++
+        job.report.Errors[entry] = err
+        return
+    }
+
+    job.progress.Done++
+    job.report.Objects++
+    job.report.Names = append(job.report.Names, entry)
+}
+
+// reportProgress is a utility function that calls the progress callback of
+// the job every interval until stop is closed, and once more before
+// closing done.
+func (job *DeleteJob) reportProgress(stop, done chan struct{}) {
+    defer close(done)
+
+    if job.opts.OnProgress == nil {
+        return
+    }
+
+    ticker := time.NewTicker(job.opts.Interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ticker.C:
+            job.opts.OnProgress(job.Progress())
+        case <-stop:
+            job.opts.OnProgress(job.Progress())
+            return
+        }
+    }
+}
+
+// Progress returns the current progress of the job.
+func (job *DeleteJob) Progress() DeleteProgress {
+    job.mutex.Lock()
+    defer job.mutex.Unlock()
+
+    progress := job.progress
+    if !job.started.IsZero() {
+        progress.Elapsed += time.Since(job.started)
+    }
+    if progress.Elapsed > 0 {
+        progress.Rate = float64(progress.Done+progress.Failed) / progress.Elapsed.Seconds()
+    }
+
+    return progress
+}
+
+// Pause stops the job from starting new removals until Resume() is
+// called. Removals already in flight complete, and are reported in the
+// progress of the job while it is paused.
+func (job *DeleteJob) Pause() {
+    job.mutex.Lock()
+    job.paused = true
+    job.mutex.Unlock()
+}
+
+// Resume resumes a paused job.
+func (job *DeleteJob) Resume() {
+    job.mutex.Lock()
+    job.paused = false
+    job.mutex.Unlock()
+
+    job.resumed.Broadcast()
+}
+
+// Cancel stops the job once the removals in flight have completed, and
+// waits for it like Wait(). Objects not processed yet are left alone.
+func (job *DeleteJob) Cancel() *DeleteReport {
+    job.mutex.Lock()
+    job.canceled = true
+    job.mutex.Unlock()
+
+    job.resumed.Broadcast()
+
+    return job.Wait()
+}
+
+// Wait waits for the job to end and returns a report of the objects it
+// deleted and of the failures.
+func (job *DeleteJob) Wait() *DeleteReport {
+    <-job.done
+
+    return job.report
+}
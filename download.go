@@ -0,0 +1,136 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "time"
+    "unsafe"
+)
+
+// ErrObjectChanged is returned by downloads when the object was modified
+// since the download started, so the data already downloaded cannot be
+// completed consistently.
+var ErrObjectChanged = errors.New("RADOS object changed during download")
+
+// downloadChunkSize is the number of bytes read at a time by downloads,
+// unless a smaller maximum chunk size is set.
+const downloadChunkSize = 4 << 20
+
+// Download is a resumable download of an object. It records the version
+// of the object when it starts, and reads the object in chunks guarded by
+// that version, so the data of two versions of the object is never
+// stitched together: if the object changes, the download fails with an
+// error wrapping ErrObjectChanged. After any other failure (e.g., of the
+// destination), WriteTo() can be called again to resume the download
+// where it stopped, possibly from another process (see ResumeDownload()).
+type Download struct {
+    c       *Context
+    name    string
+    version uint64
+    size    int64
+    off     int64
+}
+
+// NewDownload starts a download of the named object in the pool
+// referenced by the given context.
+func (c *Context) NewDownload(name string) (*Download, error) {
+    stat, err := c.AioStat(name)
+    if err != nil {
+        return nil, err
+    }
+    defer stat.Release()
+
+    if err = stat.Wait(); err != nil {
+        return nil, err
+    }
+
+    return &Download{c: c, name: name, version: stat.Version(), size: stat.Size()}, nil
+}
+
+// ResumeDownload returns a download of the named object in the pool
+// referenced by the given context started by another Download (possibly
+// in another process), as described by its Version(), Size() and
+// Offset().
+func (c *Context) ResumeDownload(name string, version uint64, size, off int64) *Download {
+    return &Download{c: c, name: name, version: version, size: size, off: off}
+}
+
+// Version returns the version of the object being downloaded.
+func (d *Download) Version() uint64 {
+    return d.version
+}
+
+// Size returns the size of the object being downloaded.
+func (d *Download) Size() int64 {
+    return d.size
+}
+
+// Offset returns the number of bytes downloaded so far.
+func (d *Download) Offset() int64 {
+    return d.off
+}
+
+// WriteTo writes the data of the object not downloaded yet to w, in chunks
+// of at most the maximum chunk size of the context (and 4 MB). It returns
+// the number of bytes written, and the error that stopped the download, if
+// any; the download can then be resumed by calling WriteTo again, unless
+// the error wraps ErrObjectChanged.
+func (d *Download) WriteTo(w io.Writer) (n int64, err error) {
+    if err := checkName(d.name); err != nil {
+        return 0, err
+    }
+
+    cname := C.CString(d.name)
+    defer C.free(unsafe.Pointer(cname))
+
+    chunk := d.c.maxChunkSize
+    if chunk <= 0 || chunk > downloadChunkSize {
+        chunk = downloadChunkSize
+    }
+
+    buf := make([]byte, chunk)
+    progress := d.c.newTransfer(d.name, d.size-d.off)
+
+    for d.off < d.size {
+        size := chunk
+        if d.size-d.off < int64(size) {
+            size = int(d.size - d.off)
+        }
+
+        start := time.Now()
+        cerr := d.c.call(opRead, "rados_read_op_operate", d.name, func() C.int {
+            return d.c.readOp(cname, buf[:size], d.off, d.version)
+        })
+        d.c.record(opRead, d.name, start, cerr, int(cerr))
+
+        switch {
+        case cerr == -C.ERANGE || cerr == -C.EOVERFLOW || cerr == -C.ENOENT:
+            return n, fmt.Errorf("RADOS download %s: version %d: %w", d.name, d.version, ErrObjectChanged)
+        case cerr < 0:
+            return n, fmt.Errorf("RADOS download %s: %w", d.name, radosErrno(cerr))
+        case int(cerr) < size:
+            // Can't happen while the object is at the same version
+            return n, fmt.Errorf("RADOS download %s: short read at %d: %w", d.name, d.off, ErrObjectChanged)
+        }
+
+        written, err := w.Write(buf[:size])
+        d.off += int64(written)
+        n += int64(written)
+        progress.add(written)
+
+        if err != nil {
+            return n, err
+        }
+    }
+
+    return n, nil
+}
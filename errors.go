@@ -0,0 +1,245 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+    "syscall"
+)
+
+var (
+    // ErrQuotaExceeded is returned when a write fails because the pool
+    // has reached its quota.
+    ErrQuotaExceeded = errors.New("RADOS pool quota exceeded")
+
+    // ErrNoSpace is returned when a write fails because the cluster (or
+    // the OSDs backing the pool) is out of space.
+    ErrNoSpace = errors.New("RADOS no space left")
+
+    // ErrClusterFull is an older name for ErrNoSpace. Errors wrapping
+    // ErrNoSpace also match ErrClusterFull with errors.Is().
+    ErrClusterFull = errors.New("RADOS cluster full")
+
+    // ErrInvalidName is returned for object names that cannot be passed
+    // to RADOS.
+    ErrInvalidName = errors.New("RADOS invalid object name")
+
+    // ErrComparisonFailed is returned when a guard of a write operation
+    // (see WriteOp.OmapCmp()) does not hold, so the operation was not
+    // applied.
+    ErrComparisonFailed = errors.New("RADOS comparison failed")
+
+    // ErrPoolNotFound is returned for operations on pools that don't
+    // exist.
+    ErrPoolNotFound = errors.New("RADOS pool not found")
+
+    // ErrPoolExists is returned when creating a pool that already exists.
+    ErrPoolExists = errors.New("RADOS pool already exists")
+
+    // ErrClosed is returned when using a cluster handle, context,
+    // completion or watch after it has been released or closed.
+    ErrClosed = errors.New("RADOS handle closed")
+)
+
+// cerrClosed is the result of the librados calls skipped because their
+// context was released. It is below the range of errnos, and of the
+// results of failed extent comparisons (see cmpextMaxLen), so that
+// radosErrno() can tell it apart.
+const cerrClosed C.int = math.MinInt32
+
+// cerrSealed is the result of the write operations that failed because the
+// object is sealed (see Object.Seal()), which librados reports as a failed
+// comparison. Like cerrClosed, it is below the range of the results of
+// librados.
+const cerrSealed C.int = cerrClosed + 1
+
+// errnoError is the error returned by a failed librados call. It carries
+// the errno reported by librados as a syscall.Errno, whose text is used as
+// the error message (unlike the C strerror(), syscall.Errno is safe for
+// concurrent use). Callers can test for the errno with
+// errors.Is() (e.g., errors.Is(err, syscall.ENOENT)) or retrieve with
+// errors.As() or Errno().
+type errnoError struct {
+    errno syscall.Errno
+}
+
+// radosErrno is a utility function that returns the error for the negative
+// errno cerr returned by a librados call.
+func radosErrno(cerr C.int) error {
+    switch cerr {
+    case cerrClosed:
+        return ErrClosed
+    case cerrSealed:
+        return ErrImmutable
+    }
+
+    return &errnoError{errno: syscall.Errno(-cerr)}
+}
+
+// closedError is a utility function that returns the error for the
+// operation op on a released handle.
+func closedError(op string) error {
+    return fmt.Errorf("RADOS %s: %w", op, ErrClosed)
+}
+
+func (e *errnoError) Error() string {
+    return e.errno.Error()
+}
+
+func (e *errnoError) Unwrap() error {
+    return e.errno
+}
+
+// Errno returns the errno reported by librados.
+func (e *errnoError) Errno() syscall.Errno {
+    return e.errno
+}
+
+// Errno returns the errno reported by librados for the failed call that
+// caused err, or 0 if err was not caused by a failed librados call.
+func Errno(err error) syscall.Errno {
+    var errno syscall.Errno
+    if errors.As(err, &errno) {
+        return errno
+    }
+
+    return 0
+}
+
+// checkName is a utility function that verifies the given object name can
+// be passed to librados. Object names are arbitrary byte strings, but
+// librados takes them as NUL-terminated C strings, so a name containing a
+// NUL byte would silently refer to a different object. Such names are
+// rejected with ErrInvalidName instead.
+func checkName(name string) error {
+    if strings.IndexByte(name, 0) >= 0 {
+        return fmt.Errorf("RADOS object name %q: %w", name, ErrInvalidName)
+    }
+
+    return nil
+}
+
+// ValidateObjectName verifies that the given object name is acceptable to
+// the cluster, so callers get a clear error up front instead of a cryptic
+// rejection from the OSDs. Invalid names are reported with an error
+// wrapping ErrInvalidName. The maximum name length is taken from the
+// osd_max_object_name_len configuration option.
+func (r *Rados) ValidateObjectName(name string) error {
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    return r.checkNameLen("object name", name, "osd_max_object_name_len")
+}
+
+// ValidateNamespace verifies that the given object namespace is acceptable
+// to the cluster like ValidateObjectName(). The maximum namespace length is
+// taken from the osd_max_object_namespace_len configuration option.
+func (r *Rados) ValidateNamespace(namespace string) error {
+    if strings.IndexByte(namespace, 0) >= 0 {
+        return fmt.Errorf("RADOS namespace %q: %w", namespace, ErrInvalidName)
+    }
+
+    return r.checkNameLen("namespace", namespace, "osd_max_object_namespace_len")
+}
+
+// checkNameLen is a utility function that verifies the length of name
+// against the limit in the given configuration option.
+func (r *Rados) checkNameLen(kind, name, option string) error {
+    value, err := r.ConfGet(option)
+    if err != nil {
+        return err
+    }
+
+    max, err := strconv.Atoi(value)
+    if err != nil {
+        return fmt.Errorf("RADOS conf get %s: %s", option, err)
+    }
+
+    if len(name) > max {
+        return fmt.Errorf("RADOS %s %q is %d bytes, longer than %s (%d): %w",
+            kind, name, len(name), option, max, ErrInvalidName)
+    }
+
+    return nil
+}
+
+// SpaceError reports a write refused because a pool reached its quota or
+// the cluster ran out of space. It wraps ErrQuotaExceeded or ErrNoSpace,
+// so callers can test for the condition with errors.Is() and retrieve the
+// affected pool with errors.As(), e.g., to pause ingestion into that pool
+// and raise an alert.
+type SpaceError struct {
+    Op   string // Operation that failed
+    Name string // Object written, if any
+    Pool string // Pool written to
+    Err  error  // ErrQuotaExceeded or ErrNoSpace
+
+    Errno syscall.Errno // Errno reported by librados, or 0 for CheckQuota()
+}
+
+func (e *SpaceError) Error() string {
+    if e.Name == "" {
+        return fmt.Sprintf("RADOS %s pool %s: %s", e.Op, e.Pool, e.Err)
+    }
+
+    return fmt.Sprintf("RADOS %s %s in pool %s: %s", e.Op, e.Name, e.Pool, e.Err)
+}
+
+func (e *SpaceError) Unwrap() []error {
+    if e.Errno == 0 {
+        return []error{e.Err}
+    }
+
+    return []error{e.Err, e.Errno}
+}
+
+// Is makes errors wrapping ErrNoSpace match ErrClusterFull too.
+func (e *SpaceError) Is(target error) bool {
+    return e.Err == ErrNoSpace && target == ErrClusterFull
+}
+
+// poolError is a utility function that returns the error for the pool
+// operation op on the named pool that returned cerr, wrapping
+// ErrPoolNotFound, ErrPoolExists or ErrPoolNotEmpty when the errno tells,
+// along with the errno.
+func poolError(op, pool string, cerr C.int) error {
+    var err error
+    switch cerr {
+    case -C.ENOENT:
+        err = ErrPoolNotFound
+    case -C.EEXIST:
+        err = ErrPoolExists
+    case -C.ENOTEMPTY:
+        err = ErrPoolNotEmpty
+    default:
+        return fmt.Errorf("RADOS %s %s: %w", op, pool, radosErrno(cerr))
+    }
+
+    return fmt.Errorf("RADOS %s %s: %w (%w)", op, pool, err, radosErrno(cerr))
+}
+
+// writeError is a utility function that builds the error for a failed
+// write operation op on the named object in the pool referenced by the
+// given context. Failures caused by a full pool or cluster are reported
+// with a SpaceError, and failed guards wrap ErrComparisonFailed, so
+// callers can test for them with errors.Is().
+func (c *Context) writeError(op, name string, cerr C.int) error {
+    switch cerr {
+    case -C.EDQUOT:
+        return &SpaceError{Op: op, Name: name, Pool: c.Pool, Err: ErrQuotaExceeded, Errno: syscall.EDQUOT}
+    case -C.ENOSPC:
+        return &SpaceError{Op: op, Name: name, Pool: c.Pool, Err: ErrNoSpace, Errno: syscall.ENOSPC}
+    case -C.ECANCELED:
+        return fmt.Errorf("RADOS %s %s: %w (%w)", op, name, ErrComparisonFailed, radosErrno(cerr))
+    }
+
+    return fmt.Errorf("RADOS %s %s: %w", op, name, radosErrno(cerr))
+}
@@ -0,0 +1,341 @@
+package rados
+
+/*
+#include "time.h"
+*/
+import "C"
+
+import (
+    "bufio"
+    "encoding/binary"
+    "fmt"
+    "io"
+)
+
+// exportMagic starts the dumps written by ExportObject().
+const exportMagic = "rados.go.object"
+
+// exportVersion is the version of the dump format written by
+// ExportObject().
+const exportVersion = 1
+
+// exportMaxString is the length above which the strings of a dump (e.g.,
+// extended attribute or omap values) are rejected as corrupted.
+const exportMaxString = 64 << 20
+
+// ExportObject writes a dump of the named object in the pool referenced by
+// the given context to w: its namespace, locator key, name, modification
+// time, extended attributes, data and omap keys, so the object can be
+// archived, or moved to another pool or cluster with ImportObject(). The
+// librados C API doesn't expose omap headers, so they are not dumped.
+//
+// The object is read with several operations, so an object modified while
+// it is exported may be dumped with a mix of old and new contents.
+//
+// The dump is a sequence of little-endian values, strings being encoded as
+// a 32-bit length followed by their bytes: the string "rados.go.object",
+// the 32-bit format version (1), the namespace, locator key and name
+// strings, the 64-bit modification time (in seconds since the Unix epoch),
+// the 32-bit number of extended attributes followed by their names and
+// values, the 64-bit size of the data followed by the data, and the omap
+// keys, each one preceded by a 1 byte and followed by its value, up to a
+// 0 byte.
+func (c *Context) ExportObject(name string, w io.Writer) error {
+    info, err := c.Stat(name)
+    if err != nil {
+        return err
+    }
+
+    xattrs, err := c.GetXattrs(name)
+    if err != nil {
+        return err
+    }
+
+    bw := bufio.NewWriter(w)
+
+    var buf []byte
+    buf = appendEncoded(buf, []byte(exportMagic))
+    buf = appendUint32(buf, exportVersion)
+    buf = appendEncoded(buf, []byte(c.namespace))
+    buf = appendEncoded(buf, []byte(c.locator))
+    buf = appendEncoded(buf, []byte(name))
+    buf = appendUint64(buf, uint64(info.ModTime().Unix()))
+    buf = appendUint32(buf, uint32(len(xattrs)))
+    for xattr, value := range xattrs {
+        buf = appendEncoded(buf, []byte(xattr))
+        buf = appendEncoded(buf, value)
+    }
+    buf = appendUint64(buf, uint64(info.Size()))
+
+    if _, err = bw.Write(buf); err != nil {
+        return err
+    }
+
+    // The data is streamed, and must match the size written above
+    obj := c.object(name)
+    chunk := obj.chunkSize()
+    if chunk <= 0 || chunk > copyChunkSize {
+        chunk = copyChunkSize
+    }
+
+    data := make([]byte, chunk)
+    progress := c.newTransfer(name, info.Size())
+
+    for off := int64(0); off < info.Size(); {
+        size := int64(chunk)
+        if info.Size()-off < size {
+            size = info.Size() - off
+        }
+
+        n, err := obj.read(data[:size], off, progress)
+        if err != nil && err != io.EOF {
+            return err
+        }
+        if int64(n) < size {
+            return fmt.Errorf("RADOS export %s: object truncated during export", name)
+        }
+
+        if _, err = bw.Write(data[:n]); err != nil {
+            return err
+        }
+        off += int64(n)
+    }
+
+    iter := c.OmapIter(name, "")
+    for iter.Next() {
+        buf = append(buf[:0], 1)
+        buf = appendEncoded(buf, []byte(iter.Key()))
+        buf = appendEncoded(buf, iter.Value())
+
+        if _, err = bw.Write(buf); err != nil {
+            return err
+        }
+    }
+    if err = iter.Err(); err != nil {
+        return err
+    }
+
+    if err = bw.WriteByte(0); err != nil {
+        return err
+    }
+
+    return bw.Flush()
+}
+
+// Export wraps the Context-based ExportObject function for the given
+// object.
+func (o *Object) Export(w io.Writer) error {
+    return o.c.ExportObject(o.name, w)
+}
+
+// exportReader is a utility type that reads the values of a dump.
+type exportReader struct {
+    r *bufio.Reader
+}
+
+// uint32 reads a little-endian 32-bit integer.
+func (er *exportReader) uint32() (uint32, error) {
+    var buf [4]byte
+    _, err := io.ReadFull(er.r, buf[:])
+    return binary.LittleEndian.Uint32(buf[:]), err
+}
+
+// uint64 reads a little-endian 64-bit integer.
+func (er *exportReader) uint64() (uint64, error) {
+    var buf [8]byte
+    _, err := io.ReadFull(er.r, buf[:])
+    return binary.LittleEndian.Uint64(buf[:]), err
+}
+
+// bytes reads a string (a little-endian 32-bit length followed by the
+// data). The data is read incrementally, so a corrupted length doesn't
+// allocate more memory than the dump holds.
+func (er *exportReader) bytes() ([]byte, error) {
+    n, err := er.uint32()
+    if err != nil {
+        return nil, err
+    }
+    if n > exportMaxString {
+        return nil, fmt.Errorf("string of %d bytes", n)
+    }
+
+    data, err := io.ReadAll(io.LimitReader(er.r, int64(n)))
+    if err == nil && len(data) < int(n) {
+        err = io.ErrUnexpectedEOF
+    }
+
+    return data, err
+}
+
+// ImportObject reads a dump written by ExportObject() from r, and writes
+// the object to the pool referenced by the given context, with the
+// namespace, locator key and name recorded in the dump, replacing any
+// existing object with the name. It returns the name of the object. The
+// object is written with several operations, so it is left incomplete if
+// importing fails.
+func (c *Context) ImportObject(r io.Reader) (name string, err error) {
+    er := &exportReader{r: bufio.NewReader(r)}
+
+    // dumpError is a utility function that reports a malformed dump
+    dumpError := func(err error) error {
+        if err == io.EOF {
+            err = io.ErrUnexpectedEOF
+        }
+        return fmt.Errorf("RADOS import %s: invalid dump: %w", name, err)
+    }
+
+    magic, err := er.bytes()
+    if err != nil || string(magic) != exportMagic {
+        return "", fmt.Errorf("RADOS import: not an object dump")
+    }
+
+    version, err := er.uint32()
+    if err != nil {
+        return "", dumpError(err)
+    }
+    if version != exportVersion {
+        return "", fmt.Errorf("RADOS import: unsupported dump version %d", version)
+    }
+
+    var fields [3][]byte // Namespace, locator key and name
+    for i := range fields {
+        if fields[i], err = er.bytes(); err != nil {
+            return "", dumpError(err)
+        }
+    }
+    namespace, locator := string(fields[0]), string(fields[1])
+    name = string(fields[2])
+
+    if err := checkName(name); err != nil {
+        return name, err
+    }
+
+    mtime, err := er.uint64()
+    if err != nil {
+        return name, dumpError(err)
+    }
+    cmtime := C.time_t(mtime)
+
+    nxattrs, err := er.uint32()
+    if err != nil {
+        return name, dumpError(err)
+    }
+
+    xattrs := make(map[string][]byte)
+    for i := uint32(0); i < nxattrs; i++ {
+        xattr, err := er.bytes()
+        if err != nil {
+            return name, dumpError(err)
+        }
+        if xattrs[string(xattr)], err = er.bytes(); err != nil {
+            return name, dumpError(err)
+        }
+    }
+
+    size, err := er.uint64()
+    if err != nil {
+        return name, dumpError(err)
+    }
+
+    dst, err := c.Clone()
+    if err != nil {
+        return name, err
+    }
+    defer dst.Release()
+
+    if err = dst.SetNamespace(namespace); err != nil {
+        return name, err
+    }
+    dst.SetLocatorKey(locator)
+
+    defer dst.audit("import", name, &err)
+
+    // Start from scratch, so that no stale extended attributes or omap
+    // keys are left behind.
+    if _, err = dst.Stat(name); err == nil {
+        if err = dst.remove(name); err != nil {
+            return name, err
+        }
+    }
+
+    chunk := dst.object(name).chunkSize()
+    if chunk <= 0 || chunk > copyChunkSize {
+        chunk = copyChunkSize
+    }
+
+    // The first chunk of data is written along with the extended
+    // attributes, which creates the object even if it is empty.
+    data := make([]byte, chunk)
+    for off := uint64(0); ; {
+        n := uint64(chunk)
+        if size-off < n {
+            n = size - off
+        }
+
+        if _, err = io.ReadFull(er.r, data[:n]); err != nil {
+            return name, dumpError(err)
+        }
+
+        op := NewWriteOp()
+
+        if off == 0 {
+            op.Create(false)
+            for xattr, value := range xattrs {
+                op.SetXattr(xattr, value)
+            }
+        }
+
+        if n > 0 {
+            op.Write(data[:n], int64(off))
+        }
+
+        cerr := dst.operate(name, op, &cmtime)
+        op.Release()
+
+        if cerr < 0 {
+            return name, dst.writeError("import", name, cerr)
+        }
+
+        off += n
+        if off >= size {
+            break
+        }
+    }
+
+    // Import the omap keys in batches
+    batch := make(map[string][]byte)
+
+    for {
+        more, err := er.r.ReadByte()
+        if err != nil {
+            return name, dumpError(err)
+        }
+
+        if more != 0 {
+            key, err := er.bytes()
+            if err != nil {
+                return name, dumpError(err)
+            }
+            if batch[string(key)], err = er.bytes(); err != nil {
+                return name, dumpError(err)
+            }
+        }
+
+        if len(batch) >= omapBatchSize || (more == 0 && len(batch) > 0) {
+            op := NewWriteOp()
+            op.OmapSet(batch)
+            cerr := dst.operate(name, op, &cmtime)
+            op.Release()
+
+            if cerr < 0 {
+                return name, dst.writeError("import", name, cerr)
+            }
+
+            batch = make(map[string][]byte)
+        }
+
+        if more == 0 {
+            return name, nil
+        }
+    }
+}
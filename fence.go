@@ -0,0 +1,107 @@
+package rados
+
+import (
+    "fmt"
+)
+
+// LockHandle is a lock held by the calling client, as taken over by
+// Fence().
+type LockHandle struct {
+    c         *Context
+    Object    string
+    Lock      string
+    Cookie    string
+    Exclusive bool
+}
+
+// Unlock releases the lock.
+func (h *LockHandle) Unlock() error {
+    return h.c.Unlock(h.Object, h.Lock, h.Cookie)
+}
+
+// Fence takes over the locks held by a dead (or presumed dead) client on
+// the named objects in the pool referenced by the given context, in the
+// order required for a safe high-availability takeover:
+//
+//  1. the client address oldClientAddr (see ClientAddrs()) is blocklisted,
+//     so the old client cannot write anymore, even if it is still alive;
+//  2. Fence waits for the OSD map with the blocklist entry, so the OSDs
+//     refuse the operations of the old client before any lock is broken;
+//  3. the locks of the old client on the objects are broken, and taken
+//     again by the calling client with new cookies.
+//
+// Fence returns handles to the locks taken. The new locks have the same
+// names, types, tags and descriptions as the broken ones, and never
+// expire. If taking over a lock fails (e.g., because another client took
+// it first), Fence stops and returns the handles of the locks taken so
+// far along with the error. The blocklist entry expires after the default
+// duration of the cluster (see BlocklistAdd()).
+func (c *Context) Fence(oldClientAddr string, lockedObjects []string) ([]*LockHandle, error) {
+    if err := c.rados.BlocklistAdd(oldClientAddr, 0); err != nil {
+        return nil, fmt.Errorf("RADOS fence %s: %w", oldClientAddr, err)
+    }
+
+    if err := c.rados.WaitForLatestOSDMap(); err != nil {
+        return nil, fmt.Errorf("RADOS fence %s: %w", oldClientAddr, err)
+    }
+
+    old := []BlocklistEntry{{Addr: oldClientAddr}}
+    var handles []*LockHandle
+
+    for _, name := range lockedObjects {
+        locks, err := c.objectLocks(name)
+        if err != nil {
+            return handles, err
+        }
+
+        for _, report := range locks {
+            if !blocklisted(old, report.Locker.Addr) {
+                continue
+            }
+
+            handle, err := c.takeOverLock(report)
+            if err != nil {
+                return handles, fmt.Errorf("RADOS fence %s: %w", oldClientAddr, err)
+            }
+            handles = append(handles, handle)
+        }
+    }
+
+    return handles, nil
+}
+
+// takeOverLock is a utility function that breaks the lock described by
+// report, and takes it again with a new cookie.
+func (c *Context) takeOverLock(report LockReport) (*LockHandle, error) {
+    opts := &LockOptions{Cookie: newLockCookie(), Description: report.Description}
+
+    if !report.Exclusive {
+        info, err := c.ListLockers(report.Object, report.Lock)
+        if err != nil {
+            return nil, err
+        }
+        opts.Tag = info.Tag
+    }
+
+    err := c.BreakLock(report.Object, report.Lock, report.Locker.Client, report.Locker.Cookie)
+    if err != nil {
+        return nil, err
+    }
+
+    if report.Exclusive {
+        err = c.LockExclusive(report.Object, report.Lock, opts)
+    } else {
+        err = c.LockShared(report.Object, report.Lock, opts)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    return &LockHandle{
+        c:         c,
+        Object:    report.Object,
+        Lock:      report.Lock,
+        Cookie:    opts.Cookie,
+        Exclusive: report.Exclusive,
+    }, nil
+}
@@ -0,0 +1,54 @@
+package rados
+
+import (
+    "hash"
+    "io"
+)
+
+// hashChunkSize is the number of bytes read at a time when hashing an
+// object, unless a smaller maximum chunk size is set.
+const hashChunkSize = 4 << 20
+
+// Hash streams the named object in the pool referenced by the given
+// context through h, and returns the resulting sum. The object is read in
+// chunks, so objects of any size can be hashed without loading them in
+// memory. h is reset first, which allows it to be reused. If the object
+// is modified while it is being hashed, the sum covers a mix of old and
+// new data.
+func (c *Context) Hash(name string, h hash.Hash) ([]byte, error) {
+    return c.object(name).hash(h)
+}
+
+// hash is a utility function that streams the object through h in chunks
+// of at most its maximum chunk size.
+func (o *Object) hash(h hash.Hash) ([]byte, error) {
+    chunk := o.chunkSize()
+    if chunk <= 0 || chunk > hashChunkSize {
+        chunk = hashChunkSize
+    }
+
+    h.Reset()
+    buf := make([]byte, chunk)
+    var off int64
+
+    for {
+        n, err := o.read(buf, off, nil)
+        h.Write(buf[:n])
+        off += int64(n)
+
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, err
+        }
+    }
+
+    return h.Sum(nil), nil
+}
+
+// Hash wraps the Context-based Hash function for the given object. The
+// object is read in chunks of at most its maximum chunk size (see
+// SetMaxChunkSize()).
+func (o *Object) Hash(h hash.Hash) ([]byte, error) {
+    return o.hash(h)
+}
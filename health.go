@@ -0,0 +1,37 @@
+package rados
+
+import (
+    "context"
+    "fmt"
+)
+
+// Check verifies that the given RADOS cluster handle can still talk to
+// the cluster, by asking the monitors for their version, which is cheap
+// enough to wire into the readiness or liveness probes of a service. It
+// returns an error if the monitors fail to answer before ctx is done.
+//
+// librados calls cannot be interrupted, so a call that times out keeps
+// running in the background until librados gives up on it (see the
+// rados_mon_op_timeout configuration option).
+func (r *Rados) Check(ctx context.Context) error {
+    if r.rados == nil {
+        return closedError("check")
+    }
+
+    done := make(chan error, 1)
+    go func() {
+        done <- r.monCommandJSON(map[string]interface{}{
+            "prefix": "version",
+        }, nil)
+    }()
+
+    select {
+    case err := <-done:
+        if err != nil {
+            return fmt.Errorf("RADOS check: %w", err)
+        }
+        return nil
+    case <-ctx.Done():
+        return fmt.Errorf("RADOS check: %w", ctx.Err())
+    }
+}
@@ -0,0 +1,256 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "encoding/json"
+    "fmt"
+    "strings"
+)
+
+const (
+    // indexSeparator separates the indexed key from the object name in
+    // the omap keys of an index object.
+    indexSeparator = "\x01"
+
+    // indexXattrPrefix prefixes the extended attribute in which an
+    // indexed object records its keys in a given index.
+    indexXattrPrefix = "rados.go.index."
+)
+
+// Index is a secondary index over objects of a pool, such as an index of
+// objects by tag or by date. An index maps keys to object names, and is
+// stored in the omap of an index object, which makes finding the objects
+// with a given key (or key prefix) cheap even in very large pools.
+//
+// The keys of an object are set when writing it through the index (see
+// Operate()). They are recorded in an extended attribute of the object in
+// the same atomic operation as the write, and the index object is updated
+// around it: new entries are added before the write and stale ones removed
+// after it. RADOS cannot update two objects atomically, so if a client
+// crashes part way through, the index may return objects that no longer
+// have the key; Keys() returns the authoritative keys of an object.
+// Objects are never missing from the index for a key they have.
+type Index struct {
+    c    *Context
+    name string
+}
+
+// IndexEntry is an entry of an index: an object and one of its keys.
+type IndexEntry struct {
+    Key  string
+    Name string
+}
+
+// NewIndex returns the index stored in the named object in the pool
+// referenced by the given context. The index object is created when the
+// first entry is added.
+func (c *Context) NewIndex(name string) *Index {
+    return &Index{c: c, name: name}
+}
+
+// Put writes data to the named object like Context.Put() and sets its keys
+// in the index to keys.
+func (idx *Index) Put(name string, data []byte, keys []string) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.WriteFull(data)
+
+    return idx.Operate(name, op, keys)
+}
+
+// Operate performs the write operation on the named object like
+// Context.Operate() and sets its keys in the index to keys, replacing any
+// keys it had before. The keys are recorded on the object as part of the
+// operation, so the index is only changed if the operation succeeds.
+// Keys may not contain NUL or \x01 bytes.
+func (idx *Index) Operate(name string, op *WriteOp, keys []string) (err error) {
+    defer idx.c.audit("operate", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    for _, key := range keys {
+        if strings.ContainsAny(key, "\x00"+indexSeparator) {
+            return fmt.Errorf("RADOS index %s key %q: %w", idx.name, key, ErrInvalidName)
+        }
+    }
+
+    oldKeys, err := idx.Keys(name)
+    if err != nil {
+        return err
+    }
+
+    added, removed := keysDiff(oldKeys, keys)
+
+    // Index the object under its new keys first, so it can always be
+    // found under the keys it has.
+    if err = idx.update(name, added, nil); err != nil {
+        return err
+    }
+
+    value, err := json.Marshal(keys)
+    if err != nil {
+        return fmt.Errorf("RADOS index %s: %s", idx.name, err)
+    }
+
+    op.SetXattr(indexXattrPrefix+idx.name, value)
+
+    if cerr := idx.c.operate(name, op, nil); cerr < 0 {
+        // Best effort: don't leave the object indexed under keys it
+        // didn't get.
+        idx.update(name, nil, added)
+        return idx.c.writeError("operate", name, cerr)
+    }
+
+    return idx.update(name, nil, removed)
+}
+
+// Remove removes the named object like Context.Remove() along with its
+// entries in the index.
+func (idx *Index) Remove(name string) error {
+    keys, err := idx.Keys(name)
+    if err != nil {
+        return err
+    }
+
+    if err = idx.c.Remove(name); err != nil {
+        return err
+    }
+
+    return idx.update(name, nil, keys)
+}
+
+// Keys returns the keys of the named object in the index, as recorded on
+// the object itself.
+func (idx *Index) Keys(name string) ([]string, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    value, cerr := idx.c.getXattr(name, indexXattrPrefix+idx.name)
+
+    switch {
+    case cerr == -C.ENOENT || cerr == -C.ENODATA:
+        return nil, nil
+    case cerr < 0:
+        return nil, fmt.Errorf("RADOS index %s keys %s: %w", idx.name, name, radosErrno(cerr))
+    }
+
+    var keys []string
+    if err := json.Unmarshal(value, &keys); err != nil {
+        return nil, fmt.Errorf("RADOS index %s keys %s: %s", idx.name, name, err)
+    }
+
+    return keys, nil
+}
+
+// Lookup returns an iterator over the objects indexed under key.
+func (idx *Index) Lookup(key string) *IndexIterator {
+    return idx.Query(key + indexSeparator)
+}
+
+// Query returns an iterator over the index entries whose key starts with
+// prefix, in key order. An empty prefix matches all entries.
+func (idx *Index) Query(prefix string) *IndexIterator {
+    return &IndexIterator{iter: idx.c.OmapIter(idx.name, prefix)}
+}
+
+// update is a utility function that adds and removes entries of the named
+// object in the index.
+func (idx *Index) update(name string, add, remove []string) (err error) {
+    if len(add) == 0 && len(remove) == 0 {
+        return nil
+    }
+
+    defer idx.c.audit("index update", idx.name, &err)
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    entries := make(map[string][]byte, len(add))
+    for _, key := range add {
+        entries[key+indexSeparator+name] = nil
+    }
+    op.OmapSet(entries)
+
+    rmKeys := make([]string, len(remove))
+    for i, key := range remove {
+        rmKeys[i] = key + indexSeparator + name
+    }
+    op.OmapRmKeys(rmKeys)
+
+    if cerr := idx.c.operate(idx.name, op, nil); cerr < 0 {
+        return idx.c.writeError("index update", idx.name, cerr)
+    }
+
+    return nil
+}
+
+// keysDiff is a utility function that returns the keys in newKeys but not
+// in oldKeys, and the keys in oldKeys but not in newKeys.
+func keysDiff(oldKeys, newKeys []string) (added, removed []string) {
+    old := make(map[string]bool, len(oldKeys))
+    for _, key := range oldKeys {
+        old[key] = true
+    }
+
+    for _, key := range newKeys {
+        if !old[key] {
+            added = append(added, key)
+        }
+        delete(old, key)
+    }
+
+    for _, key := range oldKeys {
+        if old[key] {
+            removed = append(removed, key)
+            delete(old, key)
+        }
+    }
+
+    return added, removed
+}
+
+// IndexIterator iterates over the entries of an index in key order.
+//
+//     iter := idx.Lookup("red")
+//     for iter.Next() {
+//         fmt.Println(iter.Entry().Name)
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+type IndexIterator struct {
+    iter  *OmapIterator
+    entry IndexEntry
+}
+
+// Next advances the iterator to the next entry, which is then available
+// from Entry(). It returns false when there are no more entries or an
+// error occurred (see Err()).
+func (iter *IndexIterator) Next() bool {
+    if !iter.iter.Next() {
+        return false
+    }
+
+    key, name, _ := strings.Cut(iter.iter.Key(), indexSeparator)
+    iter.entry = IndexEntry{Key: key, Name: name}
+
+    return true
+}
+
+// Entry returns the entry the iterator is positioned at.
+func (iter *IndexIterator) Entry() IndexEntry {
+    return iter.entry
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iter *IndexIterator) Err() error {
+    return iter.iter.Err()
+}
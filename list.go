@@ -0,0 +1,253 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "time"
+    "unsafe"
+)
+
+// ErrEpochChanged is returned by listings started with
+// ListObjectsAtEpoch() when the OSD map changes while they run.
+var ErrEpochChanged = errors.New("RADOS OSD map changed during listing")
+
+// listBatchSize is the number of objects retrieved from RADOS at a time
+// while listing.
+const listBatchSize = 1000
+
+// ListEntry describes an object returned by an object listing.
+type ListEntry struct {
+    Name      string
+    Namespace string
+    Locator   string
+}
+
+// ListFilter is a filter evaluated by the OSDs during an object listing,
+// so that only matching objects are sent to the client. This makes it
+// practical to search very large pools for a few objects.
+type ListFilter struct {
+    buf []byte
+}
+
+// PlainFilter returns a listing filter that matches the objects whose
+// extended attribute xattr is equal to value.
+func PlainFilter(xattr string, value []byte) *ListFilter {
+    f := &ListFilter{}
+    f.encode([]byte("plain"))
+    f.encode([]byte("_" + xattr)) // User xattrs are stored with a _ prefix
+    f.encode(value)
+
+    return f
+}
+
+// ClassFilter returns a listing filter implemented by the filter name of
+// the object class class, which is passed the given parameters. The
+// parameters must be encoded the way the object class expects.
+func ClassFilter(class, name string, params []byte) *ListFilter {
+    f := &ListFilter{}
+    f.encode([]byte(class + "." + name))
+    f.buf = append(f.buf, params...)
+
+    return f
+}
+
+// encode appends data to the filter in the RADOS wire format for strings
+// (a little-endian 32-bit length followed by the data).
+func (f *ListFilter) encode(data []byte) {
+    f.buf = appendEncoded(f.buf, data)
+}
+
+// ObjectIterator iterates over the objects in a pool. Objects are retrieved
+// from RADOS in batches as the iteration proceeds.
+//
+//     iter, err := ctx.ListObjects()
+//     ...
+//     defer iter.Close()
+//     for iter.Next() {
+//         fmt.Println(iter.Entry().Name)
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+type ObjectIterator struct {
+    c      *Context
+    filter []byte
+    cursor C.rados_object_list_cursor
+    end    C.rados_object_list_cursor
+
+    entries []ListEntry
+    entry   ListEntry
+    err     error
+    done    bool
+
+    epoch uint32 // OSD map epoch the listing is pinned to, or 0
+}
+
+// ListObjects returns an iterator over all the objects in the pool
+// referenced by the given context. The iterator must be closed with
+// Close() when it is no longer needed.
+func (c *Context) ListObjects() (*ObjectIterator, error) {
+    return c.ListObjectsWithFilter(nil)
+}
+
+// ListObjectsWithFilter returns an iterator over the objects in the pool
+// referenced by the given context that match filter. The filter is
+// evaluated by the OSDs, so objects that don't match are never sent to
+// the client. A nil filter matches all objects.
+func (c *Context) ListObjectsWithFilter(filter *ListFilter) (*ObjectIterator, error) {
+    if c.closed() {
+        return nil, closedError("list objects")
+    }
+
+    iter := &ObjectIterator{
+        c:      c,
+        cursor: C.rados_object_list_begin(c.ctx),
+        end:    C.rados_object_list_end(c.ctx),
+    }
+
+    if filter != nil {
+        iter.filter = filter.buf
+    }
+
+    return iter, nil
+}
+
+// ListObjectsAtEpoch returns an iterator over all the objects in the pool
+// referenced by the given context like ListObjects(), pinned to the
+// current OSD map epoch (see Epoch()). Listings are not snapshots, but a
+// listing that runs entirely within one OSD map epoch saw a stable
+// placement of the objects. The epoch is checked after each batch of
+// objects, and the iteration stops with an error wrapping ErrEpochChanged
+// as soon as it changed, so audits can tell whether their scan was
+// consistent, and start it again if needed.
+func (c *Context) ListObjectsAtEpoch() (*ObjectIterator, error) {
+    epoch, err := c.rados.OSDMapEpoch()
+    if err != nil {
+        return nil, err
+    }
+
+    iter, err := c.ListObjects()
+    if err != nil {
+        return nil, err
+    }
+    iter.epoch = epoch
+
+    return iter, nil
+}
+
+// Epoch returns the OSD map epoch the iterator is pinned to, or 0 if it
+// was not started with ListObjectsAtEpoch().
+func (iter *ObjectIterator) Epoch() uint32 {
+    return iter.epoch
+}
+
+// Next advances the iterator to the next object, which is then available
+// from Entry(). It returns false when there are no more objects or an
+// error occurred (see Err()).
+func (iter *ObjectIterator) Next() bool {
+    for len(iter.entries) == 0 {
+        if iter.done || iter.err != nil {
+            return false
+        }
+
+        iter.err = iter.fetch()
+    }
+
+    iter.entry = iter.entries[0]
+    iter.entries = iter.entries[1:]
+
+    return true
+}
+
+// Entry returns the object the iterator is positioned at.
+func (iter *ObjectIterator) Entry() ListEntry {
+    return iter.entry
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iter *ObjectIterator) Err() error {
+    return iter.err
+}
+
+// Close frees the resources held by the iterator.
+func (iter *ObjectIterator) Close() error {
+    if iter.cursor != nil && !iter.c.closed() {
+        C.rados_object_list_cursor_free(iter.c.ctx, iter.cursor)
+        C.rados_object_list_cursor_free(iter.c.ctx, iter.end)
+        iter.cursor, iter.end = nil, nil
+    }
+
+    iter.done = true
+
+    return nil
+}
+
+// fetch is a utility function that retrieves the next batch of objects
+// from RADOS.
+func (iter *ObjectIterator) fetch() error {
+    if iter.c.closed() {
+        return closedError("list objects")
+    }
+
+    if iter.cursor == nil || C.rados_object_list_is_end(iter.c.ctx, iter.cursor) != 0 {
+        iter.done = true
+        return nil
+    }
+
+    cresults := (*C.rados_object_list_item)(C.malloc(C.size_t(listBatchSize) *
+        C.size_t(unsafe.Sizeof(C.rados_object_list_item{}))))
+    defer C.free(unsafe.Pointer(cresults))
+
+    var cfilter *C.char
+    if len(iter.filter) > 0 {
+        cfilter = (*C.char)(C.CBytes(iter.filter))
+        defer C.free(unsafe.Pointer(cfilter))
+    }
+
+    var cnext C.rados_object_list_cursor
+
+    start := time.Now()
+    cerr := iter.c.call(opList, "rados_object_list", "", func() C.int {
+        return C.rados_object_list(iter.c.ctx, iter.cursor, iter.end, listBatchSize,
+            cfilter, C.size_t(len(iter.filter)), cresults, &cnext)
+    })
+    iter.c.record(opList, "", start, cerr, 0)
+
+    if cerr < 0 {
+        return fmt.Errorf("RADOS list objects: %w", radosErrno(cerr))
+    }
+
+    results := unsafe.Slice(cresults, int(cerr))
+    for _, item := range results {
+        iter.entries = append(iter.entries, ListEntry{
+            Name:      C.GoStringN(item.oid, C.int(item.oid_length)),
+            Namespace: C.GoStringN(item.nspace, C.int(item.nspace_length)),
+            Locator:   C.GoStringN(item.locator, C.int(item.locator_length)),
+        })
+    }
+    C.rados_object_list_free(C.size_t(cerr), cresults)
+
+    C.rados_object_list_cursor_free(iter.c.ctx, iter.cursor)
+    iter.cursor = cnext
+
+    if iter.epoch != 0 {
+        epoch, err := iter.c.rados.OSDMapEpoch()
+        if err != nil {
+            return err
+        }
+
+        if epoch != iter.epoch {
+            iter.entries = nil
+            return fmt.Errorf("RADOS list objects: epoch %d, now %d: %w", iter.epoch, epoch, ErrEpochChanged)
+        }
+    }
+
+    return nil
+}
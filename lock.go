@@ -0,0 +1,315 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "time"
+    "unsafe"
+)
+
+// ErrLocked is returned when a lock cannot be taken because another client
+// holds it.
+var ErrLocked = errors.New("RADOS object locked")
+
+// LockFlags modify how a lock is taken.
+type LockFlags uint8
+
+const (
+    // LockMayRenew lets a holder take a lock it already holds again,
+    // which renews its duration, instead of failing.
+    LockMayRenew LockFlags = C.LIBRADOS_LOCK_FLAG_MAY_RENEW
+
+    // LockMustRenew only renews a lock already held by the caller, and
+    // fails if the lock is not held (e.g., because it expired and was
+    // taken over by someone else).
+    LockMustRenew LockFlags = C.LIBRADOS_LOCK_FLAG_MUST_RENEW
+)
+
+// LockOptions are the settings of a lock taken on an object. Locks are
+// identified by their name on the object, and each holder by its client
+// and cookie.
+type LockOptions struct {
+    // Cookie distinguishes holders within the same client. It must be
+    // passed to Unlock() to release the lock.
+    Cookie string
+
+    // Description is a human-readable note shown to other clients
+    // inspecting the lock (e.g., with "rados lock info").
+    Description string
+
+    // Tag must be the same for all holders of a shared lock. It is
+    // ignored for exclusive locks.
+    Tag string
+
+    // Duration after which the lock expires unless renewed. A duration
+    // of 0 means the lock never expires.
+    Duration time.Duration
+
+    Flags LockFlags
+}
+
+// Locker describes a holder of a lock.
+type Locker struct {
+    Client string // Client name (e.g., client.4123)
+    Cookie string
+    Addr   string // Network address of the client
+}
+
+// LockInfo describes a lock on an object and its holders.
+type LockInfo struct {
+    Exclusive bool
+    Tag       string
+    Lockers   []Locker
+}
+
+// LockExclusive takes the exclusive lock lock on the named object in the
+// pool referenced by the given context, creating the object if needed. It
+// fails with an error wrapping ErrLocked if another holder has the lock.
+// Locks are advisory: they only exclude clients that take them too.
+func (c *Context) LockExclusive(name, lock string, opts *LockOptions) (err error) {
+    defer c.audit("lock", name, &err)
+
+    return c.lock(name, lock, true, opts)
+}
+
+// LockShared takes the shared lock lock on the named object in the pool
+// referenced by the given context like LockExclusive(), except that any
+// number of holders using the same tag can hold it at the same time.
+func (c *Context) LockShared(name, lock string, opts *LockOptions) (err error) {
+    defer c.audit("lock", name, &err)
+
+    return c.lock(name, lock, false, opts)
+}
+
+// lock is a utility function that takes an exclusive or shared lock.
+func (c *Context) lock(name, lock string, exclusive bool, opts *LockOptions) error {
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    if opts == nil {
+        opts = &LockOptions{}
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    clock := C.CString(lock)
+    defer C.free(unsafe.Pointer(clock))
+    ccookie := C.CString(opts.Cookie)
+    defer C.free(unsafe.Pointer(ccookie))
+    cdesc := C.CString(opts.Description)
+    defer C.free(unsafe.Pointer(cdesc))
+
+    // A nil duration makes the lock permanent
+    var cduration *C.struct_timeval
+    if opts.Duration > 0 {
+        cduration = &C.struct_timeval{
+            tv_sec:  C.time_t(opts.Duration / time.Second),
+            tv_usec: C.suseconds_t(opts.Duration % time.Second / time.Microsecond),
+        }
+    }
+
+    var cerr C.int
+    start := time.Now()
+
+    if exclusive {
+        cerr = c.call(opOther, "rados_lock_exclusive", name, func() C.int {
+            return C.rados_lock_exclusive(c.ctx, cname, clock, ccookie, cdesc, cduration, C.uint8_t(opts.Flags))
+        })
+    } else {
+        ctag := C.CString(opts.Tag)
+        defer C.free(unsafe.Pointer(ctag))
+
+        cerr = c.call(opOther, "rados_lock_shared", name, func() C.int {
+            return C.rados_lock_shared(c.ctx, cname, clock, ccookie, ctag, cdesc, cduration, C.uint8_t(opts.Flags))
+        })
+    }
+    c.record(opOther, name, start, cerr, 0)
+
+    switch {
+    case cerr == -C.EBUSY:
+        return fmt.Errorf("RADOS lock %s %s: %w (%w)", name, lock, ErrLocked, radosErrno(cerr))
+    case cerr < 0:
+        return fmt.Errorf("RADOS lock %s %s: %w", name, lock, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// Unlock releases the lock lock on the named object in the pool referenced
+// by the given context, held by the calling client with the given cookie.
+func (c *Context) Unlock(name, lock, cookie string) (err error) {
+    defer c.audit("unlock", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    clock := C.CString(lock)
+    defer C.free(unsafe.Pointer(clock))
+    ccookie := C.CString(cookie)
+    defer C.free(unsafe.Pointer(ccookie))
+
+    start := time.Now()
+    cerr := c.call(opOther, "rados_unlock", name, func() C.int {
+        return C.rados_unlock(c.ctx, cname, clock, ccookie)
+    })
+    c.record(opOther, name, start, cerr, 0)
+
+    if cerr < 0 {
+        return fmt.Errorf("RADOS unlock %s %s: %w", name, lock, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// BreakLock releases the lock lock on the named object in the pool
+// referenced by the given context, held by another client with the given
+// cookie (see ListLockers()). The other client is not told that it lost
+// the lock, so it should be fenced off first (e.g., by blocklisting it).
+func (c *Context) BreakLock(name, lock, client, cookie string) (err error) {
+    defer c.audit("break lock", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    clock := C.CString(lock)
+    defer C.free(unsafe.Pointer(clock))
+    cclient := C.CString(client)
+    defer C.free(unsafe.Pointer(cclient))
+    ccookie := C.CString(cookie)
+    defer C.free(unsafe.Pointer(ccookie))
+
+    start := time.Now()
+    cerr := c.call(opOther, "rados_break_lock", name, func() C.int {
+        return C.rados_break_lock(c.ctx, cname, clock, cclient, ccookie)
+    })
+    c.record(opOther, name, start, cerr, 0)
+
+    if cerr < 0 {
+        return fmt.Errorf("RADOS break lock %s %s: %w", name, lock, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// ListLockers returns the lock lock on the named object in the pool
+// referenced by the given context and its holders. A lock with no holders
+// has an empty list of lockers.
+func (c *Context) ListLockers(name, lock string) (*LockInfo, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    clock := C.CString(lock)
+    defer C.free(unsafe.Pointer(clock))
+
+    var tag, clients, cookies, addrs []byte
+    bufSize := 256 // Initial guess at amount of space we need
+
+    // rados_list_lockers() fails with ERANGE if the lockers don't fit in
+    // our buffers, in which case we retry with bigger ones.
+    for {
+        tag = make([]byte, bufSize)
+        clients = make([]byte, bufSize)
+        cookies = make([]byte, bufSize)
+        addrs = make([]byte, bufSize)
+
+        ctag, ctaglen := byteSliceToBuffer(tag)
+        cclients, cclientslen := byteSliceToBuffer(clients)
+        ccookies, ccookieslen := byteSliceToBuffer(cookies)
+        caddrs, caddrslen := byteSliceToBuffer(addrs)
+
+        var cexclusive C.int
+
+        start := time.Now()
+        cerr := c.call(opOther, "rados_list_lockers", name, func() C.int {
+            return C.int(C.rados_list_lockers(c.ctx, cname, clock, &cexclusive, ctag, &ctaglen,
+                cclients, &cclientslen, ccookies, &ccookieslen, caddrs, &caddrslen))
+        })
+        c.record(opOther, name, start, cerr, 0)
+
+        if cerr == -C.ERANGE {
+            bufSize *= 2
+            continue
+        } else if cerr < 0 {
+            return nil, fmt.Errorf("RADOS list lockers %s %s: %w", name, lock, radosErrno(C.int(cerr)))
+        }
+
+        info := &LockInfo{
+            Exclusive: cexclusive != 0,
+            Tag:       C.GoString(ctag),
+            Lockers:   make([]Locker, 0, int(cerr)),
+        }
+
+        // The clients, cookies and addresses are returned as strings
+        // separated by NUL bytes.
+        clientList := bytes.Split(clients[:cclientslen], []byte{0})
+        cookieList := bytes.Split(cookies[:ccookieslen], []byte{0})
+        addrList := bytes.Split(addrs[:caddrslen], []byte{0})
+
+        for i := 0; i < int(cerr) && i < len(clientList) && i < len(cookieList) && i < len(addrList); i++ {
+            info.Lockers = append(info.Lockers, Locker{
+                Client: string(clientList[i]),
+                Cookie: string(cookieList[i]),
+                Addr:   string(addrList[i]),
+            })
+        }
+
+        return info, nil
+    }
+}
+
+// newLockCookie is a utility function that returns a lock cookie unique
+// to the calling process and call.
+func newLockCookie() string {
+    hostname, _ := os.Hostname()
+
+    return fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+}
+
+// LockExclusive wraps the Context-based LockExclusive function for the
+// given object.
+func (o *Object) LockExclusive(lock string, opts *LockOptions) error {
+    return o.c.LockExclusive(o.name, lock, opts)
+}
+
+// LockShared wraps the Context-based LockShared function for the given
+// object.
+func (o *Object) LockShared(lock string, opts *LockOptions) error {
+    return o.c.LockShared(o.name, lock, opts)
+}
+
+// Unlock wraps the Context-based Unlock function for the given object.
+func (o *Object) Unlock(lock, cookie string) error {
+    return o.c.Unlock(o.name, lock, cookie)
+}
+
+// BreakLock wraps the Context-based BreakLock function for the given
+// object.
+func (o *Object) BreakLock(lock, client, cookie string) error {
+    return o.c.BreakLock(o.name, lock, client, cookie)
+}
+
+// ListLockers wraps the Context-based ListLockers function for the given
+// object.
+func (o *Object) ListLockers(lock string) (*LockInfo, error) {
+    return o.c.ListLockers(o.name, lock)
+}
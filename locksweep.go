@@ -0,0 +1,287 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// LockReport describes a lock held on an object, as found by SweepLocks().
+type LockReport struct {
+    Object      string
+    Namespace   string
+    Lock        string
+    Exclusive   bool
+    Locker      Locker
+    Description string
+    Expiration  time.Time // Zero for locks that never expire
+
+    // Stale is true if the holder can no longer use the lock because its
+    // client is blocklisted.
+    Stale bool
+
+    // Gone is true if the client of the holder has no watch on the object
+    // (see SweepOptions.Watched).
+    Gone bool
+
+    // Broken is true if SweepLocks() broke the lock.
+    Broken bool
+}
+
+// SweepOptions configure a lock sweep (see SweepLocks()).
+type SweepOptions struct {
+    // Prefix restricts the sweep to the objects whose names start with
+    // it.
+    Prefix string
+
+    // BreakStale breaks the locks whose holding client is blocklisted.
+    BreakStale bool
+
+    // Watched tells that the clients of the application watch the
+    // objects they lock for as long as they hold the lock (as RBD does
+    // with its image headers), so that a holder without a watch on the
+    // object is gone: the locks of such holders are flagged as gone, and
+    // broken if BreakGone is true. The watches expire some time after the
+    // client dies (see Watch()).
+    Watched   bool
+    BreakGone bool
+
+    // Concurrency is the number of objects in flight (1 if 0).
+    Concurrency int
+}
+
+// SweepLocks scans the objects of the pool referenced by the given context
+// whose names start with opts.Prefix, and returns a report of all the locks
+// held on them. Locks whose holding client is blocklisted are flagged as
+// stale, and, for applications whose clients watch the objects they lock,
+// locks whose holding client has no watch on the object are flagged as
+// gone; SweepLocks breaks them as requested by opts, so that the objects
+// they protect can be taken over. This lets operators clean up after
+// crashed clients that left locks behind. Expired locks are dropped by the
+// OSDs, so they are not reported. Only the objects in the namespace of the
+// context are swept, unless it is AllNamespaces.
+//
+// SweepLocks queries every object matching the prefix, so it can take a
+// long time on large pools. If querying or breaking the locks of some
+// objects fails, SweepLocks returns the reports of the others along with
+// one of the errors.
+func (c *Context) SweepLocks(opts *SweepOptions) ([]LockReport, error) {
+    if opts == nil {
+        opts = &SweepOptions{}
+    }
+
+    blocklist, err := c.rados.Blocklist()
+    if err != nil {
+        return nil, err
+    }
+
+    var reports []LockReport
+    var mutex sync.Mutex
+
+    errs, err := c.scanObjects(opts.Prefix, opts.Concurrency, func(ctx *Context, entry ListEntry) error {
+        locks, err := ctx.objectLocks(entry.Name)
+        if err != nil || len(locks) == 0 {
+            return err
+        }
+
+        var watchers []Watcher
+        if opts.Watched {
+            if watchers, err = ctx.ListWatchers(entry.Name); errors.Is(err, syscall.ENOENT) {
+                // The object was removed since its locks were listed
+                return nil
+            } else if err != nil {
+                return err
+            }
+        }
+
+        for _, report := range locks {
+            report.Namespace = entry.Namespace
+            report.Stale = blocklisted(blocklist, report.Locker.Addr)
+            report.Gone = opts.Watched && !watching(watchers, report.Locker.Client)
+
+            if (report.Stale && opts.BreakStale) || (report.Gone && opts.BreakGone) {
+                err = ctx.BreakLock(report.Object, report.Lock, report.Locker.Client, report.Locker.Cookie)
+                if err != nil {
+                    return err
+                }
+                report.Broken = true
+            }
+
+            mutex.Lock()
+            reports = append(reports, report)
+            mutex.Unlock()
+        }
+
+        return nil
+    })
+    if err != nil {
+        return reports, err
+    }
+
+    for _, err := range errs {
+        return reports, err
+    }
+
+    return reports, nil
+}
+
+// watching is a utility function that reports whether the named client
+// (e.g., client.4123) has one of the given watches.
+func watching(watchers []Watcher, client string) bool {
+    for _, watcher := range watchers {
+        if fmt.Sprintf("client.%d", watcher.ClientID) == client {
+            return true
+        }
+    }
+
+    return false
+}
+
+// objectLocks is a utility function that returns a report, without
+// staleness information, of all the locks held on the named object.
+func (c *Context) objectLocks(name string) ([]LockReport, error) {
+    out, cerr := c.exec(name, "lock", "list_locks", nil)
+
+    switch {
+    case cerr == -C.ENOENT:
+        // The object was removed since it was listed
+        return nil, nil
+    case cerr < 0:
+        return nil, fmt.Errorf("RADOS list locks %s: %w", name, radosErrno(cerr))
+    }
+
+    // The output is a cls_lock_list_locks_reply structure holding the
+    // lock names.
+    d := &decoder{buf: out}
+    body := d.structBody()
+    n := body.uint32()
+
+    var lockNames []string
+    for i := uint32(0); i < n && body.ok(); i++ {
+        lockNames = append(lockNames, string(body.bytes()))
+    }
+
+    if !body.ok() {
+        return nil, fmt.Errorf("RADOS list locks %s: invalid output", name)
+    }
+
+    var reports []LockReport
+
+    for _, lock := range lockNames {
+        info, err := c.ListLockers(name, lock)
+        if err != nil {
+            return nil, err
+        }
+
+        details, err := c.lockDetails(name, lock)
+        if err != nil {
+            return nil, err
+        }
+
+        for _, locker := range info.Lockers {
+            detail := details[locker.Client+"/"+locker.Cookie]
+
+            reports = append(reports, LockReport{
+                Object:      name,
+                Lock:        lock,
+                Exclusive:   info.Exclusive,
+                Locker:      locker,
+                Description: detail.description,
+                Expiration:  detail.expiration,
+            })
+        }
+    }
+
+    return reports, nil
+}
+
+// lockDetail holds the details of a lock holder that librados doesn't
+// report.
+type lockDetail struct {
+    description string
+    expiration  time.Time
+}
+
+// lockDetails is a utility function that returns the description and
+// expiration of every holder of the lock lock on the named object, keyed
+// by client name and cookie separated by a slash.
+func (c *Context) lockDetails(name, lock string) (map[string]lockDetail, error) {
+    in := appendStruct(nil, appendEncoded(nil, []byte(lock)))
+
+    out, cerr := c.exec(name, "lock", "get_info", in)
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS lock info %s %s: %w", name, lock, radosErrno(cerr))
+    }
+
+    // The output is a cls_lock_get_info_reply structure, which starts with
+    // a map of locker IDs (client name and cookie) to locker details
+    // (expiration, address and description).
+    details := make(map[string]lockDetail)
+
+    body := (&decoder{buf: out}).structBody()
+    n := body.uint32()
+
+    for i := uint32(0); i < n && body.ok(); i++ {
+        id := body.structBody()
+        entityType := id.uint8()
+        entityNum := int64(id.uint64())
+        cookie := string(id.bytes())
+
+        info := body.structBody()
+        sec := info.uint32()
+        nsec := info.uint32()
+
+        // Skip the client address, whose encoding depends on the
+        // features of the cluster.
+        if info.uint8() == 1 {
+            info.structBody()
+        } else {
+            info.take(135)
+        }
+
+        description := string(info.bytes())
+
+        if !id.ok() || !info.ok() {
+            return nil, fmt.Errorf("RADOS lock info %s %s: invalid output", name, lock)
+        }
+
+        detail := lockDetail{description: description}
+        if sec != 0 || nsec != 0 {
+            detail.expiration = time.Unix(int64(sec), int64(nsec))
+        }
+
+        details[fmt.Sprintf("%s.%d/%s", entityTypeName(entityType), entityNum, cookie)] = detail
+    }
+
+    if !body.ok() {
+        return nil, fmt.Errorf("RADOS lock info %s %s: invalid output", name, lock)
+    }
+
+    return details, nil
+}
+
+// entityTypeName is a utility function that returns the name of a RADOS
+// entity type, as used in entity names such as client.4123.
+func entityTypeName(entityType uint8) string {
+    switch entityType {
+    case 0x01:
+        return "mon"
+    case 0x02:
+        return "mds"
+    case 0x04:
+        return "osd"
+    case 0x08:
+        return "client"
+    case 0x10:
+        return "mgr"
+    }
+
+    return "unknown"
+}
@@ -0,0 +1,72 @@
+package rados
+
+import (
+    "fmt"
+    "sync"
+    "time"
+)
+
+// loop runs the goroutine of a background worker (e.g., a watcher or a
+// scheduler), which calls a function at regular intervals until the loop
+// is closed.
+type loop struct {
+    interval time.Duration
+    stop     chan struct{}
+    done     chan struct{}
+    closed   sync.Once
+}
+
+// newLoop is a utility function that returns a loop running every
+// interval, or an error for the given operation if interval is not
+// positive.
+func newLoop(op string, interval time.Duration) (*loop, error) {
+    if interval <= 0 {
+        return nil, fmt.Errorf("RADOS %s: invalid interval %s", op, interval)
+    }
+
+    return &loop{
+        interval: interval,
+        stop:     make(chan struct{}),
+        done:     make(chan struct{}),
+    }, nil
+}
+
+// start is a utility function that calls fn in a new goroutine right away,
+// and then every interval until the loop is closed. fn may select on the
+// stop channel of the loop to return early when it blocks.
+func (l *loop) start(fn func()) {
+    go func() {
+        defer close(l.done)
+
+        ticker := time.NewTicker(l.interval)
+        defer ticker.Stop()
+
+        for {
+            fn()
+
+            select {
+            case <-ticker.C:
+            case <-l.stop:
+                return
+            }
+        }
+    }()
+}
+
+// close is a utility function that stops the loop, waits for a running
+// call of its function to finish, and then calls release, if not nil.
+// Closing the loop again does nothing.
+func (l *loop) close(release func() error) error {
+    var err error
+
+    l.closed.Do(func() {
+        close(l.stop)
+        <-l.done
+
+        if release != nil {
+            err = release()
+        }
+    })
+
+    return err
+}
@@ -0,0 +1,171 @@
+package rados
+
+import (
+    "errors"
+    "fmt"
+    "syscall"
+)
+
+// MirrorPolicy decides how a MirroredContext handles mutations that
+// succeed on the primary but fail on the secondary.
+type MirrorPolicy int
+
+const (
+    // MirrorRequireBoth fails mutations that fail on the secondary. The
+    // mutation has been applied to the primary, so the two copies differ
+    // until it is retried.
+    MirrorRequireBoth MirrorPolicy = iota
+
+    // MirrorBestEffort ignores failures of the secondary, which are only
+    // reported to the error callback of the MirroredContext.
+    MirrorBestEffort
+)
+
+// MirroredContext applies every mutation to a primary and a secondary
+// context, which may reference pools of different clusters, for simple
+// synchronous redundancy. Mutations are applied to the primary first, and
+// only to the secondary if they succeeded on the primary. Reads are served
+// by the primary.
+//
+// Only the mutations made through the MirroredContext are mirrored, so
+// the secondary must not be written to otherwise.
+type MirroredContext struct {
+    Primary   *Context
+    Secondary *Context
+
+    policy  MirrorPolicy
+    onError func(op, name string, err error)
+}
+
+// NewMirroredContext returns a context mirroring the mutations made
+// through it from primary to secondary, handling failures of the
+// secondary according to policy. If onError is not nil, it is called with
+// the operation, object name and error for each failure of the secondary.
+// The contexts remain owned by the caller.
+func NewMirroredContext(primary, secondary *Context, policy MirrorPolicy,
+    onError func(op, name string, err error)) *MirroredContext {
+
+    return &MirroredContext{
+        Primary:   primary,
+        Secondary: secondary,
+        policy:    policy,
+        onError:   onError,
+    }
+}
+
+// mirror is a utility function that applies the mutation fn of the named
+// object to the primary, and then to the secondary.
+func (m *MirroredContext) mirror(op, name string, fn func(c *Context) error) error {
+    if err := fn(m.Primary); err != nil {
+        return err
+    }
+
+    err := fn(m.Secondary)
+    if err == nil {
+        return nil
+    }
+
+    if m.onError != nil {
+        m.onError(op, name, err)
+    }
+
+    if m.policy == MirrorBestEffort {
+        return nil
+    }
+
+    return fmt.Errorf("RADOS mirror %s %s: %w", op, name, err)
+}
+
+// Get reads all the data in the named object from the primary (see
+// Context.Get()).
+func (m *MirroredContext) Get(name string) ([]byte, error) {
+    return m.Primary.Get(name)
+}
+
+// Put writes data to the named object on both contexts (see
+// Context.Put()).
+func (m *MirroredContext) Put(name string, data []byte) error {
+    return m.mirror("put", name, func(c *Context) error {
+        return c.Put(name, data)
+    })
+}
+
+// WriteAt writes data to the named object at the byte offset off on both
+// contexts (see Object.WriteAt()).
+func (m *MirroredContext) WriteAt(name string, data []byte, off int64) error {
+    return m.mirror("write", name, func(c *Context) error {
+        _, err := c.object(name).WriteAt(data, off)
+        return err
+    })
+}
+
+// Append appends data to the named object on both contexts (see
+// Context.Append()).
+func (m *MirroredContext) Append(name string, data []byte) error {
+    return m.mirror("append", name, func(c *Context) error {
+        return c.Append(name, data)
+    })
+}
+
+// Truncate sets the size of the named object on both contexts (see
+// Context.Truncate()).
+func (m *MirroredContext) Truncate(name string, size int64) error {
+    return m.mirror("truncate", name, func(c *Context) error {
+        return c.Truncate(name, size)
+    })
+}
+
+// Remove removes the named object from both contexts (see
+// Context.Remove()). An object already missing from the secondary is not
+// a failure.
+func (m *MirroredContext) Remove(name string) error {
+    first := true
+
+    return m.mirror("remove", name, func(c *Context) error {
+        err := c.Remove(name)
+        if !first && errors.Is(err, syscall.ENOENT) {
+            err = nil
+        }
+        first = false
+
+        return err
+    })
+}
+
+// SetXattr sets the extended attribute xattr of the named object on both
+// contexts (see Context.SetXattr()).
+func (m *MirroredContext) SetXattr(name, xattr string, value []byte) error {
+    return m.mirror("set xattr", name, func(c *Context) error {
+        return c.SetXattr(name, xattr, value)
+    })
+}
+
+// OmapSet sets the given omap keys of the named object on both contexts.
+func (m *MirroredContext) OmapSet(name string, pairs map[string][]byte) error {
+    return m.Operate(name, func(op *WriteOp) {
+        op.OmapSet(pairs)
+    })
+}
+
+// OmapRmKeys removes the given omap keys of the named object on both
+// contexts.
+func (m *MirroredContext) OmapRmKeys(name string, keys []string) error {
+    return m.Operate(name, func(op *WriteOp) {
+        op.OmapRmKeys(keys)
+    })
+}
+
+// Operate performs a write operation on the named object on both contexts
+// (see Context.Operate()). A write operation can only be performed once,
+// so build is called to add the actions of the operation to a new
+// operation for each context.
+func (m *MirroredContext) Operate(name string, build func(op *WriteOp)) error {
+    return m.mirror("operate", name, func(c *Context) error {
+        op := NewWriteOp()
+        defer op.Release()
+
+        build(op)
+
+        return c.Operate(name, op)
+    })
+}
@@ -0,0 +1,188 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "encoding/json"
+    "fmt"
+    "unsafe"
+)
+
+// MonCommand sends the JSON-encoded command cmd to the monitors (for
+// example `{"prefix": "osd pool get-quota", "pool": "data"}`) and returns
+// the output of the command along with its status string.
+func (r *Rados) MonCommand(cmd []byte) ([]byte, string, error) {
+    return r.command("mon", "rados_mon_command", cmd, func(ccmd **C.char, coutbuf **C.char, coutbuflen *C.size_t, couts **C.char, coutslen *C.size_t) C.int {
+        return C.rados_mon_command(r.rados, ccmd, 1, nil, 0, coutbuf, coutbuflen, couts, coutslen)
+    })
+}
+
+// MgrCommand sends the JSON-encoded command cmd to the active manager
+// daemon (for example `{"prefix": "osd pool autoscale-status"}`) and
+// returns the output of the command along with its status string.
+func (r *Rados) MgrCommand(cmd []byte) ([]byte, string, error) {
+    return r.command("mgr", "rados_mgr_command", cmd, func(ccmd **C.char, coutbuf **C.char, coutbuflen *C.size_t, couts **C.char, coutslen *C.size_t) C.int {
+        return C.rados_mgr_command(r.rados, ccmd, 1, nil, 0, coutbuf, coutbuflen, couts, coutslen)
+    })
+}
+
+// command is a utility function that sends the JSON-encoded command cmd
+// with the given librados function, called by send, and returns the
+// output of the command along with its status string.
+func (r *Rados) command(kind, function string, cmd []byte, send func(ccmd **C.char, coutbuf **C.char, coutbuflen *C.size_t, couts **C.char, coutslen *C.size_t) C.int) ([]byte, string, error) {
+    if r.rados == nil {
+        return nil, "", closedError(kind + " command")
+    }
+
+    ccmd := C.CString(string(cmd))
+    defer C.free(unsafe.Pointer(ccmd))
+
+    var coutbuf, couts *C.char
+    var coutbuflen, coutslen C.size_t
+
+    cerr := r.call(function, "", func() C.int {
+        return send(&ccmd, &coutbuf, &coutbuflen, &couts, &coutslen)
+    })
+
+    out := C.GoBytes(unsafe.Pointer(coutbuf), C.int(coutbuflen))
+    status := C.GoStringN(couts, C.int(coutslen))
+    C.rados_buffer_free(coutbuf)
+    C.rados_buffer_free(couts)
+
+    if cerr < 0 {
+        return nil, status, fmt.Errorf("RADOS %s command: %w: %s", kind, radosErrno(cerr), status)
+    }
+
+    return out, status, nil
+}
+
+// monCommandJSON is a utility function that sends the given command to the
+// monitors with JSON output requested, and decodes the output into result.
+func (r *Rados) monCommandJSON(cmd map[string]interface{}, result interface{}) error {
+    return commandJSON("mon", r.MonCommand, cmd, result)
+}
+
+// mgrCommandJSON is a utility function that sends the given command to the
+// active manager with JSON output requested, and decodes the output into
+// result.
+func (r *Rados) mgrCommandJSON(cmd map[string]interface{}, result interface{}) error {
+    return commandJSON("mgr", r.MgrCommand, cmd, result)
+}
+
+// commandJSON is a utility function that sends the given command with the
+// send function with JSON output requested, and decodes the output into
+// result.
+func commandJSON(kind string, send func([]byte) ([]byte, string, error), cmd map[string]interface{}, result interface{}) error {
+    cmd["format"] = "json"
+
+    buf, err := json.Marshal(cmd)
+    if err != nil {
+        return fmt.Errorf("RADOS %s command: %s", kind, err)
+    }
+
+    out, _, err := send(buf)
+    if err != nil {
+        return err
+    }
+
+    if result == nil {
+        return nil
+    }
+
+    if err = json.Unmarshal(out, result); err != nil {
+        return fmt.Errorf("RADOS %s command %s: %s", kind, cmd["prefix"], err)
+    }
+
+    return nil
+}
+
+// poolSet is a utility function that sets the variable of the named pool
+// to value, like `ceph osd pool set`.
+func (r *Rados) poolSet(pool, variable, value string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd pool set",
+        "pool":   pool,
+        "var":    variable,
+        "val":    value,
+    }, nil)
+}
+
+// poolGet is a utility function that reads the variable of the named pool,
+// like `ceph osd pool get`, and decodes its value into result.
+func (r *Rados) poolGet(pool, variable string, result interface{}) error {
+    var values map[string]json.RawMessage
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd pool get",
+        "pool":   pool,
+        "var":    variable,
+    }, &values)
+    if err != nil {
+        return err
+    }
+
+    value, ok := values[variable]
+    if !ok {
+        return fmt.Errorf("RADOS pool get %s %s: not set", pool, variable)
+    }
+
+    if err = json.Unmarshal(value, result); err != nil {
+        return fmt.Errorf("RADOS pool get %s %s: %s", pool, variable, err)
+    }
+
+    return nil
+}
+
+// poolSettings is a utility function that sets the given variables of the
+// named pool in order, stopping at the first failure.
+func (r *Rados) poolSettings(pool string, settings [][2]string) error {
+    for _, setting := range settings {
+        if err := r.poolSet(pool, setting[0], setting[1]); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// OSDMapEpoch returns the current epoch of the OSD map of the cluster,
+// which changes whenever OSDs go up or down, or the placement of data
+// changes.
+func (r *Rados) OSDMapEpoch() (uint32, error) {
+    var stat struct {
+        Epoch uint32 `json:"epoch"`
+    }
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd stat",
+    }, &stat)
+    if err != nil {
+        return 0, err
+    }
+
+    return stat.Epoch, nil
+}
+
+// WaitForLatestOSDMap blocks until the given RADOS cluster handle has the
+// latest OSD map of the cluster. The operations sent afterwards make the
+// OSDs catch up with that map first, so they observe every change made
+// before the call (e.g., blocklisted clients).
+func (r *Rados) WaitForLatestOSDMap() error {
+    if r.rados == nil {
+        return closedError("wait for latest osdmap")
+    }
+
+    cerr := r.call("rados_wait_for_latest_osdmap", "", func() C.int {
+        return C.rados_wait_for_latest_osdmap(r.rados)
+    })
+    if cerr < 0 {
+        return fmt.Errorf("RADOS wait for latest osdmap: %w", radosErrno(cerr))
+    }
+
+    return nil
+}
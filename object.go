@@ -12,10 +12,15 @@ import (
     "fmt"
     "io"
     "os"
+    "syscall"
     "time"
     "unsafe"
 )
 
+// putFromChunkSize is the number of bytes read and written at a time by
+// PutFrom(), unless a smaller maximum chunk size is set.
+const putFromChunkSize = 4 << 20
+
 // sys contains underlying RADOS IO context and pool information for an object.
 // Needed for FileStat interface.
 type sys struct {
@@ -25,6 +30,9 @@ type sys struct {
 
 // Object represents an object in RADOS pool.
 //
+// Object names are arbitrary byte strings and need not be valid UTF-8, but
+// they may not contain NUL bytes (see ErrInvalidName).
+//
 // Note on RADOS IO context: Object stores its last-used context for
 // filesystem-like access, however advanced users may read/write using
 // a specific context.
@@ -33,6 +41,8 @@ type Object struct {
     size    int64
     modTime time.Time
 
+    maxChunkSize int
+
     sys
 }
 
@@ -114,13 +124,23 @@ func (c *Context) Open(name string) (*Object, error) {
 // by the given context. A pointer to the object is returned as an
 // os.FileInfo (fulfills FileStat interface).
 func (c *Context) Stat(name string) (os.FileInfo, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
     var csize C.uint64_t
     var ctime C.time_t
     cname := C.CString(name)
     defer C.free(unsafe.Pointer(cname))
 
-    if cerr := C.rados_stat(c.ctx, cname, &csize, &ctime); cerr < 0 {
-        return nil, fmt.Errorf("RADOS stat %s: %s", name, strerror(cerr))
+    start := time.Now()
+    cerr := c.call(opStat, "rados_stat", name, func() C.int {
+        return C.rados_stat(c.ctx, cname, &csize, &ctime)
+    })
+    c.record(opStat, name, start, cerr, 0)
+
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS stat %s: %w", name, radosErrno(cerr))
     }
 
     return &Object{
@@ -131,13 +151,67 @@ func (c *Context) Stat(name string) (os.FileInfo, error) {
     }, nil
 }
 
+// Touch creates the named object in the pool referenced by the given
+// context if it does not exist, and updates its modification time if it
+// does. The data of an existing object is left untouched, which makes
+// Touch useful for heartbeat and marker objects.
+func (c *Context) Touch(name string) (err error) {
+    defer c.audit("touch", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.Create(false)
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("touch", name, cerr)
+    }
+
+    return nil
+}
+
+// object is a utility function that returns a handle to the named object
+// in the pool referenced by the given context without retrieving its
+// information from RADOS.
+func (c *Context) object(name string) *Object {
+    return &Object{
+        name: name,
+        sys:  sys{c: c, pool: c.Pool},
+    }
+}
+
 // Remove deletes the named object in the pool referenced by the given context.
-func (c *Context) Remove(name string) error {
-    cname := C.CString(name)
-    defer C.free(unsafe.Pointer(cname))
+// If trash mode is enabled on the context (see SetTrash()), the object is
+// moved to the trash of the pool instead.
+func (c *Context) Remove(name string) (err error) {
+    defer c.audit("remove", name, &err)
 
-    if cerr := C.rados_remove(c.ctx, cname); cerr != 0 {
-        return fmt.Errorf("RADOS remove: %s: %s", name, strerror(cerr))
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    if c.trashRetention > 0 && c.namespace != trashNamespace {
+        _, err := c.moveToTrash(name, c.trashRetention)
+        return err
+    }
+
+    return c.remove(name)
+}
+
+// remove is a utility function that deletes the named object, bypassing
+// the trash.
+func (c *Context) remove(name string) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.Remove()
+
+    if cerr := c.operateKind(opRemove, name, op, nil); cerr != 0 {
+        return fmt.Errorf("RADOS remove: %s: %w", name, radosErrno(cerr))
     }
 
     return nil
@@ -147,12 +221,20 @@ func (c *Context) Remove(name string) error {
 // the given context to size. If this enlarges the object, the new area
 // is logically filled with zeroes. If this shrinks the object, the data
 // is removed.
-func (c *Context) Truncate(name string, size int64) error {
-    cname := C.CString(name)
-    defer C.free(unsafe.Pointer(cname))
+func (c *Context) Truncate(name string, size int64) (err error) {
+    defer c.audit("truncate", name, &err)
 
-    if cerr := C.rados_trunc(c.ctx, cname, C.uint64_t(size)); cerr != 0 {
-        return fmt.Errorf("RADOS trunc: %s: %s", name, strerror(cerr))
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.truncate(size)
+
+    if cerr := c.operate(name, op, nil); cerr != 0 {
+        return c.writeError("trunc", name, cerr)
     }
 
     return nil
@@ -160,21 +242,36 @@ func (c *Context) Truncate(name string, size int64) error {
 
 // Append writes the given data to the end of the named object
 // in the pool referenced by the given context.
-func (c *Context) Append(name string, data []byte) error {
-    cname := C.CString(name)
-    defer C.free(unsafe.Pointer(cname))
+func (c *Context) Append(name string, data []byte) (err error) {
+    defer c.audit("append", name, &err)
+
+    return c.appendObject(name, data)
+}
 
-    cdata, cdatalen := byteSliceToBuffer(data)
+// appendObject is a utility function that appends data to the named
+// object without auditing the call.
+func (c *Context) appendObject(name string, data []byte) error {
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
 
-    if cerr := C.rados_append(c.ctx, cname, cdata, cdatalen); cerr < 0 {
-        return fmt.Errorf("RADOS put %s: %s", name, strerror(cerr))
+    op.append(data)
+    op.setFlags(c.fadvise)
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("put", name, cerr)
     }
 
     return nil
 }
 
 // Get reads all the data in the named object in the pool referenced by
-// the given context. The data is returned as a byte slice.
+// the given context. The data is returned as a byte slice. Large objects
+// can be read with parallel range reads (see SetParallelGet()), and the
+// data of archived objects is read from the archive (see Archive()).
 //
 // If the object does not exist, an error is returned.
 // If the object contains no data, an empty slice is returned.
@@ -186,34 +283,253 @@ func (c *Context) Get(name string) ([]byte, error) {
     }
 
     if obj.Size() == 0 {
+        // Archive stubs are empty
+        if data, ok, err := c.getArchived(name); ok || err != nil {
+            return data, err
+        }
+
         // Return an empty slice
         return make([]byte, 0), nil
     }
 
-    cname := C.CString(name)
-    defer C.free(unsafe.Pointer(cname))
-
     data := make([]byte, obj.Size())
-    cdata, cdatalen := byteSliceToBuffer(data)
 
-    if cerr := C.rados_read(c.ctx, cname, cdata, cdatalen, 0); cerr < 0 {
-        return nil, fmt.Errorf("RADOS get %s: %s", name, strerror(cerr))
+    var n int
+    if c.parallelGetParallelism > 1 && obj.Size() >= c.parallelGetThreshold {
+        n, err = c.object(name).ParallelReadAt(data, 0, c.parallelGetParallelism)
+    } else {
+        n, err = c.object(name).ReadAt(data, 0)
+    }
+    if err != nil && err != io.EOF {
+        return nil, err
+    }
+
+    return data[:n], nil
+}
+
+// GetRange reads up to length bytes starting at the byte offset off from
+// the named object in the pool referenced by the given context. If the
+// range extends past the end of the object, only the data up to the end
+// of the object is returned.
+//
+// If the object does not exist, or the offset or length is negative, an
+// error is returned.
+func (c *Context) GetRange(name string, off, length int64) ([]byte, error) {
+    if off < 0 || length < 0 {
+        return nil, fmt.Errorf("RADOS get range %s: invalid range of %d bytes at %d: %w",
+            name, length, off, syscall.EINVAL)
+    }
+
+    obj, err := c.Stat(name)
+    if err != nil {
+        return nil, err
+    }
+
+    // Don't allocate more than the object holds
+    if off >= obj.Size() {
+        return make([]byte, 0), nil
+    }
+    if length > obj.Size()-off {
+        length = obj.Size() - off
     }
 
-    return data, nil
+    data := make([]byte, length)
+
+    n, err := c.object(name).ReadAt(data, off)
+    if err != nil && err != io.EOF {
+        return nil, err
+    }
+
+    return data[:n], nil
+}
+
+// NewSectionReader returns an io.SectionReader that reads from the named
+// object in the pool referenced by the given context starting at offset
+// off and stops with io.EOF after n bytes.
+func (c *Context) NewSectionReader(name string, off, n int64) *io.SectionReader {
+    return io.NewSectionReader(c.object(name), off, n)
 }
 
 // Put writes data to the named object in the pool referenced by the
 // given context. If the object does not exist, it will be created.
 // If the object exists, it will first be truncated to 0 then overwritten.
-func (c *Context) Put(name string, data []byte) error {
-    cname := C.CString(name)
-    defer C.free(unsafe.Pointer(cname))
+//
+// If the data is larger than the maximum chunk size of the context (see
+// SetMaxChunkSize()), it is written in several operations, and a failed
+// Put may leave the object with only part of the data.
+func (c *Context) Put(name string, data []byte) (err error) {
+    defer c.audit("put", name, &err)
 
-    cdata, cdatalen := byteSliceToBuffer(data)
+    if err := checkName(name); err != nil {
+        return err
+    }
 
-    if cerr := C.rados_write_full(c.ctx, cname, cdata, cdatalen); cerr < 0 {
-        return fmt.Errorf("RADOS put %s: %s", name, strerror(cerr))
+    first := data
+    if c.maxChunkSize > 0 && len(data) > c.maxChunkSize {
+        first = data[:c.maxChunkSize]
+    }
+
+    if cerr := c.writeFull(name, first); cerr < 0 {
+        return c.writeError("put", name, cerr)
+    }
+
+    progress := c.newTransfer(name, int64(len(data)))
+    progress.add(len(first))
+
+    if len(first) < len(data) {
+        if _, err := c.object(name).writeAt(data[len(first):], int64(len(first)), progress); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// writeFull is a utility function that replaces the data of the named
+// object with data in a single operation carrying the access hints of the
+// given context. Like rados_write_full(), it returns 0 or a negative
+// errno.
+func (c *Context) writeFull(name string, data []byte) C.int {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.WriteFull(data)
+    op.setFlags(c.fadvise)
+
+    return c.operate(name, op, nil)
+}
+
+// PutFrom writes the data read from r to the named object like Put(), but
+// streams it in chunks (of at most the maximum chunk size of the context,
+// and 4 MB), so payloads of any size can be written without holding them
+// in memory. If size is not negative, exactly size bytes are written, and
+// PutFrom fails with an error wrapping io.ErrUnexpectedEOF if r ends
+// before; otherwise, data is read from r until it returns io.EOF. Like a
+// chunked Put(), a failed PutFrom may leave the object with only part of
+// the data.
+func (c *Context) PutFrom(name string, r io.Reader, size int64) (err error) {
+    defer c.audit("put", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    chunk := c.maxChunkSize
+    if chunk <= 0 || chunk > putFromChunkSize {
+        chunk = putFromChunkSize
+    }
+    if size >= 0 && size < int64(chunk) {
+        chunk = int(size)
+    }
+
+    buf := make([]byte, chunk)
+    progress := c.newTransfer(name, size)
+    var off int64
+
+    for {
+        data := buf
+        if size >= 0 && size-off < int64(len(data)) {
+            data = data[:size-off]
+        }
+
+        n, rerr := io.ReadFull(r, data)
+        ended := rerr == io.EOF || rerr == io.ErrUnexpectedEOF
+
+        switch {
+        case rerr != nil && !ended:
+            return fmt.Errorf("RADOS put %s: %w", name, rerr)
+        case ended && size >= 0:
+            return fmt.Errorf("RADOS put %s: %d of %d bytes: %w", name, off+int64(n), size, io.ErrUnexpectedEOF)
+        case ended && n == 0 && off > 0:
+            return nil
+        }
+
+        // The first chunk replaces the data of the object, and creates it
+        // even if the stream is empty.
+        if off == 0 {
+            if cerr := c.writeFull(name, data[:n]); cerr < 0 {
+                return c.writeError("put", name, cerr)
+            }
+            progress.add(n)
+        } else if _, err := c.object(name).writeAt(data[:n], off, progress); err != nil {
+            return err
+        }
+
+        off += int64(n)
+
+        if ended || off == size {
+            return nil
+        }
+    }
+}
+
+// PutWithMtime writes data to the named object like Put(), but sets the
+// modification time of the object to mtime instead of the current time.
+// This lets migration and restore tools preserve original modification
+// times.
+func (c *Context) PutWithMtime(name string, data []byte, mtime time.Time) (err error) {
+    defer c.audit("put", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.WriteFull(data)
+
+    cmtime := C.time_t(mtime.Unix())
+
+    if cerr := c.operate(name, op, &cmtime); cerr < 0 {
+        return c.writeError("put", name, cerr)
+    }
+
+    return nil
+}
+
+// PutWithXattrs writes data to the named object like Put() and sets the
+// given extended attributes in the same atomic operation, so the object
+// is never visible with its data but without its metadata.
+func (c *Context) PutWithXattrs(name string, data []byte, xattrs map[string][]byte) (err error) {
+    defer c.audit("put", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.WriteFull(data)
+    for xattr, value := range xattrs {
+        op.SetXattr(xattr, value)
+    }
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("put", name, cerr)
+    }
+
+    return nil
+}
+
+// PutWithOmap writes data to the named object like Put() and sets the
+// given omap keys and values in the same atomic operation.
+func (c *Context) PutWithOmap(name string, data []byte, omap map[string][]byte) (err error) {
+    defer c.audit("put", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.WriteFull(data)
+    op.OmapSet(omap)
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("put", name, cerr)
     }
 
     return nil
@@ -235,17 +551,36 @@ func (o *Object) Stat() error {
     return nil
 }
 
+// Touch wraps the Context-based Touch function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) Touch() error {
+    return o.c.Touch(o.name)
+}
+
+// GetRange wraps the Context-based GetRange function for the given object.
+func (o *Object) GetRange(off, length int64) ([]byte, error) {
+    return o.c.GetRange(o.name, off, length)
+}
+
+// NewSectionReader wraps the Context-based NewSectionReader function for the given object.
+func (o *Object) NewSectionReader(off, n int64) *io.SectionReader {
+    return o.c.NewSectionReader(o.name, off, n)
+}
+
 // Remove wraps the Context-based Remove function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
 func (o *Object) Remove() error {
     return o.c.Remove(o.name)
 }
 
 // Truncate wraps the Context-based Truncate function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
 func (o *Object) Truncate(size int64) error {
     return o.c.Truncate(o.name, size)
 }
 
 // Append wraps the Context-based Append function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
 func (o *Object) Append(data []byte) error {
     return o.c.Append(o.name, data)
 }
@@ -256,10 +591,35 @@ func (o *Object) Get() ([]byte, error) {
 }
 
 // Put wraps the Context-based Put function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
 func (o *Object) Put(data []byte) error {
     return o.c.Put(o.name, data)
 }
 
+// PutWithMtime wraps the Context-based PutWithMtime function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) PutWithMtime(data []byte, mtime time.Time) error {
+    return o.c.PutWithMtime(o.name, data, mtime)
+}
+
+// PutWithXattrs wraps the Context-based PutWithXattrs function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) PutWithXattrs(data []byte, xattrs map[string][]byte) error {
+    return o.c.PutWithXattrs(o.name, data, xattrs)
+}
+
+// PutWithOmap wraps the Context-based PutWithOmap function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) PutWithOmap(data []byte, omap map[string][]byte) error {
+    return o.c.PutWithOmap(o.name, data, omap)
+}
+
+// PutFrom wraps the Context-based PutFrom function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) PutFrom(r io.Reader, size int64) error {
+    return o.c.PutFrom(o.name, r, size)
+}
+
 // ReadAt reads len(data) bytes from the given RADOS object at the byte
 // offset off. It returns the number of bytes read and the error, if any.
 // ReadAt always returns a non-nil error when n < len(data).
@@ -267,27 +627,74 @@ func (o *Object) Put(data []byte) error {
 //
 // This function adopted from the Go os.ReadAt() function.
 func (o *Object) ReadAt(data []byte, off int64) (n int, err error) {
+    if err := checkName(o.name); err != nil {
+        return 0, err
+    }
+
     cname := C.CString(o.name)
     defer C.free(unsafe.Pointer(cname))
 
+    return o.readAt(cname, data, off, o.c.newTransfer(o.name, int64(len(data))))
+}
+
+// read is a utility function that reads from the object like ReadAt(),
+// reporting progress to progress, which is nil for internal reads of
+// object data that are not transfers.
+func (o *Object) read(data []byte, off int64, progress *transfer) (n int, err error) {
+    if err := checkName(o.name); err != nil {
+        return 0, err
+    }
+
+    cname := C.CString(o.name)
+    defer C.free(unsafe.Pointer(cname))
+
+    return o.readAt(cname, data, off, progress)
+}
+
+// readAt is a utility function that reads len(data) bytes from the object
+// at the byte offset off, in chunks of at most the maximum chunk size,
+// reporting progress to progress. Reaching the end of the object ends the
+// transfer.
+func (o *Object) readAt(cname *C.char, data []byte, off int64, progress *transfer) (n int, err error) {
+    chunk := o.chunkSize()
+
     for len(data) > 0 {
-        cdata, cdatalen := byteSliceToBuffer(data)
+        size := len(data)
+        if chunk > 0 && size > chunk {
+            size = chunk
+        }
+
+        cdata, cdatalen := byteSliceToBuffer(data[:size])
         coff := C.uint64_t(off)
 
-        cerr := C.rados_read(o.c.ctx, cname, cdata, cdatalen, coff)
+        var cerr C.int
+
+        start := time.Now()
+        if o.c.useOps() {
+            cerr = o.c.call(opRead, "rados_read_op_operate", o.name, func() C.int {
+                return o.c.readOp(cname, data[:size], off, 0)
+            })
+        } else {
+            cerr = o.c.call(opRead, "rados_read", o.name, func() C.int {
+                return C.rados_read(o.c.ctx, cname, cdata, cdatalen, coff)
+            })
+        }
+        o.c.record(opRead, o.name, start, cerr, int(cerr))
 
         if cerr == 0 {
+            progress.finish()
             return n, io.EOF
         }
 
         if cerr < 0 {
-            err = fmt.Errorf("RADOS read %s: %s", o.name, strerror(cerr))
+            err = fmt.Errorf("RADOS read %s: %w", o.name, radosErrno(cerr))
             break
         }
 
         n += int(cerr)
         data = data[cerr:]
         off += int64(cerr)
+        progress.add(int(cerr))
     }
 
     return
@@ -295,30 +702,96 @@ func (o *Object) ReadAt(data []byte, off int64) (n int, err error) {
 
 // WriteAt writes len(data) bytes to the RADOS object at the byte offset
 // off. It returns the number of bytes written and an error, if any.
-// Write returns a non-nil error when n < len(data).
+// Write returns a non-nil error when n < len(data). WriteAt fails with
+// ErrImmutable if the object has been sealed.
 func (o *Object) WriteAt(data []byte, off int64) (n int, err error) {
-    cname := C.CString(o.name)
-    defer C.free(unsafe.Pointer(cname))
+    defer o.c.audit("write", o.name, &err)
+
+    if err := checkName(o.name); err != nil {
+        return 0, err
+    }
+
+    return o.writeAt(data, off, o.c.newTransfer(o.name, int64(len(data))))
+}
+
+// writeAt is a utility function that writes data to the object at the
+// byte offset off, in chunks of at most the maximum chunk size, reporting
+// progress to progress.
+func (o *Object) writeAt(data []byte, off int64, progress *transfer) (n int, err error) {
+    chunk := o.chunkSize()
 
     for len(data) > 0 {
-        cdata, cdatalen := byteSliceToBuffer(data)
-        coff := C.uint64_t(off)
+        size := len(data)
+        if chunk > 0 && size > chunk {
+            size = chunk
+        }
+
+        op := NewWriteOp()
+        op.Write(data[:size], off)
+        op.setFlags(o.c.fadvise)
 
-        cerr := C.rados_write(o.c.ctx, cname, cdata, cdatalen, coff)
+        cerr := o.c.operate(o.name, op, nil)
+        op.Release()
 
         if cerr < 0 {
-            err = fmt.Errorf("RADOS write %s: %s", o.name, strerror(cerr))
+            err = o.c.writeError("write", o.name, cerr)
             break
         }
 
-        n += int(cerr)
-        data = data[cerr:]
-        off += int64(cerr)
+        // rados_write() returns 0 once all the data has been written
+        n += size
+        data = data[size:]
+        off += int64(size)
+        progress.add(size)
     }
 
     return
 }
 
+// SetMaxChunkSize sets the maximum number of bytes transferred by a single
+// RADOS operation when reading or writing the object, overriding the
+// maximum chunk size of its context (see Context.SetMaxChunkSize()). A size
+// of 0 restores the context setting.
+func (o *Object) SetMaxChunkSize(size int) {
+    o.maxChunkSize = size
+}
+
+// chunkSize is a utility function that returns the maximum number of bytes
+// to transfer in a single operation on the object, or 0 for no limit.
+func (o *Object) chunkSize() int {
+    if o.maxChunkSize > 0 {
+        return o.maxChunkSize
+    }
+
+    return o.c.maxChunkSize
+}
+
+// WriteAtWithMtime writes len(data) bytes to the RADOS object at the byte
+// offset off like WriteAt(), but sets the modification time of the object
+// to mtime instead of the current time. The data is written in a single
+// operation, so either all of it is written or none of it is.
+// WriteAtWithMtime fails with ErrImmutable if the object has been sealed.
+func (o *Object) WriteAtWithMtime(data []byte, off int64, mtime time.Time) (n int, err error) {
+    defer o.c.audit("write", o.name, &err)
+
+    if err := checkName(o.name); err != nil {
+        return 0, err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.Write(data, off)
+
+    cmtime := C.time_t(mtime.Unix())
+
+    if cerr := o.c.operate(o.name, op, &cmtime); cerr < 0 {
+        return 0, o.c.writeError("write", o.name, cerr)
+    }
+
+    return len(data), nil
+}
+
 // TODO:
 // func (o *Object) WriteInContext(Context *c, ...)
 // func (o *Object) ReadInContext(Context *c, ...)
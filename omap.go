@@ -0,0 +1,251 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "fmt"
+    "time"
+    "unsafe"
+)
+
+// omapBatchSize is the number of omap keys retrieved from RADOS at a time
+// while iterating.
+const omapBatchSize = 1000
+
+// OmapEntry is an omap key and its value (see OmapAll()).
+type OmapEntry struct {
+    Key   string
+    Value []byte
+}
+
+// OmapGetValsByKeys returns the values of the given omap keys of the named
+// object in the pool referenced by the given context. Keys that are not
+// set are absent from the returned map.
+func (c *Context) OmapGetValsByKeys(name string, keys []string) (map[string][]byte, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    if len(keys) == 0 {
+        return map[string][]byte{}, nil
+    }
+
+    // librados copies the keys into the operation, so the C copies only
+    // need to live until rados_read_op_omap_get_vals_by_keys() returns.
+    ckeys := make([]*C.char, len(keys))
+    for i, key := range keys {
+        ckeys[i] = C.CString(key)
+        defer C.free(unsafe.Pointer(ckeys[i]))
+    }
+
+    entries, _, cerr := c.omapRead(name, func(op C.rados_read_op_t, iter *C.rados_omap_iter_t,
+        more *C.uchar, prval *C.int) {
+        C.rados_read_op_omap_get_vals_by_keys(op, &ckeys[0], C.size_t(len(keys)), iter, prval)
+    })
+
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS omap get %s: %w", name, radosErrno(cerr))
+    }
+
+    vals := make(map[string][]byte, len(entries))
+    for _, entry := range entries {
+        vals[entry.Key] = entry.Value
+    }
+
+    return vals, nil
+}
+
+// OmapIterator iterates over the omap keys of an object in key order.
+// Keys are retrieved from RADOS in batches as the iteration proceeds.
+//
+//     iter := ctx.OmapIter("index", "")
+//     for iter.Next() {
+//         fmt.Println(iter.Key(), iter.Value())
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+type OmapIterator struct {
+    c      *Context
+    name   string
+    prefix string
+
+    entries []OmapEntry
+    entry   OmapEntry
+    err     error
+    done    bool
+}
+
+// OmapIter returns an iterator over the omap keys of the named object in
+// the pool referenced by the given context that start with prefix. An
+// empty prefix matches all keys.
+func (c *Context) OmapIter(name, prefix string) *OmapIterator {
+    return &OmapIterator{c: c, name: name, prefix: prefix}
+}
+
+// OmapIterAfter returns an iterator over the omap keys of the named object
+// in the pool referenced by the given context that start with prefix, like
+// OmapIter(), beginning with the first key after start.
+func (c *Context) OmapIterAfter(name, start, prefix string) *OmapIterator {
+    return &OmapIterator{c: c, name: name, prefix: prefix, entry: OmapEntry{Key: start}}
+}
+
+// Next advances the iterator to the next key, which is then available
+// from Key() and Value(). It returns false when there are no more keys or
+// an error occurred (see Err()).
+func (iter *OmapIterator) Next() bool {
+    for len(iter.entries) == 0 {
+        if iter.done || iter.err != nil {
+            return false
+        }
+
+        iter.err = iter.fetch()
+    }
+
+    iter.entry = iter.entries[0]
+    iter.entries = iter.entries[1:]
+
+    return true
+}
+
+// Key returns the key the iterator is positioned at.
+func (iter *OmapIterator) Key() string {
+    return iter.entry.Key
+}
+
+// Value returns the value of the key the iterator is positioned at.
+func (iter *OmapIterator) Value() []byte {
+    return iter.entry.Value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iter *OmapIterator) Err() error {
+    return iter.err
+}
+
+// fetch is a utility function that retrieves the next batch of keys from
+// RADOS.
+func (iter *OmapIterator) fetch() error {
+    if err := checkName(iter.name); err != nil {
+        return err
+    }
+
+    cstart := C.CString(iter.entry.Key)
+    defer C.free(unsafe.Pointer(cstart))
+    cprefix := C.CString(iter.prefix)
+    defer C.free(unsafe.Pointer(cprefix))
+
+    entries, more, cerr := iter.c.omapRead(iter.name, func(op C.rados_read_op_t, citer *C.rados_omap_iter_t,
+        cmore *C.uchar, prval *C.int) {
+        C.rados_read_op_omap_get_vals2(op, cstart, cprefix, omapBatchSize, citer, cmore, prval)
+    })
+
+    if cerr < 0 {
+        return fmt.Errorf("RADOS omap list %s: %w", iter.name, radosErrno(cerr))
+    }
+
+    iter.entries = entries
+    iter.done = !more
+
+    return nil
+}
+
+// omapRead is a utility function that performs a read operation on the
+// named object, set up by prepare, which returns omap keys and values
+// through an iterator. It returns the keys and values in order, whether
+// more keys are available and the raw librados result.
+func (c *Context) omapRead(name string, prepare func(op C.rados_read_op_t, iter *C.rados_omap_iter_t,
+    more *C.uchar, prval *C.int)) ([]OmapEntry, bool, C.int) {
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    op := C.rados_create_read_op()
+    defer C.rados_release_read_op(op)
+
+    // The results of the omap read are stored when the operation is
+    // performed, so they must live in C memory.
+    citer := (*C.rados_omap_iter_t)(C.malloc(C.size_t(unsafe.Sizeof(C.rados_omap_iter_t(nil)))))
+    defer C.free(unsafe.Pointer(citer))
+    cmore := (*C.uchar)(C.malloc(C.size_t(unsafe.Sizeof(C.uchar(0)))))
+    defer C.free(unsafe.Pointer(cmore))
+    cprval := (*C.int)(C.malloc(C.size_t(unsafe.Sizeof(C.int(0)))))
+    defer C.free(unsafe.Pointer(cprval))
+
+    *citer = nil
+    *cmore = 0
+    *cprval = 0
+
+    prepare(op, citer, cmore, cprval)
+    defer func() {
+        if *citer != nil {
+            C.rados_omap_get_end(*citer)
+        }
+    }()
+
+    start := time.Now()
+    cerr := c.call(opRead, "rados_read_op_operate", name, func() C.int {
+        return C.rados_read_op_operate(op, c.ctx, cname, C.int(c.opFlags))
+    })
+    if cerr == 0 {
+        cerr = *cprval
+    }
+
+    var entries []OmapEntry
+    var n int
+
+    if cerr == 0 {
+        entries, n, cerr = omapEntries(*citer)
+    }
+    c.record(opRead, name, start, cerr, n)
+
+    return entries, *cmore != 0, cerr
+}
+
+// omapEntries is a utility function that collects the keys and values
+// returned by an omap iterator, along with the total size of the values.
+func omapEntries(iter C.rados_omap_iter_t) ([]OmapEntry, int, C.int) {
+    var entries []OmapEntry
+    n := 0
+
+    for {
+        var ckey, cval *C.char
+        var clen C.size_t
+
+        if cerr := C.rados_omap_get_next(iter, &ckey, &cval, &clen); cerr < 0 {
+            return nil, 0, cerr
+        }
+
+        if ckey == nil {
+            return entries, n, 0
+        }
+
+        entries = append(entries, OmapEntry{
+            Key:   C.GoString(ckey),
+            Value: C.GoBytes(unsafe.Pointer(cval), C.int(clen)),
+        })
+        n += int(clen)
+    }
+}
+
+// OmapGetValsByKeys wraps the Context-based OmapGetValsByKeys function for
+// the given object.
+func (o *Object) OmapGetValsByKeys(keys []string) (map[string][]byte, error) {
+    return o.c.OmapGetValsByKeys(o.name, keys)
+}
+
+// OmapIter wraps the Context-based OmapIter function for the given object.
+func (o *Object) OmapIter(prefix string) *OmapIterator {
+    return o.c.OmapIter(o.name, prefix)
+}
+
+// OmapIterAfter wraps the Context-based OmapIterAfter function for the
+// given object.
+func (o *Object) OmapIterAfter(start, prefix string) *OmapIterator {
+    return o.c.OmapIterAfter(o.name, start, prefix)
+}
@@ -0,0 +1,184 @@
+package omap
+
+import (
+    "bytes"
+    "encoding/gob"
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/mrkvm/rados.go"
+)
+
+// Codec converts the values of a Map to and from the bytes stored in the
+// omap.
+type Codec[T any] interface {
+    Encode(v T) ([]byte, error)
+    Decode(data []byte) (T, error)
+}
+
+// KeyCodec converts the keys of a Map to and from omap keys. The OSDs keep
+// omap keys in bytewise order, so the encoding must preserve the order of
+// the keys (i.e., a < b if and only if EncodeKey(a) < EncodeKey(b)) for
+// iterations to return the entries of a Map in key order. Omap keys cannot
+// contain NUL bytes.
+type KeyCodec[T any] interface {
+    EncodeKey(k T) (string, error)
+    DecodeKey(key string) (T, error)
+}
+
+// Bytes is the codec storing byte slices as is.
+type Bytes struct{}
+
+// Encode returns data.
+func (Bytes) Encode(data []byte) ([]byte, error) {
+    return data, nil
+}
+
+// Decode returns data.
+func (Bytes) Decode(data []byte) ([]byte, error) {
+    return data, nil
+}
+
+// String is the codec storing strings as is.
+type String struct{}
+
+// Encode returns the bytes of s.
+func (String) Encode(s string) ([]byte, error) {
+    return []byte(s), nil
+}
+
+// Decode returns data as a string.
+func (String) Decode(data []byte) (string, error) {
+    return string(data), nil
+}
+
+// JSON is the codec storing values of type T in JSON.
+type JSON[T any] struct{}
+
+// Encode returns the JSON encoding of v.
+func (JSON[T]) Encode(v T) ([]byte, error) {
+    return json.Marshal(v)
+}
+
+// Decode returns the value encoded in JSON in data.
+func (JSON[T]) Decode(data []byte) (T, error) {
+    var v T
+    err := json.Unmarshal(data, &v)
+
+    return v, err
+}
+
+// Gob is the codec storing values of type T with encoding/gob. Each value
+// is encoded on its own, so it carries its type information.
+type Gob[T any] struct{}
+
+// Encode returns the gob encoding of v.
+func (Gob[T]) Encode(v T) ([]byte, error) {
+    var buf bytes.Buffer
+    if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+        return nil, err
+    }
+
+    return buf.Bytes(), nil
+}
+
+// Decode returns the value gob-encoded in data.
+func (Gob[T]) Decode(data []byte) (T, error) {
+    var v T
+    err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+
+    return v, err
+}
+
+// StringKey is the key codec storing strings as is, which preserves their
+// order. Strings containing NUL bytes cannot be encoded.
+type StringKey struct{}
+
+// EncodeKey returns k, or an error wrapping rados.ErrInvalidName if it
+// contains a NUL byte.
+func (StringKey) EncodeKey(k string) (string, error) {
+    if strings.IndexByte(k, 0) >= 0 {
+        return "", fmt.Errorf("omap key %q: %w", k, rados.ErrInvalidName)
+    }
+
+    return k, nil
+}
+
+// DecodeKey returns key.
+func (StringKey) DecodeKey(key string) (string, error) {
+    return key, nil
+}
+
+// Uint64Key is the key codec storing unsigned integers as 16 hexadecimal
+// digits, which preserves their order.
+type Uint64Key struct{}
+
+// EncodeKey returns k as 16 hexadecimal digits.
+func (Uint64Key) EncodeKey(k uint64) (string, error) {
+    return fmt.Sprintf("%016x", k), nil
+}
+
+// DecodeKey returns the integer encoded in key.
+func (Uint64Key) DecodeKey(key string) (uint64, error) {
+    return decodeHex(key)
+}
+
+// Int64Key is the key codec storing signed integers as 16 hexadecimal
+// digits, offset so that negative integers sort before positive ones.
+type Int64Key struct{}
+
+// EncodeKey returns k as 16 hexadecimal digits.
+func (Int64Key) EncodeKey(k int64) (string, error) {
+    return fmt.Sprintf("%016x", uint64(k)^(1<<63)), nil
+}
+
+// DecodeKey returns the integer encoded in key.
+func (Int64Key) DecodeKey(key string) (int64, error) {
+    u, err := decodeHex(key)
+
+    return int64(u ^ (1 << 63)), err
+}
+
+// TimeKey is the key codec storing times as their number of nanoseconds
+// since the Unix epoch, like Int64Key, which preserves their order. Only
+// the times between the years 1678 and 2262 can be encoded, and the time
+// zone of the times is not preserved.
+type TimeKey struct{}
+
+// EncodeKey returns the encoding of t.
+func (TimeKey) EncodeKey(t time.Time) (string, error) {
+    ns := t.UnixNano()
+    if !time.Unix(0, ns).Equal(t) {
+        return "", fmt.Errorf("omap key %s: time out of range", t)
+    }
+
+    return Int64Key{}.EncodeKey(ns)
+}
+
+// DecodeKey returns the time encoded in key.
+func (TimeKey) DecodeKey(key string) (time.Time, error) {
+    ns, err := Int64Key{}.DecodeKey(key)
+    if err != nil {
+        return time.Time{}, err
+    }
+
+    return time.Unix(0, ns), nil
+}
+
+// decodeHex is a utility function that decodes the 16 hexadecimal digits
+// of an integer key.
+func decodeHex(key string) (uint64, error) {
+    if len(key) != 16 {
+        return 0, fmt.Errorf("omap key %q: not an integer key", key)
+    }
+
+    u, err := strconv.ParseUint(key, 16, 64)
+    if err != nil {
+        return 0, fmt.Errorf("omap key %q: not an integer key", key)
+    }
+
+    return u, nil
+}
@@ -0,0 +1,228 @@
+// Package omap stores Go values in the omap of RADOS objects, so that
+// structured indexes don't need hand-written encodings. A Map converts its
+// keys and values with pluggable codecs, and its keys are encoded so that
+// their order is preserved by the OSDs, which makes iterations return the
+// entries in key order:
+//
+//     users := omap.New[int64, User](ctx, "users", omap.Int64Key{}, omap.JSON[User]{})
+//     if err := users.Set(42, User{Name: "bob"}); err != nil {
+//         ...
+//     }
+//     for entry, err := range users.All() {
+//         if err != nil {
+//             ...
+//         }
+//         fmt.Println(entry.Key, entry.Value.Name)
+//     }
+package omap
+
+import (
+    "fmt"
+    "iter"
+
+    "github.com/mrkvm/rados.go"
+)
+
+// Map is a typed view of the omap of an object, which maps keys of type K
+// to values of type V. The object is created when the first entry is set.
+type Map[K comparable, V any] struct {
+    c      *rados.Context
+    name   string
+    keys   KeyCodec[K]
+    values Codec[V]
+}
+
+// New returns the map stored in the omap of the named object in the pool
+// referenced by ctx, with keys and values converted by the given codecs.
+func New[K comparable, V any](ctx *rados.Context, name string, keys KeyCodec[K], values Codec[V]) *Map[K, V] {
+    return &Map[K, V]{c: ctx, name: name, keys: keys, values: values}
+}
+
+// Get returns the value of the key k, and whether it is set.
+func (m *Map[K, V]) Get(k K) (V, bool, error) {
+    var v V
+
+    key, err := m.keys.EncodeKey(k)
+    if err != nil {
+        return v, false, err
+    }
+
+    vals, err := m.c.OmapGetValsByKeys(m.name, []string{key})
+    if err != nil {
+        return v, false, err
+    }
+
+    data, ok := vals[key]
+    if !ok {
+        return v, false, nil
+    }
+
+    if v, err = m.values.Decode(data); err != nil {
+        return v, false, fmt.Errorf("omap %s key %q: %w", m.name, key, err)
+    }
+
+    return v, true, nil
+}
+
+// Set sets the key k to the value v.
+func (m *Map[K, V]) Set(k K, v V) error {
+    return m.SetAll(map[K]V{k: v})
+}
+
+// SetAll sets the given keys to their values atomically.
+func (m *Map[K, V]) SetAll(entries map[K]V) error {
+    pairs := make(map[string][]byte, len(entries))
+
+    for k, v := range entries {
+        key, err := m.keys.EncodeKey(k)
+        if err != nil {
+            return err
+        }
+
+        data, err := m.values.Encode(v)
+        if err != nil {
+            return fmt.Errorf("omap %s key %q: %w", m.name, key, err)
+        }
+
+        pairs[key] = data
+    }
+
+    op := rados.NewWriteOp()
+    defer op.Release()
+    op.OmapSet(pairs)
+
+    return m.c.Operate(m.name, op)
+}
+
+// Delete removes the given keys atomically. Keys that are not set are
+// ignored.
+func (m *Map[K, V]) Delete(ks ...K) error {
+    keys := make([]string, len(ks))
+
+    for i, k := range ks {
+        key, err := m.keys.EncodeKey(k)
+        if err != nil {
+            return err
+        }
+        keys[i] = key
+    }
+
+    op := rados.NewWriteOp()
+    defer op.Release()
+    op.OmapRmKeys(keys)
+
+    return m.c.Operate(m.name, op)
+}
+
+// Iter returns an iterator over the entries of the map in key order.
+func (m *Map[K, V]) Iter() *Iterator[K, V] {
+    return &Iterator[K, V]{m: m, iter: m.c.OmapIter(m.name, "")}
+}
+
+// IterAfter returns an iterator over the entries of the map with a key
+// after k, in key order.
+func (m *Map[K, V]) IterAfter(k K) *Iterator[K, V] {
+    key, err := m.keys.EncodeKey(k)
+    if err != nil {
+        return &Iterator[K, V]{m: m, err: err}
+    }
+
+    return &Iterator[K, V]{m: m, iter: m.c.OmapIterAfter(m.name, key, "")}
+}
+
+// Entry is an entry of a Map (see Map.All()).
+type Entry[K comparable, V any] struct {
+    Key   K
+    Value V
+}
+
+// All returns an iterator over the entries of the map in key order, for
+// use in range loops, along with the error that stopped the iteration, if
+// any, which is yielded last with an empty entry.
+func (m *Map[K, V]) All() iter.Seq2[Entry[K, V], error] {
+    return func(yield func(Entry[K, V], error) bool) {
+        iter := m.Iter()
+
+        for iter.Next() {
+            if !yield(Entry[K, V]{Key: iter.Key(), Value: iter.Value()}, nil) {
+                return
+            }
+        }
+
+        if err := iter.Err(); err != nil {
+            yield(Entry[K, V]{}, err)
+        }
+    }
+}
+
+// Iterator iterates over the entries of a Map in key order.
+//
+//     iter := users.Iter()
+//     for iter.Next() {
+//         fmt.Println(iter.Key(), iter.Value())
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+type Iterator[K comparable, V any] struct {
+    m    *Map[K, V]
+    iter *rados.OmapIterator
+
+    key   K
+    value V
+    err   error
+}
+
+// Next advances the iterator to the next entry, which is then available
+// from Key() and Value(). It returns false when there are no more entries
+// or an error occurred (see Err()).
+func (iter *Iterator[K, V]) Next() bool {
+    if iter.err != nil || !iter.iter.Next() {
+        return false
+    }
+
+    key := iter.iter.Key()
+
+    if iter.key, iter.err = iter.m.keys.DecodeKey(key); iter.err != nil {
+        return false
+    }
+
+    if iter.value, iter.err = iter.m.values.Decode(iter.iter.Value()); iter.err != nil {
+        iter.err = fmt.Errorf("omap %s key %q: %w", iter.m.name, key, iter.err)
+        return false
+    }
+
+    return true
+}
+
+// Key returns the key of the entry the iterator is positioned at.
+func (iter *Iterator[K, V]) Key() K {
+    return iter.key
+}
+
+// Value returns the value of the entry the iterator is positioned at.
+func (iter *Iterator[K, V]) Value() V {
+    return iter.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iter *Iterator[K, V]) Err() error {
+    if iter.err != nil {
+        return iter.err
+    }
+
+    return iter.iter.Err()
+}
+
+// All returns an iterator over the remaining entries of the given
+// iterator, for use in range loops. Err() reports the error that stopped
+// the iteration, if any.
+func (iter *Iterator[K, V]) All() iter.Seq2[K, V] {
+    return func(yield func(K, V) bool) {
+        for iter.Next() {
+            if !yield(iter.Key(), iter.Value()) {
+                return
+            }
+        }
+    }
+}
@@ -0,0 +1,87 @@
+package omap
+
+import (
+    "sort"
+    "testing"
+    "time"
+
+    "github.com/mrkvm/rados.go/radostest"
+)
+
+func Test_KeyOrder(t *testing.T) {
+    ints := []int64{-1 << 63, -1000, -1, 0, 1, 255, 256, 1<<63 - 1}
+
+    var keys []string
+    for _, i := range ints {
+        key, err := Int64Key{}.EncodeKey(i)
+        if err != nil {
+            t.Fatalf("EncodeKey %d: %s", i, err)
+        }
+        keys = append(keys, key)
+
+        if d, err := (Int64Key{}).DecodeKey(key); err != nil || d != i {
+            t.Errorf("DecodeKey %s: got %d, %v, want %d", key, d, err, i)
+        }
+    }
+
+    if !sort.StringsAreSorted(keys) {
+        t.Errorf("Int64Key: keys out of order: %v", keys)
+    }
+
+    now := time.Now()
+    a, _ := TimeKey{}.EncodeKey(now)
+    b, _ := TimeKey{}.EncodeKey(now.Add(time.Nanosecond))
+    if a >= b {
+        t.Errorf("TimeKey: got %s >= %s", a, b)
+    }
+
+    if _, err := (StringKey{}).EncodeKey("a\x00b"); err == nil {
+        t.Errorf("StringKey: expected an error for a key with a NUL byte")
+    }
+}
+
+func Test_Map(t *testing.T) {
+    ctx := radostest.NewPool(t).Context(t)
+
+    type record struct {
+        Name string
+    }
+
+    m := New[int64, record](ctx, "map", Int64Key{}, JSON[record]{})
+
+    for _, i := range []int64{3, -2, 10, 1} {
+        if err := m.Set(i, record{Name: "r"}); err != nil {
+            t.Fatalf("Set %d: %s", i, err)
+        }
+    }
+
+    if err := m.Delete(10); err != nil {
+        t.Fatalf("Delete: %s", err)
+    }
+
+    if _, ok, err := m.Get(10); err != nil || ok {
+        t.Errorf("Get deleted key: got %v, %v", ok, err)
+    }
+    if v, ok, err := m.Get(3); err != nil || !ok || v.Name != "r" {
+        t.Errorf("Get: got %v, %v, %v", v, ok, err)
+    }
+
+    var got []int64
+    for entry, err := range m.All() {
+        if err != nil {
+            t.Fatalf("All: %s", err)
+        }
+        got = append(got, entry.Key)
+    }
+    if len(got) != 3 || got[0] != -2 || got[1] != 1 || got[2] != 3 {
+        t.Errorf("All: got %v, want [-2 1 3]", got)
+    }
+
+    iter := m.IterAfter(1)
+    if !iter.Next() || iter.Key() != 3 || iter.Next() {
+        t.Errorf("IterAfter: expected only key 3")
+    }
+    if err := iter.Err(); err != nil {
+        t.Errorf("IterAfter: %s", err)
+    }
+}
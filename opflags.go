@@ -0,0 +1,142 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "stdint.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "unsafe"
+)
+
+// OpFlags modify how operations are handled by librados and the OSDs (see
+// Context.SetOpFlags()).
+type OpFlags int
+
+// Operation flags.
+const (
+    // OpBalanceReads lets reads be served by any replica instead of the
+    // primary OSD.
+    OpBalanceReads OpFlags = C.LIBRADOS_OPERATION_BALANCE_READS
+
+    // OpLocalizeReads lets reads be served by the replica closest to the
+    // client (see the crush_location configuration option).
+    OpLocalizeReads OpFlags = C.LIBRADOS_OPERATION_LOCALIZE_READS
+
+    // OpOrderReadsWrites orders reads with respect to in-flight writes.
+    OpOrderReadsWrites OpFlags = C.LIBRADOS_OPERATION_ORDER_READS_WRITES
+
+    // OpIgnoreCache bypasses the cache tier of the pool.
+    OpIgnoreCache OpFlags = C.LIBRADOS_OPERATION_IGNORE_CACHE
+
+    // OpSkipRWLocks skips the object locks of the OSDs. Only for use by
+    // tools that know what they are doing.
+    OpSkipRWLocks OpFlags = C.LIBRADOS_OPERATION_SKIPRWLOCKS
+
+    // OpIgnoreOverlay ignores the cache tier overlay of the pool.
+    OpIgnoreOverlay OpFlags = C.LIBRADOS_OPERATION_IGNORE_OVERLAY
+
+    // OpFullTry lets an operation that reduces space usage (e.g., a
+    // removal) proceed even if the pool or cluster is full.
+    OpFullTry OpFlags = C.LIBRADOS_OPERATION_FULL_TRY
+
+    // OpFullForce makes an operation proceed even if the pool or cluster
+    // is full.
+    OpFullForce OpFlags = C.LIBRADOS_OPERATION_FULL_FORCE
+
+    // OpIgnoreRedirect ignores redirects of the object.
+    OpIgnoreRedirect OpFlags = C.LIBRADOS_OPERATION_IGNORE_REDIRECT
+)
+
+// FadviseFlags are hints to the OSDs about how object data will be
+// accessed (see Context.SetFadvise()).
+type FadviseFlags int
+
+// Access hints.
+const (
+    FadviseRandom     FadviseFlags = C.LIBRADOS_OP_FLAG_FADVISE_RANDOM
+    FadviseSequential FadviseFlags = C.LIBRADOS_OP_FLAG_FADVISE_SEQUENTIAL
+    FadviseWillNeed   FadviseFlags = C.LIBRADOS_OP_FLAG_FADVISE_WILLNEED
+    FadviseDontNeed   FadviseFlags = C.LIBRADOS_OP_FLAG_FADVISE_DONTNEED
+    FadviseNoCache    FadviseFlags = C.LIBRADOS_OP_FLAG_FADVISE_NOCACHE
+)
+
+// SetOpFlags sets the operation flags used by default for all subsequent
+// operations performed through the given context: reads and writes of
+// object data (Get, Put, ReadAt, WriteAt and the like), removals, write
+// operations (see Operate()) and omap reads. Flags of 0, the default,
+// restore the librados behavior. Other operations (e.g., Stat, Append or
+// asynchronous operations) are not affected.
+//
+// Reads and writes of object data are performed with compound operations
+// while flags are set, which is slightly more expensive.
+func (c *Context) SetOpFlags(flags OpFlags) {
+    c.opFlags = flags
+}
+
+// OpFlags returns the operation flags set on the given context.
+func (c *Context) OpFlags() OpFlags {
+    return c.opFlags
+}
+
+// SetFadvise sets the access hints passed with all subsequent reads and
+// writes of object data through the given context, e.g.,
+// FadviseSequential|FadviseDontNeed for a bulk scan that should not evict
+// hot data from the caches of the OSDs. Hints of 0, the default, pass no
+// hints.
+func (c *Context) SetFadvise(flags FadviseFlags) {
+    c.fadvise = flags
+}
+
+// Fadvise returns the access hints set on the given context.
+func (c *Context) Fadvise() FadviseFlags {
+    return c.fadvise
+}
+
+// useOps is a utility function that reports whether reads of object data
+// through the given context must be performed with compound operations to
+// pass the flags set on the context. Writes always are (see operate()).
+func (c *Context) useOps() bool {
+    return c.opFlags != 0 || c.fadvise != 0
+}
+
+// readOp is a utility function that reads len(data) bytes at the byte
+// offset off of the named object with a read operation carrying the flags
+// set on the given context, guarded by the version of the object unless
+// version is 0. Like rados_read(), it returns the number of bytes read or
+// a negative errno.
+func (c *Context) readOp(cname *C.char, data []byte, off int64, version uint64) C.int {
+    op := C.rados_create_read_op()
+    defer C.rados_release_read_op(op)
+
+    // The buffer and results are filled in when the operation is
+    // performed, so they must live in C memory.
+    cdata := C.malloc(C.size_t(len(data) + 1))
+    defer C.free(cdata)
+    cread := (*C.size_t)(C.malloc(C.size_t(unsafe.Sizeof(C.size_t(0)))))
+    defer C.free(unsafe.Pointer(cread))
+    cprval := (*C.int)(C.malloc(C.size_t(unsafe.Sizeof(C.int(0)))))
+    defer C.free(unsafe.Pointer(cprval))
+
+    *cread = 0
+    *cprval = 0
+
+    if version != 0 {
+        C.rados_read_op_assert_version(op, C.uint64_t(version))
+    }
+    C.rados_read_op_read(op, C.uint64_t(off), C.size_t(len(data)), (*C.char)(cdata), cread, cprval)
+    C.rados_read_op_set_flags(op, C.int(c.fadvise))
+
+    cerr := C.rados_read_op_operate(op, c.ctx, cname, C.int(c.opFlags))
+    if cerr == 0 {
+        cerr = *cprval
+    }
+    if cerr < 0 {
+        return cerr
+    }
+
+    return C.int(copy(data, C.GoBytes(cdata, C.int(*cread))))
+}
@@ -0,0 +1,97 @@
+package rados
+
+import (
+    "io"
+)
+
+// parallelReadChunkSize is the number of bytes read by each operation of a
+// parallel read, unless a smaller maximum chunk size is set.
+const parallelReadChunkSize = 4 << 20
+
+// ParallelReadAt reads len(data) bytes from the given RADOS object at the
+// byte offset off like ReadAt(), but splits the range into chunks (of at
+// most the maximum chunk size of the object, and 4 MB) that are read with
+// up to parallelism asynchronous operations in flight, so reading a large
+// object is not bound by the latency of a single operation. It returns the
+// number of bytes read and the error, if any; at the end of the object,
+// that error is io.EOF.
+func (o *Object) ParallelReadAt(data []byte, off int64, parallelism int) (n int, err error) {
+    if parallelism < 1 {
+        parallelism = 1
+    }
+
+    chunk := o.chunkSize()
+    if chunk <= 0 || chunk > parallelReadChunkSize {
+        chunk = parallelReadChunkSize
+    }
+
+    type pendingRead struct {
+        cp    *Completion
+        start int
+        size  int
+    }
+
+    var pending []pendingRead
+    next := 0
+    short := false // The object ended before the end of data
+
+    for {
+        // Keep parallelism reads in flight until something goes wrong
+        for next < len(data) && len(pending) < parallelism && err == nil && !short {
+            size := len(data) - next
+            if size > chunk {
+                size = chunk
+            }
+
+            cp, aerr := o.c.AioRead(o.name, size, off+int64(next))
+            if aerr != nil {
+                err = aerr
+                break
+            }
+
+            pending = append(pending, pendingRead{cp: cp, start: next, size: size})
+            next += size
+        }
+
+        if len(pending) == 0 {
+            break
+        }
+
+        // Reads are collected in order, so data[:n] is always complete
+        p := pending[0]
+        pending = pending[1:]
+
+        werr := p.cp.Wait()
+        if err == nil && !short {
+            if werr != nil {
+                err = werr
+            } else {
+                got := copy(data[p.start:p.start+p.size], p.cp.Data())
+                n += got
+                short = got < p.size
+            }
+        }
+        p.cp.Release()
+    }
+
+    if err == nil && n < len(data) {
+        err = io.EOF
+    }
+
+    return n, err
+}
+
+// SetParallelGet makes Get() read the objects of at least threshold bytes
+// with ParallelReadAt(), keeping up to parallelism range reads in flight,
+// instead of reading them serially. A parallelism of 0 or 1, the default,
+// disables parallel gets.
+func (c *Context) SetParallelGet(threshold int64, parallelism int) {
+    c.parallelGetThreshold = threshold
+    c.parallelGetParallelism = parallelism
+}
+
+// ParallelGet returns the threshold and parallelism of the parallel gets of
+// the given context (see SetParallelGet()).
+func (c *Context) ParallelGet() (int64, int) {
+    return c.parallelGetThreshold, c.parallelGetParallelism
+}
@@ -0,0 +1,161 @@
+package rados
+
+import (
+    "reflect"
+    "strings"
+    "time"
+)
+
+// PGSummary summarizes the states of the placement groups of the cluster.
+// A placement group can be in several states at once (e.g.,
+// "active+undersized+degraded+backfilling"), so it may be counted in
+// several of the fields.
+type PGSummary struct {
+    Total       int            // Number of placement groups
+    ByState     map[string]int // Number of placement groups by full state
+    ActiveClean int            // Active and clean, i.e., healthy
+    Inactive    int            // Not active, i.e., not serving IO
+    Degraded    int            // With fewer replicas than required
+    Recovering  int            // Recovering degraded objects
+    Backfilling int            // Copying data to new replicas
+
+    DegradedObjects  uint64 // Object replicas missing
+    MisplacedObjects uint64 // Object replicas stored in the wrong place
+    UnfoundObjects   uint64 // Objects with no replica found
+}
+
+// Clean reports whether all the placement groups are active and clean,
+// i.e., the cluster is not recovering.
+func (s *PGSummary) Clean() bool {
+    return s.ActiveClean == s.Total
+}
+
+// PGSummary returns a summary of the states of the placement groups of
+// the cluster.
+func (r *Rados) PGSummary() (*PGSummary, error) {
+    var stat struct {
+        Summary struct {
+            ByState []struct {
+                Name string `json:"name"`
+                Num  int    `json:"num"`
+            } `json:"num_pg_by_state"`
+            Total            int    `json:"num_pgs"`
+            DegradedObjects  uint64 `json:"degraded_objects"`
+            MisplacedObjects uint64 `json:"misplaced_objects"`
+            UnfoundObjects   uint64 `json:"unfound_objects"`
+        } `json:"pg_summary"`
+    }
+
+    err := r.mgrCommandJSON(map[string]interface{}{
+        "prefix": "pg stat",
+    }, &stat)
+    if err != nil {
+        return nil, err
+    }
+
+    summary := &PGSummary{
+        Total:            stat.Summary.Total,
+        ByState:          make(map[string]int),
+        DegradedObjects:  stat.Summary.DegradedObjects,
+        MisplacedObjects: stat.Summary.MisplacedObjects,
+        UnfoundObjects:   stat.Summary.UnfoundObjects,
+    }
+
+    for _, state := range stat.Summary.ByState {
+        summary.ByState[state.Name] += state.Num
+
+        states := make(map[string]bool)
+        for _, s := range strings.Split(state.Name, "+") {
+            states[s] = true
+        }
+
+        if states["active"] && states["clean"] {
+            summary.ActiveClean += state.Num
+        }
+        if !states["active"] {
+            summary.Inactive += state.Num
+        }
+        if states["degraded"] {
+            summary.Degraded += state.Num
+        }
+        if states["recovering"] || states["recovery_wait"] {
+            summary.Recovering += state.Num
+        }
+        if states["backfilling"] || states["backfill_wait"] {
+            summary.Backfilling += state.Num
+        }
+    }
+
+    return summary, nil
+}
+
+// PGUpdate is an update sent by a PGWatcher: either a new summary of the
+// placement group states, or the error that prevented getting one.
+type PGUpdate struct {
+    Summary *PGSummary
+    Err     error
+}
+
+// PGWatcher polls the placement group states of a cluster (see
+// Rados.WatchPGs()). A watcher must be stopped with Close() when it is no
+// longer needed.
+type PGWatcher struct {
+    // C delivers the updates. It is closed when the watcher is closed.
+    C <-chan PGUpdate
+
+    r       *Rados
+    updates chan PGUpdate
+    last    *PGSummary
+    loop    *loop
+}
+
+// WatchPGs starts polling the placement group states of the cluster every
+// interval, e.g., to hold back heavy writes while the cluster recovers.
+// An update is sent on the C channel of the returned watcher for the first
+// poll, and then whenever the summary changes or polling fails. Updates
+// are not buffered: polling waits until the previous update has been
+// received.
+func (r *Rados) WatchPGs(interval time.Duration) (*PGWatcher, error) {
+    l, err := newLoop("watch pgs", interval)
+    if err != nil {
+        return nil, err
+    }
+
+    updates := make(chan PGUpdate)
+    w := &PGWatcher{
+        C:       updates,
+        r:       r,
+        updates: updates,
+        loop:    l,
+    }
+
+    l.start(w.poll)
+
+    return w, nil
+}
+
+// poll is a utility function that polls the placement group states once,
+// sending an update if they changed until the watcher is closed.
+func (w *PGWatcher) poll() {
+    summary, err := w.r.PGSummary()
+
+    if err != nil || w.last == nil || !reflect.DeepEqual(summary, w.last) {
+        select {
+        case w.updates <- PGUpdate{Summary: summary, Err: err}:
+        case <-w.loop.stop:
+            return
+        }
+    }
+    if err == nil {
+        w.last = summary
+    }
+}
+
+// Close stops the watcher and closes its C channel. Closing it again does
+// nothing.
+func (w *PGWatcher) Close() error {
+    return w.loop.close(func() error {
+        close(w.updates)
+        return nil
+    })
+}
@@ -0,0 +1,217 @@
+package rados
+
+import (
+    "fmt"
+    "reflect"
+    "time"
+)
+
+// ObjectPlacement describes where an object is stored: its placement
+// group and the OSDs serving it (see Context.Placement()).
+type ObjectPlacement struct {
+    Epoch         uint32 // Epoch of the OSD map the placement was computed with
+    PoolID        int64
+    PG            string // E.g., "1.3b"
+    Up            []int  // OSDs the placement group maps to, per CRUSH
+    UpPrimary     int
+    Acting        []int // OSDs actually serving the placement group
+    ActingPrimary int   // OSD serving reads and coordinating writes
+}
+
+// Placement returns the placement group of the named object in the pool
+// referenced by the given context, and the OSDs serving it, like `ceph osd
+// map`, so latency investigations can correlate slow objects with
+// specific OSDs. The placement is computed from the name of the object,
+// or the locator key of the context if it is set, so the object doesn't
+// need to exist.
+func (c *Context) Placement(name string) (*ObjectPlacement, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    var placement struct {
+        Epoch         uint32 `json:"epoch"`
+        PoolID        int64  `json:"pool_id"`
+        PG            string `json:"pgid"`
+        Up            []int  `json:"up"`
+        UpPrimary     int    `json:"up_primary"`
+        Acting        []int  `json:"acting"`
+        ActingPrimary int    `json:"acting_primary"`
+    }
+
+    // Objects are placed by their locator key, if they have one
+    object := name
+    if c.locator != "" {
+        object = c.locator
+    }
+
+    cmd := map[string]interface{}{
+        "prefix": "osd map",
+        "pool":   c.Pool,
+        "object": object,
+    }
+    if c.namespace != "" && c.namespace != AllNamespaces {
+        cmd["nspace"] = c.namespace
+    }
+
+    if err := c.rados.monCommandJSON(cmd, &placement); err != nil {
+        return nil, err
+    }
+
+    return &ObjectPlacement{
+        Epoch:         placement.Epoch,
+        PoolID:        placement.PoolID,
+        PG:            placement.PG,
+        Up:            placement.Up,
+        UpPrimary:     placement.UpPrimary,
+        Acting:        placement.Acting,
+        ActingPrimary: placement.ActingPrimary,
+    }, nil
+}
+
+// OSDLocation describes where an OSD is in the CRUSH hierarchy of the
+// cluster.
+type OSDLocation struct {
+    OSD  int
+    Host string
+
+    // CrushLocation maps the CRUSH bucket types to the buckets the OSD is
+    // in, e.g., {"host": "node1", "rack": "r1", "root": "default"}.
+    CrushLocation map[string]string
+}
+
+// OSDLocation returns the host and CRUSH location of the given OSD, like
+// `ceph osd find`.
+func (r *Rados) OSDLocation(osd int) (*OSDLocation, error) {
+    var location struct {
+        OSD           int               `json:"osd"`
+        Host          string            `json:"host"`
+        CrushLocation map[string]string `json:"crush_location"`
+    }
+
+    err := r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd find",
+        "id":     osd,
+    }, &location)
+    if err != nil {
+        return nil, err
+    }
+
+    return &OSDLocation{
+        OSD:           location.OSD,
+        Host:          location.Host,
+        CrushLocation: location.CrushLocation,
+    }, nil
+}
+
+// PrimaryLocation returns the primary OSD of the named object in the pool
+// referenced by the given context (see Placement()) along with its host
+// and CRUSH location, so topology-aware applications can schedule work
+// that uses the object close to its data. It fails if the placement group
+// of the object has no primary, i.e., it is down.
+func (c *Context) PrimaryLocation(name string) (*OSDLocation, error) {
+    placement, err := c.Placement(name)
+    if err != nil {
+        return nil, err
+    }
+
+    if placement.ActingPrimary < 0 {
+        return nil, fmt.Errorf("RADOS primary location %s: PG %s has no primary", name, placement.PG)
+    }
+
+    return c.rados.OSDLocation(placement.ActingPrimary)
+}
+
+// PlacementEvent is an event sent by a PlacementWatcher: either a change of
+// the acting set of the placement group of an object, or the error that
+// prevented getting its placement.
+type PlacementEvent struct {
+    Name string
+    Old  *ObjectPlacement // Placement before the change
+    New  *ObjectPlacement // Placement after the change
+    Err  error
+}
+
+// PlacementWatcher polls the placement of a set of objects (see
+// Context.WatchPlacements()). A watcher must be stopped with Close() when
+// it is no longer needed.
+type PlacementWatcher struct {
+    // C delivers the events. It is closed when the watcher is closed.
+    C <-chan PlacementEvent
+
+    c      *Context
+    events chan PlacementEvent
+    names  []string
+    last   map[string]*ObjectPlacement
+    loop   *loop
+}
+
+// WatchPlacements starts polling the placement of the named objects in the
+// pool referenced by the given context every interval, and sends an event
+// on the C channel of the returned watcher whenever the acting set of the
+// placement group of one of them changes (e.g., because an OSD failed or
+// data is being rebalanced), so applications can anticipate windows of
+// degraded performance. Each poll sends a monitor command per object, so
+// only a few important objects should be watched. Events are not
+// buffered: polling waits until the previous event has been received.
+func (c *Context) WatchPlacements(names []string, interval time.Duration) (*PlacementWatcher, error) {
+    l, err := newLoop("watch placements", interval)
+    if err != nil {
+        return nil, err
+    }
+
+    // The namespace and locator key of the watched objects must not change
+    ctx, err := c.Clone()
+    if err != nil {
+        return nil, err
+    }
+
+    events := make(chan PlacementEvent)
+    w := &PlacementWatcher{
+        C:      events,
+        c:      ctx,
+        events: events,
+        names:  append([]string(nil), names...),
+        last:   make(map[string]*ObjectPlacement),
+        loop:   l,
+    }
+
+    l.start(w.poll)
+
+    return w, nil
+}
+
+// poll is a utility function that polls the placement of the watched
+// objects once, sending events for the changes until the watcher is
+// closed.
+func (w *PlacementWatcher) poll() {
+    for _, name := range w.names {
+        placement, err := w.c.Placement(name)
+
+        old := w.last[name]
+        if err == nil {
+            w.last[name] = placement
+        }
+
+        changed := old != nil && err == nil &&
+            (!reflect.DeepEqual(old.Acting, placement.Acting) || old.ActingPrimary != placement.ActingPrimary)
+        if err == nil && !changed {
+            continue
+        }
+
+        select {
+        case w.events <- PlacementEvent{Name: name, Old: old, New: placement, Err: err}:
+        case <-w.loop.stop:
+            return
+        }
+    }
+}
+
+// Close stops the watcher and closes its C channel. Closing it again does
+// nothing.
+func (w *PlacementWatcher) Close() error {
+    return w.loop.close(func() error {
+        close(w.events)
+        return w.c.Release()
+    })
+}
@@ -0,0 +1,95 @@
+package rados
+
+import (
+    "time"
+)
+
+// TransferProgress reports the progress of a transfer of object data (see
+// Context.SetProgress()).
+type TransferProgress struct {
+    Name    string        // Object transferred
+    Bytes   int64         // Bytes transferred so far
+    Total   int64         // Bytes to transfer
+    Rate    float64       // Bytes transferred per second
+    Elapsed time.Duration // Time since the transfer started
+}
+
+// SetProgress makes reads and writes of object data through the given
+// context (Get, Put, ReadAt and WriteAt, as well as the copies and exports
+// of objects read from it) call fn as their data is transferred, at most
+// once every interval and once when they complete, so tools can show the
+// progress of multi-GB transfers. A nil fn, the default, disables progress
+// reports.
+//
+// Progress is reported after each operation, so transfers should be split
+// into several operations with SetMaxChunkSize() for reports to be
+// meaningful.
+func (c *Context) SetProgress(fn func(progress TransferProgress), interval time.Duration) {
+    c.progress = fn
+    c.progressInterval = interval
+}
+
+// transfer tracks the progress of a transfer of object data. A nil
+// transfer tracks nothing.
+type transfer struct {
+    fn       func(progress TransferProgress)
+    interval time.Duration
+    progress TransferProgress
+    start    time.Time
+    last     time.Time // Time of the last report
+}
+
+// newTransfer is a utility function that starts tracking the transfer of
+// total bytes of the named object through the given context. It returns
+// nil if progress reports are disabled on the context.
+func (c *Context) newTransfer(name string, total int64) *transfer {
+    if c.progress == nil {
+        return nil
+    }
+
+    now := time.Now()
+
+    return &transfer{
+        fn:       c.progress,
+        interval: c.progressInterval,
+        progress: TransferProgress{Name: name, Total: total},
+        start:    now,
+        last:     now,
+    }
+}
+
+// add is a utility function that accounts for n more bytes transferred,
+// and reports the progress if the interval has elapsed since the last
+// report or the transfer is complete.
+func (t *transfer) add(n int) {
+    if t == nil {
+        return
+    }
+
+    t.progress.Bytes += int64(n)
+
+    now := time.Now()
+    if now.Sub(t.last) < t.interval && t.progress.Bytes < t.progress.Total {
+        return
+    }
+    t.last = now
+
+    t.progress.Elapsed = now.Sub(t.start)
+    if t.progress.Elapsed > 0 {
+        t.progress.Rate = float64(t.progress.Bytes) / t.progress.Elapsed.Seconds()
+    }
+
+    t.fn(t.progress)
+}
+
+// finish is a utility function that ends a transfer cut short by the end
+// of the object: the bytes transferred so far become the total, and are
+// reported unless they already were.
+func (t *transfer) finish() {
+    if t == nil || t.progress.Bytes == t.progress.Total {
+        return
+    }
+
+    t.progress.Total = t.progress.Bytes
+    t.add(0)
+}
@@ -7,6 +7,7 @@ package rados
 /*
 #cgo LDFLAGS: -lrados
 #include "stdlib.h"
+#include "errno.h"
 #include "rados/librados.h"
 */
 import "C"
@@ -14,6 +15,8 @@ import "C"
 import (
     "bytes"
     "fmt"
+    "os"
+    "sort"
     "unsafe"
 )
 
@@ -24,62 +27,372 @@ type Rados struct {
     used     uint64
     avail    uint64
     nObjects uint64
+
+    shared *sharedCluster // Set for handles returned by DefaultCluster()
+
+    opts      options    // Settings the handle was created with
+    audit     *auditor   // Audit settings, or nil
+    callbacks *callbacks // Callback pool of the AIO completions
+}
+
+// Option configures how a RADOS cluster handle is created (see
+// NewWithOptions()).
+type Option func(*options)
+
+// options holds the settings used to create a cluster handle. It must
+// remain comparable, since it identifies shared handles (see
+// DefaultCluster()).
+type options struct {
+    configFile    string
+    user          string
+    clusterName   string
+    keyring       string
+    key           string
+    monHost       string
+    crushLocation string
+    readPolicy    ReadPolicy
+    environment   bool
+    skipStat      bool
+}
+
+// WithConfigFile makes RADOS look for its configuration in configFile
+// instead of searching the default paths (e.g., /etc/ceph/ceph.conf).
+func WithConfigFile(configFile string) Option {
+    return func(o *options) {
+        o.configFile = configFile
+    }
+}
+
+// WithUser makes RADOS authenticate as the given CephX user, e.g., "rgw"
+// for client.rgw, instead of client.admin.
+func WithUser(user string) Option {
+    return func(o *options) {
+        o.user = user
+    }
+}
+
+// WithClusterName sets the name of the cluster, which selects the default
+// configuration file (e.g., /etc/ceph/backup.conf for "backup") and
+// keyring, instead of "ceph".
+func WithClusterName(clusterName string) Option {
+    return func(o *options) {
+        o.clusterName = clusterName
+    }
+}
+
+// WithKeyring makes RADOS read the CephX key of the client from the
+// keyring file instead of the one set in the configuration.
+func WithKeyring(keyring string) Option {
+    return func(o *options) {
+        o.keyring = keyring
+    }
+}
+
+// WithKey sets the CephX secret key of the client (e.g., "AQ...=="), so
+// credentials obtained from a secret manager can be used without writing
+// a keyring file to disk. It takes precedence over keyrings.
+func WithKey(key string) Option {
+    return func(o *options) {
+        o.key = key
+    }
+}
+
+// WithMonHost sets the addresses of the monitors of the cluster, as a
+// comma-separated list (e.g., "10.0.0.1,10.0.0.2:6789"). Unless a
+// configuration file is also given with WithConfigFile(), no configuration
+// file is read at all, so the client can be configured entirely from
+// options (see WithKey()), e.g., with monitor addresses from service
+// discovery.
+func WithMonHost(monHost string) Option {
+    return func(o *options) {
+        o.monHost = monHost
+    }
+}
+
+// WithEnvironment makes the handle honor the environment variables used by
+// the standard Ceph command-line tools, which is convenient in containers:
+// CEPH_CONF and CEPH_KEYRING set the configuration file and keyring when
+// they are not set by other options (see WithConfigFile() and
+// WithKeyring()), and CEPH_ARGS holds command-line arguments (e.g.,
+// "--id rgw --mon-host 10.0.0.1") that override the configuration file
+// and the other options.
+func WithEnvironment() Option {
+    return func(o *options) {
+        o.environment = true
+
+        if conf := os.Getenv("CEPH_CONF"); conf != "" && o.configFile == "" {
+            o.configFile = conf
+        }
+        if keyring := os.Getenv("CEPH_KEYRING"); keyring != "" && o.keyring == "" {
+            o.keyring = keyring
+        }
+    }
+}
+
+// WithoutClusterStat makes connecting skip retrieving the cluster
+// statistics, which fails for keys whose capabilities are restricted to
+// some pools, so such keys can still be used for object I/O. Size(),
+// Used(), Avail() and NObjects() then return 0 until Stat() is called.
+func WithoutClusterStat() Option {
+    return func(o *options) {
+        o.skipStat = true
+    }
 }
 
 // New returns a RADOS cluster handle that is used to create IO
 // Contexts and perform other RADOS actions. If configFile is
 // non-empty, RADOS will look for its configuration there, otherwise
-// the default paths will be searched (e.g., /etc/ceph/ceph.conf).
-//
-// TODO: allow caller to specify Ceph user.
+// the default paths will be searched (e.g., /etc/ceph/ceph.conf). To
+// connect as another user than client.admin, see NewWithOptions() and
+// WithUser().
 func New(configFile string) (*Rados, error) {
-    r := &Rados{}
-    var cerr C.int
+    return NewWithOptions(WithConfigFile(configFile))
+}
 
-    if cerr = C.rados_create(&r.rados, nil); cerr < 0 {
-        return nil, fmt.Errorf("RADOS create: %s", strerror(cerr))
+// NewWithOptions returns a RADOS cluster handle like New(), configured by
+// the given options.
+func NewWithOptions(opts ...Option) (*Rados, error) {
+    var o options
+    for _, opt := range opts {
+        opt(&o)
     }
 
-    if configFile == "" {
-        cerr = C.rados_conf_read_file(r.rados, nil)
+    return newRados(o)
+}
+
+// newRados is a utility function that creates and connects a RADOS cluster
+// handle with the given settings.
+func newRados(o options) (*Rados, error) {
+    r := &Rados{opts: o, callbacks: &callbacks{}}
+    var cerr C.int
+
+    if o.user == "" && o.clusterName == "" {
+        cerr = C.rados_create(&r.rados, nil)
     } else {
-        cconfigFile := C.CString(configFile)
+        user, clusterName := o.user, o.clusterName
+        if user == "" {
+            user = "admin"
+        }
+        if clusterName == "" {
+            clusterName = "ceph"
+        }
+
+        cname := C.CString("client." + user)
+        defer C.free(unsafe.Pointer(cname))
+        cclusterName := C.CString(clusterName)
+        defer C.free(unsafe.Pointer(cclusterName))
+
+        cerr = C.rados_create2(&r.rados, cclusterName, cname, 0)
+    }
+
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS create: %w", radosErrno(cerr))
+    }
+
+    switch {
+    case o.configFile != "":
+        cconfigFile := C.CString(o.configFile)
         defer C.free(unsafe.Pointer(cconfigFile))
 
         cerr = C.rados_conf_read_file(r.rados, cconfigFile)
+    case o.monHost == "":
+        cerr = C.rados_conf_read_file(r.rados, nil)
     }
 
     if cerr < 0 {
-        return nil, fmt.Errorf("RADOS config: %s", strerror(cerr))
+        C.rados_shutdown(r.rados)
+        return nil, fmt.Errorf("RADOS config: %w", radosErrno(cerr))
+    }
+
+    settings := [][2]string{
+        {"keyring", o.keyring},
+        {"key", o.key},
+        {"mon_host", o.monHost},
+        {"crush_location", o.crushLocation},
+    }
+    for _, setting := range settings {
+        if setting[1] == "" {
+            continue
+        }
+
+        if err := r.confSet(setting[0], setting[1]); err != nil {
+            C.rados_shutdown(r.rados)
+            return nil, err
+        }
+    }
+
+    // CEPH_ARGS
+    if o.environment {
+        if cerr = C.rados_conf_parse_env(r.rados, nil); cerr < 0 {
+            C.rados_shutdown(r.rados)
+            return nil, fmt.Errorf("RADOS config CEPH_ARGS: %w", radosErrno(cerr))
+        }
     }
 
     if cerr = C.rados_connect(r.rados); cerr < 0 {
-        return nil, fmt.Errorf("RADOS connect: %s", strerror(cerr))
+        C.rados_shutdown(r.rados)
+        return nil, fmt.Errorf("RADOS connect: %w", radosErrno(cerr))
     }
 
     // Fill in cluster statistics
-    if err := r.Stat(); err != nil {
-        r.Release()
-        return nil, err
+    if !o.skipStat {
+        if err := r.Stat(); err != nil {
+            r.Release()
+            return nil, err
+        }
     }
 
     return r, nil
 }
 
-// NewDefault returns a RADOS cluster handle based on the default config file.
-// See New() for more information.
+// NewDefault returns a RADOS cluster handle based on the default config
+// file, honoring the environment variables of the Ceph command-line tools
+// (see WithEnvironment()). See New() for more information.
 func NewDefault() (r *Rados, err error) {
-    r, err = New("")
+    r, err = NewWithOptions(WithEnvironment())
     return r, err
 }
 
+// ConfGet returns the value of the named configuration option of the
+// given RADOS cluster handle.
+func (r *Rados) ConfGet(option string) (string, error) {
+    if r.rados == nil {
+        return "", closedError("conf get " + option)
+    }
+
+    coption := C.CString(option)
+    defer C.free(unsafe.Pointer(coption))
+
+    var buf []byte
+    bufSize := 256 // Initial guess at amount of space we need
+
+    // rados_conf_get() fails with ENAMETOOLONG if the value doesn't
+    // fit in our buffer, in which case we retry with a bigger one.
+    for {
+        buf = make([]byte, bufSize)
+        cdata, cdatalen := byteSliceToBuffer(buf)
+
+        cerr := C.rados_conf_get(r.rados, coption, cdata, cdatalen)
+
+        if cerr == -C.ENAMETOOLONG {
+            bufSize *= 2
+            continue
+        } else if cerr < 0 {
+            return "", fmt.Errorf("RADOS conf get %s: %w", option, radosErrno(cerr))
+        }
+
+        return C.GoString(cdata), nil
+    }
+}
+
+// confSet is a utility function that sets the named configuration option
+// of the given RADOS cluster handle to value.
+func (r *Rados) confSet(option, value string) error {
+    if r.rados == nil {
+        return closedError("conf set " + option)
+    }
+
+    coption := C.CString(option)
+    defer C.free(unsafe.Pointer(coption))
+    cvalue := C.CString(value)
+    defer C.free(unsafe.Pointer(cvalue))
+
+    if cerr := C.rados_conf_set(r.rados, coption, cvalue); cerr < 0 {
+        return fmt.Errorf("RADOS conf set %s: %w", option, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// ReloadConfig reads the configuration of the given RADOS cluster handle
+// again from path, or from the default paths if path is empty, and applies
+// the options that can be changed at runtime (e.g., log levels, timeouts
+// and throttles) without reconnecting, so long-lived daemons can pick up
+// configuration changes. Other options, like the monitor addresses, only
+// take effect when the handle connects again (see Reconnect()).
+func (r *Rados) ReloadConfig(path string) error {
+    if r.rados == nil {
+        return closedError("config " + path)
+    }
+
+    var cpath *C.char
+    if path != "" {
+        cpath = C.CString(path)
+        defer C.free(unsafe.Pointer(cpath))
+    }
+
+    if cerr := C.rados_conf_read_file(r.rados, cpath); cerr < 0 {
+        return fmt.Errorf("RADOS config %s: %w", path, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// ApplyConf sets the given configuration options of the given RADOS
+// cluster handle at runtime, like ReloadConfig(), in the order of their
+// names. It stops at the first option that cannot be set, leaving the
+// options set before it changed.
+func (r *Rados) ApplyConf(conf map[string]string) error {
+    options := make([]string, 0, len(conf))
+    for option := range conf {
+        options = append(options, option)
+    }
+    sort.Strings(options)
+
+    for _, option := range options {
+        if err := r.confSet(option, conf[option]); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// ClientAddrs returns the network addresses (including the nonce) of the
+// given RADOS cluster handle, as seen by the cluster. This is the address
+// to blocklist in order to fence off this client instance.
+func (r *Rados) ClientAddrs() (string, error) {
+    var caddrs *C.char
+
+    if r.rados == nil {
+        return "", closedError("get addrs")
+    }
+
+    cerr := r.call("rados_getaddrs", "", func() C.int {
+        return C.rados_getaddrs(r.rados, &caddrs)
+    })
+    if cerr < 0 {
+        return "", fmt.Errorf("RADOS get addrs: %w", radosErrno(cerr))
+    }
+    defer C.rados_buffer_free(caddrs)
+
+    return C.GoString(caddrs), nil
+}
+
+// InstanceID returns the ID of the given RADOS cluster handle within the
+// cluster (its global client ID), or 0 if the handle was released.
+func (r *Rados) InstanceID() uint64 {
+    if r.rados == nil {
+        return 0
+    }
+
+    return uint64(C.rados_get_instance_id(r.rados))
+}
+
 // Stat retrieves the current cluster statistics and stores them in
 // the Rados structure.
 func (r *Rados) Stat() error {
     var cstat C.struct_rados_cluster_stat_t
 
-    if cerr := C.rados_cluster_stat(r.rados, &cstat); cerr < 0 {
-        return fmt.Errorf("RADOS cluster stat: %s", strerror(cerr))
+    if r.rados == nil {
+        return closedError("cluster stat")
+    }
+
+    cerr := r.call("rados_cluster_stat", "", func() C.int {
+        return C.rados_cluster_stat(r.rados, &cstat)
+    })
+    if cerr < 0 {
+        return fmt.Errorf("RADOS cluster stat: %w", radosErrno(cerr))
     }
 
     r.size = uint64(cstat.kb)
@@ -110,39 +423,124 @@ func (r *Rados) NObjects() uint64 {
     return r.nObjects
 }
 
-// Release handle and disconnect from RADOS cluster.
+// Release handle and disconnect from RADOS cluster. For a shared handle
+// returned by DefaultCluster(), the connection is only closed once every
+// user has released its handle. Once a handle is released, operations on
+// it fail with an error wrapping ErrClosed, and releasing it again does
+// nothing.
 //
 // TODO: track all open ioctx, ensure all async operations have
 // completed before calling rados_shutdown, because it doesn't do that
 // itself.
 func (r *Rados) Release() error {
+    if r.rados == nil {
+        return nil
+    }
+
+    if r.shared != nil && !r.shared.release() {
+        r.rados = nil
+        return nil
+    }
+
     C.rados_shutdown(r.rados)
+    r.rados = nil
+    r.callbacks.close()
+
+    return nil
+}
+
+// Reconnect replaces the connection of the given RADOS cluster handle with
+// a new one, created with the options the handle was created with
+// overridden by opts, e.g., to authenticate with a rotated CephX key
+// (see WithKeyring()) without restarting the process. The new connection
+// is established before the old one is closed, so the handle is left
+// unchanged if connecting fails.
+//
+// The contexts created from the handle use the old connection, so they
+// must be released before calling Reconnect, and created again afterwards.
+// Reconnect must not be called concurrently with other uses of the handle,
+// and fails for shared handles (see DefaultCluster()).
+func (r *Rados) Reconnect(opts ...Option) error {
+    if r.shared != nil {
+        return fmt.Errorf("RADOS reconnect: cannot reconnect a shared cluster handle")
+    }
+    if r.rados == nil {
+        return closedError("reconnect")
+    }
+
+    o := r.opts
+    for _, opt := range opts {
+        opt(&o)
+    }
+
+    nr, err := newRados(o)
+    if err != nil {
+        return err
+    }
+
+    C.rados_shutdown(r.rados)
+    audit, callbacks := r.audit, r.callbacks
+    *r = *nr
+    r.callbacks = callbacks
+
+    if audit != nil {
+        for _, s := range audit.sinks {
+            if err = r.AddAuditSink(s.sink, s.onError); err != nil {
+                return err
+            }
+        }
+    }
 
     return nil
 }
 
 // CreatePool creates the named pool in the given RADOS cluster.
-// CreatePool uses the default admin user and crush rule.
+// CreatePool uses the default admin user and crush rule. It fails with an
+// error wrapping ErrPoolExists if the pool already exists.
 //
 // TODO: Add ability to create pools with specific admin users/crush rules.
 func (r *Rados) CreatePool(poolName string) error {
+    if r.rados == nil {
+        return closedError("pool create " + poolName)
+    }
+
     cname := C.CString(poolName)
     defer C.free(unsafe.Pointer(cname))
 
-    if cerr := C.rados_pool_create(r.rados, cname); cerr < 0 {
-        return fmt.Errorf("RADOS pool create %s: %s", poolName, strerror(cerr))
+    cerr := r.call("rados_pool_create", poolName, func() C.int {
+        return C.rados_pool_create(r.rados, cname)
+    })
+    if cerr < 0 {
+        return poolError("pool create", poolName, cerr)
     }
 
     return nil
 }
 
-// DeletePool deletes the named pool in the given RADOS cluster.
+// DeletePool deletes the named pool in the given RADOS cluster. It fails
+// with an error wrapping ErrPoolNotFound if the pool doesn't exist.
+//
+// Deprecated: DeletePool deletes the pool without any safety check. Use
+// DeletePoolWithOptions() instead, which only deletes the pool when the
+// deletion is explicitly allowed.
 func (r *Rados) DeletePool(poolName string) error {
+    return r.deletePool(poolName)
+}
+
+// deletePool is a utility function that deletes the named pool.
+func (r *Rados) deletePool(poolName string) error {
+    if r.rados == nil {
+        return closedError("pool delete " + poolName)
+    }
+
     cname := C.CString(poolName)
     defer C.free(unsafe.Pointer(cname))
 
-    if cerr := C.rados_pool_delete(r.rados, cname); cerr < 0 {
-        return fmt.Errorf("RADOS pool delete %s: %s", poolName, strerror(cerr))
+    cerr := r.call("rados_pool_delete", poolName, func() C.int {
+        return C.rados_pool_delete(r.rados, cname)
+    })
+    if cerr < 0 {
+        return poolError("pool delete", poolName, cerr)
     }
 
     return nil
@@ -151,6 +549,10 @@ func (r *Rados) DeletePool(poolName string) error {
 // ListPools retuns a list of pools in the given RADOS cluster as
 // a slice of strings.
 func (r *Rados) ListPools() ([]string, error) {
+    if r.rados == nil {
+        return nil, closedError("list pools")
+    }
+
     var buf []byte
     bufSize := 256 // Initial guess at amount of space we need
 
@@ -163,10 +565,12 @@ func (r *Rados) ListPools() ([]string, error) {
 
         // rados_pool_list() returns the number of bytes needed
         // to return all pools.
-        cbufsize := C.rados_pool_list(r.rados, cdata, cdatalen)
+        cbufsize := r.call("rados_pool_list", "", func() C.int {
+            return C.rados_pool_list(r.rados, cdata, cdatalen)
+        })
 
         if cbufsize < 0 {
-            return nil, fmt.Errorf("RADOS list pools: %s", strerror(cbufsize))
+            return nil, fmt.Errorf("RADOS list pools: %w", radosErrno(cbufsize))
         } else if int(cbufsize) > bufSize {
             // We didn't have enough space -- try again
             bufSize = int(cbufsize)
@@ -200,9 +604,3 @@ func byteSliceToBuffer(data []byte) (*C.char, C.size_t) {
         return (*C.char)(unsafe.Pointer(&data)), C.size_t(0)
     }
 }
-
-// strerror is a utility wrapper around the libc strerror() function. It returns
-// a Go string containing the text of the error.
-func strerror(cerr C.int) string {
-    return C.GoString(C.strerror(-cerr))
-}
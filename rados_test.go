@@ -2,9 +2,18 @@ package rados
 
 import (
     "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "expvar"
     "fmt"
     "io"
+    "math"
     "os"
+    "strconv"
+    "strings"
+    "syscall"
     "testing"
     "time"
 )
@@ -21,8 +30,11 @@ func fatalOnError(t *testing.T, e error, message string, parameters ...interface
     }
 }
 
+var poolCounter int
+
 func poolName() string {
-    return fmt.Sprintf("rados.go.test.%d.%d", time.Now().Unix(), os.Getpid())
+    poolCounter++
+    return fmt.Sprintf("rados.go.test.%d.%d.%d", time.Now().Unix(), os.Getpid(), poolCounter)
 }
 
 type radosTest struct {
@@ -51,8 +63,8 @@ func setup(t *testing.T) *radosTest {
 func teardown(t *testing.T, test *radosTest) {
     var err error
 
-    err = test.rados.DeletePool(test.poolName)
-    fatalOnError(t, err, "Teardown: DeletePool")
+    _, err = test.rados.DeletePoolWithOptions(test.poolName, &DeleteOptions{AllowPoolDelete: true})
+    fatalOnError(t, err, "Teardown: DeletePoolWithOptions")
 
     err = test.rados.Release()
     fatalOnError(t, err, "Teardown: Release")
@@ -392,3 +404,4095 @@ func Test_PoolStat(t *testing.T) {
     _, err = ctx.PoolStat()
     fatalOnError(t, err, "PoolStat")
 }
+
+func Test_WriteWithMtime(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    mtime := time.Unix(1000000000, 0)
+
+    // Put data with an explicit mtime
+    err = ctx.PutWithMtime(name, []byte("12345"), mtime)
+    fatalOnError(t, err, "PutWithMtime")
+
+    obj, err := ctx.Open(name)
+    fatalOnError(t, err, "Open")
+
+    if !obj.ModTime().Equal(mtime) {
+        t.Errorf("Object mtime mismatch, was %v, expected %v", obj.ModTime(), mtime)
+    }
+
+    // Overwrite part of the object with a later mtime
+    mtime = mtime.Add(time.Hour)
+    n, err := obj.WriteAtWithMtime([]byte("C"), 2, mtime)
+    fatalOnError(t, err, "WriteAtWithMtime")
+
+    if n != 1 {
+        t.Errorf("Expected to have 1 bytes written but was %d", n)
+    }
+
+    err = obj.Stat()
+    fatalOnError(t, err, "Stat")
+
+    if !obj.ModTime().Equal(mtime) {
+        t.Errorf("Object mtime mismatch, was %v, expected %v", obj.ModTime(), mtime)
+    }
+
+    data, err := obj.Get()
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, []byte("12C45")) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data, "12C45")
+    }
+}
+
+func Test_Touch(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := []byte("test data")
+
+    // Touch a new object
+    err = ctx.Touch(name)
+    fatalOnError(t, err, "Touch")
+
+    objInfo, err := ctx.Stat(name)
+    fatalOnError(t, err, "Stat")
+
+    if objInfo.Size() != int64(0) {
+        t.Errorf("Object size mismatch, was %d, expected %d", objInfo.Size(), 0)
+    }
+
+    // Touch an existing object with an old mtime
+    mtime := time.Unix(1000000000, 0)
+    err = ctx.PutWithMtime(name, data, mtime)
+    fatalOnError(t, err, "PutWithMtime")
+
+    err = ctx.Touch(name)
+    fatalOnError(t, err, "Touch")
+
+    objInfo, err = ctx.Stat(name)
+    fatalOnError(t, err, "Stat")
+
+    if !objInfo.ModTime().After(mtime) {
+        t.Errorf("Object mtime was not updated, was %v", objInfo.ModTime())
+    }
+
+    // The data must be unchanged
+    data2, err := ctx.Get(name)
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data)
+    }
+}
+
+func Test_PutWithXattrs(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := []byte("test data")
+    xattrs := map[string][]byte{
+        "content-type": []byte("text/plain"),
+        "owner":        []byte("test"),
+    }
+
+    err = ctx.PutWithXattrs(name, data, xattrs)
+    fatalOnError(t, err, "PutWithXattrs")
+
+    data2, err := ctx.Get(name)
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data)
+    }
+
+    for xattr, value := range xattrs {
+        value2, err := ctx.GetXattr(name, xattr)
+        errorOnError(t, err, "GetXattr %s", xattr)
+
+        if !bytes.Equal(value, value2) {
+            t.Errorf("Xattr %s mismatch, was %s, expected %s", xattr, value2, value)
+        }
+    }
+
+    // A value that doesn't fit the initial buffer
+    value := bytes.Repeat([]byte("x"), 1000)
+    err = ctx.SetXattr(name, "large", value)
+    fatalOnError(t, err, "SetXattr")
+
+    value2, err := ctx.GetXattr(name, "large")
+    fatalOnError(t, err, "GetXattr")
+
+    if !bytes.Equal(value, value2) {
+        t.Errorf("Xattr large mismatch, was %d bytes, expected %d", len(value2), len(value))
+    }
+}
+
+func Test_PutWithOmap(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := []byte("test data")
+    omap := map[string][]byte{
+        "key1": []byte("value1"),
+        "key2": []byte{},
+    }
+
+    err = ctx.PutWithOmap(name, data, omap)
+    fatalOnError(t, err, "PutWithOmap")
+
+    data2, err := ctx.Get(name)
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data)
+    }
+
+    // Read back the omap, including a key that isn't set
+    vals, err := ctx.OmapGetValsByKeys(name, []string{"key1", "key2", "key3"})
+    fatalOnError(t, err, "OmapGetValsByKeys")
+
+    if len(vals) != len(omap) {
+        t.Errorf("Expected %d omap values, got %d", len(omap), len(vals))
+    }
+
+    for key, val := range omap {
+        if !bytes.Equal(vals[key], val) {
+            t.Errorf("Omap value mismatch for %s, was %s, expected %s", key, vals[key], val)
+        }
+    }
+}
+
+func Test_GetRange(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := []byte("0123456789")
+
+    err = ctx.Put(name, data)
+    fatalOnError(t, err, "Put")
+
+    // Read from the middle of the object
+    data2, err := ctx.GetRange(name, 2, 3)
+    fatalOnError(t, err, "GetRange")
+
+    if !bytes.Equal(data[2:5], data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data[2:5])
+    }
+
+    // Read past the end of the object
+    data2, err = ctx.GetRange(name, 8, 5)
+    fatalOnError(t, err, "GetRange")
+
+    if !bytes.Equal(data[8:], data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data[8:])
+    }
+
+    // Read with a length far past the end of the object
+    data2, err = ctx.GetRange(name, 4, math.MaxInt64)
+    fatalOnError(t, err, "GetRange")
+
+    if !bytes.Equal(data[4:], data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data[4:])
+    }
+
+    data2, err = ctx.GetRange(name, 20, 5)
+    fatalOnError(t, err, "GetRange")
+
+    if len(data2) != 0 {
+        t.Errorf("Expected no data past the end of the object, got %s", data2)
+    }
+
+    // Read a range that doesn't exist
+    if _, err = ctx.GetRange("object that does not exist", 0, 5); err == nil {
+        t.Errorf("GetRange should have failed")
+    }
+
+    if _, err = ctx.object(name).GetRange(0, -1); !errors.Is(err, syscall.EINVAL) {
+        t.Errorf("Expected EINVAL from GetRange with a negative length, got %v", err)
+    }
+
+    // Read through a section reader
+    data2 = make([]byte, 10)
+    n, err := io.ReadFull(ctx.NewSectionReader(name, 4, 4), data2)
+
+    if err != io.ErrUnexpectedEOF {
+        t.Errorf("Expected ErrUnexpectedEOF for short section read, got %v", err)
+    }
+
+    if !bytes.Equal(data[4:8], data2[:n]) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2[:n], data[4:8])
+    }
+}
+
+func Test_GetMany(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    objects := make(map[string][]byte)
+    names := make([]string, 0)
+    for i := 0; i < 20; i++ {
+        name := fmt.Sprintf("test-object-%d", i)
+        objects[name] = []byte(fmt.Sprintf("test data %d", i))
+        names = append(names, name)
+
+        err = ctx.Put(name, objects[name])
+        fatalOnError(t, err, "Put")
+    }
+
+    // Include an empty object and one that doesn't exist
+    _, err = ctx.Create("empty-object")
+    fatalOnError(t, err, "Create")
+    objects["empty-object"] = []byte{}
+    names = append(names, "empty-object", "missing-object")
+
+    data, errs := ctx.GetMany(names, 4)
+
+    if len(errs) != 1 || errs["missing-object"] == nil {
+        t.Errorf("Expected only missing-object to fail, got %v", errs)
+    }
+
+    if len(data) != len(objects) {
+        t.Errorf("Expected %d objects read but was %d", len(objects), len(data))
+    }
+
+    for name := range objects {
+        if !bytes.Equal(objects[name], data[name]) {
+            t.Errorf("Object %s data mismatch, was %s, expected %s", name, data[name], objects[name])
+        }
+    }
+}
+
+func Test_PutMany(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    objects := make(map[string][]byte)
+    for i := 0; i < 20; i++ {
+        name := fmt.Sprintf("test-object-%d", i)
+        objects[name] = []byte(fmt.Sprintf("test data %d", i))
+    }
+    objects["empty-object"] = []byte{}
+
+    errs := ctx.PutMany(objects, 4, false)
+
+    if len(errs) != 0 {
+        t.Errorf("Expected no errors from PutMany, got %v", errs)
+    }
+
+    for name := range objects {
+        data, err := ctx.Get(name)
+        errorOnError(t, err, "Get %s", name)
+
+        if !bytes.Equal(objects[name], data) {
+            t.Errorf("Object %s data mismatch, was %s, expected %s", name, data, objects[name])
+        }
+    }
+}
+
+func Test_Transaction(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Put("old-object", []byte("old data"))
+    fatalOnError(t, err, "Put")
+
+    // Commit a transaction touching several objects
+    tx := ctx.NewTransaction("test-journal")
+    tx.Put("new-object", []byte("new data"))
+    tx.Put("old-object", []byte("updated data"))
+    tx.Remove("removed-object")
+
+    err = ctx.Put("removed-object", []byte("removed data"))
+    fatalOnError(t, err, "Put")
+
+    err = tx.Commit()
+    fatalOnError(t, err, "Commit")
+
+    data, err := ctx.Get("new-object")
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, []byte("new data")) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data, "new data")
+    }
+
+    data, err = ctx.Get("old-object")
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, []byte("updated data")) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data, "updated data")
+    }
+
+    if _, err = ctx.Stat("removed-object"); err == nil {
+        t.Errorf("Object removed-object should have been removed")
+    }
+
+    if _, err = ctx.Stat("test-journal"); err == nil {
+        t.Errorf("Journal should have been removed")
+    }
+
+    // Leave a journal behind as if the client crashed after applying
+    // the first mutation, then recover it
+    version, cerr, err := ctx.objectVersion("old-object")
+    fatalOnError(t, err, "objectVersion")
+    if cerr < 0 {
+        t.Fatalf("objectVersion failed: %s", radosErrno(cerr))
+    }
+
+    record := transactionRecord{
+        ID: "crashed",
+        Ops: []transactionOp{
+            {Op: "put", Name: "new-object", Data: []byte("recovered data"), Exists: true},
+            {Op: "put", Name: "old-object", Data: []byte("recovered data"), Exists: true, Version: version},
+        },
+    }
+
+    err = ctx.SetXattr("new-object", transactionXattr, []byte("crashed"))
+    fatalOnError(t, err, "SetXattr")
+
+    journal, err := json.Marshal(&record)
+    fatalOnError(t, err, "Marshal")
+
+    err = ctx.Put("test-journal", journal)
+    fatalOnError(t, err, "Put")
+
+    err = ctx.RecoverTransaction("test-journal")
+    fatalOnError(t, err, "RecoverTransaction")
+
+    // The applied mutation must not be applied again
+    data, err = ctx.Get("new-object")
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, []byte("new data")) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data, "new data")
+    }
+
+    data, err = ctx.Get("old-object")
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, []byte("recovered data")) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data, "recovered data")
+    }
+
+    // A transaction on a modified object must conflict
+    tx = ctx.NewTransaction("test-journal")
+    tx.Put("old-object", []byte("conflicting data"))
+
+    record = transactionRecord{
+        ID:  "conflict",
+        Ops: []transactionOp{{Op: "put", Name: "old-object", Exists: true, Version: version}},
+    }
+
+    journal, err = json.Marshal(&record)
+    fatalOnError(t, err, "Marshal")
+
+    err = ctx.Put("test-journal", journal)
+    fatalOnError(t, err, "Put")
+
+    if err = tx.Commit(); err == nil {
+        t.Errorf("Commit should have failed while a journal exists")
+    }
+
+    if err = ctx.RecoverTransaction("test-journal"); !errors.Is(err, ErrTransactionConflict) {
+        t.Errorf("Expected ErrTransactionConflict from RecoverTransaction, got %v", err)
+    }
+}
+
+func Test_Seal(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := []byte("test data")
+
+    obj, err := ctx.Create(name)
+    fatalOnError(t, err, "Create")
+
+    err = obj.Put(data)
+    fatalOnError(t, err, "Put")
+
+    sealed, err := obj.IsSealed()
+    fatalOnError(t, err, "IsSealed")
+
+    if sealed {
+        t.Errorf("Object should not be sealed")
+    }
+
+    err = obj.Seal()
+    fatalOnError(t, err, "Seal")
+
+    // Sealing twice is fine
+    err = obj.Seal()
+    fatalOnError(t, err, "Seal")
+
+    sealed, err = obj.IsSealed()
+    fatalOnError(t, err, "IsSealed")
+
+    if !sealed {
+        t.Errorf("Object should be sealed")
+    }
+
+    ver, err := obj.VersionRead()
+    fatalOnError(t, err, "VersionRead")
+
+    if ver.Tag != sealTag {
+        t.Errorf("Expected the version tag of a sealed object, got %+v", ver)
+    }
+
+    if err = obj.Put([]byte("new data")); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from Put, got %v", err)
+    }
+
+    if _, err = obj.WriteAt([]byte("new data"), 0); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from WriteAt, got %v", err)
+    }
+
+    if err = obj.Remove(); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from Remove, got %v", err)
+    }
+
+    // The seal is enforced by the OSD, so the Context functions are
+    // refused too
+    if err = ctx.Put(name, []byte("new data")); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from Context.Put, got %v", err)
+    }
+
+    if err = ctx.Append(name, []byte("more")); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from Context.Append, got %v", err)
+    }
+
+    if err = ctx.Truncate(name, 0); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from Context.Truncate, got %v", err)
+    }
+
+    if err = ctx.Remove(name); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from Context.Remove, got %v", err)
+    }
+
+    write, err := ctx.AioWriteFull(name, []byte("new data"))
+    fatalOnError(t, err, "AioWriteFull")
+    if err = write.Wait(); !errors.Is(err, ErrImmutable) {
+        t.Errorf("Expected ErrImmutable from AioWriteFull, got %v", err)
+    }
+    write.Release()
+
+    // Writes to objects that don't exist are not affected by the guard
+    err = ctx.Put("unsealed", data)
+    fatalOnError(t, err, "Put")
+
+    // The data must be unchanged
+    data2, err := obj.Get()
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data)
+    }
+}
+
+func Test_CheckQuota(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    // No quota on a new pool
+    err = ctx.CheckQuota(1 << 20)
+    fatalOnError(t, err, "CheckQuota")
+
+    // Limit the pool to a single object
+    cmd := fmt.Sprintf(`{"prefix": "osd pool set-quota", "pool": "%s", "field": "max_objects", "val": "1"}`,
+        test.poolName)
+    _, _, err = test.rados.MonCommand([]byte(cmd))
+    fatalOnError(t, err, "MonCommand")
+
+    err = ctx.Put("test-object", []byte("test data"))
+    fatalOnError(t, err, "Put")
+
+    // Pool statistics are updated lazily by the OSDs
+    for i := 0; i < 30; i++ {
+        if err = ctx.CheckQuota(0); err != nil {
+            break
+        }
+        time.Sleep(time.Second)
+    }
+
+    if !errors.Is(err, ErrQuotaExceeded) {
+        t.Errorf("Expected ErrQuotaExceeded from CheckQuota, got %v", err)
+    }
+
+    var spaceErr *SpaceError
+    if errors.As(err, &spaceErr) && spaceErr.Pool != test.poolName {
+        t.Errorf("Expected error for pool %s, got %s", test.poolName, spaceErr.Pool)
+    }
+}
+
+func Test_SpaceError(t *testing.T) {
+    err := error(&SpaceError{Op: "put", Name: "obj", Pool: "data", Err: ErrNoSpace})
+
+    if !errors.Is(err, ErrNoSpace) || !errors.Is(err, ErrClusterFull) {
+        t.Errorf("Expected %v to match ErrNoSpace and ErrClusterFull", err)
+    }
+
+    if errors.Is(err, ErrQuotaExceeded) {
+        t.Errorf("Expected %v not to match ErrQuotaExceeded", err)
+    }
+}
+
+func Test_Errno(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    _, err = ctx.Stat("missing")
+    if !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected ENOENT from Stat, got %v", err)
+    }
+
+    if errno := Errno(err); errno != syscall.ENOENT {
+        t.Errorf("Expected errno ENOENT, got %d", errno)
+    }
+
+    err = &SpaceError{Op: "put", Name: "obj", Pool: "data", Err: ErrQuotaExceeded, Errno: syscall.EDQUOT}
+    if !errors.Is(err, ErrQuotaExceeded) || Errno(err) != syscall.EDQUOT {
+        t.Errorf("Expected %v to match ErrQuotaExceeded and EDQUOT", err)
+    }
+
+    if Errno(ErrInvalidName) != 0 {
+        t.Errorf("Expected no errno for ErrInvalidName")
+    }
+}
+
+func Test_OpFlags(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    ctx.SetOpFlags(OpBalanceReads | OpFullTry)
+    ctx.SetFadvise(FadviseSequential | FadviseDontNeed)
+
+    clone, err := ctx.Clone()
+    fatalOnError(t, err, "Clone")
+    defer clone.Release()
+
+    if clone.OpFlags() != ctx.OpFlags() || clone.Fadvise() != ctx.Fadvise() {
+        t.Errorf("Expected clone flags %d/%d, got %d/%d", ctx.OpFlags(), ctx.Fadvise(), clone.OpFlags(), clone.Fadvise())
+    }
+
+    data := []byte("flagged data")
+
+    err = ctx.Put("flagged", data)
+    fatalOnError(t, err, "Put")
+
+    obj, err := ctx.Open("flagged")
+    fatalOnError(t, err, "Open")
+
+    _, err = obj.WriteAt([]byte("FLAGGED"), 0)
+    fatalOnError(t, err, "WriteAt")
+
+    got, err := ctx.Get("flagged")
+    fatalOnError(t, err, "Get")
+
+    if string(got) != "FLAGGED data" {
+        t.Errorf("Expected %q, got %q", "FLAGGED data", got)
+    }
+
+    err = ctx.Remove("flagged")
+    fatalOnError(t, err, "Remove")
+
+    if _, err = ctx.Stat("flagged"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected ENOENT after Remove, got %v", err)
+    }
+}
+
+func Test_ReadPolicy(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    if ctx.ReadPolicy() != ReadPrimary {
+        t.Errorf("Expected ReadPrimary by default, got %d", ctx.ReadPolicy())
+    }
+
+    ctx.SetOpFlags(OpFullTry)
+    ctx.SetReadPolicy(ReadBalance)
+    ctx.SetReadPolicy(ReadLocalize)
+
+    if ctx.ReadPolicy() != ReadLocalize || ctx.OpFlags() != OpFullTry|OpLocalizeReads {
+        t.Errorf("Expected ReadLocalize with OpFullTry, got %d (flags %d)", ctx.ReadPolicy(), ctx.OpFlags())
+    }
+
+    err = ctx.Put("replicated", []byte("replicated data"))
+    fatalOnError(t, err, "Put")
+
+    data, err := ctx.Get("replicated")
+    fatalOnError(t, err, "Get")
+
+    if string(data) != "replicated data" {
+        t.Errorf("Expected %q, got %q", "replicated data", data)
+    }
+
+    ctx.SetReadPolicy(ReadPrimary)
+    if ctx.OpFlags() != OpFullTry {
+        t.Errorf("Expected only OpFullTry after ReadPrimary, got %d", ctx.OpFlags())
+    }
+}
+
+func Test_CacheTier(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    tier := poolName()
+    err := test.rados.CreatePool(tier)
+    fatalOnError(t, err, "CreatePool")
+    defer test.rados.DeletePool(tier)
+
+    err = test.rados.AddTier(test.poolName, tier)
+    fatalOnError(t, err, "AddTier")
+
+    err = test.rados.SetCacheMode(tier, CacheModeWriteback)
+    fatalOnError(t, err, "SetCacheMode")
+
+    err = test.rados.SetHitSet(tier, HitSetConfig{Type: "bloom", Count: 4, Period: time.Hour, FPP: 0.05})
+    errorOnError(t, err, "SetHitSet")
+
+    err = test.rados.SetCacheTargets(tier, CacheTargets{
+        MaxBytes:       1 << 30,
+        DirtyRatio:     0.4,
+        DirtyHighRatio: 0.6,
+        FullRatio:      0.8,
+    })
+    errorOnError(t, err, "SetCacheTargets")
+
+    err = test.rados.SetOverlay(test.poolName, tier)
+    fatalOnError(t, err, "SetOverlay")
+
+    err = test.rados.RemoveOverlay(test.poolName)
+    errorOnError(t, err, "RemoveOverlay")
+
+    err = test.rados.SetCacheMode(tier, CacheModeProxy)
+    errorOnError(t, err, "SetCacheMode")
+
+    err = test.rados.RemoveTier(test.poolName, tier)
+    errorOnError(t, err, "RemoveTier")
+}
+
+func Test_Autoscale(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    err := test.rados.SetAutoscaleMode(test.poolName, AutoscaleWarn)
+    fatalOnError(t, err, "SetAutoscaleMode")
+
+    mode, err := test.rados.AutoscaleMode(test.poolName)
+    fatalOnError(t, err, "AutoscaleMode")
+
+    if mode != AutoscaleWarn {
+        t.Errorf("Expected mode %s, got %s", AutoscaleWarn, mode)
+    }
+
+    err = test.rados.SetTargetSizeRatio(test.poolName, 0.25)
+    fatalOnError(t, err, "SetTargetSizeRatio")
+
+    ratio, err := test.rados.TargetSizeRatio(test.poolName)
+    fatalOnError(t, err, "TargetSizeRatio")
+
+    if ratio != 0.25 {
+        t.Errorf("Expected target size ratio 0.25, got %g", ratio)
+    }
+
+    err = test.rados.SetPGNum(test.poolName, 16)
+    fatalOnError(t, err, "SetPGNum")
+
+    if _, err = test.rados.PGNum(test.poolName); err != nil {
+        t.Errorf("PGNum failed: %v", err)
+    }
+
+    status, err := test.rados.AutoscaleStatus()
+    fatalOnError(t, err, "AutoscaleStatus")
+
+    found := false
+    for _, pool := range status {
+        if pool.Pool == test.poolName {
+            found = true
+
+            if pool.Mode != AutoscaleWarn || pool.TargetRatio != 0.25 {
+                t.Errorf("Unexpected autoscale status %+v", pool)
+            }
+        }
+    }
+
+    if !found {
+        t.Errorf("Pool %s missing from autoscale status", test.poolName)
+    }
+}
+
+func Test_CrushRules(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    rules, err := test.rados.CrushRules()
+    fatalOnError(t, err, "CrushRules")
+
+    if len(rules) == 0 {
+        t.Fatalf("Expected at least one CRUSH rule")
+    }
+
+    rule, err := test.rados.CrushRule(rules[0].Name)
+    fatalOnError(t, err, "CrushRule")
+
+    if rule.ID != rules[0].ID || rule.Root() == "" || rule.FailureDomain() == "" {
+        t.Errorf("Unexpected CRUSH rule %+v", rule)
+    }
+
+    if _, err = test.rados.CrushRule("no-such-rule"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected ENOENT for a missing rule, got %v", err)
+    }
+}
+
+func Test_WatchPGs(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    summary, err := test.rados.PGSummary()
+    fatalOnError(t, err, "PGSummary")
+
+    if summary.Total == 0 {
+        t.Errorf("Expected placement groups, got %+v", summary)
+    }
+
+    if _, err = test.rados.WatchPGs(0); err == nil {
+        t.Errorf("Expected WatchPGs to reject a zero interval")
+    }
+
+    w, err := test.rados.WatchPGs(100 * time.Millisecond)
+    fatalOnError(t, err, "WatchPGs")
+
+    select {
+    case update := <-w.C:
+        fatalOnError(t, update.Err, "WatchPGs")
+
+        if update.Summary.Total == 0 {
+            t.Errorf("Expected placement groups in update, got %+v", update.Summary)
+        }
+    case <-time.After(10 * time.Second):
+        t.Errorf("No update from WatchPGs")
+    }
+
+    errorOnError(t, w.Close(), "Close")
+    errorOnError(t, w.Close(), "Close again")
+
+    if _, ok := <-w.C; ok {
+        t.Errorf("Expected channel to be closed after Close")
+    }
+}
+
+func Test_MonitorCapacity(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    alerts := make(chan CapacityAlert, 10)
+
+    // Usage is always at least 0 and never above 2
+    m, err := test.rados.MonitorCapacity(CapacityMonitorOptions{
+        Interval:   100 * time.Millisecond,
+        Thresholds: []float64{2, 0},
+        Pools:      []string{test.poolName},
+        OnAlert: func(alert CapacityAlert) {
+            alerts <- alert
+        },
+        OnError: func(err error) {
+            t.Errorf("MonitorCapacity failed: %v", err)
+        },
+    })
+    fatalOnError(t, err, "MonitorCapacity")
+    defer m.Close()
+
+    seen := make(map[string]bool)
+    for len(seen) < 2 {
+        select {
+        case alert := <-alerts:
+            if alert.Threshold != 0 || !alert.Rising {
+                t.Errorf("Unexpected alert %+v", alert)
+            }
+            seen[alert.Pool] = true
+        case <-time.After(10 * time.Second):
+            t.Fatalf("Missing alerts, got %v", seen)
+        }
+    }
+
+    errorOnError(t, m.Close(), "Close")
+
+    if !seen[""] || !seen[test.poolName] {
+        t.Errorf("Expected alerts for the cluster and pool, got %v", seen)
+    }
+
+    select {
+    case alert := <-alerts:
+        t.Errorf("Unexpected repeated alert %+v", alert)
+    default:
+    }
+}
+
+func Test_Reconnect(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    rados, err := NewDefault()
+    fatalOnError(t, err, "NewDefault")
+    defer rados.Release()
+
+    id := rados.InstanceID()
+
+    err = rados.Reconnect()
+    fatalOnError(t, err, "Reconnect")
+
+    if rados.InstanceID() == id {
+        t.Errorf("Expected a new instance ID after Reconnect")
+    }
+
+    ctx, err := rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Put("reconnected", []byte("data"))
+    errorOnError(t, err, "Put")
+
+    shared, err := DefaultCluster()
+    fatalOnError(t, err, "DefaultCluster")
+    defer shared.Release()
+
+    if err = shared.Reconnect(); err == nil {
+        t.Errorf("Expected Reconnect of a shared handle to fail")
+    }
+}
+
+func Test_NamedClusters(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    err := RegisterCluster("primary", ClusterConfig{User: "admin"})
+    fatalOnError(t, err, "RegisterCluster")
+    defer UnregisterCluster("primary")
+
+    if err = RegisterCluster("primary", ClusterConfig{}); err == nil {
+        t.Errorf("Expected registering a cluster twice to fail")
+    }
+
+    ctx, err := Cluster("primary").NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Put("named", []byte("data"))
+    errorOnError(t, err, "Put")
+
+    r1, err := Cluster("primary").Rados()
+    fatalOnError(t, err, "Rados")
+
+    r2, err := Cluster("primary").Rados()
+    fatalOnError(t, err, "Rados")
+
+    if r1 != r2 {
+        t.Errorf("Expected the same handle for a named cluster")
+    }
+
+    if _, err = Cluster("missing").NewContext(test.poolName); err == nil {
+        t.Errorf("Expected an error for an unregistered cluster")
+    }
+}
+
+func Test_WithKey(t *testing.T) {
+    rados, err := NewDefault()
+    fatalOnError(t, err, "NewDefault")
+
+    user, err := rados.ConfGet("name")
+    fatalOnError(t, err, "ConfGet")
+
+    var auth []struct {
+        Key string `json:"key"`
+    }
+    err = rados.monCommandJSON(map[string]interface{}{
+        "prefix": "auth get",
+        "entity": user,
+    }, &auth)
+    rados.Release()
+    fatalOnError(t, err, "auth get")
+
+    if len(auth) != 1 {
+        t.Fatalf("Expected one key for %s, got %d", user, len(auth))
+    }
+
+    rados, err = NewWithOptions(WithKeyring("/nonexistent"), WithKey(auth[0].Key))
+    fatalOnError(t, err, "NewWithOptions")
+    defer rados.Release()
+
+    if _, err = rados.ListPools(); err != nil {
+        t.Errorf("ListPools with inline key failed: %v", err)
+    }
+
+    _, err = NewWithOptions(WithKeyring("/nonexistent"), WithKey("AQBuHjRkAAAAABAAVmPxW5zsLNU3IQfQnXhsrQ=="))
+    if err == nil {
+        t.Errorf("Expected connecting with a wrong key to fail")
+    }
+}
+
+func Test_WithMonHost(t *testing.T) {
+    rados, err := NewDefault()
+    fatalOnError(t, err, "NewDefault")
+
+    monHost, err := rados.ConfGet("mon_host")
+    fatalOnError(t, err, "ConfGet")
+
+    keyring, err := rados.ConfGet("keyring")
+    rados.Release()
+    fatalOnError(t, err, "ConfGet")
+
+    rados, err = NewWithOptions(WithMonHost(monHost), WithKeyring(keyring))
+    fatalOnError(t, err, "NewWithOptions")
+    defer rados.Release()
+
+    if _, err = rados.ListPools(); err != nil {
+        t.Errorf("ListPools without configuration file failed: %v", err)
+    }
+}
+
+func Test_ParallelReadAt(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    data := make([]byte, 1<<20+123)
+    for i := range data {
+        data[i] = byte(i * 7)
+    }
+
+    err = ctx.Put("large", data)
+    fatalOnError(t, err, "Put")
+
+    obj, err := ctx.Open("large")
+    fatalOnError(t, err, "Open")
+    obj.SetMaxChunkSize(64 << 10)
+
+    buf := make([]byte, len(data)-100)
+    n, err := obj.ParallelReadAt(buf, 100, 8)
+    fatalOnError(t, err, "ParallelReadAt")
+
+    if n != len(buf) || !bytes.Equal(buf, data[100:]) {
+        t.Errorf("ParallelReadAt returned wrong data (%d bytes)", n)
+    }
+
+    // Reading past the end returns the available data and io.EOF
+    buf = make([]byte, 200<<10)
+    n, err = obj.ParallelReadAt(buf, int64(len(data)-1000), 4)
+    if err != io.EOF || n != 1000 || !bytes.Equal(buf[:n], data[len(data)-1000:]) {
+        t.Errorf("Expected 1000 bytes and io.EOF, got %d bytes and %v", n, err)
+    }
+}
+
+func Test_ListObjectsAtEpoch(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    for i := 0; i < 10; i++ {
+        err = ctx.Put(fmt.Sprintf("obj-%d", i), []byte("data"))
+        fatalOnError(t, err, "Put")
+    }
+
+    epoch, err := test.rados.OSDMapEpoch()
+    fatalOnError(t, err, "OSDMapEpoch")
+
+    iter, err := ctx.ListObjectsAtEpoch()
+    fatalOnError(t, err, "ListObjectsAtEpoch")
+
+    if iter.Epoch() < epoch {
+        t.Errorf("Expected epoch >= %d, got %d", epoch, iter.Epoch())
+    }
+
+    // Changing the pool changes the OSD map
+    err = test.rados.poolSet(test.poolName, "target_size_ratio", "0.1")
+    fatalOnError(t, err, "poolSet")
+
+    for iter.Next() {
+    }
+    iter.Close()
+
+    if !errors.Is(iter.Err(), ErrEpochChanged) {
+        t.Errorf("Expected ErrEpochChanged, got %v", iter.Err())
+    }
+}
+
+func Test_Trash(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.SetNamespace("ns")
+    fatalOnError(t, err, "SetNamespace")
+
+    err = ctx.PutWithXattrs("precious", []byte("precious data"), map[string][]byte{"owner": []byte("me")})
+    fatalOnError(t, err, "PutWithXattrs")
+
+    err = ctx.Put("expendable", []byte("expendable data"))
+    fatalOnError(t, err, "Put")
+
+    ctx.SetTrash(time.Hour)
+    err = ctx.Remove("precious")
+    fatalOnError(t, err, "Remove")
+
+    ctx.SetTrash(time.Nanosecond)
+    err = ctx.Remove("expendable")
+    fatalOnError(t, err, "Remove")
+
+    if _, err = ctx.Stat("precious"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected trashed object to be gone, got %v", err)
+    }
+
+    // Stray objects in the trash namespace are not listed
+    stray, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer stray.Release()
+
+    err = stray.SetNamespace(trashNamespace)
+    fatalOnError(t, err, "SetNamespace")
+    err = stray.Put("stray", []byte("stray data"))
+    fatalOnError(t, err, "Put")
+
+    entries, err := ctx.ListTrash()
+    fatalOnError(t, err, "ListTrash")
+
+    if len(entries) != 2 {
+        t.Fatalf("Expected 2 trash entries, got %d", len(entries))
+    }
+
+    report, err := ctx.PurgeExpiredTrash(&DeleteOptions{DryRun: true})
+    fatalOnError(t, err, "PurgeExpiredTrash")
+
+    if report.Objects != 1 || report.Bytes != uint64(len("expendable data")) {
+        t.Errorf("Expected 1 entry to purge, got %+v", report)
+    }
+
+    report, err = ctx.PurgeExpiredTrash(nil)
+    fatalOnError(t, err, "PurgeExpiredTrash")
+
+    if report.Objects != 1 || len(report.Errors) != 0 {
+        t.Errorf("Expected 1 purged entry, got %+v", report)
+    }
+
+    entries, err = ctx.ListTrash()
+    fatalOnError(t, err, "ListTrash")
+
+    if len(entries) != 1 || entries[0].Name != "precious" || entries[0].Namespace != "ns" {
+        t.Fatalf("Unexpected trash entries %+v", entries)
+    }
+
+    err = ctx.RestoreTrash(entries[0].ID)
+    fatalOnError(t, err, "RestoreTrash")
+
+    data, err := ctx.Get("precious")
+    fatalOnError(t, err, "Get")
+
+    if string(data) != "precious data" {
+        t.Errorf("Expected restored data, got %q", data)
+    }
+
+    xattrs, err := ctx.GetXattrs("precious")
+    fatalOnError(t, err, "GetXattrs")
+
+    if len(xattrs) != 1 || string(xattrs["owner"]) != "me" {
+        t.Errorf("Unexpected restored xattrs %v", xattrs)
+    }
+
+    // Restoring over an object created again under the name fails
+    ctx.SetTrash(time.Hour)
+    err = ctx.Remove("precious")
+    fatalOnError(t, err, "Remove")
+
+    err = ctx.Put("precious", []byte("new data"))
+    fatalOnError(t, err, "Put")
+
+    if err = ctx.Restore("precious"); !errors.Is(err, syscall.EEXIST) {
+        t.Errorf("Expected EEXIST, got %v", err)
+    }
+
+    data, err = ctx.Get("precious")
+    fatalOnError(t, err, "Get")
+
+    if string(data) != "new data" {
+        t.Errorf("Expected the new object to be kept, got %q", data)
+    }
+}
+
+func Test_Tags(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    for _, name := range []string{"apple", "cherry", "banana"} {
+        err = ctx.Put(name, []byte(name))
+        fatalOnError(t, err, "Put")
+    }
+
+    err = ctx.SetTags("apple", map[string]string{"color": "red", "kind": "fruit"})
+    fatalOnError(t, err, "SetTags")
+    err = ctx.SetTags("cherry", map[string]string{"color": "red"})
+    fatalOnError(t, err, "SetTags")
+    err = ctx.SetTags("banana", map[string]string{"color": "yellow"})
+    fatalOnError(t, err, "SetTags")
+
+    // Retagging moves the object in the index
+    err = ctx.SetTags("apple", map[string]string{"color": "green"})
+    fatalOnError(t, err, "SetTags")
+
+    tags, err := ctx.Tags("apple")
+    fatalOnError(t, err, "Tags")
+
+    if len(tags) != 1 || tags["color"] != "green" {
+        t.Errorf("Unexpected tags %v", tags)
+    }
+
+    var red []string
+    iter := ctx.TagIndex("color").Lookup("red")
+    for iter.Next() {
+        red = append(red, iter.Entry().Name)
+    }
+    fatalOnError(t, iter.Err(), "Lookup")
+
+    if len(red) != 1 || red[0] != "cherry" {
+        t.Errorf("Expected [cherry] tagged red, got %v", red)
+    }
+
+    iter = ctx.TagIndex("kind").Lookup("fruit")
+    if iter.Next() {
+        t.Errorf("Expected no objects of kind fruit, got %s", iter.Entry().Name)
+    }
+
+    if err = ctx.SetTags("missing", map[string]string{"color": "red"}); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected ENOENT tagging a missing object, got %v", err)
+    }
+
+    if err = ctx.SetTags("apple", map[string]string{"bad\x01key": "x"}); !errors.Is(err, ErrInvalidName) {
+        t.Errorf("Expected ErrInvalidName for a bad tag key, got %v", err)
+    }
+}
+
+func Test_FindTags(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    sizes := map[string]string{"small": "1", "medium": "50", "large": "900", "gone": "700"}
+    for name, size := range sizes {
+        err = ctx.Put(name, []byte(name))
+        fatalOnError(t, err, "Put")
+
+        err = ctx.SetTags(name, map[string]string{"size": size, "owner": "alice"})
+        fatalOnError(t, err, "SetTags")
+    }
+
+    // Removed objects are skipped even though they remain in the index
+    err = ctx.Remove("gone")
+    fatalOnError(t, err, "Remove")
+
+    iter := ctx.FindByTag("size", "900")
+    if !iter.Next() || iter.Name() != "large" || iter.Tags()["owner"] != "alice" {
+        t.Errorf("Expected to find large, got %q", iter.Name())
+    }
+    if iter.Next() {
+        t.Errorf("Unexpected match %s", iter.Name())
+    }
+    fatalOnError(t, iter.Err(), "FindByTag")
+
+    var big []string
+    iter = ctx.FindTagged("size", func(name string, tags map[string]string) bool {
+        size, err := strconv.Atoi(tags["size"])
+        return err == nil && size > 10
+    })
+    for iter.Next() {
+        big = append(big, iter.Name())
+    }
+    fatalOnError(t, iter.Err(), "FindTagged")
+
+    if strings.Join(big, ",") != "medium,large" {
+        t.Errorf("Expected [medium large], got %v", big)
+    }
+}
+
+func Test_Audit(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    var buf bytes.Buffer
+    err := test.rados.SetAuditSink(NewWriterAuditSink(&buf), func(err error) {
+        t.Errorf("Audit sink failed: %v", err)
+    })
+    fatalOnError(t, err, "SetAuditSink")
+    defer test.rados.SetAuditSink(nil, nil)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Put("audited", []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    _, err = ctx.Get("audited")
+    fatalOnError(t, err, "Get")
+
+    err = ctx.Truncate("audited", 2)
+    fatalOnError(t, err, "Truncate")
+
+    err = ctx.Remove("audited")
+    fatalOnError(t, err, "Remove")
+
+    err = ctx.Remove("audited")
+    if err == nil {
+        t.Fatalf("Expected second Remove to fail")
+    }
+
+    var ops []string
+    dec := json.NewDecoder(&buf)
+    for {
+        var entry AuditEntry
+        if err := dec.Decode(&entry); err == io.EOF {
+            break
+        } else if err != nil {
+            t.Fatalf("Decoding audit entry failed: %v", err)
+        }
+
+        if entry.Object != "audited" || entry.Pool != test.poolName || entry.Client == "" {
+            t.Errorf("Unexpected audit entry %+v", entry)
+        }
+
+        op := entry.Op
+        if entry.Error != "" {
+            op += " failed"
+        }
+        ops = append(ops, op)
+    }
+
+    if strings.Join(ops, ",") != "put,truncate,remove,remove failed" {
+        t.Errorf("Unexpected audited operations %v", ops)
+    }
+
+    // Audit entries can be appended to an object without being audited
+    err = test.rados.SetAuditSink(NewObjectAuditSink(ctx, "audit.log"), nil)
+    fatalOnError(t, err, "SetAuditSink")
+
+    err = ctx.Put("audited", []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    log, err := ctx.Get("audit.log")
+    fatalOnError(t, err, "Get")
+
+    if bytes.Count(log, []byte("\n")) != 1 {
+        t.Errorf("Expected one audit entry in the log object, got %q", log)
+    }
+}
+
+func Test_RemoveAll(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    for i := 0; i < 20; i++ {
+        err = ctx.Put(fmt.Sprintf("tmp-%d", i), []byte("12345"))
+        fatalOnError(t, err, "Put")
+    }
+
+    err = ctx.Put("keep", []byte("keep"))
+    fatalOnError(t, err, "Put")
+
+    report, err := ctx.RemoveAll("tmp-", &DeleteOptions{DryRun: true, Concurrency: 4})
+    fatalOnError(t, err, "RemoveAll")
+
+    if !report.DryRun || report.Objects != 20 || report.Bytes != 100 || len(report.Names) != 20 {
+        t.Errorf("Unexpected dry-run report %+v", report)
+    }
+
+    if _, err = ctx.Stat("tmp-0"); err != nil {
+        t.Errorf("Dry run removed objects: %v", err)
+    }
+
+    report, err = ctx.RemoveAll("tmp-", &DeleteOptions{Concurrency: 4})
+    fatalOnError(t, err, "RemoveAll")
+
+    if report.Objects != 20 || len(report.Errors) != 0 {
+        t.Errorf("Unexpected report %+v", report)
+    }
+
+    if _, err = ctx.Stat("tmp-0"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected tmp-0 to be removed, got %v", err)
+    }
+
+    if _, err = ctx.Stat("keep"); err != nil {
+        t.Errorf("Expected keep to remain: %v", err)
+    }
+
+    report, err = test.rados.DeletePoolWithOptions(test.poolName, &DeleteOptions{DryRun: true})
+    fatalOnError(t, err, "DeletePoolWithOptions")
+
+    if report.Objects != 1 {
+        t.Errorf("Expected 1 object in the pool, got %+v", report)
+    }
+}
+
+func Test_DeletePoolWithOptions(t *testing.T) {
+    rados, err := NewDefault()
+    fatalOnError(t, err, "New")
+    defer rados.Release()
+
+    pool := poolName()
+    err = rados.CreatePool(pool)
+    fatalOnError(t, err, "CreatePool")
+
+    ctx, err := rados.NewContext(pool)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Put("obj", []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    _, err = rados.DeletePoolWithOptions(pool, nil)
+    if !errors.Is(err, ErrPoolDeleteNotAllowed) {
+        t.Errorf("Expected ErrPoolDeleteNotAllowed, got %v", err)
+    }
+
+    opts := &DeleteOptions{AllowPoolDelete: true, EnablePoolDelete: true, RefuseNonEmpty: true}
+    _, err = rados.DeletePoolWithOptions(pool, opts)
+    if !errors.Is(err, ErrPoolNotEmpty) {
+        t.Errorf("Expected ErrPoolNotEmpty, got %v", err)
+    }
+
+    err = ctx.Remove("obj")
+    fatalOnError(t, err, "Remove")
+
+    _, err = rados.DeletePoolWithOptions(pool, opts)
+    fatalOnError(t, err, "DeletePoolWithOptions")
+
+    pools, err := rados.ListPools()
+    fatalOnError(t, err, "ListPools")
+
+    for _, p := range pools {
+        if p == pool {
+            t.Errorf("Pool %s was not deleted", pool)
+        }
+    }
+}
+
+func Test_RemoveSoft(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Restore("obj")
+    if !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected ENOENT, got %v", err)
+    }
+
+    for _, version := range []string{"v1", "v2"} {
+        err = ctx.Put("obj", []byte(version))
+        fatalOnError(t, err, "Put")
+
+        err = ctx.RemoveSoft("obj", time.Millisecond)
+        fatalOnError(t, err, "RemoveSoft")
+
+        time.Sleep(time.Millisecond)
+    }
+
+    if _, err = ctx.Stat("obj"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected obj to be removed, got %v", err)
+    }
+
+    err = ctx.Restore("obj")
+    fatalOnError(t, err, "Restore")
+
+    data, err := ctx.Get("obj")
+    fatalOnError(t, err, "Get")
+
+    if string(data) != "v2" {
+        t.Errorf("Expected the latest version, got %q", data)
+    }
+
+    errs := make(chan error, 1)
+    reaper, err := test.rados.StartTrashReaper(test.poolName, time.Hour, func(err error) {
+        errs <- err
+    })
+    fatalOnError(t, err, "StartTrashReaper")
+    reaper.Close()
+
+    select {
+    case err = <-errs:
+        t.Errorf("Reaper failed: %v", err)
+    default:
+    }
+
+    entries, err := ctx.ListTrash()
+    fatalOnError(t, err, "ListTrash")
+
+    if len(entries) != 0 {
+        t.Errorf("Expected the reaper to purge the trash, got %v", entries)
+    }
+}
+
+func Test_DeleteJob(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    var names []string
+    for i := 0; i < 50; i++ {
+        name := fmt.Sprintf("obj-%d", i)
+        err = ctx.Put(name, []byte("data"))
+        fatalOnError(t, err, "Put")
+        names = append(names, name)
+    }
+    names = append(names, "missing")
+
+    var last DeleteProgress
+    job, err := ctx.StartDelete(names, DeleteJobOptions{
+        Concurrency: 8,
+        OnProgress: func(progress DeleteProgress) {
+            last = progress
+        },
+    })
+    fatalOnError(t, err, "StartDelete")
+
+    job.Pause()
+    job.Resume()
+
+    report := job.Wait()
+
+    if report.Objects != 50 || len(report.Errors) != 1 {
+        t.Errorf("Unexpected report %+v", report)
+    }
+
+    if last.Total != 51 || last.Done != 50 || last.Failed != 1 {
+        t.Errorf("Unexpected final progress %+v", last)
+    }
+
+    if _, err = ctx.Stat("obj-0"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected obj-0 to be removed, got %v", err)
+    }
+
+    err = ctx.Put("obj-0", []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    job, err = ctx.StartDelete([]string{"obj-0"}, DeleteJobOptions{})
+    fatalOnError(t, err, "StartDelete")
+
+    job.Pause()
+    job.Cancel()
+}
+
+func Test_TransferProgress(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    var reports []TransferProgress
+    ctx.SetMaxChunkSize(1024)
+    ctx.SetProgress(func(progress TransferProgress) {
+        reports = append(reports, progress)
+    }, 0)
+
+    data := make([]byte, 10*1024)
+    err = ctx.Put("obj", data)
+    fatalOnError(t, err, "Put")
+
+    if len(reports) != 10 {
+        t.Fatalf("Expected 10 progress reports, got %d", len(reports))
+    }
+
+    last := reports[len(reports)-1]
+    if last.Name != "obj" || last.Bytes != int64(len(data)) || last.Total != int64(len(data)) {
+        t.Errorf("Unexpected final progress %+v", last)
+    }
+
+    reports = nil
+    ctx.SetProgress(func(progress TransferProgress) {
+        reports = append(reports, progress)
+    }, time.Hour)
+
+    _, err = ctx.Get("obj")
+    fatalOnError(t, err, "Get")
+
+    if len(reports) != 1 || reports[0].Bytes != int64(len(data)) {
+        t.Errorf("Expected a single final report, got %+v", reports)
+    }
+
+    // A read cut short by the end of the object still reports its end
+    reports = nil
+    obj, err := ctx.Open("obj")
+    fatalOnError(t, err, "Open")
+
+    n, err := obj.ReadAt(make([]byte, 2048), int64(len(data))-512)
+    if n != 512 || err != io.EOF {
+        t.Fatalf("Expected 512 bytes and io.EOF, got %d and %v", n, err)
+    }
+
+    if len(reports) != 1 || reports[0].Bytes != 512 || reports[0].Total != 512 {
+        t.Errorf("Expected a final report of 512 bytes, got %+v", reports)
+    }
+}
+
+func Test_StatsWindow(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    if stats := ctx.Stats(); stats.Window != 0 || stats.WriteRate != 0 {
+        t.Errorf("Unexpected throughput without a window: %+v", stats)
+    }
+
+    ctx.SetStatsWindow(10 * time.Second)
+
+    err = ctx.Put("obj", make([]byte, 1000))
+    fatalOnError(t, err, "Put")
+
+    _, err = ctx.Get("obj")
+    fatalOnError(t, err, "Get")
+
+    stats := ctx.Stats()
+    if stats.Window != 10*time.Second || stats.WriteRate != 100 || stats.ReadRate != 100 {
+        t.Errorf("Unexpected throughput %+v", stats)
+    }
+
+    clone, err := ctx.Clone()
+    fatalOnError(t, err, "Clone")
+    defer clone.Release()
+
+    if clone.StatsWindow() != 10*time.Second {
+        t.Errorf("Expected the clone to have the window, got %v", clone.StatsWindow())
+    }
+}
+
+func Test_PutFrom(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    ctx.SetMaxChunkSize(1024)
+
+    data := bytes.Repeat([]byte("0123456789"), 1000)
+
+    err = ctx.PutFrom("sized", bytes.NewReader(data), int64(len(data)))
+    fatalOnError(t, err, "PutFrom")
+
+    // Hide the size of the reader
+    err = ctx.PutFrom("unsized", io.MultiReader(bytes.NewReader(data)), -1)
+    fatalOnError(t, err, "PutFrom")
+
+    for _, name := range []string{"sized", "unsized"} {
+        got, err := ctx.Get(name)
+        fatalOnError(t, err, "Get")
+
+        if !bytes.Equal(got, data) {
+            t.Errorf("Unexpected data in %s: %d bytes", name, len(got))
+        }
+    }
+
+    err = ctx.PutFrom("empty", strings.NewReader(""), -1)
+    fatalOnError(t, err, "PutFrom")
+
+    if info, err := ctx.Stat("empty"); err != nil || info.Size() != 0 {
+        t.Errorf("Expected an empty object, got %v", err)
+    }
+
+    err = ctx.PutFrom("short", bytes.NewReader(data), int64(len(data)+1))
+    if !errors.Is(err, io.ErrUnexpectedEOF) {
+        t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+    }
+}
+
+func Test_MirroredContext(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    secondaryPool := poolName()
+    err := test.rados.CreatePool(secondaryPool)
+    fatalOnError(t, err, "CreatePool")
+    defer test.rados.DeletePool(secondaryPool)
+
+    primary, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer primary.Release()
+
+    secondary, err := test.rados.NewContext(secondaryPool)
+    fatalOnError(t, err, "NewContext")
+    defer secondary.Release()
+
+    var failures []string
+    m := NewMirroredContext(primary, secondary, MirrorRequireBoth, func(op, name string, err error) {
+        failures = append(failures, op+" "+name)
+    })
+
+    err = m.Put("obj", []byte("hello"))
+    fatalOnError(t, err, "Put")
+
+    err = m.WriteAt("obj", []byte("J"), 0)
+    fatalOnError(t, err, "WriteAt")
+
+    err = m.SetXattr("obj", "color", []byte("red"))
+    fatalOnError(t, err, "SetXattr")
+
+    err = m.OmapSet("obj", map[string][]byte{"key": []byte("value")})
+    fatalOnError(t, err, "OmapSet")
+
+    for _, c := range []*Context{primary, secondary} {
+        data, err := c.Get("obj")
+        fatalOnError(t, err, "Get")
+
+        if string(data) != "Jello" {
+            t.Errorf("Unexpected data %q in pool %s", data, c.Pool)
+        }
+
+        value, err := c.GetXattr("obj", "color")
+        if err != nil || string(value) != "red" {
+            t.Errorf("Unexpected xattr %q in pool %s: %v", value, c.Pool, err)
+        }
+
+        vals, err := c.OmapGetValsByKeys("obj", []string{"key"})
+        if err != nil || string(vals["key"]) != "value" {
+            t.Errorf("Unexpected omap %v in pool %s: %v", vals, c.Pool, err)
+        }
+    }
+
+    err = primary.Put("only-primary", []byte("x"))
+    fatalOnError(t, err, "Put")
+
+    err = m.Remove("only-primary")
+    fatalOnError(t, err, "Remove")
+
+    // Make the secondary fail exclusive creations
+    for _, name := range []string{"dup1", "dup2"} {
+        err = secondary.Put(name, []byte("x"))
+        fatalOnError(t, err, "Put")
+    }
+
+    create := func(op *WriteOp) {
+        op.Create(true)
+    }
+
+    err = m.Operate("dup1", create)
+    if !errors.Is(err, syscall.EEXIST) {
+        t.Errorf("Expected EEXIST from the secondary, got %v", err)
+    }
+
+    if len(failures) != 1 || failures[0] != "operate dup1" {
+        t.Errorf("Unexpected failures %v", failures)
+    }
+
+    m = NewMirroredContext(primary, secondary, MirrorBestEffort, nil)
+    err = m.Operate("dup2", create)
+    fatalOnError(t, err, "Operate")
+
+    if _, err = primary.Stat("dup2"); err != nil {
+        t.Errorf("Expected dup2 on the primary: %v", err)
+    }
+}
+
+func Test_Replication(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    replicaPool := poolName()
+    err := test.rados.CreatePool(replicaPool)
+    fatalOnError(t, err, "CreatePool")
+    defer test.rados.DeletePool(replicaPool)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    replica, err := test.rados.NewContext(replicaPool)
+    fatalOnError(t, err, "NewContext")
+    defer replica.Release()
+
+    // The journal runs alongside a compliance log
+    var log bytes.Buffer
+    err = test.rados.SetAuditSink(NewWriterAuditSink(&log), nil)
+    fatalOnError(t, err, "SetAuditSink")
+    defer test.rados.SetAuditSink(nil, nil)
+
+    journal := replica.NewReplicationJournal("journal", test.poolName)
+    err = test.rados.AddAuditSink(journal, func(err error) {
+        t.Errorf("Journal failed: %v", err)
+    })
+    fatalOnError(t, err, "AddAuditSink")
+
+    err = ctx.Put("obj", []byte("v1"))
+    fatalOnError(t, err, "Put")
+
+    err = ctx.SetXattr("obj", "color", []byte("red"))
+    fatalOnError(t, err, "SetXattr")
+
+    err = ctx.Put("gone", []byte("x"))
+    fatalOnError(t, err, "Put")
+
+    err = ctx.Remove("gone")
+    fatalOnError(t, err, "Remove")
+
+    opts := &ReplayOptions{Pools: map[string]string{test.poolName: replicaPool}}
+    n, err := journal.Replay(test.rados, opts)
+    fatalOnError(t, err, "Replay")
+
+    if n != 4 {
+        t.Errorf("Expected 4 entries replayed, got %d", n)
+    }
+
+    if lines := strings.Count(log.String(), "\n"); lines < 4 {
+        t.Errorf("Expected the mutations to be logged too, got %d entries", lines)
+    }
+
+    data, err := replica.Get("obj")
+    if err != nil || string(data) != "v1" {
+        t.Errorf("Unexpected replica %q: %v", data, err)
+    }
+
+    if value, err := replica.GetXattr("obj", "color"); err != nil || string(value) != "red" {
+        t.Errorf("Unexpected replica xattr %q: %v", value, err)
+    }
+
+    if _, err = replica.Stat("gone"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected gone not to be replicated, got %v", err)
+    }
+
+    checkpoint, err := journal.Checkpoint()
+    fatalOnError(t, err, "Checkpoint")
+
+    if checkpoint.IsZero() {
+        t.Errorf("Expected a checkpoint")
+    }
+
+    // Nothing left to replay
+    if n, err = journal.Replay(test.rados, opts); err != nil || n != 0 {
+        t.Errorf("Expected nothing to replay, got %d: %v", n, err)
+    }
+
+    // The replica changed after the source: keep it
+    err = ctx.Put("obj", []byte("v2"))
+    fatalOnError(t, err, "Put")
+
+    time.Sleep(2 * time.Second)
+    err = replica.Put("obj", []byte("local"))
+    fatalOnError(t, err, "Put")
+
+    opts.Conflict = ConflictKeepNewer
+    _, err = journal.Replay(test.rados, opts)
+    fatalOnError(t, err, "Replay")
+
+    if data, _ = replica.Get("obj"); string(data) != "local" {
+        t.Errorf("Expected the newer replica to be kept, got %q", data)
+    }
+}
+
+func Test_Update(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    appendX := func(data []byte, exists bool) ([]byte, error) {
+        return append(data, 'x'), nil
+    }
+
+    err = ctx.Update("obj", appendX)
+    fatalOnError(t, err, "Update")
+
+    err = ctx.Update("obj", appendX)
+    fatalOnError(t, err, "Update")
+
+    if data, _ := ctx.Get("obj"); string(data) != "xx" {
+        t.Errorf("Expected xx, got %q", data)
+    }
+
+    // Interleave other writers with the cycle
+    conflicts := []struct {
+        kind   ConflictKind
+        name   string
+        writer func(name string) error
+    }{
+        {ConflictModified, "obj", func(name string) error { return ctx.Put(name, []byte("other")) }},
+        {ConflictDeleted, "obj", ctx.Remove},
+        {ConflictCreated, "new", func(name string) error { return ctx.Put(name, []byte("other")) }},
+    }
+
+    for _, conflict := range conflicts {
+        err = ctx.Update(conflict.name, func(data []byte, exists bool) ([]byte, error) {
+            if err := conflict.writer(conflict.name); err != nil {
+                return nil, err
+            }
+            return []byte("mine"), nil
+        })
+
+        var cerr *ConflictError
+        if !errors.As(err, &cerr) || !errors.Is(err, ErrConflict) || cerr.Kind != conflict.kind {
+            t.Errorf("Expected %v, got %v", conflict.kind, err)
+        }
+
+        if data, _ := ctx.Get(conflict.name); string(data) == "mine" {
+            t.Errorf("Update was applied despite the %v", conflict.kind)
+        }
+    }
+}
+
+func Test_PutIfVersion(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    if _, _, err = ctx.GetV("obj"); !errors.Is(err, syscall.ENOENT) {
+        t.Errorf("Expected ENOENT, got %v", err)
+    }
+
+    err = ctx.PutIfVersion("obj", []byte("v1"), 0)
+    fatalOnError(t, err, "PutIfVersion")
+
+    err = ctx.PutIfVersion("obj", []byte("v1"), 0)
+    if !errors.Is(err, ErrConflict) {
+        t.Errorf("Expected a conflict creating obj again, got %v", err)
+    }
+
+    data, version, err := ctx.GetV("obj")
+    fatalOnError(t, err, "GetV")
+
+    if string(data) != "v1" || version == 0 {
+        t.Errorf("Unexpected GetV %q at version %d", data, version)
+    }
+
+    err = ctx.PutIfVersion("obj", []byte("v2"), version)
+    fatalOnError(t, err, "PutIfVersion")
+
+    err = ctx.PutIfVersion("obj", []byte("v3"), version)
+
+    var cerr *ConflictError
+    if !errors.As(err, &cerr) || cerr.Kind != ConflictModified || cerr.Version != version {
+        t.Errorf("Expected a lost update, got %v", err)
+    }
+
+    if data, _ = ctx.Get("obj"); string(data) != "v2" {
+        t.Errorf("Expected v2, got %q", data)
+    }
+}
+
+func Test_CompareAndWrite(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.Put("slots", []byte("aaaabbbbcccc"))
+    fatalOnError(t, err, "Put")
+
+    obj, err := ctx.Open("slots")
+    fatalOnError(t, err, "Open")
+
+    err = obj.CompareAndWrite(4, []byte("bbbb"), []byte("BBBB"))
+    fatalOnError(t, err, "CompareAndWrite")
+
+    err = obj.CompareAndWrite(8, []byte("ccxc"), []byte("CCCC"))
+
+    var merr *MismatchError
+    if !errors.As(err, &merr) || !errors.Is(err, ErrComparisonFailed) || merr.Offset != 10 {
+        t.Errorf("Expected a mismatch at offset 10, got %v", err)
+    }
+
+    if data, _ := ctx.Get("slots"); string(data) != "aaaaBBBBcccc" {
+        t.Errorf("Unexpected data %q", data)
+    }
+
+    // Mismatches far into the extent are not mistaken for other errors
+    big, err := ctx.Create("big")
+    fatalOnError(t, err, "Create")
+
+    expect := make([]byte, 1<<20)
+    expect[1044481] = 1
+
+    err = big.CompareAndWrite(0, expect, expect)
+    if !errors.As(err, &merr) || merr.Offset != 1044481 {
+        t.Errorf("Expected a mismatch at offset 1044481, got %v", err)
+    }
+}
+
+func Test_AppendCapped(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    for i := 0; i < 3; i++ {
+        err = ctx.AppendCapped("log", []byte("0123"), 10)
+        if i < 2 {
+            fatalOnError(t, err, "AppendCapped")
+        } else if !errors.Is(err, ErrCapExceeded) {
+            t.Errorf("Expected ErrCapExceeded, got %v", err)
+        }
+    }
+
+    err = ctx.AppendCapped("log", []byte("01"), 10)
+    fatalOnError(t, err, "AppendCapped")
+
+    if data, _ := ctx.Get("log"); string(data) != "0123012301" {
+        t.Errorf("Unexpected data %q", data)
+    }
+}
+
+func Test_RingBuffer(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    rb, err := ctx.CreateRingBuffer("ring", 3, 16)
+    fatalOnError(t, err, "CreateRingBuffer")
+
+    if _, err = ctx.CreateRingBuffer("ring", 3, 16); !errors.Is(err, syscall.EEXIST) {
+        t.Errorf("Expected EEXIST, got %v", err)
+    }
+
+    records, err := rb.Records()
+    fatalOnError(t, err, "Records")
+
+    if len(records) != 0 {
+        t.Errorf("Expected no records, got %v", records)
+    }
+
+    if _, err = rb.Push(make([]byte, 13)); err == nil {
+        t.Errorf("Expected an oversized record to be refused")
+    }
+
+    rb, err = ctx.OpenRingBuffer("ring")
+    fatalOnError(t, err, "OpenRingBuffer")
+
+    for i := 0; i < 5; i++ {
+        seq, err := rb.Push([]byte(fmt.Sprintf("record %d", i)))
+        fatalOnError(t, err, "Push")
+
+        if seq != uint64(i) {
+            t.Errorf("Expected sequence number %d, got %d", i, seq)
+        }
+    }
+
+    records, err = rb.Records()
+    fatalOnError(t, err, "Records")
+
+    if len(records) != 3 {
+        t.Fatalf("Expected 3 records, got %d", len(records))
+    }
+
+    for i, record := range records {
+        if record.Seq != uint64(i+2) || string(record.Data) != fmt.Sprintf("record %d", i+2) {
+            t.Errorf("Unexpected record %d: %d %q", i, record.Seq, record.Data)
+        }
+    }
+}
+
+// failingWriter fails once it has been written n bytes.
+type failingWriter struct {
+    bytes.Buffer
+    n int
+}
+
+func (w *failingWriter) Write(data []byte) (int, error) {
+    if w.Len()+len(data) > w.n {
+        data = data[:w.n-w.Len()]
+        w.Buffer.Write(data)
+        return len(data), errors.New("writer full")
+    }
+
+    return w.Buffer.Write(data)
+}
+
+func Test_Download(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    ctx.SetMaxChunkSize(1000)
+
+    data := bytes.Repeat([]byte("0123456789"), 500)
+    err = ctx.Put("obj", data)
+    fatalOnError(t, err, "Put")
+
+    d, err := ctx.NewDownload("obj")
+    fatalOnError(t, err, "NewDownload")
+
+    w := &failingWriter{n: 2500}
+    if _, err = d.WriteTo(w); err == nil || d.Offset() != 2500 {
+        t.Fatalf("Expected the download to stop at 2500, got %d: %v", d.Offset(), err)
+    }
+
+    // Resume from another download
+    d = ctx.ResumeDownload("obj", d.Version(), d.Size(), d.Offset())
+    w.n = len(data)
+
+    n, err := d.WriteTo(w)
+    fatalOnError(t, err, "WriteTo")
+
+    if n != int64(len(data)-2500) || !bytes.Equal(w.Bytes(), data) {
+        t.Errorf("Unexpected download of %d bytes", n)
+    }
+
+    d, err = ctx.NewDownload("obj")
+    fatalOnError(t, err, "NewDownload")
+
+    err = ctx.Put("obj", data)
+    fatalOnError(t, err, "Put")
+
+    if _, err = d.WriteTo(io.Discard); !errors.Is(err, ErrObjectChanged) {
+        t.Errorf("Expected ErrObjectChanged, got %v", err)
+    }
+}
+
+func Test_PublishStats(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "rados." + test.poolName
+    err = ctx.PublishStats(name)
+    fatalOnError(t, err, "PublishStats")
+
+    err = ctx.Put("obj", []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    if vars := expvar.Get(name).String(); !strings.Contains(vars, `"BytesWritten":4`) {
+        t.Errorf("Unexpected published stats %s", vars)
+    }
+
+    if err = ctx.PublishStats(name); err == nil {
+        t.Errorf("Expected publishing the stats twice to fail")
+    }
+}
+
+func Test_Trace(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    var buf bytes.Buffer
+    SetTrace(&buf)
+    defer SetTrace(nil)
+
+    _, err = ctx.Stat("missing")
+    if err == nil {
+        t.Fatalf("Expected Stat of a missing object to fail")
+    }
+
+    trace := buf.String()
+    expected := fmt.Sprintf(`rados_stat pool=%s op=stat object="missing"`, test.poolName)
+    if !strings.Contains(trace, expected) || !strings.Contains(trace, "errno="+syscall.ENOENT.Error()) {
+        t.Errorf("Unexpected trace %q", trace)
+    }
+
+    // Asynchronous operations and cluster calls are traced as well
+    buf.Reset()
+    ctx.SetProfileLabels(context.Background())
+
+    cp, err := ctx.AioStat("missing")
+    fatalOnError(t, err, "AioStat")
+    cp.Wait()
+    cp.Release()
+
+    _, err = test.rados.ListPools()
+    fatalOnError(t, err, "ListPools")
+
+    trace = buf.String()
+    for _, function := range []string{"rados_aio_stat", "rados_pool_list"} {
+        if !strings.Contains(trace, function+" ") {
+            t.Errorf("Expected %s in trace %q", function, trace)
+        }
+    }
+}
+
+func Test_AioCallbacks(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    err := test.rados.SetCallbackPool(2, 1, CallbackBlock)
+    fatalOnError(t, err, "SetCallbackPool")
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    const n = 10
+    results := make(chan error, n)
+
+    for i := 0; i < n; i++ {
+        cp, err := ctx.AioWriteFull(fmt.Sprintf("obj%d", i), []byte("data"))
+        fatalOnError(t, err, "AioWriteFull")
+
+        cp.OnComplete(func(cp *Completion) {
+            err := cp.Wait()
+            cp.Release()
+            results <- err
+        })
+    }
+
+    for i := 0; i < n; i++ {
+        select {
+        case err := <-results:
+            errorOnError(t, err, "AioWriteFull")
+        case <-time.After(30 * time.Second):
+            t.Fatalf("Timed out waiting for callbacks")
+        }
+    }
+
+    if err = test.rados.SetCallbackPool(4, 1, CallbackSpawn); err == nil {
+        t.Errorf("Expected SetCallbackPool to fail once the pool is started")
+    }
+}
+
+func Test_ParallelGet(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    ctx.SetMaxChunkSize(1000)
+    ctx.SetParallelGet(2000, 4)
+
+    for _, size := range []int{10, 2000, 9999} {
+        data := bytes.Repeat([]byte("x"), size)
+        err = ctx.Put("obj", data)
+        fatalOnError(t, err, "Put")
+
+        got, err := ctx.Get("obj")
+        fatalOnError(t, err, "Get")
+
+        if !bytes.Equal(got, data) {
+            t.Errorf("Unexpected data of %d bytes for an object of %d bytes", len(got), size)
+        }
+    }
+}
+
+// Benchmark_Get compares serial and parallel gets of a 64 MB object.
+func Benchmark_Get(b *testing.B) {
+    rados, err := NewDefault()
+    if err != nil {
+        b.Fatalf("New: %v", err)
+    }
+    defer rados.Release()
+
+    pool := poolName()
+    if err = rados.CreatePool(pool); err != nil {
+        b.Fatalf("CreatePool: %v", err)
+    }
+    defer rados.DeletePool(pool)
+
+    ctx, err := rados.NewContext(pool)
+    if err != nil {
+        b.Fatalf("NewContext: %v", err)
+    }
+    defer ctx.Release()
+
+    data := bytes.Repeat([]byte("x"), 64<<20)
+    if err = ctx.Put("obj", data); err != nil {
+        b.Fatalf("Put: %v", err)
+    }
+
+    for _, parallelism := range []int{1, 4, 16} {
+        b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+            ctx.SetParallelGet(0, parallelism)
+            b.SetBytes(int64(len(data)))
+
+            for i := 0; i < b.N; i++ {
+                if _, err := ctx.Get("obj"); err != nil {
+                    b.Fatalf("Get: %v", err)
+                }
+            }
+        })
+    }
+}
+
+func Test_Backoff(t *testing.T) {
+    var events []BackoffEvent
+    b := NewBackoff(BackoffOptions{
+        Min:        time.Millisecond,
+        Max:        3 * time.Millisecond,
+        SlowOp:     time.Second,
+        MaxRetries: 1,
+        OnEvent: func(event BackoffEvent) {
+            events = append(events, event)
+        },
+    })
+
+    busy := fmt.Errorf("RADOS remove obj: %w", syscall.EBUSY)
+    steps := []struct {
+        latency time.Duration
+        err     error
+        delay   time.Duration
+    }{
+        {time.Millisecond, busy, time.Millisecond},
+        {2 * time.Second, nil, 2 * time.Millisecond},
+        {time.Millisecond, busy, 3 * time.Millisecond},
+        {time.Millisecond, syscall.ENOENT, 3 * time.Millisecond},
+        {time.Millisecond, nil, 1500 * time.Microsecond},
+        {time.Millisecond, nil, 0},
+    }
+
+    for i, step := range steps {
+        b.Observe(step.latency, step.err)
+        if b.Delay() != step.delay {
+            t.Errorf("Step %d: expected a delay of %v, got %v", i, step.delay, b.Delay())
+        }
+    }
+
+    if len(events) != 5 || !events[1].Slow || events[0].Err != busy {
+        t.Errorf("Unexpected events %+v", events)
+    }
+
+    if !b.Retry(1, busy) || b.Retry(2, busy) || b.Retry(1, syscall.ENOENT) {
+        t.Errorf("Unexpected retries")
+    }
+
+    // Bulk operations are retried while the cluster is busy
+    attempts := 0
+    err := b.run(func() error {
+        attempts++
+        return busy
+    })
+    if attempts != 2 || err != busy {
+        t.Errorf("Expected 2 attempts failing with %v, got %d and %v", busy, attempts, err)
+    }
+
+    attempts = 0
+    if err = (*Backoff)(nil).run(func() error { attempts++; return busy }); attempts != 1 || err != busy {
+        t.Errorf("Expected 1 attempt without backoff, got %d and %v", attempts, err)
+    }
+}
+
+func Test_SlowOps(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    var slow []SlowOp
+    ctx.SetSlowOps(time.Nanosecond, func(op SlowOp) {
+        slow = append(slow, op)
+    })
+
+    err = ctx.Put("obj", []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    if len(slow) != 1 || slow[0].Op != "write" || slow[0].Object != "obj" || slow[0].Size != 4 ||
+        slow[0].Pool != test.poolName || slow[0].Duration <= 0 {
+        t.Errorf("Unexpected slow operations %+v", slow)
+    }
+
+    ctx.SetSlowOps(time.Hour, func(op SlowOp) {
+        t.Errorf("Unexpected slow operation %+v", op)
+    })
+
+    _, err = ctx.Get("obj")
+    fatalOnError(t, err, "Get")
+}
+
+func Test_Check(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+    defer cancel()
+
+    err := test.rados.Check(ctx)
+    fatalOnError(t, err, "Check")
+
+    canceled, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    // The answer of the monitors may still win the race
+    if err = test.rados.Check(canceled); err != nil && !errors.Is(err, context.Canceled) {
+        t.Errorf("Expected a canceled check, got %v", err)
+    }
+}
+
+func Test_ApplyConf(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    err := test.rados.ApplyConf(map[string]string{"rados_osd_op_timeout": "42"})
+    fatalOnError(t, err, "ApplyConf")
+
+    value, err := test.rados.ConfGet("rados_osd_op_timeout")
+    fatalOnError(t, err, "ConfGet")
+
+    if value != "42" && value != "42.000000" {
+        t.Errorf("Unexpected rados_osd_op_timeout %q", value)
+    }
+
+    if err = test.rados.ApplyConf(map[string]string{"option that does not exist": "1"}); err == nil {
+        t.Errorf("Expected ApplyConf to fail")
+    }
+
+    err = test.rados.ReloadConfig("")
+    fatalOnError(t, err, "ReloadConfig")
+}
+
+func Test_NewDefaultEnvironment(t *testing.T) {
+    t.Setenv("CEPH_ARGS", "--rados-osd-op-timeout 17")
+
+    rados, err := NewDefault()
+    fatalOnError(t, err, "NewDefault")
+    defer rados.Release()
+
+    value, err := rados.ConfGet("rados_osd_op_timeout")
+    fatalOnError(t, err, "ConfGet")
+
+    if value != "17" && value != "17.000000" {
+        t.Errorf("Expected CEPH_ARGS to set rados_osd_op_timeout, got %q", value)
+    }
+}
+
+func Test_WithoutClusterStat(t *testing.T) {
+    rados, err := NewWithOptions(WithoutClusterStat())
+    fatalOnError(t, err, "NewWithOptions")
+    defer rados.Release()
+
+    if rados.Size() != 0 {
+        t.Errorf("Expected no cluster statistics, got a size of %d", rados.Size())
+    }
+
+    err = rados.Stat()
+    fatalOnError(t, err, "Stat")
+
+    if rados.Size() == 0 {
+        t.Errorf("Expected cluster statistics after Stat")
+    }
+}
+
+func Test_AnalyzePool(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    objects := map[string]int{
+        "logs/a":   100,
+        "logs/b":   2000,
+        "images/c": 5 << 20,
+        "empty":    0,
+    }
+    for name, size := range objects {
+        err = ctx.Put(name, make([]byte, size))
+        fatalOnError(t, err, "Put %s", name)
+    }
+
+    analysis, err := ctx.AnalyzePool(&AnalyzeOptions{Concurrency: 4})
+    fatalOnError(t, err, "AnalyzePool")
+
+    if analysis.Objects != 4 || analysis.Bytes != 100+2000+5<<20 || len(analysis.Errors) != 0 {
+        t.Errorf("Unexpected totals %+v", analysis)
+    }
+
+    if logs := analysis.Prefixes["logs/"]; logs.Objects != 2 || logs.Bytes != 2100 {
+        t.Errorf("Unexpected usage of logs/: %+v", logs)
+    }
+
+    counts := make(map[int64]uint64)
+    for _, bucket := range analysis.Sizes {
+        counts[bucket.Min] = bucket.Objects
+    }
+
+    if counts[0] != 1 || counts[1] != 1 || counts[1<<10] != 1 || counts[4<<20] != 1 {
+        t.Errorf("Unexpected size histogram %+v", analysis.Sizes)
+    }
+}
+
+func Test_LargestObjects(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    for i := 0; i < 10; i++ {
+        err = ctx.Put(fmt.Sprintf("obj%d", i), make([]byte, i*100))
+        fatalOnError(t, err, "Put")
+    }
+
+    report, err := ctx.LargestObjects(3, 4)
+    fatalOnError(t, err, "LargestObjects")
+
+    var names []string
+    for _, object := range report.Objects {
+        names = append(names, object.Name)
+    }
+
+    if strings.Join(names, ",") != "obj9,obj8,obj7" || report.Objects[0].Size != 900 {
+        t.Errorf("Unexpected largest objects %+v", report.Objects)
+    }
+}
+
+func Test_Placement(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    placement, err := ctx.Placement("obj")
+    fatalOnError(t, err, "Placement")
+
+    if placement.PG == "" || len(placement.Acting) == 0 || placement.ActingPrimary != placement.Acting[0] {
+        t.Errorf("Unexpected placement %+v", placement)
+    }
+
+    // Objects with the same locator key are placed together
+    ctx.SetLocatorKey("key")
+    a, err := ctx.Placement("a")
+    fatalOnError(t, err, "Placement")
+    b, err := ctx.Placement("b")
+    fatalOnError(t, err, "Placement")
+
+    if a.PG != b.PG {
+        t.Errorf("Expected objects with the same locator key in the same PG, got %s and %s", a.PG, b.PG)
+    }
+}
+
+func Test_PrimaryLocation(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    placement, err := ctx.Placement("obj")
+    fatalOnError(t, err, "Placement")
+
+    location, err := ctx.PrimaryLocation("obj")
+    fatalOnError(t, err, "PrimaryLocation")
+
+    if location.OSD != placement.ActingPrimary || location.Host == "" || location.CrushLocation["host"] != location.Host {
+        t.Errorf("Unexpected primary location %+v", location)
+    }
+}
+
+func Test_WatchPlacements(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    w, err := ctx.WatchPlacements([]string{"obj"}, 10*time.Millisecond)
+    fatalOnError(t, err, "WatchPlacements")
+
+    // The cluster is stable, so there should be no events
+    select {
+    case event := <-w.C:
+        t.Errorf("Unexpected placement event %+v", event)
+    case <-time.After(100 * time.Millisecond):
+    }
+
+    err = w.Close()
+    fatalOnError(t, err, "Close")
+
+    if _, ok := <-w.C; ok {
+        t.Errorf("Expected C to be closed")
+    }
+}
+
+func Test_ExportObject(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.SetNamespace("ns")
+    fatalOnError(t, err, "SetNamespace")
+    ctx.SetMaxChunkSize(1000)
+
+    data := bytes.Repeat([]byte("0123456789"), 250)
+    err = ctx.PutWithOmap("obj", data, map[string][]byte{"a": []byte("1"), "b": nil})
+    fatalOnError(t, err, "PutWithOmap")
+    err = ctx.SetXattr("obj", "xattr", []byte("value"))
+    fatalOnError(t, err, "SetXattr")
+
+    info, err := ctx.Stat("obj")
+    fatalOnError(t, err, "Stat")
+
+    var dump bytes.Buffer
+    err = ctx.ExportObject("obj", &dump)
+    fatalOnError(t, err, "ExportObject")
+
+    err = ctx.Remove("obj")
+    fatalOnError(t, err, "Remove")
+
+    // Importing through another namespace restores the recorded one
+    other, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer other.Release()
+
+    name, err := other.ImportObject(bytes.NewReader(dump.Bytes()))
+    fatalOnError(t, err, "ImportObject")
+
+    if name != "obj" {
+        t.Errorf("Unexpected imported name %q", name)
+    }
+
+    got, err := ctx.Get("obj")
+    fatalOnError(t, err, "Get")
+    if !bytes.Equal(got, data) {
+        t.Errorf("Unexpected imported data of %d bytes", len(got))
+    }
+
+    value, err := ctx.GetXattr("obj", "xattr")
+    fatalOnError(t, err, "GetXattr")
+    if string(value) != "value" {
+        t.Errorf("Unexpected imported xattr %q", value)
+    }
+
+    keys, err := ctx.OmapGetValsByKeys("obj", []string{"a", "b"})
+    fatalOnError(t, err, "OmapGetValsByKeys")
+    if _, ok := keys["b"]; len(keys) != 2 || !ok || string(keys["a"]) != "1" {
+        t.Errorf("Unexpected imported omap %v", keys)
+    }
+
+    imported, err := ctx.Stat("obj")
+    fatalOnError(t, err, "Stat")
+    if !imported.ModTime().Equal(info.ModTime()) {
+        t.Errorf("Expected a modification time of %v, got %v", info.ModTime(), imported.ModTime())
+    }
+
+    if _, err = other.ImportObject(strings.NewReader("garbage")); err == nil {
+        t.Errorf("Expected importing garbage to fail")
+    }
+
+    // A corrupted length fails without allocating it
+    corrupted := dump.Bytes()[:4+len(exportMagic)+4]
+    corrupted = appendUint32(append([]byte(nil), corrupted...), 1<<31)
+    if _, err = other.ImportObject(bytes.NewReader(corrupted)); err == nil {
+        t.Errorf("Expected importing a corrupted dump to fail")
+    }
+}
+
+func Test_PoolErrors(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    if err := test.rados.CreatePool(test.poolName); !errors.Is(err, ErrPoolExists) || !errors.Is(err, syscall.EEXIST) {
+        t.Errorf("Expected ErrPoolExists, got %v", err)
+    }
+
+    missing := poolName()
+
+    if _, err := test.rados.NewContext(missing); !errors.Is(err, ErrPoolNotFound) {
+        t.Errorf("Expected ErrPoolNotFound from NewContext, got %v", err)
+    }
+
+    if err := test.rados.DeletePool(missing); !errors.Is(err, ErrPoolNotFound) {
+        t.Errorf("Expected ErrPoolNotFound from DeletePool, got %v", err)
+    }
+}
+
+func Test_Closed(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+
+    errorOnError(t, ctx.Release(), "Release")
+    errorOnError(t, ctx.Release(), "Release again")
+
+    if err = ctx.Put("obj", []byte("data")); !errors.Is(err, ErrClosed) {
+        t.Errorf("Put after Release: got %v, want ErrClosed", err)
+    }
+    if _, err = ctx.AioStat("obj"); !errors.Is(err, ErrClosed) {
+        t.Errorf("AioStat after Release: got %v, want ErrClosed", err)
+    }
+    if _, err = ctx.ListObjects(); !errors.Is(err, ErrClosed) {
+        t.Errorf("ListObjects after Release: got %v, want ErrClosed", err)
+    }
+
+    ctx, err = test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    cp, err := ctx.AioWriteFull("obj", []byte("data"))
+    fatalOnError(t, err, "AioWriteFull")
+    errorOnError(t, cp.Release(), "Completion Release")
+    errorOnError(t, cp.Release(), "Completion Release again")
+
+    r, err := NewDefault()
+    fatalOnError(t, err, "NewDefault")
+    errorOnError(t, r.Release(), "Rados Release")
+    errorOnError(t, r.Release(), "Rados Release again")
+
+    if _, err = r.NewContext(test.poolName); !errors.Is(err, ErrClosed) {
+        t.Errorf("NewContext after Release: got %v, want ErrClosed", err)
+    }
+
+    r, err = NewDefault()
+    fatalOnError(t, err, "NewDefault")
+    ctx, err = r.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    errorOnError(t, r.Release(), "Rados Release")
+
+    if err = ctx.Put("obj", []byte("data")); !errors.Is(err, ErrClosed) {
+        t.Errorf("Put after releasing the cluster handle: got %v, want ErrClosed", err)
+    }
+    errorOnError(t, ctx.Release(), "Release after the cluster handle")
+}
+
+func Test_Seq(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    fatalOnError(t, ctx.PutWithOmap("obj", []byte("data"), map[string][]byte{
+        "a": []byte("1"),
+        "b": []byte("2"),
+        "c": []byte("3"),
+    }), "PutWithOmap")
+
+    var names []string
+    for name, err := range ctx.Objects() {
+        fatalOnError(t, err, "Objects")
+        names = append(names, name)
+    }
+    if len(names) != 1 || names[0] != "obj" {
+        t.Errorf("Objects: got %v, want [obj]", names)
+    }
+
+    var keys []string
+    for entry, err := range ctx.object("obj").OmapAll() {
+        fatalOnError(t, err, "OmapAll")
+        keys = append(keys, entry.Key+"="+string(entry.Value))
+    }
+    if got := strings.Join(keys, ","); got != "a=1,b=2,c=3" {
+        t.Errorf("OmapAll: got %s, want a=1,b=2,c=3", got)
+    }
+
+    // Exiting early
+    for entry := range ctx.OmapAll("obj") {
+        if entry.Key != "a" {
+            t.Errorf("OmapAll: got %s first, want a", entry.Key)
+        }
+        break
+    }
+
+    // Errors are yielded last
+    for entry, err := range ctx.OmapAll("missing") {
+        if !errors.Is(err, syscall.ENOENT) {
+            t.Errorf("OmapAll: got %v, %v for a missing object, want ENOENT", entry, err)
+        }
+    }
+}
+
+func Test_JSON(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    type record struct {
+        Name  string
+        Count int
+    }
+
+    in := record{Name: "obj", Count: 3}
+    fatalOnError(t, ctx.PutJSON("json", in), "PutJSON")
+    fatalOnError(t, ctx.PutGob("gob", in), "PutGob")
+
+    var out record
+    errorOnError(t, ctx.GetJSON("json", &out), "GetJSON")
+    if out != in {
+        t.Errorf("GetJSON: got %v, want %v", out, in)
+    }
+
+    out = record{}
+    errorOnError(t, ctx.GetGob("gob", &out), "GetGob")
+    if out != in {
+        t.Errorf("GetGob: got %v, want %v", out, in)
+    }
+
+    contentType, err := ctx.ContentType("json")
+    errorOnError(t, err, "ContentType")
+    if contentType != ContentTypeJSON {
+        t.Errorf("ContentType: got %s, want %s", contentType, ContentTypeJSON)
+    }
+
+    if err = ctx.GetJSON("gob", &out); !errors.Is(err, ErrContentType) {
+        t.Errorf("GetJSON of gob object: got %v, want ErrContentType", err)
+    }
+
+    // Objects without a content type are decoded as is
+    fatalOnError(t, ctx.Put("plain", []byte(`{"Name": "plain"}`)), "Put")
+    errorOnError(t, ctx.GetJSON("plain", &out), "GetJSON plain")
+}
+
+func Test_Archive(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    coldPool := poolName()
+    err := test.rados.CreatePool(coldPool)
+    fatalOnError(t, err, "CreatePool")
+    defer test.rados.DeletePool(coldPool)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    cold, err := test.rados.NewContext(coldPool)
+    fatalOnError(t, err, "NewContext cold")
+    defer cold.Release()
+
+    // Archiving never replaces objects of the cold pool
+    fatalOnError(t, cold.Put("obj", []byte("unrelated")), "Put cold")
+
+    data := []byte("archived data")
+    fatalOnError(t, ctx.Put("obj", data), "Put")
+    fatalOnError(t, ctx.Archive("obj", cold), "Archive")
+
+    info, err := ctx.Stat("obj")
+    fatalOnError(t, err, "Stat")
+    if info.Size() != 0 {
+        t.Errorf("Stat stub: got size %d, want 0", info.Size())
+    }
+
+    loc, err := ctx.ArchiveLocation("obj")
+    fatalOnError(t, err, "ArchiveLocation")
+    if loc == nil || loc.Pool != coldPool || loc.Size != int64(len(data)) || loc.Name == "obj" {
+        t.Fatalf("ArchiveLocation: got %+v", loc)
+    }
+    archived := loc.Name
+
+    got, err := ctx.Get("obj")
+    errorOnError(t, err, "Get")
+    if !bytes.Equal(got, data) {
+        t.Errorf("Get stub: got %q, want %q", got, data)
+    }
+
+    fatalOnError(t, ctx.Unarchive("obj"), "Unarchive")
+
+    if loc, err = ctx.ArchiveLocation("obj"); err != nil || loc != nil {
+        t.Errorf("ArchiveLocation after Unarchive: got %+v, %v", loc, err)
+    }
+    if _, err = cold.Stat(archived); err == nil {
+        t.Errorf("Stat archived copy after Unarchive: expected an error")
+    }
+    if got, err = cold.Get("obj"); err != nil || string(got) != "unrelated" {
+        t.Errorf("Get unrelated cold object: got %q, %v", got, err)
+    }
+
+    got, err = ctx.Get("obj")
+    errorOnError(t, err, "Get")
+    if !bytes.Equal(got, data) {
+        t.Errorf("Get restored: got %q, want %q", got, data)
+    }
+}
+
+func Test_PruneSnaps(t *testing.T) {
+    start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+    // A snapshot every 30 minutes for 3 days
+    var snaps []PoolSnap
+    for i := 0; i < 3*48; i++ {
+        snaps = append(snaps, PoolSnap{ID: uint64(i + 1), Time: start.Add(time.Duration(i) * 30 * time.Minute)})
+    }
+
+    prune := pruneSnaps(snaps, BackupOptions{KeepLast: 3, KeepHourly: 4, KeepDaily: 3})
+
+    pruned := make(map[uint64]bool)
+    for _, snap := range prune {
+        pruned[snap.ID] = true
+    }
+
+    var kept []uint64
+    for _, snap := range snaps {
+        if !pruned[snap.ID] {
+            kept = append(kept, snap.ID)
+        }
+    }
+
+    // The last 3, the last of each of the last 4 hours, and the last of
+    // each day
+    want := []uint64{48, 96, 138, 140, 142, 143, 144}
+    if fmt.Sprint(kept) != fmt.Sprint(want) {
+        t.Errorf("pruneSnaps: kept %v, want %v", kept, want)
+    }
+
+    if prune = pruneSnaps(snaps[:1], BackupOptions{}); len(prune) != 0 {
+        t.Errorf("pruneSnaps: expected the most recent snapshot to be kept")
+    }
+}
+
+func Test_ScheduleBackups(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    taken := make(chan PoolSnap, 1)
+    scheduler, err := ctx.ScheduleBackups(BackupOptions{
+        Interval: time.Hour,
+        KeepLast: 1,
+        OnSnapshot: func(snap PoolSnap) {
+            taken <- snap
+        },
+        OnError: func(err error) {
+            t.Errorf("OnError: %s", err)
+        },
+    })
+    fatalOnError(t, err, "ScheduleBackups")
+
+    var snap PoolSnap
+    select {
+    case snap = <-taken:
+    case <-time.After(30 * time.Second):
+        t.Fatalf("ScheduleBackups: no snapshot taken")
+    }
+    errorOnError(t, scheduler.Close(), "Close")
+    errorOnError(t, scheduler.Close(), "Close again")
+
+    if !strings.HasPrefix(snap.Name, "backup-") {
+        t.Errorf("OnSnapshot: got snapshot %s", snap.Name)
+    }
+
+    snaps, err := ctx.Snaps()
+    errorOnError(t, err, "Snaps")
+    if len(snaps) != 1 || snaps[0].Name != snap.Name {
+        t.Errorf("Snaps: got %v", snaps)
+    }
+
+    errorOnError(t, ctx.RemoveSnap(snap.Name), "RemoveSnap")
+}
+
+func Test_Fence(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    // The client to fence off
+    old, err := NewDefault()
+    fatalOnError(t, err, "NewDefault")
+    defer old.Release()
+
+    oldCtx, err := old.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext old")
+    defer oldCtx.Release()
+
+    addr, err := old.ClientAddrs()
+    fatalOnError(t, err, "ClientAddrs")
+    defer test.rados.BlocklistRemove(addr)
+
+    err = oldCtx.LockExclusive("obj", "lock", &LockOptions{Cookie: "old", Description: "owner"})
+    fatalOnError(t, err, "LockExclusive")
+
+    handles, err := ctx.Fence(addr, []string{"obj"})
+    fatalOnError(t, err, "Fence")
+    if len(handles) != 1 || handles[0].Lock != "lock" || !handles[0].Exclusive {
+        t.Fatalf("Fence: got handles %+v", handles)
+    }
+
+    info, err := ctx.ListLockers("obj", "lock")
+    fatalOnError(t, err, "ListLockers")
+    if len(info.Lockers) != 1 || info.Lockers[0].Cookie != handles[0].Cookie {
+        t.Errorf("ListLockers: got %+v, want the new cookie", info.Lockers)
+    }
+
+    if err = oldCtx.Put("obj", []byte("data")); err == nil {
+        t.Errorf("Put from fenced client: expected an error")
+    }
+
+    errorOnError(t, handles[0].Unlock(), "Unlock")
+}
+
+func Test_ContextIntrospection(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    id, err := ctx.PoolID()
+    errorOnError(t, err, "PoolID")
+    if id <= 0 {
+        t.Errorf("PoolID: got %d", id)
+    }
+
+    name, err := ctx.PoolName()
+    errorOnError(t, err, "PoolName")
+    if name != test.poolName {
+        t.Errorf("PoolName: got %s, want %s", name, test.poolName)
+    }
+
+    fatalOnError(t, ctx.SetNamespace("ns"), "SetNamespace")
+    namespace, err := ctx.Namespace()
+    errorOnError(t, err, "Namespace")
+    if namespace != "ns" {
+        t.Errorf("Namespace: got %s, want ns", namespace)
+    }
+
+    fatalOnError(t, ctx.Put("obj", []byte("data")), "Put")
+    version := ctx.LastVersion()
+    fatalOnError(t, ctx.Put("obj", []byte("data")), "Put")
+    if ctx.LastVersion() <= version {
+        t.Errorf("LastVersion: got %d after %d", ctx.LastVersion(), version)
+    }
+}
+
+func Test_AuditCoverage(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    var buf bytes.Buffer
+    err := test.rados.SetAuditSink(NewWriterAuditSink(&buf), nil)
+    fatalOnError(t, err, "SetAuditSink")
+    defer test.rados.SetAuditSink(nil, nil)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.PutWithChecksum("audited", []byte("data"))
+    fatalOnError(t, err, "PutWithChecksum")
+
+    err = ctx.SetTags("audited", map[string]string{"kind": "test"})
+    fatalOnError(t, err, "SetTags")
+
+    errs := ctx.PutMany(map[string][]byte{"audited": []byte("more")}, 1, false)
+    if len(errs) != 0 {
+        t.Fatalf("PutMany failed: %v", errs)
+    }
+
+    remove, err := ctx.AioRemove("audited")
+    fatalOnError(t, err, "AioRemove")
+    err = remove.Wait()
+    fatalOnError(t, err, "Wait")
+    remove.Release()
+
+    var ops []string
+    dec := json.NewDecoder(&buf)
+    for {
+        var entry AuditEntry
+        if err := dec.Decode(&entry); err == io.EOF {
+            break
+        } else if err != nil {
+            t.Fatalf("Decoding audit entry failed: %v", err)
+        }
+
+        if entry.Object == "audited" {
+            ops = append(ops, entry.Op)
+        }
+    }
+
+    if strings.Join(ops, ",") != "put,set tags,put,remove" {
+        t.Errorf("Unexpected audited operations %v", ops)
+    }
+}
+
+func Test_BinaryNames(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    // Names that are not valid UTF-8 are fine
+    name := "test-\xff\xfe-object"
+    data := []byte("test data")
+
+    err = ctx.Put(name, data)
+    fatalOnError(t, err, "Put")
+
+    obj, err := ctx.Stat(name)
+    fatalOnError(t, err, "Stat")
+
+    if obj.Name() != name {
+        t.Errorf("Object name mismatch, was %q, expected %q", obj.Name(), name)
+    }
+
+    // Names with NUL bytes are rejected
+    name = "test\x00object"
+
+    if err = ctx.Put(name, data); !errors.Is(err, ErrInvalidName) {
+        t.Errorf("Expected ErrInvalidName from Put, got %v", err)
+    }
+
+    if _, err = ctx.Get(name); !errors.Is(err, ErrInvalidName) {
+        t.Errorf("Expected ErrInvalidName from Get, got %v", err)
+    }
+
+    if _, err = ctx.Stat("test"); err == nil {
+        t.Errorf("Object test should not exist")
+    }
+}
+
+func Test_ValidateObjectName(t *testing.T) {
+    var rados *Rados
+    var err error
+
+    rados, err = NewDefault()
+    fatalOnError(t, err, "New")
+    defer rados.Release()
+
+    value, err := rados.ConfGet("osd_max_object_name_len")
+    fatalOnError(t, err, "ConfGet")
+
+    max, err := strconv.Atoi(value)
+    fatalOnError(t, err, "Atoi")
+
+    err = rados.ValidateObjectName(strings.Repeat("x", max))
+    errorOnError(t, err, "ValidateObjectName")
+
+    if err = rados.ValidateObjectName(strings.Repeat("x", max+1)); !errors.Is(err, ErrInvalidName) {
+        t.Errorf("Expected ErrInvalidName for long name, got %v", err)
+    }
+
+    if err = rados.ValidateObjectName("test\x00object"); !errors.Is(err, ErrInvalidName) {
+        t.Errorf("Expected ErrInvalidName for name with NUL, got %v", err)
+    }
+
+    err = rados.ValidateNamespace("test-namespace")
+    errorOnError(t, err, "ValidateNamespace")
+
+    if _, err = rados.ConfGet("option that does not exist"); err == nil {
+        t.Errorf("ConfGet should have failed")
+    }
+}
+
+func Test_ListObjects(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    names := make(map[string]bool)
+    for i := 0; i < 20; i++ {
+        name := fmt.Sprintf("test-object-%d", i)
+        names[name] = true
+
+        xattrs := map[string][]byte{"parity": []byte(fmt.Sprint(i % 2))}
+        err = ctx.PutWithXattrs(name, []byte("test data"), xattrs)
+        fatalOnError(t, err, "PutWithXattrs")
+    }
+
+    // List all the objects
+    iter, err := ctx.ListObjects()
+    fatalOnError(t, err, "ListObjects")
+    defer iter.Close()
+
+    found := make(map[string]bool)
+    for iter.Next() {
+        found[iter.Entry().Name] = true
+    }
+    fatalOnError(t, iter.Err(), "ListObjects iteration")
+
+    if len(found) != len(names) {
+        t.Errorf("Expected %d objects listed but was %d", len(names), len(found))
+    }
+
+    for name := range names {
+        if !found[name] {
+            t.Errorf("Expected to find object %s but it was not present.", name)
+        }
+    }
+
+    // List the objects with an even index
+    iter, err = ctx.ListObjectsWithFilter(PlainFilter("parity", []byte("0")))
+    fatalOnError(t, err, "ListObjectsWithFilter")
+    defer iter.Close()
+
+    count := 0
+    for iter.Next() {
+        count++
+        if !names[iter.Entry().Name] {
+            t.Errorf("Unexpected object %s listed", iter.Entry().Name)
+        }
+    }
+    fatalOnError(t, iter.Err(), "ListObjectsWithFilter iteration")
+
+    if count != len(names)/2 {
+        t.Errorf("Expected %d objects listed with filter but was %d", len(names)/2, count)
+    }
+}
+
+func Test_ContextClone(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    clone, err := ctx.Clone()
+    fatalOnError(t, err, "Clone")
+    defer clone.Release()
+
+    name := "test-object"
+    data := []byte("test data")
+
+    // Put the object in a namespace through the clone
+    err = clone.SetNamespace("test-namespace")
+    fatalOnError(t, err, "SetNamespace")
+
+    err = clone.Put(name, data)
+    fatalOnError(t, err, "Put")
+
+    // It must not be visible in the default namespace
+    if _, err = ctx.Stat(name); err == nil {
+        t.Errorf("Object %s should not exist in the default namespace", name)
+    }
+
+    // A clone of the clone uses the same namespace
+    clone2, err := clone.Clone()
+    fatalOnError(t, err, "Clone")
+    defer clone2.Release()
+
+    data2, err := clone2.Get(name)
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data)
+    }
+
+    // Listing all namespaces finds the object
+    err = clone2.SetNamespace(AllNamespaces)
+    fatalOnError(t, err, "SetNamespace")
+
+    iter, err := clone2.ListObjects()
+    fatalOnError(t, err, "ListObjects")
+    defer iter.Close()
+
+    found := false
+    for iter.Next() {
+        if iter.Entry().Name == name && iter.Entry().Namespace == "test-namespace" {
+            found = true
+        }
+    }
+    fatalOnError(t, iter.Err(), "ListObjects iteration")
+
+    if !found {
+        t.Errorf("Expected to find object %s in namespace test-namespace", name)
+    }
+}
+
+func Test_ContextStats(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    data := []byte("test data")
+
+    err = ctx.Put("test-object", data)
+    fatalOnError(t, err, "Put")
+
+    _, err = ctx.Get("test-object")
+    fatalOnError(t, err, "Get")
+
+    _, err = ctx.Stat("object that does not exist")
+    if err == nil {
+        t.Errorf("Stat should have failed")
+    }
+
+    stats := ctx.Stats()
+
+    if stats.BytesWritten != uint64(len(data)) {
+        t.Errorf("Expected %d bytes written but was %d", len(data), stats.BytesWritten)
+    }
+
+    if stats.BytesRead != uint64(len(data)) {
+        t.Errorf("Expected %d bytes read but was %d", len(data), stats.BytesRead)
+    }
+
+    // Put, Get (stat and read) and Stat
+    if stats.Ops != 4 {
+        t.Errorf("Expected 4 operations but was %d", stats.Ops)
+    }
+
+    if stats.Errors != 1 || stats.PerOp["stat"].Errors != 1 {
+        t.Errorf("Expected 1 failed stat operation but was %d (%d)", stats.PerOp["stat"].Errors, stats.Errors)
+    }
+
+    if stats.PerOp["write"].Ops != 1 || stats.PerOp["write"].Latency <= 0 {
+        t.Errorf("Unexpected write stats %+v", stats.PerOp["write"])
+    }
+}
+
+func Test_MaxChunkSize(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := []byte("0123456789")
+
+    ctx.SetMaxChunkSize(3)
+
+    if ctx.MaxChunkSize() != 3 {
+        t.Errorf("Expected max chunk size 3 but was %d", ctx.MaxChunkSize())
+    }
+
+    // Put is split into 4 writes
+    err = ctx.Put(name, data)
+    fatalOnError(t, err, "Put")
+
+    if ops := ctx.Stats().PerOp["write"].Ops; ops != 4 {
+        t.Errorf("Expected 4 write operations but was %d", ops)
+    }
+
+    // Get is split into 4 reads
+    data2, err := ctx.Get(name)
+    fatalOnError(t, err, "Get")
+
+    if !bytes.Equal(data, data2) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2, data)
+    }
+
+    if ops := ctx.Stats().PerOp["read"].Ops; ops != 4 {
+        t.Errorf("Expected 4 read operations but was %d", ops)
+    }
+
+    // The object setting overrides the context setting
+    obj, err := ctx.Open(name)
+    fatalOnError(t, err, "Open")
+    obj.SetMaxChunkSize(5)
+
+    n, err := obj.WriteAt([]byte("abcdefghij"), 0)
+    fatalOnError(t, err, "WriteAt")
+
+    if n != 10 {
+        t.Errorf("Expected to have 10 bytes written but was %d", n)
+    }
+
+    if ops := ctx.Stats().PerOp["write"].Ops; ops != 6 {
+        t.Errorf("Expected 6 write operations but was %d", ops)
+    }
+
+    data2 = make([]byte, 10)
+    n, err = obj.ReadAt(data2, 0)
+    fatalOnError(t, err, "ReadAt")
+
+    if !bytes.Equal([]byte("abcdefghij"), data2[:n]) {
+        t.Errorf("Object data mismatch, was %s, expected %s", data2[:n], "abcdefghij")
+    }
+}
+
+func Test_ClientAddrs(t *testing.T) {
+    var rados *Rados
+    var err error
+
+    rados, err = NewDefault()
+    fatalOnError(t, err, "New")
+    defer rados.Release()
+
+    addrs, err := rados.ClientAddrs()
+    fatalOnError(t, err, "ClientAddrs")
+
+    if addrs == "" {
+        t.Errorf("Expected client addresses but got none")
+    }
+}
+
+func Test_DefaultCluster(t *testing.T) {
+    rados, err := DefaultCluster()
+    fatalOnError(t, err, "DefaultCluster")
+
+    rados2, err := DefaultCluster()
+    fatalOnError(t, err, "DefaultCluster")
+
+    id := rados.InstanceID()
+    if rados2.InstanceID() != id {
+        t.Errorf("Expected DefaultCluster to share the connection")
+    }
+
+    // The connection must remain usable until the last user releases it,
+    // even if another user releases its handle twice
+    err = rados.Release()
+    fatalOnError(t, err, "Release")
+    err = rados.Release()
+    fatalOnError(t, err, "Release again")
+
+    if _, err = rados.ListPools(); !errors.Is(err, ErrClosed) {
+        t.Errorf("Expected ErrClosed from a released handle, got %v", err)
+    }
+
+    _, err = rados2.ListPools()
+    fatalOnError(t, err, "ListPools")
+
+    err = rados2.Release()
+    fatalOnError(t, err, "Release")
+
+    // A new connection is created once all users have released it
+    rados3, err := DefaultCluster()
+    fatalOnError(t, err, "DefaultCluster")
+    defer rados3.Release()
+
+    if rados3.InstanceID() == id {
+        t.Errorf("Expected DefaultCluster to create a new connection")
+    }
+}
+
+func Test_ContextPool(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    pool := test.rados.NewContextPool(1)
+    defer pool.Close()
+
+    ctx, err := pool.Get(test.poolName, "ns1")
+    fatalOnError(t, err, "Get")
+
+    // Put data in the object
+    err = ctx.Put("obj", []byte("ns1 data"))
+    fatalOnError(t, err, "Put")
+
+    ctx.SetLocatorKey("key")
+    pool.Put(ctx)
+
+    // The idle context is reused for the same namespace
+    ctx2, err := pool.Get(test.poolName, "ns1")
+    fatalOnError(t, err, "Get")
+
+    if ctx2 != ctx {
+        t.Errorf("Expected Get to reuse the idle context")
+    }
+
+    data, err := ctx2.Get("obj")
+    fatalOnError(t, err, "Get object")
+
+    if string(data) != "ns1 data" {
+        t.Errorf("Expected %q, got %q", "ns1 data", data)
+    }
+
+    // But not for another namespace
+    ctx3, err := pool.Get(test.poolName, "ns2")
+    fatalOnError(t, err, "Get")
+
+    if ctx3 == ctx2 {
+        t.Errorf("Expected Get to return a new context")
+    }
+
+    if _, err = ctx3.Get("obj"); err == nil {
+        t.Errorf("Expected object to be missing in another namespace")
+    }
+
+    pool.Put(ctx2)
+    pool.Put(ctx3)
+}
+
+func Test_OmapCmp(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+
+    err = ctx.PutWithOmap(name, []byte("job"), map[string][]byte{"state": []byte("queued")})
+    fatalOnError(t, err, "PutWithOmap")
+
+    // Move the state machine from queued to running
+    op := NewWriteOp()
+    op.OmapCmp("state", CmpEq, []byte("queued"))
+    op.OmapSet(map[string][]byte{"state": []byte("running")})
+    err = ctx.Operate(name, op)
+    op.Release()
+    fatalOnError(t, err, "Operate")
+
+    // A second transition from queued must fail
+    op = NewWriteOp()
+    op.OmapCmp("state", CmpEq, []byte("queued"))
+    op.OmapSet(map[string][]byte{"state": []byte("done")})
+    err = ctx.Operate(name, op)
+    op.Release()
+
+    if !errors.Is(err, ErrComparisonFailed) {
+        t.Errorf("Expected ErrComparisonFailed, got %v", err)
+    }
+
+    vals, err := ctx.OmapGetValsByKeys(name, []string{"state"})
+    fatalOnError(t, err, "OmapGetValsByKeys")
+
+    if string(vals["state"]) != "running" {
+        t.Errorf("Expected state running, got %s", vals["state"])
+    }
+}
+
+func Test_Index(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    idx := ctx.NewIndex("index")
+
+    err = idx.Put("obj1", []byte("data1"), []string{"red", "2024-05-01"})
+    fatalOnError(t, err, "Put")
+    err = idx.Put("obj2", []byte("data2"), []string{"red", "2024-06-01"})
+    fatalOnError(t, err, "Put")
+
+    // Re-index obj1 under different keys
+    err = idx.Put("obj1", []byte("data1"), []string{"blue", "2024-05-01"})
+    fatalOnError(t, err, "Put")
+
+    names := func(iter *IndexIterator) []string {
+        var names []string
+        for iter.Next() {
+            names = append(names, iter.Entry().Name)
+        }
+        errorOnError(t, iter.Err(), "IndexIterator")
+        return names
+    }
+
+    if got := names(idx.Lookup("red")); len(got) != 1 || got[0] != "obj2" {
+        t.Errorf("Expected [obj2] for red, got %v", got)
+    }
+
+    if got := names(idx.Query("2024-")); len(got) != 2 || got[0] != "obj1" || got[1] != "obj2" {
+        t.Errorf("Expected [obj1 obj2] for 2024-, got %v", got)
+    }
+
+    keys, err := idx.Keys("obj1")
+    fatalOnError(t, err, "Keys")
+
+    if len(keys) != 2 || keys[0] != "blue" {
+        t.Errorf("Expected keys [blue 2024-05-01], got %v", keys)
+    }
+
+    err = idx.Remove("obj2")
+    fatalOnError(t, err, "Remove")
+
+    if got := names(idx.Lookup("red")); len(got) != 0 {
+        t.Errorf("Expected no objects for red, got %v", got)
+    }
+}
+
+func Test_IncrCounter(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "counters"
+
+    value, err := ctx.IncrCounter(name, "ids", 1)
+    fatalOnError(t, err, "IncrCounter")
+
+    if value != 1 {
+        t.Errorf("Expected counter 1, got %d", value)
+    }
+
+    value, err = ctx.IncrCounter(name, "ids", 41)
+    fatalOnError(t, err, "IncrCounter")
+
+    if value != 42 {
+        t.Errorf("Expected counter 42, got %d", value)
+    }
+
+    // The fallback must agree with the object class
+    value, err = ctx.incrCounter(name, "ids", -2)
+    fatalOnError(t, err, "incrCounter")
+
+    if value != 40 {
+        t.Errorf("Expected counter 40, got %d", value)
+    }
+}
+
+func Test_Version(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+
+    err = ctx.Put(name, []byte("v1"))
+    fatalOnError(t, err, "Put")
+
+    err = ctx.VersionSet(name, ObjVersion{Ver: 1, Tag: "test"})
+    fatalOnError(t, err, "VersionSet")
+
+    ver, err := ctx.VersionRead(name)
+    fatalOnError(t, err, "VersionRead")
+
+    if ver.Ver != 1 || ver.Tag != "test" {
+        t.Errorf("Expected version 1/test, got %d/%s", ver.Ver, ver.Tag)
+    }
+
+    // Update the object only if it is still at version 1
+    op := NewWriteOp()
+    op.VersionCheck(ver, VersionEq)
+    op.VersionInc()
+    op.WriteFull([]byte("v2"))
+    err = ctx.Operate(name, op)
+    op.Release()
+    fatalOnError(t, err, "Operate")
+
+    // A second update from version 1 must fail
+    op = NewWriteOp()
+    op.VersionCheck(ver, VersionEq)
+    op.WriteFull([]byte("v3"))
+    err = ctx.Operate(name, op)
+    op.Release()
+
+    if !errors.Is(err, ErrComparisonFailed) {
+        t.Errorf("Expected ErrComparisonFailed, got %v", err)
+    }
+
+    data, err := ctx.Get(name)
+    fatalOnError(t, err, "Get")
+
+    if string(data) != "v2" {
+        t.Errorf("Expected data v2, got %s", data)
+    }
+}
+
+func Test_Refcount(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "chunk"
+
+    // Write the chunk and take the first reference atomically
+    op := NewWriteOp()
+    op.WriteFull([]byte("chunk data"))
+    op.RefcountGet("owner1")
+    err = ctx.Operate(name, op)
+    op.Release()
+    fatalOnError(t, err, "Operate")
+
+    err = ctx.RefcountGet(name, "owner2")
+    fatalOnError(t, err, "RefcountGet")
+
+    tags, err := ctx.RefcountRead(name)
+    fatalOnError(t, err, "RefcountRead")
+
+    if len(tags) != 2 {
+        t.Errorf("Expected 2 references, got %v", tags)
+    }
+
+    err = ctx.RefcountPut(name, "owner1")
+    fatalOnError(t, err, "RefcountPut")
+
+    if _, err = ctx.Stat(name); err != nil {
+        t.Errorf("Expected object to exist while referenced: %s", err)
+    }
+
+    // Dropping the last reference removes the object
+    err = ctx.RefcountPut(name, "owner2")
+    fatalOnError(t, err, "RefcountPut")
+
+    if _, err = ctx.Stat(name); err == nil {
+        t.Errorf("Expected object to be removed with its last reference")
+    }
+}
+
+func Test_WatchNotify(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+
+    err = ctx.Put(name, []byte("data"))
+    fatalOnError(t, err, "Put")
+
+    received := make(chan []byte, 1)
+
+    watch, err := ctx.Watch(name, 10*time.Second, func(notifierID uint64, data []byte) []byte {
+        received <- data
+        return []byte("reply")
+    }, nil)
+    fatalOnError(t, err, "Watch")
+
+    _, err = watch.Check()
+    fatalOnError(t, err, "Check")
+
+    replies, err := ctx.Notify(name, []byte("hello"), 5*time.Second)
+    fatalOnError(t, err, "Notify")
+
+    if string(replies[watch.ID()]) != "reply" {
+        t.Errorf("Expected reply from watcher, got %v", replies)
+    }
+
+    select {
+    case data := <-received:
+        if string(data) != "hello" {
+            t.Errorf("Expected notification hello, got %s", data)
+        }
+    default:
+        t.Errorf("Expected notification to be handled when Notify returns")
+    }
+
+    err = watch.Close()
+    fatalOnError(t, err, "Close")
+
+    // A watch can close itself from its handler
+    var self *Watch
+    closed := make(chan error, 1)
+
+    self, err = ctx.Watch(name, 10*time.Second, func(notifierID uint64, data []byte) []byte {
+        closed <- self.Close()
+        return nil
+    }, nil)
+    fatalOnError(t, err, "Watch")
+
+    _, err = ctx.Notify(name, []byte("bye"), 5*time.Second)
+    fatalOnError(t, err, "Notify")
+
+    select {
+    case err = <-closed:
+        errorOnError(t, err, "Close from handler")
+    default:
+        t.Errorf("Expected the handler to close the watch")
+    }
+
+    if _, err = ctx.Watch(name, 1500*time.Millisecond, nil, nil); err == nil {
+        t.Errorf("Expected Watch to reject a fractional timeout")
+    }
+}
+
+func Test_Lock(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    opts := &LockOptions{
+        Cookie:      "cookie1",
+        Description: "test lease",
+        Duration:    30 * time.Second,
+    }
+
+    err = ctx.LockExclusive(name, "lease", opts)
+    fatalOnError(t, err, "LockExclusive")
+
+    // Renew the lease without releasing it
+    opts.Flags = LockMustRenew
+    err = ctx.LockExclusive(name, "lease", opts)
+    fatalOnError(t, err, "LockExclusive renew")
+
+    // Another holder can't take it
+    other := &LockOptions{Cookie: "cookie2"}
+    if err = ctx.LockExclusive(name, "lease", other); !errors.Is(err, ErrLocked) {
+        t.Errorf("Expected ErrLocked, got %v", err)
+    }
+
+    info, err := ctx.ListLockers(name, "lease")
+    fatalOnError(t, err, "ListLockers")
+
+    if !info.Exclusive || len(info.Lockers) != 1 || info.Lockers[0].Cookie != "cookie1" {
+        t.Errorf("Unexpected lock info %+v", info)
+    }
+
+    err = ctx.BreakLock(name, "lease", info.Lockers[0].Client, "cookie1")
+    fatalOnError(t, err, "BreakLock")
+
+    // Renewing a lock that is no longer held fails
+    if err = ctx.LockExclusive(name, "lease", opts); err == nil {
+        t.Errorf("Expected renewal of a broken lock to fail")
+    }
+
+    // Shared locks with the same tag coexist
+    for _, cookie := range []string{"a", "b"} {
+        err = ctx.LockShared(name, "shared", &LockOptions{Cookie: cookie, Tag: "readers"})
+        fatalOnError(t, err, "LockShared")
+    }
+
+    info, err = ctx.ListLockers(name, "shared")
+    fatalOnError(t, err, "ListLockers")
+
+    if info.Exclusive || info.Tag != "readers" || len(info.Lockers) != 2 {
+        t.Errorf("Unexpected lock info %+v", info)
+    }
+
+    err = ctx.Unlock(name, "shared", "a")
+    fatalOnError(t, err, "Unlock")
+}
+
+func Test_SweepLocks(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    opts := &LockOptions{Cookie: "cookie", Description: "test lock", Duration: time.Second}
+
+    err = ctx.LockExclusive("job-1", "owner", opts)
+    fatalOnError(t, err, "LockExclusive")
+
+    opts.Duration = 0
+    err = ctx.LockExclusive("job-2", "owner", opts)
+    fatalOnError(t, err, "LockExclusive")
+
+    err = ctx.LockExclusive("other", "owner", opts)
+    fatalOnError(t, err, "LockExclusive")
+
+    // Let the first lock expire
+    time.Sleep(2 * time.Second)
+
+    reports, err := ctx.SweepLocks(&SweepOptions{Prefix: "job-", BreakStale: true, Watched: true})
+    fatalOnError(t, err, "SweepLocks")
+
+    // Our own client isn't blocklisted, so no lock is stale, but it
+    // doesn't watch the object, so the lock is gone
+    if len(reports) != 1 || reports[0].Object != "job-2" || reports[0].Stale || !reports[0].Gone ||
+        reports[0].Broken {
+        t.Fatalf("Unexpected reports %+v", reports)
+    }
+
+    if reports[0].Description != "test lock" {
+        t.Errorf("Expected description %q, got %q", "test lock", reports[0].Description)
+    }
+
+    watch, err := ctx.Watch("job-2", 0, func(uint64, []byte) []byte { return nil }, nil)
+    fatalOnError(t, err, "Watch")
+    defer watch.Close()
+
+    reports, err = ctx.SweepLocks(&SweepOptions{Prefix: "job-", Watched: true, BreakGone: true})
+    fatalOnError(t, err, "SweepLocks")
+
+    if len(reports) != 1 || reports[0].Gone || reports[0].Broken {
+        t.Fatalf("Unexpected reports %+v", reports)
+    }
+}
+
+func Test_Hash(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    name := "test-object"
+    data := bytes.Repeat([]byte("0123456789"), 1000)
+
+    err = ctx.Put(name, data)
+    fatalOnError(t, err, "Put")
+
+    expected := sha256.Sum256(data)
+
+    // Hash in small chunks to exercise streaming
+    obj, err := ctx.Open(name)
+    fatalOnError(t, err, "Open")
+    obj.SetMaxChunkSize(1024)
+
+    sum, err := obj.Hash(sha256.New())
+    fatalOnError(t, err, "Hash")
+
+    if !bytes.Equal(sum, expected[:]) {
+        t.Errorf("Hash mismatch, was %x, expected %x", sum, expected)
+    }
+}
+
+func Test_Compare(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    data := bytes.Repeat([]byte("0123456789"), 100)
+
+    err = ctx.Put("a", data)
+    fatalOnError(t, err, "Put")
+    err = ctx.Put("b", data)
+    fatalOnError(t, err, "Put")
+
+    ctx.SetMaxChunkSize(64)
+
+    equal, firstDiff, err := Compare(ctx, "a", ctx, "b")
+    fatalOnError(t, err, "Compare")
+
+    if !equal || firstDiff != -1 {
+        t.Errorf("Expected objects to be equal, got %v at %d", equal, firstDiff)
+    }
+
+    // Change a byte in the middle of b
+    _, err = ctx.object("b").WriteAt([]byte("x"), 500)
+    fatalOnError(t, err, "WriteAt")
+
+    equal, firstDiff, err = Compare(ctx, "a", ctx, "b")
+    fatalOnError(t, err, "Compare")
+
+    if equal || firstDiff != 500 {
+        t.Errorf("Expected objects to differ at 500, got %v at %d", equal, firstDiff)
+    }
+
+    // A truncated copy differs at its end
+    err = ctx.Truncate("b", 100)
+    fatalOnError(t, err, "Truncate")
+
+    equal, firstDiff, err = Compare(ctx, "a", ctx, "b")
+    fatalOnError(t, err, "Compare")
+
+    if equal || firstDiff != 100 {
+        t.Errorf("Expected objects to differ at 100, got %v at %d", equal, firstDiff)
+    }
+}
+
+func Test_CopyPool(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    dstPool := poolName()
+    err := test.rados.CreatePool(dstPool)
+    fatalOnError(t, err, "CreatePool")
+    defer test.rados.DeletePool(dstPool)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.PutWithOmap("obj1", []byte("data1"), map[string][]byte{"key": []byte("value")})
+    fatalOnError(t, err, "PutWithOmap")
+    err = ctx.SetXattr("obj1", "attr", []byte("xvalue"))
+    fatalOnError(t, err, "SetXattr")
+
+    err = ctx.SetNamespace("ns")
+    fatalOnError(t, err, "SetNamespace")
+    err = ctx.Put("obj2", []byte("data2"))
+    fatalOnError(t, err, "Put")
+
+    var last CopyProgress
+    errs, err := test.rados.CopyPool(test.poolName, dstPool, &CopyPoolOptions{
+        Concurrency: 2,
+        Progress:    func(progress CopyProgress) { last = progress },
+    })
+    fatalOnError(t, err, "CopyPool")
+
+    if len(errs) != 0 {
+        t.Errorf("Unexpected copy errors %v", errs)
+    }
+
+    if last.Objects != 2 || last.Bytes != 10 {
+        t.Errorf("Unexpected progress %+v", last)
+    }
+
+    dst, err := test.rados.NewContext(dstPool)
+    fatalOnError(t, err, "NewContext")
+    defer dst.Release()
+
+    data, err := dst.Get("obj1")
+    fatalOnError(t, err, "Get")
+    if string(data) != "data1" {
+        t.Errorf("Expected data1, got %s", data)
+    }
+
+    value, err := dst.GetXattr("obj1", "attr")
+    fatalOnError(t, err, "GetXattr")
+    if string(value) != "xvalue" {
+        t.Errorf("Expected xattr xvalue, got %s", value)
+    }
+
+    vals, err := dst.OmapGetValsByKeys("obj1", []string{"key"})
+    fatalOnError(t, err, "OmapGetValsByKeys")
+    if string(vals["key"]) != "value" {
+        t.Errorf("Expected omap value, got %s", vals["key"])
+    }
+
+    err = dst.SetNamespace("ns")
+    fatalOnError(t, err, "SetNamespace")
+
+    data, err = dst.Get("obj2")
+    fatalOnError(t, err, "Get")
+    if string(data) != "data2" {
+        t.Errorf("Expected data2, got %s", data)
+    }
+}
+
+func Test_VerifyPool(t *testing.T) {
+    test := setup(t)
+    defer teardown(t, test)
+
+    ctx, err := test.rados.NewContext(test.poolName)
+    fatalOnError(t, err, "NewContext")
+    defer ctx.Release()
+
+    err = ctx.PutWithChecksum("good", []byte("good data"))
+    fatalOnError(t, err, "PutWithChecksum")
+    err = ctx.PutWithChecksum("bad", []byte("bad data"))
+    fatalOnError(t, err, "PutWithChecksum")
+    err = ctx.Put("plain", []byte("plain data"))
+    fatalOnError(t, err, "Put")
+
+    // Corrupt the data behind the checksum's back
+    _, err = ctx.object("bad").WriteAt([]byte("B"), 0)
+    fatalOnError(t, err, "WriteAt")
+
+    err = ctx.VerifyChecksum("good")
+    fatalOnError(t, err, "VerifyChecksum")
+
+    if err = ctx.VerifyChecksum("bad"); !errors.Is(err, ErrChecksumMismatch) {
+        t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+    }
+
+    report, err := ctx.VerifyPool("", 2)
+    fatalOnError(t, err, "VerifyPool")
+
+    if report.Verified != 1 || len(report.Corrupted) != 1 || len(report.NoChecksum) != 1 ||
+        len(report.Missing) != 0 || len(report.Errors) != 0 {
+        t.Errorf("Unexpected report %+v", report)
+    }
+
+    if len(report.Corrupted) == 1 && report.Corrupted[0].Name != "bad" {
+        t.Errorf("Expected bad to be corrupted, got %s", report.Corrupted[0].Name)
+    }
+}
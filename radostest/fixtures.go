@@ -0,0 +1,236 @@
+package radostest
+
+import (
+    "bytes"
+    "fmt"
+    "math/rand"
+
+    "github.com/mrkvm/rados.go"
+)
+
+// Extent is a range of an object that holds data. The rest of a sparse
+// object reads as zeros.
+type Extent struct {
+    Off  int64
+    Data []byte
+}
+
+// Object is a generated test object (see Generator), which can be written
+// to a pool and verified back.
+type Object struct {
+    Name    string
+    Size    int64
+    Extents []Extent // Ranges written, in order; they may overlap
+    Xattrs  map[string][]byte
+    Omap    map[string][]byte
+}
+
+// Data returns the full contents of the object as it reads back.
+func (o *Object) Data() []byte {
+    data := make([]byte, o.Size)
+    for _, extent := range o.Extents {
+        copy(data[extent.Off:], extent.Data)
+    }
+
+    return data
+}
+
+// Write writes the object to the pool referenced by ctx, replacing any
+// existing object with the same name.
+func (o *Object) Write(ctx *rados.Context) error {
+    if _, err := ctx.Stat(o.Name); err == nil {
+        if err = ctx.Remove(o.Name); err != nil {
+            return err
+        }
+    }
+
+    obj, err := ctx.Create(o.Name)
+    if err != nil {
+        return err
+    }
+
+    for _, extent := range o.Extents {
+        if _, err = obj.WriteAt(extent.Data, extent.Off); err != nil {
+            return err
+        }
+    }
+
+    // Extend the object past its last extent if needed
+    if err = ctx.Truncate(o.Name, o.Size); err != nil {
+        return err
+    }
+
+    for xattr, value := range o.Xattrs {
+        if err = ctx.SetXattr(o.Name, xattr, value); err != nil {
+            return err
+        }
+    }
+
+    if len(o.Omap) > 0 {
+        op := rados.NewWriteOp()
+        defer op.Release()
+
+        op.OmapSet(o.Omap)
+
+        if err = ctx.Operate(o.Name, op); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// Verify reads the object back from the pool referenced by ctx, and
+// returns an error describing the first difference found between the
+// stored object and the generated one.
+func (o *Object) Verify(ctx *rados.Context) error {
+    data, err := ctx.Get(o.Name)
+    if err != nil {
+        return err
+    }
+
+    expected := o.Data()
+    if len(data) != len(expected) {
+        return fmt.Errorf("radostest: %s: size is %d, expected %d", o.Name, len(data), len(expected))
+    }
+
+    for i := range data {
+        if data[i] != expected[i] {
+            return fmt.Errorf("radostest: %s: data differs at offset %d", o.Name, i)
+        }
+    }
+
+    xattrs, err := ctx.GetXattrs(o.Name)
+    if err != nil {
+        return err
+    }
+
+    if err = compareMaps(o.Name, "xattr", xattrs, o.Xattrs); err != nil {
+        return err
+    }
+
+    omap := make(map[string][]byte)
+    iter := ctx.OmapIter(o.Name, "")
+    for iter.Next() {
+        omap[iter.Key()] = iter.Value()
+    }
+
+    if err = iter.Err(); err != nil {
+        return err
+    }
+
+    return compareMaps(o.Name, "omap key", omap, o.Omap)
+}
+
+// compareMaps is a utility function that returns an error describing the
+// first difference between the stored and expected maps.
+func compareMaps(name, kind string, stored, expected map[string][]byte) error {
+    for key, value := range expected {
+        got, ok := stored[key]
+        if !ok {
+            return fmt.Errorf("radostest: %s: %s %q is missing", name, kind, key)
+        }
+
+        if !bytes.Equal(got, value) {
+            return fmt.Errorf("radostest: %s: %s %q is %q, expected %q", name, kind, key, got, value)
+        }
+    }
+
+    for key := range stored {
+        if _, ok := expected[key]; !ok {
+            return fmt.Errorf("radostest: %s: unexpected %s %q", name, kind, key)
+        }
+    }
+
+    return nil
+}
+
+// Default settings of a Generator.
+const (
+    defaultMaxSize  = 64 << 10
+    defaultMaxValue = 64
+)
+
+// Generator generates random test objects. The same seed and settings
+// always generate the same objects, so failures can be reproduced. The
+// zero value is a generator seeded with 0, with the default settings.
+type Generator struct {
+    MaxSize    int64   // Maximum object size (default 64 KB)
+    Sparseness float64 // Fraction of objects that are sparse (0 to 1)
+    MaxXattrs  int     // Maximum number of extended attributes
+    MaxOmap    int     // Maximum number of omap keys
+    MaxValue   int     // Maximum size of xattr and omap values (default 64)
+
+    rand *rand.Rand
+}
+
+// NewGenerator returns a generator of random objects seeded with seed.
+func NewGenerator(seed int64) *Generator {
+    return &Generator{
+        MaxSize:  defaultMaxSize,
+        MaxValue: defaultMaxValue,
+        rand:     rand.New(rand.NewSource(seed)),
+    }
+}
+
+// Next generates a random object with the given name.
+func (g *Generator) Next(name string) *Object {
+    if g.rand == nil {
+        g.rand = rand.New(rand.NewSource(0))
+    }
+
+    maxSize := g.MaxSize
+    if maxSize <= 0 {
+        maxSize = defaultMaxSize
+    }
+
+    maxValue := g.MaxValue
+    if maxValue <= 0 {
+        maxValue = defaultMaxValue
+    }
+
+    o := &Object{
+        Name:   name,
+        Size:   g.rand.Int63n(maxSize + 1),
+        Xattrs: make(map[string][]byte),
+        Omap:   make(map[string][]byte),
+    }
+
+    if o.Size > 0 && g.rand.Float64() < g.Sparseness {
+        // A few extents, leaving holes in between
+        for i := g.rand.Intn(4) + 1; i > 0; i-- {
+            off := g.rand.Int63n(o.Size)
+            length := g.rand.Int63n(o.Size-off) + 1
+            o.Extents = append(o.Extents, Extent{Off: off, Data: g.bytes(int(length))})
+        }
+    } else if o.Size > 0 {
+        o.Extents = []Extent{{Off: 0, Data: g.bytes(int(o.Size))}}
+    }
+
+    for i := g.intn(g.MaxXattrs); i > 0; i-- {
+        o.Xattrs[fmt.Sprintf("xattr.%d", i)] = g.bytes(g.intn(maxValue))
+    }
+
+    for i := g.intn(g.MaxOmap); i > 0; i-- {
+        o.Omap[fmt.Sprintf("key.%d", i)] = g.bytes(g.intn(maxValue))
+    }
+
+    return o
+}
+
+// intn is a utility function that returns a random number from 0 to n.
+func (g *Generator) intn(n int) int {
+    if n <= 0 {
+        return 0
+    }
+
+    return g.rand.Intn(n + 1)
+}
+
+// bytes is a utility function that returns n random bytes.
+func (g *Generator) bytes(n int) []byte {
+    data := make([]byte, n)
+    g.rand.Read(data)
+
+    return data
+}
@@ -0,0 +1,98 @@
+// Package radostest provides helpers for writing tests against a live RADOS
+// cluster with the rados package, such as temporary pools that are deleted
+// when the test ends.
+//
+// Tests are skipped when no cluster is reachable. The cluster configuration
+// is read from the file named by the CEPH_CONF environment variable if set,
+// and from the default paths (e.g., /etc/ceph/ceph.conf) otherwise.
+//
+//     func TestSomething(t *testing.T) {
+//         pool := radostest.NewPool(t)
+//         ctx := pool.Context(t)
+//         ...
+//     }
+package radostest
+
+import (
+    "fmt"
+    "os"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/mrkvm/rados.go"
+)
+
+// poolCounter makes the names of the pools created by a process unique.
+var poolCounter atomic.Uint64
+
+// PoolName returns a new name for a temporary pool, which is unique across
+// the processes running on the host.
+func PoolName() string {
+    return fmt.Sprintf("rados.go.test.%d.%d.%d", time.Now().Unix(), os.Getpid(), poolCounter.Add(1))
+}
+
+// Connect returns a cluster handle, which is released when the test ends.
+// The test is skipped if the cluster cannot be reached.
+func Connect(t testing.TB) *rados.Rados {
+    t.Helper()
+
+    r, err := rados.New(os.Getenv("CEPH_CONF"))
+    if err != nil {
+        t.Skipf("RADOS cluster not reachable: %s", err)
+    }
+
+    t.Cleanup(func() {
+        r.Release()
+    })
+
+    return r
+}
+
+// Pool is a temporary pool, which is deleted when the test that created it
+// ends.
+type Pool struct {
+    Rados *rados.Rados
+    Name  string
+}
+
+// NewPool creates a temporary pool on a new cluster handle (see
+// Connect()). The test is skipped if the cluster cannot be reached, and
+// fails if the pool cannot be created.
+func NewPool(t testing.TB) *Pool {
+    t.Helper()
+
+    p := &Pool{Rados: Connect(t), Name: PoolName()}
+
+    if err := p.Rados.CreatePool(p.Name); err != nil {
+        t.Fatalf("radostest: %s", err)
+    }
+
+    // Cleanups run in reverse order, so the pool is deleted before the
+    // handle is released.
+    t.Cleanup(func() {
+        _, err := p.Rados.DeletePoolWithOptions(p.Name, &rados.DeleteOptions{AllowPoolDelete: true})
+        if err != nil {
+            t.Errorf("radostest: %s", err)
+        }
+    })
+
+    return p
+}
+
+// Context returns a new IO context for the pool, which is released when
+// the test ends.
+func (p *Pool) Context(t testing.TB) *rados.Context {
+    t.Helper()
+
+    ctx, err := p.Rados.NewContext(p.Name)
+    if err != nil {
+        t.Fatalf("radostest: %s", err)
+    }
+
+    t.Cleanup(func() {
+        ctx.Release()
+    })
+
+    return ctx
+}
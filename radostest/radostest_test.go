@@ -0,0 +1,66 @@
+package radostest
+
+import (
+    "bytes"
+    "fmt"
+    "testing"
+)
+
+func Test_PoolName(t *testing.T) {
+    if a, b := PoolName(), PoolName(); a == b {
+        t.Errorf("Expected unique pool names, got %s twice", a)
+    }
+}
+
+func Test_NewPool(t *testing.T) {
+    pool := NewPool(t)
+    ctx := pool.Context(t)
+
+    if err := ctx.Put("test-object", []byte("data")); err != nil {
+        t.Fatalf("Put: %s", err)
+    }
+}
+
+func Test_Generator(t *testing.T) {
+    a := NewGenerator(1)
+    b := NewGenerator(1)
+
+    for i := 0; i < 10; i++ {
+        if !bytes.Equal(a.Next("obj").Data(), b.Next("obj").Data()) {
+            t.Fatalf("Expected generators with the same seed to agree")
+        }
+    }
+}
+
+func Test_ZeroGenerator(t *testing.T) {
+    var a Generator
+    b := NewGenerator(0)
+
+    for i := 0; i < 10; i++ {
+        if !bytes.Equal(a.Next("obj").Data(), b.Next("obj").Data()) {
+            t.Fatalf("Expected the zero generator to agree with seed 0")
+        }
+    }
+}
+
+func Test_GeneratedObjects(t *testing.T) {
+    pool := NewPool(t)
+    ctx := pool.Context(t)
+
+    g := NewGenerator(42)
+    g.Sparseness = 0.5
+    g.MaxXattrs = 4
+    g.MaxOmap = 8
+
+    for i := 0; i < 20; i++ {
+        obj := g.Next(fmt.Sprintf("obj-%d", i))
+
+        if err := obj.Write(ctx); err != nil {
+            t.Fatalf("Write %s: %s", obj.Name, err)
+        }
+
+        if err := obj.Verify(ctx); err != nil {
+            t.Errorf("Verify: %s", err)
+        }
+    }
+}
@@ -0,0 +1,68 @@
+package rados
+
+// ReadPolicy selects which OSDs serve the reads performed through a
+// context (see Context.SetReadPolicy()).
+type ReadPolicy int
+
+// Read policies.
+const (
+    // ReadPrimary sends all reads to the primary OSD of the object, the
+    // librados default.
+    ReadPrimary ReadPolicy = iota
+
+    // ReadBalance spreads reads over all the replicas of the object.
+    ReadBalance
+
+    // ReadLocalize sends reads to the replica closest to the client,
+    // according to the CRUSH location of the client (see
+    // WithCrushLocation()).
+    ReadLocalize
+)
+
+// WithReadPolicy sets the read policy of the contexts created from the
+// cluster handle (see Context.SetReadPolicy()).
+func WithReadPolicy(policy ReadPolicy) Option {
+    return func(o *options) {
+        o.readPolicy = policy
+    }
+}
+
+// WithCrushLocation sets the CRUSH location of the client, e.g.,
+// "host=node1 rack=r1 datacenter=dc1", which ReadLocalize uses to pick the
+// closest replica. It sets the crush_location configuration option,
+// overriding the configuration file.
+func WithCrushLocation(location string) Option {
+    return func(o *options) {
+        o.crushLocation = location
+    }
+}
+
+// SetReadPolicy sets the read policy used by all subsequent reads through
+// the given context. Serving reads from replicas offloads the primary OSDs
+// for read-mostly workloads, but a read from a replica may not reflect
+// writes that are still in flight. The policy is applied with the
+// operation flags of the context (see SetOpFlags()).
+func (c *Context) SetReadPolicy(policy ReadPolicy) {
+    flags := c.opFlags &^ (OpBalanceReads | OpLocalizeReads)
+
+    switch policy {
+    case ReadBalance:
+        flags |= OpBalanceReads
+    case ReadLocalize:
+        flags |= OpLocalizeReads
+    }
+
+    c.SetOpFlags(flags)
+}
+
+// ReadPolicy returns the read policy of the given context.
+func (c *Context) ReadPolicy() ReadPolicy {
+    switch {
+    case c.opFlags&OpLocalizeReads != 0:
+        return ReadLocalize
+    case c.opFlags&OpBalanceReads != 0:
+        return ReadBalance
+    }
+
+    return ReadPrimary
+}
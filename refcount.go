@@ -0,0 +1,152 @@
+package rados
+
+import (
+    "encoding/binary"
+    "fmt"
+)
+
+// RefcountGet adds the taking of a reference to the object, identified by
+// tag, to the operation (see Context.RefcountGet()).
+func (op *WriteOp) RefcountGet(tag string) {
+    body := appendEncoded(nil, []byte(tag))
+    body = append(body, 0) // No implicit reference
+
+    op.Exec("refcount", "get", appendStruct(nil, body))
+}
+
+// RefcountPut adds the dropping of the reference to the object identified
+// by tag to the operation (see Context.RefcountPut()).
+func (op *WriteOp) RefcountPut(tag string) {
+    body := appendEncoded(nil, []byte(tag))
+    body = append(body, 0) // No implicit reference
+
+    op.Exec("refcount", "put", appendStruct(nil, body))
+}
+
+// RefcountGet takes a reference, identified by tag, to the named object
+// in the pool referenced by the given context. The references of an
+// object are maintained by the OSD through the refcount object class,
+// which lets layers that share objects between several owners (such as
+// deduplicated or content-addressed chunks) reclaim an object once its
+// last owner is gone. Taking a reference with a tag the object already
+// holds has no effect, so each owner should use a tag of its own.
+func (c *Context) RefcountGet(name, tag string) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.RefcountGet(tag)
+
+    return c.Operate(name, op)
+}
+
+// RefcountPut drops the reference identified by tag to the named object
+// in the pool referenced by the given context. When the last reference is
+// dropped, the OSD removes the object. Dropping a reference the object
+// doesn't hold has no effect.
+func (c *Context) RefcountPut(name, tag string) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.RefcountPut(tag)
+
+    return c.Operate(name, op)
+}
+
+// RefcountSet replaces the references to the named object in the pool
+// referenced by the given context with the given tags.
+func (c *Context) RefcountSet(name string, tags []string) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.Exec("refcount", "set", appendStruct(nil, encodeStrings(nil, tags)))
+
+    return c.Operate(name, op)
+}
+
+// RefcountRead returns the tags of the references to the named object in
+// the pool referenced by the given context.
+func (c *Context) RefcountRead(name string) ([]string, error) {
+    in := appendStruct(nil, []byte{0}) // No implicit reference
+
+    out, err := c.Exec(name, "refcount", "read", in)
+    if err != nil {
+        return nil, err
+    }
+
+    // The output is a cls_refcount_read_ret structure holding the tags
+    body, ok := decodeStruct(out)
+    if !ok {
+        return nil, fmt.Errorf("RADOS refcount read %s: invalid output", name)
+    }
+
+    tags, ok := decodeStrings(body)
+    if !ok {
+        return nil, fmt.Errorf("RADOS refcount read %s: invalid output", name)
+    }
+
+    return tags, nil
+}
+
+// encodeStrings is a utility function that appends a list of strings to
+// buf in the RADOS wire format (a little-endian 32-bit count followed by
+// the strings).
+func encodeStrings(buf []byte, strs []string) []byte {
+    buf = appendUint32(buf, uint32(len(strs)))
+    for _, s := range strs {
+        buf = appendEncoded(buf, []byte(s))
+    }
+
+    return buf
+}
+
+// decodeStrings is a utility function that decodes a list of strings in
+// the RADOS wire format.
+func decodeStrings(buf []byte) ([]string, bool) {
+    if len(buf) < 4 {
+        return nil, false
+    }
+
+    n := binary.LittleEndian.Uint32(buf)
+    buf = buf[4:]
+
+    var strs []string
+    for i := uint32(0); i < n; i++ {
+        if len(buf) < 4 {
+            return nil, false
+        }
+
+        length := binary.LittleEndian.Uint32(buf)
+        if uint64(len(buf)-4) < uint64(length) {
+            return nil, false
+        }
+
+        strs = append(strs, string(buf[4:4+length]))
+        buf = buf[4+length:]
+    }
+
+    return strs, true
+}
+
+// RefcountGet wraps the Context-based RefcountGet function for the given
+// object.
+func (o *Object) RefcountGet(tag string) error {
+    return o.c.RefcountGet(o.name, tag)
+}
+
+// RefcountPut wraps the Context-based RefcountPut function for the given
+// object.
+func (o *Object) RefcountPut(tag string) error {
+    return o.c.RefcountPut(o.name, tag)
+}
+
+// RefcountSet wraps the Context-based RefcountSet function for the given
+// object.
+func (o *Object) RefcountSet(tags []string) error {
+    return o.c.RefcountSet(o.name, tags)
+}
+
+// RefcountRead wraps the Context-based RefcountRead function for the given
+// object.
+func (o *Object) RefcountRead() ([]string, error) {
+    return o.c.RefcountRead(o.name)
+}
@@ -0,0 +1,336 @@
+package rados
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sync/atomic"
+    "syscall"
+    "time"
+)
+
+const (
+    // journalEntryPrefix prefixes the omap keys of the entries of a
+    // replication journal.
+    journalEntryPrefix = "e."
+
+    // journalCheckpointKey is the omap key of a replication journal that
+    // records the last entry replicated.
+    journalCheckpointKey = "checkpoint"
+)
+
+// ConflictPolicy decides how replication handles objects of the
+// destination that were modified there since the mutation replicated.
+type ConflictPolicy int
+
+const (
+    // ConflictOverwrite replaces the destination object with the source
+    // object: the source always wins.
+    ConflictOverwrite ConflictPolicy = iota
+
+    // ConflictKeepNewer leaves the destination object alone if it was
+    // modified after the last mutation of the source object. Modification
+    // times have a resolution of one second, and the clocks of the clients
+    // of both clusters must be synchronized.
+    ConflictKeepNewer
+)
+
+// ReplayOptions configure the replay of a replication journal.
+type ReplayOptions struct {
+    // Conflict is the policy for objects modified on the destination.
+    Conflict ConflictPolicy
+
+    // Pools maps the source pools to the destination pools of different
+    // names. Other pools are replicated to pools of the same name.
+    Pools map[string]string
+}
+
+// JournalEntry records the mutation of an object in a replication journal.
+type JournalEntry struct {
+    Time      time.Time `json:"time"`
+    Op        string    `json:"op"`
+    Pool      string    `json:"pool"`
+    Namespace string    `json:"namespace,omitempty"`
+    Object    string    `json:"object"`
+}
+
+// ReplicationJournal records the objects mutated through a cluster handle,
+// so they can be replicated to another cluster (see Replay() and
+// StartReplicator()). The journal is stored in the omap of an object, and
+// is fed by the audit entries of the handle, which cover every mutating
+// call: it is an AuditSink, to be added with Rados.AddAuditSink() so it
+// can run alongside other audit sinks.
+//
+// Replication is state-based: the journal only records which objects
+// changed, and replaying it copies their current data, extended
+// attributes and omap keys (or removes them if they no longer exist), so
+// replaying an entry more than once is harmless. Objects with locator keys
+// are not supported.
+type ReplicationJournal struct {
+    c     *Context
+    name  string
+    pools map[string]bool
+    seq   atomic.Uint64
+}
+
+// NewReplicationJournal returns the replication journal stored in the
+// named object in the pool referenced by the given context, which records
+// the mutations of objects in the given pools (of all pools but the one
+// of the journal if none are given).
+func (c *Context) NewReplicationJournal(name string, pools ...string) *ReplicationJournal {
+    j := &ReplicationJournal{c: c, name: name}
+
+    if len(pools) > 0 {
+        j.pools = make(map[string]bool, len(pools))
+        for _, pool := range pools {
+            j.pools[pool] = true
+        }
+    }
+
+    return j
+}
+
+// Audit records the mutation described by the audit entry in the journal,
+// unless it failed or concerns an object that is not replicated. Lock and
+// pool snapshot operations are not recorded.
+func (j *ReplicationJournal) Audit(entry AuditEntry) error {
+    switch {
+    case entry.Error != "" || entry.Object == "":
+        return nil
+    case j.pools == nil && entry.Pool == j.c.Pool:
+        return nil
+    case j.pools != nil && !j.pools[entry.Pool]:
+        return nil
+    case entry.Pool == j.c.Pool && entry.Namespace == j.c.namespace && entry.Object == j.name:
+        return nil
+    }
+
+    switch entry.Op {
+    case "lock", "unlock", "break lock", "create snap", "remove snap":
+        return nil
+    }
+
+    value, err := json.Marshal(JournalEntry{
+        Time:      entry.Time,
+        Op:        entry.Op,
+        Pool:      entry.Pool,
+        Namespace: entry.Namespace,
+        Object:    entry.Object,
+    })
+    if err != nil {
+        return err
+    }
+
+    // Keys sort in the order of the mutations, and are unique across
+    // clients and goroutines.
+    key := fmt.Sprintf("%s%020d.%d.%d", journalEntryPrefix, entry.Time.UnixNano(),
+        entry.Instance, j.seq.Add(1))
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.OmapSet(map[string][]byte{key: value})
+
+    if cerr := j.c.operate(j.name, op, nil); cerr < 0 {
+        return j.c.writeError("journal", j.name, cerr)
+    }
+
+    return nil
+}
+
+// Checkpoint returns the time of the mutation last replicated from the
+// journal, or the zero time if nothing was replicated yet. The replication
+// lag is the time elapsed since.
+func (j *ReplicationJournal) Checkpoint() (time.Time, error) {
+    vals, err := j.c.OmapGetValsByKeys(j.name, []string{journalCheckpointKey})
+    if err != nil && !errors.Is(err, syscall.ENOENT) {
+        return time.Time{}, err
+    }
+
+    value, ok := vals[journalCheckpointKey]
+    if !ok {
+        return time.Time{}, nil
+    }
+
+    var entry JournalEntry
+    if err = json.Unmarshal(value, &entry); err != nil {
+        return time.Time{}, fmt.Errorf("RADOS journal %s checkpoint: %s", j.name, err)
+    }
+
+    return entry.Time, nil
+}
+
+// Replay replicates the objects recorded in the journal to the cluster
+// dst (which may be the source cluster if the pools are mapped to other
+// pools), in the order of their mutations. Entries are removed from the
+// journal as they are replicated, and the checkpoint advanced. Replay
+// stops at the first object that fails to replicate, so it is retried
+// first by the next replay. It returns the number of entries replicated.
+func (j *ReplicationJournal) Replay(dst *Rados, opts *ReplayOptions) (int, error) {
+    if opts == nil {
+        opts = &ReplayOptions{}
+    }
+
+    contexts := j.c.rados.NewContextPool(2)
+    defer contexts.Close()
+
+    dstContexts := dst.NewContextPool(2)
+    defer dstContexts.Close()
+
+    iter := j.c.OmapIter(j.name, journalEntryPrefix)
+    replayed := 0
+
+    for {
+        var keys []string
+        var last []byte
+
+        // Replicate a batch of entries, then trim them
+        for len(keys) < omapBatchSize && iter.Next() {
+            var entry JournalEntry
+            if err := json.Unmarshal(iter.Value(), &entry); err != nil {
+                return replayed, fmt.Errorf("RADOS journal %s entry %s: %s", j.name, iter.Key(), err)
+            }
+
+            if err := replicateObject(contexts, dstContexts, entry, opts); err != nil {
+                if terr := j.trim(keys, last); terr != nil {
+                    return replayed, terr
+                }
+                return replayed + len(keys), err
+            }
+
+            keys = append(keys, iter.Key())
+            last = iter.Value()
+        }
+
+        if len(keys) == 0 {
+            return replayed, iter.Err()
+        }
+
+        if err := j.trim(keys, last); err != nil {
+            return replayed, err
+        }
+        replayed += len(keys)
+    }
+}
+
+// trim is a utility function that removes the given replicated entries
+// from the journal, and records the last of them as the checkpoint.
+func (j *ReplicationJournal) trim(keys []string, last []byte) error {
+    if len(keys) == 0 {
+        return nil
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.OmapRmKeys(keys)
+    op.OmapSet(map[string][]byte{journalCheckpointKey: last})
+
+    if cerr := j.c.operate(j.name, op, nil); cerr < 0 {
+        return j.c.writeError("journal trim", j.name, cerr)
+    }
+
+    return nil
+}
+
+// replicateObject is a utility function that copies the current state of
+// the object of the journal entry from the cluster of src to the one of
+// dst, or removes it from dst if it no longer exists.
+func replicateObject(src, dst *ContextPool, entry JournalEntry, opts *ReplayOptions) error {
+    srcCtx, err := src.Get(entry.Pool, entry.Namespace)
+    if err != nil {
+        return err
+    }
+    defer src.Put(srcCtx)
+
+    dstPool, ok := opts.Pools[entry.Pool]
+    if !ok {
+        dstPool = entry.Pool
+    }
+
+    dstCtx, err := dst.Get(dstPool, entry.Namespace)
+    if err != nil {
+        return err
+    }
+    defer dst.Put(dstCtx)
+
+    if opts.Conflict == ConflictKeepNewer {
+        info, err := dstCtx.Stat(entry.Object)
+        if err == nil && info.ModTime().After(entry.Time.Truncate(time.Second)) {
+            return nil
+        }
+    }
+
+    _, err = srcCtx.Stat(entry.Object)
+    if errors.Is(err, syscall.ENOENT) {
+        err = dstCtx.remove(entry.Object)
+        if errors.Is(err, syscall.ENOENT) {
+            err = nil
+        }
+        return err
+    } else if err != nil {
+        return err
+    }
+
+    _, err = copyObject(srcCtx, entry.Object, dstCtx, entry.Object, false, nil)
+
+    return err
+}
+
+// ReplicatorOptions configure a replicator.
+type ReplicatorOptions struct {
+    // Interval between two replays of the journal.
+    Interval time.Duration
+
+    // Replay configures each replay.
+    Replay ReplayOptions
+
+    // OnError, if not nil, is called when a replay fails.
+    OnError func(err error)
+}
+
+// Replicator replays a replication journal in the background (see
+// ReplicationJournal.StartReplicator()). A replicator must be stopped with
+// Close() when it is no longer needed.
+type Replicator struct {
+    j    *ReplicationJournal
+    dst  *Rados
+    opts ReplicatorOptions
+    loop *loop
+}
+
+// StartReplicator starts replaying the journal to the cluster dst every
+// opts.Interval (see Replay()), for asynchronous replication of raw pools
+// to a remote cluster. The callback is called from the goroutine of the
+// replicator.
+func (j *ReplicationJournal) StartReplicator(dst *Rados, opts ReplicatorOptions) (*Replicator, error) {
+    l, err := newLoop("start replicator", opts.Interval)
+    if err != nil {
+        return nil, err
+    }
+
+    rep := &Replicator{
+        j:    j,
+        dst:  dst,
+        opts: opts,
+        loop: l,
+    }
+
+    l.start(rep.replay)
+
+    return rep, nil
+}
+
+// replay is a utility function that replays the journal once, reporting
+// errors to the callback of the replicator.
+func (rep *Replicator) replay() {
+    if _, err := rep.j.Replay(rep.dst, &rep.opts.Replay); err != nil && rep.opts.OnError != nil {
+        rep.opts.OnError(err)
+    }
+}
+
+// Close stops the replicator, waiting for a running replay to finish.
+// Closing it again does nothing.
+func (rep *Replicator) Close() error {
+    return rep.loop.close(nil)
+}
@@ -0,0 +1,225 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "stdint.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "encoding/binary"
+    "fmt"
+    "strconv"
+    "unsafe"
+)
+
+// Omap keys of a ring buffer object.
+const (
+    ringHeadKey     = "rados.go.ring.head"
+    ringSlotsKey    = "rados.go.ring.slots"
+    ringSlotSizeKey = "rados.go.ring.slot_size"
+)
+
+// ringRecordHeader is the size of the length prefix of the records of a
+// ring buffer.
+const ringRecordHeader = 4
+
+// RingBuffer is a fixed-size circular buffer of records stored in a single
+// object, for bounded telemetry or trace storage: once the buffer is full,
+// each new record overwrites the oldest one. Records are stored in slots
+// of a fixed size in the data of the object, and the sequence number of
+// the next record (the head) in its omap. Each push writes its record and
+// advances the head in a single operation guarded by the head, so
+// concurrent writers never overwrite each other's records.
+type RingBuffer struct {
+    c        *Context
+    name     string
+    slots    int
+    slotSize int
+}
+
+// RingRecord is a record read from a ring buffer.
+type RingRecord struct {
+    Seq  uint64 // Sequence number of the record, from 0
+    Data []byte
+}
+
+// CreateRingBuffer creates a ring buffer of slots records of up to
+// slotSize - 4 bytes each in the named object in the pool referenced by
+// the given context. It fails with an error wrapping syscall.EEXIST if the
+// object exists.
+func (c *Context) CreateRingBuffer(name string, slots, slotSize int) (rb *RingBuffer, err error) {
+    defer c.audit("create ring buffer", name, &err)
+
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    if slots < 1 || slotSize <= ringRecordHeader {
+        return nil, fmt.Errorf("RADOS ring buffer %s: invalid geometry %d x %d", name, slots, slotSize)
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.Create(true)
+    op.OmapSet(map[string][]byte{
+        ringHeadKey:     []byte("0"),
+        ringSlotsKey:    []byte(strconv.Itoa(slots)),
+        ringSlotSizeKey: []byte(strconv.Itoa(slotSize)),
+    })
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return nil, c.writeError("create ring buffer", name, cerr)
+    }
+
+    return &RingBuffer{c: c, name: name, slots: slots, slotSize: slotSize}, nil
+}
+
+// OpenRingBuffer returns the ring buffer stored in the named object in the
+// pool referenced by the given context (see CreateRingBuffer()).
+func (c *Context) OpenRingBuffer(name string) (*RingBuffer, error) {
+    vals, err := c.OmapGetValsByKeys(name, []string{ringSlotsKey, ringSlotSizeKey})
+    if err != nil {
+        return nil, err
+    }
+
+    slots, err1 := strconv.Atoi(string(vals[ringSlotsKey]))
+    slotSize, err2 := strconv.Atoi(string(vals[ringSlotSizeKey]))
+    if err1 != nil || err2 != nil {
+        return nil, fmt.Errorf("RADOS ring buffer %s: not a ring buffer", name)
+    }
+
+    return &RingBuffer{c: c, name: name, slots: slots, slotSize: slotSize}, nil
+}
+
+// Push appends a record to the ring buffer, overwriting the oldest record
+// if the buffer is full, and returns its sequence number. The record may
+// be up to the slot size of the buffer minus 4 bytes long.
+func (rb *RingBuffer) Push(data []byte) (seq uint64, err error) {
+    defer rb.c.audit("ring push", rb.name, &err)
+
+    if len(data) > rb.slotSize-ringRecordHeader {
+        return 0, fmt.Errorf("RADOS ring buffer %s: %d bytes record over %d", rb.name, len(data),
+            rb.slotSize-ringRecordHeader)
+    }
+
+    record := appendUint32(nil, uint32(len(data)))
+    record = append(record, data...)
+
+    for {
+        head, err := rb.head()
+        if err != nil {
+            return 0, err
+        }
+
+        op := NewWriteOp()
+
+        op.OmapCmp(ringHeadKey, CmpEq, []byte(strconv.FormatUint(head, 10)))
+        op.Write(record, int64(head%uint64(rb.slots))*int64(rb.slotSize))
+        op.OmapSet(map[string][]byte{ringHeadKey: []byte(strconv.FormatUint(head+1, 10))})
+
+        cerr := rb.c.operate(rb.name, op, nil)
+        op.Release()
+
+        switch {
+        case cerr == -C.ECANCELED:
+            // Someone else pushed a record in the meantime
+            continue
+        case cerr < 0:
+            return 0, rb.c.writeError("ring push", rb.name, cerr)
+        }
+
+        return head, nil
+    }
+}
+
+// head is a utility function that returns the sequence number of the next
+// record of the ring buffer.
+func (rb *RingBuffer) head() (uint64, error) {
+    vals, err := rb.c.OmapGetValsByKeys(rb.name, []string{ringHeadKey})
+    if err != nil {
+        return 0, err
+    }
+
+    head, err := strconv.ParseUint(string(vals[ringHeadKey]), 10, 64)
+    if err != nil {
+        return 0, fmt.Errorf("RADOS ring buffer %s: invalid head %q", rb.name, vals[ringHeadKey])
+    }
+
+    return head, nil
+}
+
+// Records returns the records held by the ring buffer, from the oldest to
+// the newest. The head and the records are read in a single operation, so
+// they are consistent even while other clients push records.
+func (rb *RingBuffer) Records() ([]RingRecord, error) {
+    length := rb.slots * rb.slotSize
+
+    // The data is read when the operation is performed, so the buffer
+    // and results must live in C memory.
+    cdata := C.malloc(C.size_t(length))
+    defer C.free(cdata)
+    cread := (*C.size_t)(C.malloc(C.size_t(unsafe.Sizeof(C.size_t(0)))))
+    defer C.free(unsafe.Pointer(cread))
+    cprval := (*C.int)(C.malloc(C.size_t(unsafe.Sizeof(C.int(0)))))
+    defer C.free(unsafe.Pointer(cprval))
+
+    *cread = 0
+    *cprval = 0
+
+    ckey := C.CString(ringHeadKey)
+    defer C.free(unsafe.Pointer(ckey))
+
+    entries, _, cerr := rb.c.omapRead(rb.name, func(op C.rados_read_op_t, iter *C.rados_omap_iter_t,
+        more *C.uchar, prval *C.int) {
+        C.rados_read_op_omap_get_vals_by_keys(op, &ckey, 1, iter, prval)
+        C.rados_read_op_read(op, 0, C.size_t(length), (*C.char)(cdata), cread, cprval)
+    })
+    if cerr == 0 {
+        cerr = *cprval
+    }
+
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS ring buffer %s: %w", rb.name, radosErrno(cerr))
+    }
+
+    if len(entries) != 1 {
+        return nil, fmt.Errorf("RADOS ring buffer %s: not a ring buffer", rb.name)
+    }
+
+    head, err := strconv.ParseUint(string(entries[0].Value), 10, 64)
+    if err != nil {
+        return nil, fmt.Errorf("RADOS ring buffer %s: invalid head %q", rb.name, entries[0].Value)
+    }
+
+    data := C.GoBytes(cdata, C.int(*cread))
+
+    var first uint64
+    if head > uint64(rb.slots) {
+        first = head - uint64(rb.slots)
+    }
+
+    records := make([]RingRecord, 0, head-first)
+    for seq := first; seq < head; seq++ {
+        off := int(seq%uint64(rb.slots)) * rb.slotSize
+        if off+ringRecordHeader > len(data) {
+            return nil, fmt.Errorf("RADOS ring buffer %s: record %d missing", rb.name, seq)
+        }
+
+        size := int(binary.LittleEndian.Uint32(data[off:]))
+        if size > rb.slotSize-ringRecordHeader || off+ringRecordHeader+size > len(data) {
+            return nil, fmt.Errorf("RADOS ring buffer %s: record %d corrupted", rb.name, seq)
+        }
+
+        records = append(records, RingRecord{
+            Seq:  seq,
+            Data: data[off+ringRecordHeader : off+ringRecordHeader+size],
+        })
+    }
+
+    return records, nil
+}
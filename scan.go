@@ -0,0 +1,73 @@
+package rados
+
+import (
+    "strings"
+    "sync"
+)
+
+// scanObjects is a utility function that lists the objects in the pool
+// referenced by the given context whose names start with prefix, and calls
+// fn for each of them from concurrency goroutines. fn is passed a context
+// for the namespace and locator key of the object, which it must not keep.
+// scanObjects returns once all the calls have returned, with the error
+// returned by fn for each object it failed for, and the error that stopped
+// the listing, if any.
+func (c *Context) scanObjects(prefix string, concurrency int,
+    fn func(ctx *Context, entry ListEntry) error) (map[ListEntry]error, error) {
+
+    if concurrency < 1 {
+        concurrency = 1
+    }
+
+    iter, err := c.ListObjects()
+    if err != nil {
+        return nil, err
+    }
+    defer iter.Close()
+
+    contexts := c.rados.NewContextPool(concurrency)
+    defer contexts.Close()
+
+    var mutex sync.Mutex
+    var wg sync.WaitGroup
+    errs := make(map[ListEntry]error)
+    entries := make(chan ListEntry)
+
+    for i := 0; i < concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+
+            for entry := range entries {
+                ctx, err := contexts.Get(c.Pool, entry.Namespace)
+
+                if err == nil {
+                    if entry.Locator != "" {
+                        ctx.SetLocatorKey(entry.Locator)
+                    }
+
+                    err = c.backoff.run(func() error {
+                        return fn(ctx, entry)
+                    })
+                    contexts.Put(ctx)
+                }
+
+                if err != nil {
+                    mutex.Lock()
+                    errs[entry] = err
+                    mutex.Unlock()
+                }
+            }
+        }()
+    }
+
+    for iter.Next() {
+        if entry := iter.Entry(); strings.HasPrefix(entry.Name, prefix) {
+            entries <- entry
+        }
+    }
+    close(entries)
+    wg.Wait()
+
+    return errs, iter.Err()
+}
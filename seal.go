@@ -0,0 +1,126 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "errors"
+    "fmt"
+    "time"
+    "unsafe"
+)
+
+// ErrImmutable is returned when modifying an object that has been sealed.
+var ErrImmutable = errors.New("RADOS object is immutable")
+
+const (
+    // sealXattr is the extended attribute that marks an object as sealed.
+    sealXattr = "rados.go.sealed"
+
+    // sealValue is the value of sealXattr on sealed objects.
+    sealValue = "1"
+
+    // sealLock is the name of the lock held on sealed objects.
+    sealLock = "rados.go.seal"
+
+    // sealTag is the tag of the user-level version of sealed objects (see
+    // ObjVersion), which the seal guard of write operations checks.
+    sealTag = "rados.go.sealed"
+)
+
+// sealGuard is the input of the check_conds method of the version object
+// class that fails on sealed objects.
+var sealGuard = versionCheck(ObjVersion{Tag: sealTag}, VersionTagNe)
+
+// Seal marks the object as immutable (write once, read many). Once an
+// object is sealed, the functions of this package that modify it, through
+// an Object or a Context alike (Put, WriteAt, Append, Truncate, Remove,
+// Operate and so on), fail with an error wrapping ErrImmutable: each write
+// operation carries a guard on the seal, which the OSD checks atomically
+// with the write. Sealing cannot be undone through this package, and
+// object class methods called with Exec() are not guarded.
+//
+// The seal is recorded in the tag of the user-level version of the object
+// (see VersionRead()), whose version number is kept. Seal fails with an
+// error wrapping ErrComparisonFailed if the version changes while the
+// object is sealed.
+//
+// Seal also takes a permanent exclusive lock on the object, so that other
+// lock-aware clients see it as locked. Note that the seal is enforced by
+// this package: clients that modify the object directly through librados
+// are not prevented from doing so.
+func (o *Object) Seal() (err error) {
+    defer o.c.audit("seal", o.name, &err)
+
+    if err := checkName(o.name); err != nil {
+        return err
+    }
+
+    // Objects that don't exist yet are unversioned
+    var ver ObjVersion
+    if out, cerr := o.c.exec(o.name, "version", "read", nil); cerr == 0 {
+        if ver, err = decodeVersionRead(o.name, out); err != nil {
+            return err
+        }
+    } else if cerr != -C.ENOENT {
+        return fmt.Errorf("RADOS seal %s: %w", o.name, radosErrno(cerr))
+    }
+
+    // Sealing a sealed object again is harmless, so the operation itself
+    // is not guarded.
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.unguarded = true
+    op.VersionCheck(ver, VersionEq)
+    op.VersionSet(ObjVersion{Ver: ver.Ver, Tag: sealTag})
+    op.SetXattr(sealXattr, []byte(sealValue))
+
+    if cerr := o.c.operate(o.name, op, nil); cerr < 0 {
+        return o.c.writeError("seal", o.name, cerr)
+    }
+
+    cname := C.CString(o.name)
+    defer C.free(unsafe.Pointer(cname))
+    clock := C.CString(sealLock)
+    defer C.free(unsafe.Pointer(clock))
+    cdesc := C.CString("sealed")
+    defer C.free(unsafe.Pointer(cdesc))
+
+    // A nil duration makes the lock permanent. The lock already being
+    // held means the object was sealed before.
+    start := time.Now()
+    cerr := o.c.call(opOther, "rados_lock_exclusive", o.name, func() C.int {
+        return C.rados_lock_exclusive(o.c.ctx, cname, clock, clock, cdesc, nil, 0)
+    })
+    o.c.record(opOther, o.name, start, cerr, 0)
+
+    if cerr < 0 && cerr != -C.EEXIST && cerr != -C.EBUSY {
+        return fmt.Errorf("RADOS seal %s: %w", o.name, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// IsSealed returns true if the object has been sealed.
+func (o *Object) IsSealed() (bool, error) {
+    if err := checkName(o.name); err != nil {
+        return false, err
+    }
+
+    value, cerr := o.c.getXattr(o.name, sealXattr)
+
+    switch {
+    case cerr == 0:
+        return string(value) == sealValue, nil
+    case cerr == -C.ENODATA || cerr == -C.ENOENT:
+        return false, nil
+    default:
+        return false, fmt.Errorf("RADOS seal check %s: %w", o.name, radosErrno(cerr))
+    }
+}
@@ -0,0 +1,118 @@
+package rados
+
+import (
+    "iter"
+)
+
+// All returns an iterator over the remaining objects of the given
+// iterator, for use in range loops. The iterator is closed when the loop
+// ends, including when it is exited early, and Err() reports the error
+// that stopped the iteration, if any:
+//
+//     iter, err := ctx.ListObjects()
+//     ...
+//     for entry := range iter.All() {
+//         fmt.Println(entry.Name)
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+func (iter *ObjectIterator) All() iter.Seq[ListEntry] {
+    return func(yield func(ListEntry) bool) {
+        defer iter.Close()
+
+        for iter.Next() {
+            if !yield(iter.Entry()) {
+                return
+            }
+        }
+    }
+}
+
+// Objects returns an iterator over the names of all the objects in the
+// pool referenced by the given context, along with the error that stopped
+// the listing, if any, which is yielded last with an empty name:
+//
+//     for name, err := range ctx.Objects() {
+//         if err != nil {
+//             ...
+//         }
+//         fmt.Println(name)
+//     }
+//
+// Ranging over the names only ignores errors. Use ListObjects() to access
+// the namespace and locator key of the objects.
+func (c *Context) Objects() iter.Seq2[string, error] {
+    return func(yield func(string, error) bool) {
+        objects, err := c.ListObjects()
+        if err != nil {
+            yield("", err)
+            return
+        }
+
+        for entry := range objects.All() {
+            if !yield(entry.Name, nil) {
+                return
+            }
+        }
+
+        if err = objects.Err(); err != nil {
+            yield("", err)
+        }
+    }
+}
+
+// All returns an iterator over the remaining keys of the given iterator
+// and their values, for use in range loops. Err() reports the error that
+// stopped the iteration, if any:
+//
+//     iter := ctx.OmapIter("index", "")
+//     for key, value := range iter.All() {
+//         fmt.Println(key, value)
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+func (iter *OmapIterator) All() iter.Seq2[string, []byte] {
+    return func(yield func(string, []byte) bool) {
+        for iter.Next() {
+            if !yield(iter.Key(), iter.Value()) {
+                return
+            }
+        }
+    }
+}
+
+// OmapAll returns an iterator over all the omap keys of the named object
+// in the pool referenced by the given context and their values, in key
+// order, along with the error that stopped the iteration, if any, which is
+// yielded last with an empty entry:
+//
+//     for entry, err := range ctx.OmapAll("index") {
+//         if err != nil {
+//             ...
+//         }
+//         fmt.Println(entry.Key, entry.Value)
+//     }
+//
+// Ranging over the entries only ignores errors.
+func (c *Context) OmapAll(name string) iter.Seq2[OmapEntry, error] {
+    return func(yield func(OmapEntry, error) bool) {
+        iter := c.OmapIter(name, "")
+
+        for iter.Next() {
+            if !yield(iter.entry, nil) {
+                return
+            }
+        }
+
+        if err := iter.Err(); err != nil {
+            yield(OmapEntry{}, err)
+        }
+    }
+}
+
+// OmapAll wraps the Context-based OmapAll function for the given object.
+func (o *Object) OmapAll() iter.Seq2[OmapEntry, error] {
+    return o.c.OmapAll(o.name)
+}
@@ -0,0 +1,65 @@
+package rados
+
+/*
+#include "stdlib.h"
+*/
+import "C"
+
+import (
+    "time"
+)
+
+// SlowOp describes an operation that took longer than the slow operation
+// threshold of its context (see Context.SetSlowOps()).
+type SlowOp struct {
+    Pool      string
+    Namespace string
+    Op        string // Kind of operation: "read", "write", "stat", etc.
+    Object    string // Empty for listings
+    Size      int    // Bytes transferred
+    Duration  time.Duration
+    Err       error // Error of the operation, if it failed
+}
+
+// SetSlowOps makes the given context report the operations that take
+// longer than threshold to fn, e.g., to log them or count them in a
+// metric, which helps spotting pathological objects or degraded placement
+// groups from the client side. fn is called synchronously once the
+// operation has completed (from the goroutine waiting for it, for
+// asynchronous operations), so it should be quick. A threshold of 0, the
+// default, disables the reports.
+func (c *Context) SetSlowOps(threshold time.Duration, fn func(op SlowOp)) {
+    c.slowOpThreshold = threshold
+    c.onSlowOp = fn
+}
+
+// record is a utility function that accounts for an operation of the
+// given kind on the named object in the statistics of the context (see
+// contextStats.record()), and reports it if it was slow.
+func (c *Context) record(kind opKind, name string, start time.Time, cerr C.int, n int) {
+    c.stats.record(kind, start, cerr, n)
+
+    if c.slowOpThreshold <= 0 || c.onSlowOp == nil {
+        return
+    }
+
+    duration := time.Since(start)
+    if duration < c.slowOpThreshold {
+        return
+    }
+
+    op := SlowOp{
+        Pool:      c.Pool,
+        Namespace: c.namespace,
+        Op:        opKindNames[kind],
+        Object:    name,
+        Duration:  duration,
+    }
+    if cerr < 0 {
+        op.Err = radosErrno(cerr)
+    } else if n > 0 {
+        op.Size = n
+    }
+
+    c.onSlowOp(op)
+}
@@ -0,0 +1,121 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "fmt"
+    "sort"
+    "time"
+    "unsafe"
+)
+
+// PoolSnap describes a snapshot of a pool.
+type PoolSnap struct {
+    ID   uint64
+    Name string
+    Time time.Time // When the snapshot was taken
+}
+
+// CreateSnap takes a snapshot of the pool referenced by the given context
+// under the given name. Pool snapshots cannot be taken of pools using
+// self-managed snapshots (e.g., RBD pools).
+func (c *Context) CreateSnap(name string) (err error) {
+    defer c.audit("create snap", name, &err)
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    cerr := c.call(opOther, "rados_ioctx_snap_create", "", func() C.int {
+        return C.rados_ioctx_snap_create(c.ctx, cname)
+    })
+    if cerr < 0 {
+        return fmt.Errorf("RADOS snap create %s: %w", name, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// RemoveSnap removes the named snapshot of the pool referenced by the given
+// context.
+func (c *Context) RemoveSnap(name string) (err error) {
+    defer c.audit("remove snap", name, &err)
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    cerr := c.call(opOther, "rados_ioctx_snap_remove", "", func() C.int {
+        return C.rados_ioctx_snap_remove(c.ctx, cname)
+    })
+    if cerr < 0 {
+        return fmt.Errorf("RADOS snap remove %s: %w", name, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// Snaps returns the snapshots of the pool referenced by the given context,
+// oldest first.
+func (c *Context) Snaps() ([]PoolSnap, error) {
+    var ids []C.rados_snap_t
+    maxlen := 16 // Initial guess at the number of snapshots
+
+    // rados_ioctx_snap_list() fails with ERANGE if the snapshots don't
+    // fit in our buffer, in which case we retry with a bigger one.
+    for {
+        ids = make([]C.rados_snap_t, maxlen)
+
+        cerr := c.call(opOther, "rados_ioctx_snap_list", "", func() C.int {
+            return C.rados_ioctx_snap_list(c.ctx, &ids[0], C.int(maxlen))
+        })
+
+        if cerr == -C.ERANGE {
+            maxlen *= 2
+            continue
+        } else if cerr < 0 {
+            return nil, fmt.Errorf("RADOS snap list: %w", radosErrno(cerr))
+        }
+
+        ids = ids[:cerr]
+        break
+    }
+
+    snaps := make([]PoolSnap, 0, len(ids))
+    buf := make([]byte, 256)
+
+    for _, id := range ids {
+        cname, cnamelen := byteSliceToBuffer(buf)
+
+        cerr := c.call(opOther, "rados_ioctx_snap_get_name", "", func() C.int {
+            return C.rados_ioctx_snap_get_name(c.ctx, id, cname, C.int(cnamelen))
+        })
+        if cerr < 0 {
+            return nil, fmt.Errorf("RADOS snap get name %d: %w", id, radosErrno(cerr))
+        }
+
+        var cstamp C.time_t
+        cerr = c.call(opOther, "rados_ioctx_snap_get_stamp", "", func() C.int {
+            return C.rados_ioctx_snap_get_stamp(c.ctx, id, &cstamp)
+        })
+        if cerr < 0 {
+            return nil, fmt.Errorf("RADOS snap get stamp %d: %w", id, radosErrno(cerr))
+        }
+
+        snaps = append(snaps, PoolSnap{
+            ID:   uint64(id),
+            Name: C.GoString(cname),
+            Time: time.Unix(int64(cstamp), 0),
+        })
+    }
+
+    sort.Slice(snaps, func(i, j int) bool {
+        return snaps[i].ID < snaps[j].ID
+    })
+
+    return snaps, nil
+}
@@ -0,0 +1,252 @@
+package rados
+
+/*
+#include "stdlib.h"
+*/
+import "C"
+
+import (
+    "expvar"
+    "fmt"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// opKind classifies the operations counted in the context statistics.
+type opKind int
+
+const (
+    opRead opKind = iota
+    opWrite
+    opStat
+    opRemove
+    opXattr
+    opList
+    opOther
+    nOpKinds
+)
+
+// opKindNames are the names under which the statistics of each kind of
+// operation are reported.
+var opKindNames = [nOpKinds]string{"read", "write", "stat", "remove", "xattr", "list", "other"}
+
+// OpStats holds the counters for one kind of operation.
+type OpStats struct {
+    Ops     uint64        // Number of operations
+    Errors  uint64        // Number of failed operations
+    Latency time.Duration // Sum of the latencies of all operations
+}
+
+// ContextStats is a snapshot of the operation counters of a Context.
+type ContextStats struct {
+    Ops          uint64
+    Errors       uint64
+    BytesRead    uint64
+    BytesWritten uint64
+
+    // PerOp holds the counters for each kind of operation: "read",
+    // "write", "stat", "remove", "xattr", "list" and "other".
+    PerOp map[string]OpStats
+
+    // ReadRate and WriteRate are the bytes read and written per second
+    // over the last Window, if a throughput window is set (see
+    // Context.SetStatsWindow()).
+    Window    time.Duration
+    ReadRate  float64
+    WriteRate float64
+}
+
+// windowBuckets is the number of buckets a throughput window is split
+// into. The window slides one bucket at a time.
+const windowBuckets = 10
+
+// throughputWindow accounts for the bytes read and written over a sliding
+// window of time.
+type throughputWindow struct {
+    window time.Duration
+    width  time.Duration // Width of a bucket
+
+    mutex   sync.Mutex
+    buckets [windowBuckets]throughputBucket
+}
+
+// throughputBucket holds the bytes read and written during a slice of
+// time of a throughput window.
+type throughputBucket struct {
+    slice   int64 // Index of the slice of time, since the Unix epoch
+    read    uint64
+    written uint64
+}
+
+// newThroughputWindow is a utility function that creates a throughput
+// window of the given duration.
+func newThroughputWindow(window time.Duration) *throughputWindow {
+    width := window / windowBuckets
+    if width <= 0 {
+        width = 1
+    }
+
+    return &throughputWindow{window: window, width: width}
+}
+
+// add is a utility function that accounts for read and written bytes
+// transferred now.
+func (w *throughputWindow) add(now time.Time, read, written int) {
+    slice := now.UnixNano() / int64(w.width)
+
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+
+    b := &w.buckets[slice%windowBuckets]
+    if b.slice != slice {
+        *b = throughputBucket{slice: slice}
+    }
+    b.read += uint64(read)
+    b.written += uint64(written)
+}
+
+// rates is a utility function that returns the bytes read and written per
+// second over the window, as of now.
+func (w *throughputWindow) rates(now time.Time) (float64, float64) {
+    slice := now.UnixNano() / int64(w.width)
+
+    w.mutex.Lock()
+    defer w.mutex.Unlock()
+
+    var read, written uint64
+    for _, b := range w.buckets {
+        if b.slice > slice-windowBuckets && b.slice <= slice {
+            read += b.read
+            written += b.written
+        }
+    }
+
+    seconds := w.window.Seconds()
+
+    return float64(read) / seconds, float64(written) / seconds
+}
+
+// contextStats holds the live counters of a Context. All the counters are
+// updated atomically, so they can be shared by concurrent operations.
+type contextStats struct {
+    ops          [nOpKinds]atomic.Uint64
+    errors       [nOpKinds]atomic.Uint64
+    nanos        [nOpKinds]atomic.Uint64
+    bytesRead    atomic.Uint64
+    bytesWritten atomic.Uint64
+
+    window atomic.Pointer[throughputWindow] // Throughput window, or nil
+}
+
+// record is a utility function that accounts for an operation of the
+// given kind which started at start, returned cerr and transferred n
+// bytes.
+func (s *contextStats) record(kind opKind, start time.Time, cerr C.int, n int) {
+    s.ops[kind].Add(1)
+    s.nanos[kind].Add(uint64(time.Since(start)))
+
+    if cerr < 0 {
+        s.errors[kind].Add(1)
+        return
+    }
+
+    if n <= 0 {
+        return
+    }
+
+    switch kind {
+    case opRead:
+        s.bytesRead.Add(uint64(n))
+        if w := s.window.Load(); w != nil {
+            w.add(time.Now(), n, 0)
+        }
+    case opWrite:
+        s.bytesWritten.Add(uint64(n))
+        if w := s.window.Load(); w != nil {
+            w.add(time.Now(), 0, n)
+        }
+    }
+}
+
+// SetStatsWindow makes the given context track the bytes read and written
+// per second over a sliding window of the given duration, reported by
+// Stats() along with the counters, so applications can adapt their load
+// to the throughput they observe. The window slides by a tenth of its
+// duration at a time. A window of 0, the default, disables the tracking.
+// Changing the window discards the throughput tracked so far.
+func (c *Context) SetStatsWindow(window time.Duration) {
+    if window <= 0 {
+        c.stats.window.Store(nil)
+        return
+    }
+
+    c.stats.window.Store(newThroughputWindow(window))
+}
+
+// StatsWindow returns the throughput window of the given context, or 0 if
+// throughput is not tracked.
+func (c *Context) StatsWindow() time.Duration {
+    if w := c.stats.window.Load(); w != nil {
+        return w.window
+    }
+
+    return 0
+}
+
+// Stats returns a snapshot of the operation counters of the given context.
+// The counters cover all the operations performed through the context
+// since it was created, and the throughput rates cover the last window
+// (see SetStatsWindow()).
+func (c *Context) Stats() ContextStats {
+    stats := ContextStats{
+        BytesRead:    c.stats.bytesRead.Load(),
+        BytesWritten: c.stats.bytesWritten.Load(),
+        PerOp:        make(map[string]OpStats),
+    }
+
+    for kind := opKind(0); kind < nOpKinds; kind++ {
+        op := OpStats{
+            Ops:     c.stats.ops[kind].Load(),
+            Errors:  c.stats.errors[kind].Load(),
+            Latency: time.Duration(c.stats.nanos[kind].Load()),
+        }
+
+        stats.Ops += op.Ops
+        stats.Errors += op.Errors
+        stats.PerOp[opKindNames[kind]] = op
+    }
+
+    if w := c.stats.window.Load(); w != nil {
+        stats.Window = w.window
+        stats.ReadRate, stats.WriteRate = w.rates(time.Now())
+    }
+
+    return stats
+}
+
+// publishMutex serializes the publications of PublishStats(), so that
+// concurrent publications under the same name fail instead of panicking.
+var publishMutex sync.Mutex
+
+// PublishStats publishes the operation counters of the given context as
+// the expvar variable of the given name, so they are served along with the
+// other variables of the process (e.g., on /debug/vars). The variable
+// holds the snapshot returned by Stats() whenever it is read. Variables
+// cannot be unpublished, so the counters remain available after the
+// context is released, frozen at their last values. It fails if a variable
+// with the name is already published.
+func (c *Context) PublishStats(name string) error {
+    publishMutex.Lock()
+    defer publishMutex.Unlock()
+
+    if expvar.Get(name) != nil {
+        return fmt.Errorf("RADOS publish stats %s: variable already published", name)
+    }
+
+    expvar.Publish(name, expvar.Func(func() interface{} {
+        return c.Stats()
+    }))
+
+    return nil
+}
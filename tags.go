@@ -0,0 +1,230 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "syscall"
+)
+
+const (
+    // tagsXattr is the extended attribute in which an object records its
+    // tags.
+    tagsXattr = "rados.go.tags"
+
+    // tagIndexPrefix prefixes the names of the index objects of tags.
+    tagIndexPrefix = "rados.go.tags."
+)
+
+// SetTags sets the tags of the named object in the pool referenced by the
+// given context to tags, replacing any tags it had before, so objects can
+// be labeled with application metadata and later found by tag (see
+// TagIndex()). The object must exist. Tag keys and values may not contain
+// NUL or \x01 bytes.
+//
+// The tags are recorded in an extended attribute of the object in a single
+// atomic operation, and mirrored in one index per tag key, which is updated
+// around it like an Index.
+func (c *Context) SetTags(name string, tags map[string]string) (err error) {
+    defer c.audit("set tags", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    for key, value := range tags {
+        if key == "" || strings.ContainsAny(key, "\x00"+indexSeparator) ||
+            strings.ContainsAny(value, "\x00"+indexSeparator) {
+            return fmt.Errorf("RADOS tag %q=%q: %w", key, value, ErrInvalidName)
+        }
+    }
+
+    oldTags, err := c.Tags(name)
+    if err != nil {
+        return err
+    }
+
+    // Index the object under its new tags first, so it can always be
+    // found under the tags it has.
+    for key, value := range tags {
+        if old, ok := oldTags[key]; !ok || old != value {
+            if err = c.TagIndex(key).update(name, []string{value}, nil); err != nil {
+                return err
+            }
+        }
+    }
+
+    value, err := json.Marshal(tags)
+    if err != nil {
+        return fmt.Errorf("RADOS tags %s: %s", name, err)
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.SetXattr(tagsXattr, value)
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("set tags", name, cerr)
+    }
+
+    for key, old := range oldTags {
+        if value, ok := tags[key]; !ok || value != old {
+            if err = c.TagIndex(key).update(name, nil, []string{old}); err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
+
+// Tags returns the tags of the named object in the pool referenced by the
+// given context.
+func (c *Context) Tags(name string) (map[string]string, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    value, cerr := c.getXattr(name, tagsXattr)
+
+    switch {
+    case cerr == -C.ENODATA:
+        return map[string]string{}, nil
+    case cerr < 0:
+        return nil, fmt.Errorf("RADOS tags %s: %w", name, radosErrno(cerr))
+    }
+
+    tags := make(map[string]string)
+    if err := json.Unmarshal(value, &tags); err != nil {
+        return nil, fmt.Errorf("RADOS tags %s: %s", name, err)
+    }
+
+    return tags, nil
+}
+
+// TagIndex returns the index of the objects by the value of the tag key in
+// the pool referenced by the given context, e.g.,
+// ctx.TagIndex("color").Lookup("red") iterates over the objects tagged
+// with color=red. Objects removed without clearing their tags first (see
+// SetTags()) remain in the index.
+func (c *Context) TagIndex(key string) *Index {
+    return c.NewIndex(tagIndexPrefix + key)
+}
+
+// TagIterator iterates over the objects found by a tag search (see
+// FindByTag() and FindTagged()).
+//
+//     iter := ctx.FindByTag("color", "red")
+//     for iter.Next() {
+//         fmt.Println(iter.Name(), iter.Tags())
+//     }
+//     if err := iter.Err(); err != nil {
+//         ...
+//     }
+type TagIterator struct {
+    c     *Context
+    iter  *IndexIterator
+    match func(name string, tags map[string]string) bool
+
+    name string
+    tags map[string]string
+    err  error
+}
+
+// FindByTag returns an iterator over the objects in the pool referenced by
+// the given context tagged with key=value, in name order.
+func (c *Context) FindByTag(key, value string) *TagIterator {
+    return &TagIterator{
+        c:    c,
+        iter: c.TagIndex(key).Lookup(value),
+        match: func(name string, tags map[string]string) bool {
+            v, ok := tags[key]
+            return ok && v == value
+        },
+    }
+}
+
+// FindTagged returns an iterator over the objects in the pool referenced
+// by the given context that have the tag key (whatever its value) and for
+// which match, if not nil, returns true. match is passed the name and all
+// the tags of each object, so it can test any combination of them, e.g.,
+// objects with a size tag above a threshold and a given owner. Objects are
+// returned in the order of the values of key.
+func (c *Context) FindTagged(key string, match func(name string, tags map[string]string) bool) *TagIterator {
+    return &TagIterator{
+        c:    c,
+        iter: c.TagIndex(key).Query(""),
+        match: func(name string, tags map[string]string) bool {
+            if _, ok := tags[key]; !ok {
+                return false
+            }
+
+            return match == nil || match(name, tags)
+        },
+    }
+}
+
+// Next advances the iterator to the next matching object, which is then
+// available from Name() and Tags(). It returns false when there are no
+// more objects or an error occurred (see Err()).
+//
+// The tags of each object found in the index are read from the object
+// itself, so objects that were removed or no longer match (e.g., because a
+// client crashed while retagging them) are skipped.
+func (iter *TagIterator) Next() bool {
+    for iter.err == nil && iter.iter.Next() {
+        name := iter.iter.Entry().Name
+
+        tags, err := iter.c.Tags(name)
+        if errors.Is(err, syscall.ENOENT) {
+            continue
+        } else if err != nil {
+            iter.err = err
+            return false
+        }
+
+        if iter.match(name, tags) {
+            iter.name, iter.tags = name, tags
+            return true
+        }
+    }
+
+    return false
+}
+
+// Name returns the name of the object the iterator is positioned at.
+func (iter *TagIterator) Name() string {
+    return iter.name
+}
+
+// Tags returns the tags of the object the iterator is positioned at.
+func (iter *TagIterator) Tags() map[string]string {
+    return iter.tags
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (iter *TagIterator) Err() error {
+    if iter.err != nil {
+        return iter.err
+    }
+
+    return iter.iter.Err()
+}
+
+// SetTags wraps the Context-based SetTags function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) SetTags(tags map[string]string) error {
+    return o.c.SetTags(o.name, tags)
+}
+
+// Tags wraps the Context-based Tags function for the given object.
+func (o *Object) Tags() (map[string]string, error) {
+    return o.c.Tags(o.name)
+}
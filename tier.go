@@ -0,0 +1,115 @@
+package rados
+
+import (
+    "strconv"
+    "time"
+)
+
+// CacheMode is the caching mode of a cache tier pool.
+type CacheMode string
+
+// Cache modes.
+const (
+    CacheModeNone      CacheMode = "none"
+    CacheModeWriteback CacheMode = "writeback"
+    CacheModeReadproxy CacheMode = "readproxy"
+    CacheModeReadonly  CacheMode = "readonly"
+    CacheModeProxy     CacheMode = "proxy"
+)
+
+// HitSetConfig configures how a cache tier pool tracks accesses to decide
+// which objects to promote.
+type HitSetConfig struct {
+    Type   string        // Hit set type, e.g., "bloom"
+    Count  int           // Number of hit sets to keep
+    Period time.Duration // Time covered by each hit set (whole seconds)
+    FPP    float64       // False positive probability of bloom hit sets, or 0
+}
+
+// CacheTargets configures when a cache tier pool flushes and evicts
+// objects. All the fields are applied; a maximum of 0 means no limit.
+type CacheTargets struct {
+    MaxBytes       uint64  // Flush and evict past this many bytes
+    MaxObjects     uint64  // Flush and evict past this many objects
+    DirtyRatio     float64 // Start flushing at this ratio of dirty objects
+    DirtyHighRatio float64 // Flush faster at this ratio of dirty objects
+    FullRatio      float64 // Start evicting at this ratio of the targets
+}
+
+// AddTier makes the tier pool a tier of the base pool.
+func (r *Rados) AddTier(base, tier string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix":   "osd tier add",
+        "pool":     base,
+        "tierpool": tier,
+    }, nil)
+}
+
+// RemoveTier removes the tier pool from the tiers of the base pool.
+func (r *Rados) RemoveTier(base, tier string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix":   "osd tier rm",
+        "pool":     base,
+        "tierpool": tier,
+    }, nil)
+}
+
+// SetOverlay directs the clients of the base pool to the tier pool.
+func (r *Rados) SetOverlay(base, tier string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix":      "osd tier set-overlay",
+        "pool":        base,
+        "overlaypool": tier,
+    }, nil)
+}
+
+// RemoveOverlay directs the clients of the base pool back to the base
+// pool.
+func (r *Rados) RemoveOverlay(base string) error {
+    return r.monCommandJSON(map[string]interface{}{
+        "prefix": "osd tier rm-overlay",
+        "pool":   base,
+    }, nil)
+}
+
+// SetCacheMode sets the caching mode of the tier pool. Switching a tier
+// with dirty objects to CacheModeNone or CacheModeReadonly loses data
+// unless the tier has been flushed first.
+func (r *Rados) SetCacheMode(tier string, mode CacheMode) error {
+    cmd := map[string]interface{}{
+        "prefix": "osd tier cache-mode",
+        "pool":   tier,
+        "mode":   string(mode),
+    }
+    if mode == CacheModeReadonly {
+        cmd["yes_i_really_mean_it"] = true
+    }
+
+    return r.monCommandJSON(cmd, nil)
+}
+
+// SetHitSet configures the hit sets of the tier pool.
+func (r *Rados) SetHitSet(tier string, config HitSetConfig) error {
+    settings := [][2]string{
+        {"hit_set_type", config.Type},
+        {"hit_set_count", strconv.Itoa(config.Count)},
+        {"hit_set_period", strconv.FormatInt(int64(config.Period/time.Second), 10)},
+    }
+    if config.FPP > 0 {
+        settings = append(settings, [2]string{"hit_set_fpp", strconv.FormatFloat(config.FPP, 'g', -1, 64)})
+    }
+
+    return r.poolSettings(tier, settings)
+}
+
+// SetCacheTargets configures the flush and eviction targets of the tier
+// pool.
+func (r *Rados) SetCacheTargets(tier string, targets CacheTargets) error {
+    return r.poolSettings(tier, [][2]string{
+        {"target_max_bytes", strconv.FormatUint(targets.MaxBytes, 10)},
+        {"target_max_objects", strconv.FormatUint(targets.MaxObjects, 10)},
+        {"cache_target_dirty_ratio", strconv.FormatFloat(targets.DirtyRatio, 'g', -1, 64)},
+        {"cache_target_dirty_high_ratio", strconv.FormatFloat(targets.DirtyHighRatio, 'g', -1, 64)},
+        {"cache_target_full_ratio", strconv.FormatFloat(targets.FullRatio, 'g', -1, 64)},
+    })
+}
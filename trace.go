@@ -0,0 +1,125 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "context"
+    "io"
+    "log"
+    "os"
+    "runtime/pprof"
+    "sync/atomic"
+    "syscall"
+    "time"
+)
+
+// tracer is the logger librados calls are traced to, or nil if trace mode
+// is disabled.
+var tracer atomic.Pointer[log.Logger]
+
+func init() {
+    if trace := os.Getenv("RADOS_GO_TRACE"); trace != "" && trace != "0" {
+        SetTrace(os.Stderr)
+    }
+}
+
+// SetTrace enables trace mode, in which each librados call made by the
+// cluster handles and contexts is logged to w with its pool, object,
+// duration and errno. A nil writer disables trace mode. Trace mode is
+// disabled by default, unless the RADOS_GO_TRACE environment variable is
+// set (to a value other than "0"), in which case calls are logged to the
+// standard error.
+func SetTrace(w io.Writer) {
+    if w == nil {
+        tracer.Store(nil)
+        return
+    }
+
+    tracer.Store(log.New(w, "rados: ", log.LstdFlags|log.Lmicroseconds))
+}
+
+// SetProfileLabels makes the librados calls of the given context run with
+// the pprof labels of ctx plus the labels "rados_pool" and "rados_op",
+// naming the pool and the kind of operation, so that CPU profiles
+// attribute the time spent in librados to RADOS operations. The labels of
+// the calling goroutine are set back to those of ctx after each call, so
+// ctx should be the context the goroutine got its labels from (see
+// pprof.Do()), or context.Background() if it has none. A nil ctx, the
+// default, leaves the labels of the goroutines alone.
+func (c *Context) SetProfileLabels(ctx context.Context) {
+    c.profileCtx = ctx
+    c.profileLabels = nil
+
+    if ctx == nil {
+        return
+    }
+
+    // The labeled contexts are made once, so calls don't allocate
+    c.profileLabels = new([nOpKinds]context.Context)
+    for kind, name := range opKindNames {
+        c.profileLabels[kind] = pprof.WithLabels(ctx, pprof.Labels("rados_pool", c.Pool, "rados_op", name))
+    }
+}
+
+// call is a utility function that makes fn, the librados call function
+// for an operation of the given kind on the named object, and returns its
+// result. The call runs with the pprof labels of the context (see
+// SetProfileLabels()), and is logged in trace mode. If the context was
+// released, fn is not called, and call returns cerrClosed.
+func (c *Context) call(kind opKind, function, name string, fn func() C.int) C.int {
+    if c.closed() {
+        return cerrClosed
+    }
+
+    start := time.Now()
+
+    if c.profileLabels != nil {
+        pprof.SetGoroutineLabels(c.profileLabels[kind])
+    }
+    cerr := fn()
+    if c.profileLabels != nil {
+        pprof.SetGoroutineLabels(c.profileCtx)
+    }
+
+    trace(function, c.Pool, kind, name, start, cerr)
+
+    return cerr
+}
+
+// call is a utility function that makes fn, the librados call function
+// for an operation of the given cluster handle, about the named pool if
+// any, and returns its result. The call is logged in trace mode. If the
+// handle was released, fn is not called, and call returns cerrClosed.
+func (r *Rados) call(function, pool string, fn func() C.int) C.int {
+    if r.rados == nil {
+        return cerrClosed
+    }
+
+    start := time.Now()
+    cerr := fn()
+
+    trace(function, pool, opOther, "", start, cerr)
+
+    return cerr
+}
+
+// trace is a utility function that logs a librados call that started at
+// start and returned cerr, in trace mode.
+func trace(function, pool string, kind opKind, name string, start time.Time, cerr C.int) {
+    t := tracer.Load()
+    if t == nil {
+        return
+    }
+
+    errno := "0"
+    if cerr < 0 {
+        errno = syscall.Errno(-cerr).Error()
+    }
+
+    t.Printf("%s pool=%s op=%s object=%q duration=%s result=%d errno=%s",
+        function, pool, opKindNames[kind], name, time.Since(start), int(cerr), errno)
+}
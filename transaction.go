@@ -0,0 +1,248 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+)
+
+// ErrTransactionConflict is returned when an object touched by a
+// transaction was modified by someone else while the transaction was
+// being applied.
+var ErrTransactionConflict = errors.New("RADOS transaction conflict")
+
+// transactionXattr is the extended attribute in which a transaction
+// records its ID on every object it writes. This lets recovery tell
+// mutations that were already applied from mutations that were not.
+const transactionXattr = "rados.go.transaction"
+
+// Transaction groups mutations of several objects in one pool. RADOS only
+// provides atomicity for a single object, so a Transaction offers the next
+// best thing: the intended mutations are first recorded in a journal
+// object, then applied object by object, each guarded by the version the
+// object had when the transaction was committed. If the client crashes
+// part way through, RecoverTransaction() rolls the remaining mutations
+// forward from the journal.
+//
+// Each object may only be mutated once per transaction. Objects written by
+// a transaction keep the transaction ID in an extended attribute
+// afterwards.
+type Transaction struct {
+    c       *Context
+    journal string
+    ops     []transactionOp
+}
+
+// transactionOp is a single mutation as recorded in the journal.
+type transactionOp struct {
+    Op      string `json:"op"`
+    Name    string `json:"name"`
+    Data    []byte `json:"data,omitempty"`
+    Exists  bool   `json:"exists"`
+    Version uint64 `json:"version"`
+}
+
+// transactionRecord is the content of a journal object.
+type transactionRecord struct {
+    ID  string          `json:"id"`
+    Ops []transactionOp `json:"ops"`
+}
+
+// NewTransaction returns a new, empty transaction on the pool referenced
+// by the given context that uses the named object as its journal. Only one
+// transaction can use a given journal object at a time.
+func (c *Context) NewTransaction(journal string) *Transaction {
+    return &Transaction{c: c, journal: journal}
+}
+
+// Put adds a write of data to the named object to the transaction,
+// replacing any existing data like Context.Put().
+func (tx *Transaction) Put(name string, data []byte) {
+    tx.ops = append(tx.ops, transactionOp{Op: "put", Name: name, Data: data})
+}
+
+// Remove adds the removal of the named object to the transaction.
+func (tx *Transaction) Remove(name string) {
+    tx.ops = append(tx.ops, transactionOp{Op: "remove", Name: name})
+}
+
+// Commit records the transaction in its journal and applies it. If an
+// object was modified by someone else since Commit recorded its version,
+// Commit stops with ErrTransactionConflict and leaves the journal in
+// place; the remaining mutations can then be retried with
+// RecoverTransaction(), or abandoned by removing the journal object.
+func (tx *Transaction) Commit() (err error) {
+    defer tx.c.audit("transaction", tx.journal, &err)
+
+    record := transactionRecord{
+        ID:  fmt.Sprintf("%s.%d", tx.journal, time.Now().UnixNano()),
+        Ops: make([]transactionOp, len(tx.ops)),
+    }
+
+    // Record the current version of every object, which is what each
+    // mutation will assert when it is applied.
+    for i, op := range tx.ops {
+        if err := checkName(op.Name); err != nil {
+            return err
+        }
+
+        version, cerr, err := tx.c.objectVersion(op.Name)
+        if err != nil {
+            return fmt.Errorf("RADOS transaction %s: %w", tx.journal, err)
+        }
+        if cerr < 0 && cerr != -C.ENOENT {
+            return fmt.Errorf("RADOS transaction %s: stat %s: %w", tx.journal, op.Name, radosErrno(cerr))
+        }
+
+        op.Exists = cerr == 0
+        op.Version = version
+        record.Ops[i] = op
+    }
+
+    data, err := json.Marshal(&record)
+    if err != nil {
+        return fmt.Errorf("RADOS transaction %s: %w", tx.journal, err)
+    }
+
+    // The journal is created exclusively, so we never overwrite the
+    // journal of a transaction that still needs to be recovered.
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.Create(true)
+    op.WriteFull(data)
+
+    if cerr := tx.c.operate(tx.journal, op, nil); cerr < 0 {
+        return fmt.Errorf("RADOS transaction %s journal: %w", tx.journal, radosErrno(cerr))
+    }
+
+    return tx.c.applyTransaction(tx.journal, &record)
+}
+
+// RecoverTransaction rolls forward the transaction recorded in the named
+// journal object, applying the mutations that were not yet applied, and
+// removes the journal. Mutations that were already applied are skipped, so
+// RecoverTransaction can safely be called more than once.
+func (c *Context) RecoverTransaction(journal string) error {
+    data, err := c.Get(journal)
+    if err != nil {
+        return err
+    }
+
+    var record transactionRecord
+    if err = json.Unmarshal(data, &record); err != nil {
+        return fmt.Errorf("RADOS transaction %s: %w", journal, err)
+    }
+
+    return c.applyTransaction(journal, &record)
+}
+
+// applyTransaction is a utility function that applies the mutations of the
+// given transaction that were not yet applied, then removes its journal.
+func (c *Context) applyTransaction(journal string, record *transactionRecord) error {
+    for _, op := range record.Ops {
+        applied, err := c.transactionApplied(record.ID, &op)
+        if err != nil {
+            return fmt.Errorf("RADOS transaction %s: %w", journal, err)
+        }
+
+        if applied {
+            continue
+        }
+
+        wop := NewWriteOp()
+
+        if op.Exists {
+            wop.AssertVersion(op.Version)
+        } else {
+            wop.Create(true)
+        }
+
+        if op.Op == "remove" {
+            wop.Remove()
+        } else {
+            wop.WriteFull(op.Data)
+            wop.SetXattr(transactionXattr, []byte(record.ID))
+        }
+
+        cerr := c.operate(op.Name, wop, nil)
+        wop.Release()
+
+        switch {
+        case cerr == -C.ERANGE || cerr == -C.EOVERFLOW || cerr == -C.EEXIST || cerr == -C.ENOENT:
+            err = fmt.Errorf("RADOS transaction %s: %s %s: %w", journal, op.Op, op.Name, ErrTransactionConflict)
+        case cerr < 0:
+            err = fmt.Errorf("RADOS transaction %s: %s %s: %w", journal, op.Op, op.Name, radosErrno(cerr))
+        }
+
+        // Each mutation is audited on its own object, like Put() and
+        // Remove() would be.
+        c.audit(op.Op, op.Name, &err)
+        if err != nil {
+            return err
+        }
+    }
+
+    return c.remove(journal)
+}
+
+// transactionApplied is a utility function that reports whether the given
+// mutation of transaction id has already been applied.
+func (c *Context) transactionApplied(id string, op *transactionOp) (bool, error) {
+    if op.Op == "remove" {
+        // A removal was applied once the object is gone. Removing an
+        // object that didn't exist to begin with is a no-op.
+        if !op.Exists {
+            return true, nil
+        }
+
+        _, cerr, err := c.objectVersion(op.Name)
+        if err != nil {
+            return false, fmt.Errorf("stat %s: %w", op.Name, err)
+        }
+        if cerr < 0 && cerr != -C.ENOENT {
+            return false, fmt.Errorf("stat %s: %w", op.Name, radosErrno(cerr))
+        }
+
+        return cerr == -C.ENOENT, nil
+    }
+
+    value, cerr := c.getXattr(op.Name, transactionXattr)
+
+    switch {
+    case cerr == 0:
+        return bytes.Equal(value, []byte(id)), nil
+    case cerr == -C.ENOENT || cerr == -C.ENODATA:
+        return false, nil
+    default:
+        return false, fmt.Errorf("%s: %w", op.Name, radosErrno(cerr))
+    }
+}
+
+// objectVersion is a utility function that returns the current version of
+// the named object along with the raw librados result of the stat. The
+// error is set instead if the stat can't be sent at all (e.g., because the
+// context is closed).
+func (c *Context) objectVersion(name string) (uint64, C.int, error) {
+    stat, err := c.AioStat(name)
+    if err != nil {
+        return 0, 0, err
+    }
+    defer stat.Release()
+
+    if stat.Wait(); stat.ret < 0 {
+        return 0, stat.ret, nil
+    }
+
+    return stat.Version(), 0, nil
+}
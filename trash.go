@@ -0,0 +1,406 @@
+package rados
+
+import (
+    "fmt"
+    "os"
+    "syscall"
+    "time"
+)
+
+// trashNamespace is the namespace objects are moved to when they are
+// removed in trash mode.
+const trashNamespace = "rados.go.trash"
+
+// Extended attributes recording where a trashed object came from and
+// until when it is kept.
+const (
+    trashXattrName      = "rados.go.trash.name"
+    trashXattrNamespace = "rados.go.trash.namespace"
+    trashXattrLocator   = "rados.go.trash.locator"
+    trashXattrDeleted   = "rados.go.trash.deleted"
+    trashXattrExpires   = "rados.go.trash.expires"
+)
+
+// TrashEntry describes an object in the trash of a pool.
+type TrashEntry struct {
+    ID        string    // Name of the object in the trash
+    Name      string    // Original name of the object
+    Namespace string    // Original namespace of the object
+    Locator   string    // Original locator key of the object
+    Deleted   time.Time // When the object was removed
+    Expires   time.Time // When the object may be purged
+}
+
+// SetTrash enables trash mode on the given context when retention is
+// positive: subsequent removals of objects through the context move them
+// to the trash of the pool, where they are kept for at least retention
+// and can be restored (see RestoreTrash()), instead of deleting them. A
+// retention of 0, the default, disables trash mode.
+//
+// Moving an object to the trash copies it (data, extended attributes and
+// omap keys) and then deletes the original, so it costs as much as
+// writing the object again, and is not atomic: an object removed while it
+// is being written may be trashed with a mix of old and new data.
+// Trashed objects still count against the quota of the pool until they
+// are purged (see PurgeExpiredTrash()).
+func (c *Context) SetTrash(retention time.Duration) {
+    c.trashRetention = retention
+}
+
+// Trash returns the trash retention of the given context, or 0 if trash
+// mode is disabled.
+func (c *Context) Trash() time.Duration {
+    return c.trashRetention
+}
+
+// trashContext is a utility function that returns a new context for the
+// trash namespace of the pool referenced by the given context. It must be
+// released by the caller.
+func (c *Context) trashContext() (*Context, error) {
+    trash, err := c.rados.NewContext(c.Pool)
+    if err != nil {
+        return nil, err
+    }
+
+    if err = trash.SetNamespace(trashNamespace); err != nil {
+        trash.Release()
+        return nil, err
+    }
+
+    return trash, nil
+}
+
+// moveToTrash is a utility function that moves the named object to the
+// trash of the pool, keeping it for retention. It returns the trash entry
+// of the object.
+func (c *Context) moveToTrash(name string, retention time.Duration) (*TrashEntry, error) {
+    trash, err := c.trashContext()
+    if err != nil {
+        return nil, err
+    }
+    defer trash.Release()
+
+    now := time.Now().UTC()
+    entry := &TrashEntry{
+        ID:        fmt.Sprintf("%s.%s", now.Format("20060102T150405.000000000Z"), name),
+        Name:      name,
+        Namespace: c.namespace,
+        Locator:   c.locator,
+        Deleted:   now,
+        Expires:   now.Add(retention),
+    }
+
+    _, err = copyObject(c, name, trash, entry.ID, false, func(xattrs map[string][]byte) {
+        xattrs[trashXattrName] = []byte(entry.Name)
+        xattrs[trashXattrNamespace] = []byte(entry.Namespace)
+        xattrs[trashXattrLocator] = []byte(entry.Locator)
+        xattrs[trashXattrDeleted] = []byte(entry.Deleted.Format(time.RFC3339Nano))
+        xattrs[trashXattrExpires] = []byte(entry.Expires.Format(time.RFC3339Nano))
+    })
+    if err != nil {
+        trash.remove(entry.ID)
+        return nil, err
+    }
+
+    if err = c.remove(name); err != nil {
+        trash.remove(entry.ID)
+        return nil, err
+    }
+
+    return entry, nil
+}
+
+// RemoveSoft removes the named object like Remove(), but moves it to the
+// trash of the pool for at least retention whether or not trash mode is
+// enabled on the given context (see SetTrash()), so it can be restored
+// with Restore(). Each soft removal of a name keeps its own version of
+// the object in the trash.
+func (c *Context) RemoveSoft(name string, retention time.Duration) (err error) {
+    defer c.audit("remove", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    _, err = c.moveToTrash(name, retention)
+    return err
+}
+
+// Restore moves the most recently removed version of the named object
+// back from the trash of the pool, to the namespace of the given context.
+// It fails with an error wrapping syscall.ENOENT if the trash holds no
+// version of the object, and with one wrapping syscall.EEXIST if an object
+// with the name exists again (see RestoreTrash()).
+func (c *Context) Restore(name string) error {
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    entries, err := c.ListTrash()
+    if err != nil {
+        return err
+    }
+
+    var latest *TrashEntry
+    for i, entry := range entries {
+        if entry.Name != name || entry.Namespace != c.namespace {
+            continue
+        }
+
+        if latest == nil || entry.Deleted.After(latest.Deleted) {
+            latest = &entries[i]
+        }
+    }
+
+    if latest == nil {
+        return fmt.Errorf("RADOS restore %s: %w", name, syscall.ENOENT)
+    }
+
+    return c.RestoreTrash(latest.ID)
+}
+
+// RemoveSoft wraps the Context-based RemoveSoft function for the given
+// object.
+func (o *Object) RemoveSoft(retention time.Duration) error {
+    return o.c.RemoveSoft(o.name, retention)
+}
+
+// Restore wraps the Context-based Restore function for the given object.
+func (o *Object) Restore() error {
+    return o.c.Restore(o.name)
+}
+
+// ListTrash returns the objects in the trash of the pool referenced by the
+// given context, from all namespaces. Objects of the trash namespace that
+// are not trash entries are skipped.
+func (c *Context) ListTrash() ([]TrashEntry, error) {
+    trash, err := c.trashContext()
+    if err != nil {
+        return nil, err
+    }
+    defer trash.Release()
+
+    iter, err := trash.ListObjects()
+    if err != nil {
+        return nil, err
+    }
+    defer iter.Close()
+
+    var entries []TrashEntry
+
+    for iter.Next() {
+        id := iter.Entry().Name
+
+        xattrs, err := trash.GetXattrs(id)
+        if err != nil {
+            return nil, err
+        }
+
+        if entry := newTrashEntry(id, xattrs); entry != nil {
+            entries = append(entries, *entry)
+        }
+    }
+
+    return entries, iter.Err()
+}
+
+// trashEntry is a utility function that reads the trash entry of the
+// object id in the trash namespace referenced by the given context.
+func (c *Context) trashEntry(id string) (*TrashEntry, error) {
+    xattrs, err := c.GetXattrs(id)
+    if err != nil {
+        return nil, err
+    }
+
+    entry := newTrashEntry(id, xattrs)
+    if entry == nil {
+        return nil, fmt.Errorf("RADOS trash %s: not a trash entry", id)
+    }
+
+    return entry, nil
+}
+
+// newTrashEntry is a utility function that returns the trash entry of the
+// object id with the given extended attributes, or nil if the object is
+// not a trash entry.
+func newTrashEntry(id string, xattrs map[string][]byte) *TrashEntry {
+    name, ok := xattrs[trashXattrName]
+    if !ok {
+        return nil
+    }
+
+    entry := &TrashEntry{
+        ID:        id,
+        Name:      string(name),
+        Namespace: string(xattrs[trashXattrNamespace]),
+        Locator:   string(xattrs[trashXattrLocator]),
+    }
+    entry.Deleted, _ = time.Parse(time.RFC3339Nano, string(xattrs[trashXattrDeleted]))
+    entry.Expires, _ = time.Parse(time.RFC3339Nano, string(xattrs[trashXattrExpires]))
+
+    return entry
+}
+
+// RestoreTrash moves the object id back from the trash of the pool
+// referenced by the given context to its original name, namespace and
+// locator key. It fails with an error wrapping syscall.EEXIST if an object
+// with the original name exists again.
+func (c *Context) RestoreTrash(id string) error {
+    trash, err := c.trashContext()
+    if err != nil {
+        return err
+    }
+    defer trash.Release()
+
+    entry, err := trash.trashEntry(id)
+    if err != nil {
+        return err
+    }
+
+    dst, err := c.rados.NewContext(c.Pool)
+    if err != nil {
+        return err
+    }
+    defer dst.Release()
+
+    if err = dst.SetNamespace(entry.Namespace); err != nil {
+        return err
+    }
+    dst.SetLocatorKey(entry.Locator)
+
+    // The copy is exclusive, so an object that was created again under
+    // the original name is never replaced.
+    _, err = copyObject(trash, id, dst, entry.Name, true, func(xattrs map[string][]byte) {
+        for _, xattr := range []string{trashXattrName, trashXattrNamespace, trashXattrLocator,
+            trashXattrDeleted, trashXattrExpires} {
+            delete(xattrs, xattr)
+        }
+    })
+    if err != nil {
+        return err
+    }
+
+    return trash.remove(id)
+}
+
+// PurgeExpiredTrash deletes the objects in the trash of the pool
+// referenced by the given context whose retention has expired, and returns
+// a report of the objects deleted (named by their trash IDs). In dry-run
+// mode, nothing is deleted.
+func (c *Context) PurgeExpiredTrash(opts *DeleteOptions) (*DeleteReport, error) {
+    if opts == nil {
+        opts = &DeleteOptions{}
+    }
+
+    entries, err := c.ListTrash()
+    if err != nil {
+        return nil, err
+    }
+
+    trash, err := c.trashContext()
+    if err != nil {
+        return nil, err
+    }
+    defer trash.Release()
+
+    now := time.Now()
+    report := &DeleteReport{DryRun: opts.DryRun, Errors: make(map[ListEntry]error)}
+
+    for _, entry := range entries {
+        if entry.Expires.After(now) {
+            continue
+        }
+
+        listEntry := ListEntry{Name: entry.ID, Namespace: trashNamespace}
+
+        var info os.FileInfo
+        err := c.backoff.run(func() (err error) {
+            if info, err = trash.Stat(entry.ID); err == nil && !opts.DryRun {
+                err = trash.remove(entry.ID)
+            }
+            return err
+        })
+        if info != nil && !opts.DryRun {
+            trash.audit("purge", entry.ID, &err)
+        }
+
+        if err != nil {
+            report.Errors[listEntry] = err
+            continue
+        }
+
+        report.add(listEntry, info.Size())
+    }
+
+    return report, nil
+}
+
+// TrashReaper purges the expired objects from the trash of a pool in the
+// background (see Rados.StartTrashReaper()). A reaper must be stopped
+// with Close() when it is no longer needed.
+type TrashReaper struct {
+    r       *Rados
+    pool    string
+    onError func(err error)
+    loop    *loop
+}
+
+// StartTrashReaper starts purging the objects whose retention has expired
+// from the trash of the named pool every interval (see
+// PurgeExpiredTrash()). If onError is not nil, it is called from the
+// goroutine of the reaper for each purge that fails, and for each object
+// that could not be purged.
+func (r *Rados) StartTrashReaper(pool string, interval time.Duration, onError func(err error)) (*TrashReaper, error) {
+    l, err := newLoop("trash reaper", interval)
+    if err != nil {
+        return nil, err
+    }
+
+    reaper := &TrashReaper{
+        r:       r,
+        pool:    pool,
+        onError: onError,
+        loop:    l,
+    }
+
+    l.start(reaper.purge)
+
+    return reaper, nil
+}
+
+// purge is a utility function that purges the expired objects from the
+// trash once, reporting errors to the callback of the reaper.
+func (reaper *TrashReaper) purge() {
+    report, err := reaper.purgePool()
+
+    if reaper.onError == nil {
+        return
+    }
+
+    if err != nil {
+        reaper.onError(err)
+        return
+    }
+
+    for entry, err := range report.Errors {
+        reaper.onError(fmt.Errorf("RADOS purge trash %s: %w", entry.Name, err))
+    }
+}
+
+// purgePool is a utility function that purges the expired objects from
+// the trash of the pool of the reaper.
+func (reaper *TrashReaper) purgePool() (*DeleteReport, error) {
+    c, err := reaper.r.NewContext(reaper.pool)
+    if err != nil {
+        return nil, err
+    }
+    defer c.Release()
+
+    return c.PurgeExpiredTrash(nil)
+}
+
+// Close stops the reaper, waiting for a running purge to finish. Closing it
+// again does nothing.
+func (reaper *TrashReaper) Close() error {
+    return reaper.loop.close(nil)
+}
@@ -0,0 +1,137 @@
+package rados
+
+/*
+#include "errno.h"
+*/
+import "C"
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "sync"
+)
+
+var (
+    // ErrChecksumMismatch is returned when the data of an object doesn't
+    // match its stored checksum.
+    ErrChecksumMismatch = errors.New("RADOS checksum mismatch")
+
+    // ErrNoChecksum is returned when verifying an object that has no
+    // stored checksum.
+    ErrNoChecksum = errors.New("RADOS object has no checksum")
+)
+
+// checksumXattr is the extended attribute holding the hex-encoded SHA-256
+// checksum of the data of an object (see PutWithChecksum()).
+const checksumXattr = "rados.go.sha256"
+
+// PutWithChecksum writes data to the named object like Put(), and stores
+// its SHA-256 checksum in an extended attribute of the object in the same
+// atomic operation, so that the data can be verified later (see
+// VerifyChecksum() and VerifyPool()).
+func (c *Context) PutWithChecksum(name string, data []byte) (err error) {
+    defer c.audit("put", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    sum := sha256.Sum256(data)
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.WriteFull(data)
+    op.SetXattr(checksumXattr, []byte(hex.EncodeToString(sum[:])))
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("write", name, cerr)
+    }
+
+    return nil
+}
+
+// VerifyChecksum reads the named object in the pool referenced by the
+// given context and verifies its data against its stored checksum. It
+// returns an error wrapping ErrChecksumMismatch if the data is corrupted,
+// and one wrapping ErrNoChecksum if the object has no stored checksum.
+func (c *Context) VerifyChecksum(name string) error {
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    stored, cerr := c.getXattr(name, checksumXattr)
+
+    switch {
+    case cerr == -C.ENODATA:
+        return fmt.Errorf("RADOS verify %s: %w", name, ErrNoChecksum)
+    case cerr < 0:
+        return fmt.Errorf("RADOS verify %s: %w", name, radosErrno(cerr))
+    }
+
+    sum, err := c.Hash(name, sha256.New())
+    if err != nil {
+        return err
+    }
+
+    if !bytes.Equal([]byte(hex.EncodeToString(sum)), stored) {
+        return fmt.Errorf("RADOS verify %s: %w", name, ErrChecksumMismatch)
+    }
+
+    return nil
+}
+
+// VerifyReport is the outcome of a pool verification (see VerifyPool()).
+type VerifyReport struct {
+    Verified uint64 // Objects whose data matched their checksum
+
+    Corrupted  []ListEntry // Objects whose data doesn't match their checksum
+    NoChecksum []ListEntry // Objects without a stored checksum
+    Missing    []ListEntry // Objects removed while the pool was scanned
+    Errors     map[ListEntry]error
+}
+
+// VerifyPool verifies the objects in the pool referenced by the given
+// context whose names start with prefix against their stored checksums
+// (see PutWithChecksum()), keeping up to concurrency objects in flight at
+// a time. Only the objects in the namespace of the context are verified,
+// unless it is AllNamespaces. The returned error is only set if the
+// verification could not proceed at all (e.g., because listing the pool
+// failed).
+func (c *Context) VerifyPool(prefix string, concurrency int) (*VerifyReport, error) {
+    report := &VerifyReport{Errors: make(map[ListEntry]error)}
+    var mutex sync.Mutex
+
+    errs, err := c.scanObjects(prefix, concurrency, func(ctx *Context, entry ListEntry) error {
+        err := ctx.VerifyChecksum(entry.Name)
+
+        mutex.Lock()
+        defer mutex.Unlock()
+
+        switch {
+        case err == nil:
+            report.Verified++
+        case errors.Is(err, ErrChecksumMismatch):
+            report.Corrupted = append(report.Corrupted, entry)
+        case errors.Is(err, ErrNoChecksum):
+            report.NoChecksum = append(report.NoChecksum, entry)
+        default:
+            if _, cerr, serr := ctx.objectVersion(entry.Name); serr == nil && cerr == -C.ENOENT {
+                report.Missing = append(report.Missing, entry)
+                return nil
+            }
+            return err
+        }
+
+        return nil
+    })
+
+    for entry, err := range errs {
+        report.Errors[entry] = err
+    }
+
+    return report, err
+}
@@ -0,0 +1,184 @@
+package rados
+
+import (
+    "encoding/binary"
+    "fmt"
+)
+
+// ObjVersion is a user-level object version maintained by the version
+// object class. Unlike the RADOS object version, which changes on every
+// write, it only changes when explicitly set or incremented, which lets
+// applications version objects on their own terms (as the RADOS Gateway
+// does for its metadata). The tag identifies a version lineage: it is
+// chosen randomly by the OSD when a version is first incremented, so two
+// objects rarely share a tag by accident.
+type ObjVersion struct {
+    Ver uint64
+    Tag string
+}
+
+// VersionCond is a condition on the user-level version of an object (see
+// WriteOp.VersionCheck()).
+type VersionCond uint32
+
+// Conditions on the user-level version of an object. The ordering
+// conditions compare version numbers, and the tag conditions compare tags.
+const (
+    VersionEq    VersionCond = 1
+    VersionGt    VersionCond = 2
+    VersionGe    VersionCond = 3
+    VersionLt    VersionCond = 4
+    VersionLe    VersionCond = 5
+    VersionTagEq VersionCond = 6
+    VersionTagNe VersionCond = 7
+)
+
+// VersionSet adds the setting of the user-level version of the object to
+// ver to the operation.
+func (op *WriteOp) VersionSet(ver ObjVersion) {
+    op.Exec("version", "set", appendStruct(nil, encodeObjVersion(nil, ver)))
+}
+
+// VersionInc adds the increment of the user-level version of the object
+// to the operation.
+func (op *WriteOp) VersionInc() {
+    body := encodeObjVersion(nil, ObjVersion{})
+    body = appendUint32(body, 0) // No conditions
+
+    op.Exec("version", "inc", appendStruct(nil, body))
+}
+
+// VersionCheck adds a guard to the operation that makes it fail unless
+// the user-level version of the object satisfies cond with respect to ver
+// (e.g., with VersionEq, unless it is ver). Combined with VersionInc(),
+// this makes the OSD enforce check-and-update semantics on the version. A
+// failed guard makes the whole operation fail with an error wrapping
+// ErrComparisonFailed, without applying any of its actions.
+func (op *WriteOp) VersionCheck(ver ObjVersion, cond VersionCond) {
+    op.Exec("version", "check_conds", versionCheck(ver, cond))
+}
+
+// versionCheck is a utility function that returns the input of the
+// check_conds method of the version object class for a single condition.
+func versionCheck(ver ObjVersion, cond VersionCond) []byte {
+    cond1 := encodeObjVersion(nil, ver)
+    cond1 = appendUint32(cond1, uint32(cond))
+
+    body := encodeObjVersion(nil, ObjVersion{})
+    body = appendUint32(body, 1) // One condition
+    body = appendStruct(body, cond1)
+
+    return appendStruct(nil, body)
+}
+
+// VersionSet sets the user-level version of the named object in the pool
+// referenced by the given context to ver.
+func (c *Context) VersionSet(name string, ver ObjVersion) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.VersionSet(ver)
+
+    return c.Operate(name, op)
+}
+
+// VersionInc increments the user-level version of the named object in the
+// pool referenced by the given context.
+func (c *Context) VersionInc(name string) error {
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.VersionInc()
+
+    return c.Operate(name, op)
+}
+
+// VersionRead returns the user-level version of the named object in the
+// pool referenced by the given context. Objects whose version was never
+// set have the zero version.
+func (c *Context) VersionRead(name string) (ObjVersion, error) {
+    out, err := c.Exec(name, "version", "read", nil)
+    if err != nil {
+        return ObjVersion{}, err
+    }
+
+    return decodeVersionRead(name, out)
+}
+
+// decodeVersionRead is a utility function that decodes the output of the
+// read method of the version object class for the named object.
+func decodeVersionRead(name string, out []byte) (ObjVersion, error) {
+    // The output is a cls_version_read_ret structure holding the version
+    body, ok := decodeStruct(out)
+    if !ok {
+        return ObjVersion{}, fmt.Errorf("RADOS version read %s: invalid output", name)
+    }
+
+    ver, ok := decodeObjVersion(body)
+    if !ok {
+        return ObjVersion{}, fmt.Errorf("RADOS version read %s: invalid output", name)
+    }
+
+    return ver, nil
+}
+
+// encodeObjVersion is a utility function that appends ver to buf in the
+// format of the version object class.
+func encodeObjVersion(buf []byte, ver ObjVersion) []byte {
+    body := appendUint64(nil, ver.Ver)
+    body = appendEncoded(body, []byte(ver.Tag))
+
+    return appendStruct(buf, body)
+}
+
+// decodeObjVersion is a utility function that decodes a version in the
+// format of the version object class.
+func decodeObjVersion(buf []byte) (ObjVersion, bool) {
+    body, ok := decodeStruct(buf)
+    if !ok || len(body) < 12 {
+        return ObjVersion{}, false
+    }
+
+    ver := binary.LittleEndian.Uint64(body)
+    tagLen := binary.LittleEndian.Uint32(body[8:])
+
+    if uint64(len(body)-12) < uint64(tagLen) {
+        return ObjVersion{}, false
+    }
+
+    return ObjVersion{Ver: ver, Tag: string(body[12 : 12+tagLen])}, true
+}
+
+// decodeStruct is a utility function that returns the body of a versioned
+// RADOS structure at the start of buf.
+func decodeStruct(buf []byte) ([]byte, bool) {
+    if len(buf) < 6 {
+        return nil, false
+    }
+
+    length := binary.LittleEndian.Uint32(buf[2:])
+
+    if uint64(len(buf)-6) < uint64(length) {
+        return nil, false
+    }
+
+    return buf[6 : 6+length], true
+}
+
+// VersionSet wraps the Context-based VersionSet function for the given
+// object. It fails with ErrImmutable if the object has been sealed.
+func (o *Object) VersionSet(ver ObjVersion) error {
+    return o.c.VersionSet(o.name, ver)
+}
+
+// VersionInc wraps the Context-based VersionInc function for the given
+// object. It fails with ErrImmutable if the object has been sealed.
+func (o *Object) VersionInc() error {
+    return o.c.VersionInc(o.name)
+}
+
+// VersionRead wraps the Context-based VersionRead function for the given
+// object.
+func (o *Object) VersionRead() (ObjVersion, error) {
+    return o.c.VersionRead(o.name)
+}
@@ -0,0 +1,447 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "stdint.h"
+#include "errno.h"
+#include "pthread.h"
+#include "rados/librados.h"
+
+extern void goWatchCallback(void *, uint64_t, uint64_t, uint64_t, void *, size_t);
+extern void goWatchErrCallback(void *, uint64_t, int);
+*/
+import "C"
+
+import (
+    "encoding/binary"
+    "fmt"
+    "sync"
+    "time"
+    "unsafe"
+)
+
+// WatchHandler is called for each notification received by a watch, with
+// the ID of the notifying client and the notification payload. The
+// payload it returns, which may be nil, is delivered back to the notifier
+// (see Notify()), which allows request/response style exchanges between
+// clients. Handlers run on a librados thread, and the notifier waits until
+// they return, so they should not block for long.
+type WatchHandler func(notifierID uint64, data []byte) []byte
+
+// WatcherID identifies a watch: the ID of the client that registered it
+// (see Rados.InstanceID()) and the cookie of the watch within the client.
+type WatcherID struct {
+    ClientID uint64
+    Cookie   uint64
+}
+
+// Watch is a registration for the notifications sent to an object (see
+// Context.Watch()). A watch must be closed with Close() when it is no
+// longer needed.
+type Watch struct {
+    c      *Context
+    name   string
+    cookie C.uint64_t
+
+    id      uintptr
+    carg    unsafe.Pointer
+    handler WatchHandler
+    onError func(err error)
+    closed  bool
+}
+
+var (
+    watchesMutex sync.Mutex
+    watches      = make(map[uintptr]*Watch)
+    nextWatchID  uintptr
+
+    // callbackThreads holds the threads running a watch callback, so
+    // Close() can tell whether it is called from one
+    callbackThreads = make(map[C.pthread_t]bool)
+)
+
+// Watch registers for the notifications sent to the named object in the
+// pool referenced by the given context (see Notify()), calling handler
+// for each of them. The object must exist.
+//
+// The OSD considers the watch dead, and stops delivering notifications to
+// it, if it hasn't heard from the client for timeout, which must be a
+// whole number of seconds; a timeout of 0 uses the cluster default (the
+// osd_client_watch_timeout configuration option, 30 seconds by default).
+// A short timeout lets the cluster notice dead watchers quickly, which
+// matters when watches are used for fencing. If the watch is lost (e.g.,
+// because the client was disconnected for longer than timeout), onError,
+// if not nil, is called with the error; the watch must then be closed and
+// registered again.
+func (c *Context) Watch(name string, timeout time.Duration, handler WatchHandler,
+    onError func(err error)) (*Watch, error) {
+
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    if timeout < 0 || timeout%time.Second != 0 {
+        return nil, fmt.Errorf("RADOS watch %s: timeout %s is not a whole number of seconds", name, timeout)
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    w := &Watch{c: c, name: name, handler: handler, onError: onError}
+
+    // librados hands the argument back to the callbacks, so it must live
+    // in C memory. It holds the ID under which the watch is registered.
+    watchesMutex.Lock()
+    nextWatchID++
+    w.id = nextWatchID
+    watches[w.id] = w
+    watchesMutex.Unlock()
+
+    w.carg = C.malloc(C.size_t(unsafe.Sizeof(uintptr(0))))
+    *(*uintptr)(w.carg) = w.id
+
+    start := time.Now()
+    cerr := c.call(opOther, "rados_watch3", name, func() C.int {
+        return C.rados_watch3(c.ctx, cname, &w.cookie, C.rados_watchcb2_t(C.goWatchCallback),
+            C.rados_watcherrcb_t(C.goWatchErrCallback), C.uint32_t(timeout/time.Second), w.carg)
+    })
+    c.record(opOther, name, start, cerr, 0)
+
+    if cerr < 0 {
+        w.unregister()
+        return nil, fmt.Errorf("RADOS watch %s: %w", name, radosErrno(cerr))
+    }
+
+    return w, nil
+}
+
+// ID returns the identifier of the watch, under which the notifier
+// receives its replies.
+func (w *Watch) ID() WatcherID {
+    return WatcherID{
+        ClientID: w.c.rados.InstanceID(),
+        Cookie:   uint64(w.cookie),
+    }
+}
+
+// Check verifies that the watch is still registered with the OSD, and
+// returns how long ago the registration was last confirmed. It returns an
+// error if the watch was lost, in which case it must be closed and
+// registered again.
+func (w *Watch) Check() (time.Duration, error) {
+    if w.closed || w.c.closed() {
+        return 0, closedError("watch check " + w.name)
+    }
+
+    cerr := w.c.call(opOther, "rados_watch_check", w.name, func() C.int {
+        return C.rados_watch_check(w.c.ctx, w.cookie)
+    })
+    if cerr < 0 {
+        return 0, fmt.Errorf("RADOS watch check %s: %w", w.name, radosErrno(cerr))
+    }
+
+    return time.Duration(cerr) * time.Millisecond, nil
+}
+
+// Close unregisters the watch. Once Close returns, the handlers of the
+// watch are no longer called. Closing a watch again does nothing.
+//
+// A watch may be closed from its handler or error callback (e.g., to
+// register again after losing the watch). Close then doesn't wait for the
+// callbacks in progress, since the calling one would never finish, and
+// only the calling callback may still run after Close returns.
+func (w *Watch) Close() error {
+    if w.closed {
+        return nil
+    }
+    w.closed = true
+
+    start := time.Now()
+    cerr := w.c.call(opOther, "rados_unwatch2", w.name, func() C.int {
+        return C.rados_unwatch2(w.c.ctx, w.cookie)
+    })
+    w.c.record(opOther, w.name, start, cerr, 0)
+
+    if inWatchCallback() {
+        // Flushing would wait for the calling callback forever. Callbacks
+        // already queued may still look up the watch, so their argument
+        // is not freed.
+        w.carg = nil
+    } else if w.c.rados.rados != nil {
+        // Wait for the callbacks in progress before unregistering
+        C.rados_watch_flush(w.c.rados.rados)
+    }
+    w.unregister()
+
+    if cerr < 0 {
+        return fmt.Errorf("RADOS unwatch %s: %w", w.name, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// unregister is a utility function that removes the watch from the
+// registry of watches and frees the argument of its callbacks.
+func (w *Watch) unregister() {
+    watchesMutex.Lock()
+    delete(watches, w.id)
+    watchesMutex.Unlock()
+
+    if w.carg != nil {
+        C.free(w.carg)
+        w.carg = nil
+    }
+}
+
+// enterWatchCallback is a utility function that records that the current
+// thread runs a watch callback, until the returned function is called.
+// The goroutine of a callback stays on the thread of librados until the
+// callback returns.
+func enterWatchCallback() func() {
+    thread := C.pthread_self()
+
+    watchesMutex.Lock()
+    callbackThreads[thread] = true
+    watchesMutex.Unlock()
+
+    return func() {
+        watchesMutex.Lock()
+        delete(callbackThreads, thread)
+        watchesMutex.Unlock()
+    }
+}
+
+// inWatchCallback is a utility function that returns whether the current
+// goroutine runs a watch callback.
+func inWatchCallback() bool {
+    thread := C.pthread_self()
+
+    watchesMutex.Lock()
+    defer watchesMutex.Unlock()
+
+    return callbackThreads[thread]
+}
+
+// lookupWatch is a utility function that returns the watch whose callback
+// argument is arg, or nil if it was closed.
+func lookupWatch(arg unsafe.Pointer) *Watch {
+    watchesMutex.Lock()
+    defer watchesMutex.Unlock()
+
+    return watches[*(*uintptr)(arg)]
+}
+
+//export goWatchCallback
+func goWatchCallback(arg unsafe.Pointer, notifyID, cookie, notifierID C.uint64_t,
+    data unsafe.Pointer, dataLen C.size_t) {
+
+    w := lookupWatch(arg)
+    if w == nil {
+        return
+    }
+
+    defer enterWatchCallback()()
+
+    var reply []byte
+    if w.handler != nil {
+        reply = w.handler(uint64(notifierID), C.GoBytes(data, C.int(dataLen)))
+    }
+
+    // The notifier waits until every watcher acknowledges the notification
+    cname := C.CString(w.name)
+    defer C.free(unsafe.Pointer(cname))
+
+    creply, creplylen := byteSliceToBuffer(reply)
+
+    C.rados_notify_ack(w.c.ctx, cname, notifyID, cookie, creply, C.int(creplylen))
+}
+
+//export goWatchErrCallback
+func goWatchErrCallback(arg unsafe.Pointer, cookie C.uint64_t, cerr C.int) {
+    w := lookupWatch(arg)
+    if w == nil || w.onError == nil {
+        return
+    }
+
+    defer enterWatchCallback()()
+
+    w.onError(fmt.Errorf("RADOS watch %s: %w", w.name, radosErrno(cerr)))
+}
+
+// Notify sends a notification with the given payload to all the watchers
+// of the named object in the pool referenced by the given context (see
+// Watch()), and waits until they all have handled it or timeout expires.
+// A timeout of 0 uses the cluster default. It returns the replies of the
+// watchers (see WatchHandler), keyed by watcher. If some watchers didn't
+// reply in time, Notify returns the replies it received along with an
+// error.
+func (c *Context) Notify(name string, data []byte, timeout time.Duration) (map[WatcherID][]byte, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    cdata, cdatalen := byteSliceToBuffer(data)
+
+    var creply *C.char
+    var creplylen C.size_t
+
+    start := time.Now()
+    cerr := c.call(opOther, "rados_notify2", name, func() C.int {
+        return C.rados_notify2(c.ctx, cname, cdata, C.int(cdatalen), C.uint64_t(timeout/time.Millisecond),
+            &creply, &creplylen)
+    })
+    c.record(opOther, name, start, cerr, 0)
+
+    var replies map[WatcherID][]byte
+    var missed []WatcherID
+
+    if creply != nil {
+        var ok bool
+        replies, missed, ok = decodeNotifyReplies(C.GoBytes(unsafe.Pointer(creply), C.int(creplylen)))
+        C.rados_buffer_free(creply)
+
+        if !ok && cerr >= 0 {
+            return nil, fmt.Errorf("RADOS notify %s: invalid reply", name)
+        }
+    }
+
+    if cerr < 0 {
+        if len(missed) > 0 {
+            return replies, fmt.Errorf("RADOS notify %s: %w (%d watchers did not reply)",
+                name, radosErrno(cerr), len(missed))
+        }
+        return replies, fmt.Errorf("RADOS notify %s: %w", name, radosErrno(cerr))
+    }
+
+    return replies, nil
+}
+
+// Watcher describes a watch registered on an object (see Watch()).
+type Watcher struct {
+    WatcherID
+    Addr    string        // Network address of the client
+    Timeout time.Duration // After which the OSD considers the watch dead
+}
+
+// ListWatchers returns the watches registered on the named object in the
+// pool referenced by the given context.
+func (c *Context) ListWatchers(name string) ([]Watcher, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    maxWatchers := C.size_t(8) // Initial guess at the number of watchers
+
+    // rados_list_watchers() fails with ERANGE if the watchers don't fit in
+    // our buffer, setting maxWatchers to the number of watchers, in which
+    // case we retry with a bigger one.
+    for {
+        cwatchers := make([]C.obj_watch_t, maxWatchers)
+
+        start := time.Now()
+        cerr := c.call(opOther, "rados_list_watchers", name, func() C.int {
+            return C.rados_list_watchers(c.ctx, cname, &cwatchers[0], &maxWatchers)
+        })
+        c.record(opOther, name, start, cerr, 0)
+
+        if cerr == -C.ERANGE {
+            if maxWatchers <= C.size_t(len(cwatchers)) {
+                maxWatchers = 2 * C.size_t(len(cwatchers))
+            }
+            continue
+        } else if cerr < 0 {
+            return nil, fmt.Errorf("RADOS list watchers %s: %w", name, radosErrno(cerr))
+        }
+
+        watchers := make([]Watcher, 0, int(maxWatchers))
+        for _, cwatcher := range cwatchers[:maxWatchers] {
+            watchers = append(watchers, Watcher{
+                WatcherID: WatcherID{
+                    ClientID: uint64(cwatcher.watcher_id),
+                    Cookie:   uint64(cwatcher.cookie),
+                },
+                Addr:    C.GoString(&cwatcher.addr[0]),
+                Timeout: time.Duration(cwatcher.timeout_seconds) * time.Second,
+            })
+        }
+
+        return watchers, nil
+    }
+}
+
+// decodeNotifyReplies is a utility function that decodes the reply buffer
+// of a notification, which holds the replies of the watchers that
+// acknowledged it followed by the list of watchers that timed out.
+func decodeNotifyReplies(buf []byte) (map[WatcherID][]byte, []WatcherID, bool) {
+    replies := make(map[WatcherID][]byte)
+
+    if len(buf) < 4 {
+        return nil, nil, false
+    }
+
+    n := binary.LittleEndian.Uint32(buf)
+    buf = buf[4:]
+
+    for i := uint32(0); i < n; i++ {
+        if len(buf) < 20 {
+            return nil, nil, false
+        }
+
+        id := WatcherID{
+            ClientID: binary.LittleEndian.Uint64(buf),
+            Cookie:   binary.LittleEndian.Uint64(buf[8:]),
+        }
+
+        length := binary.LittleEndian.Uint32(buf[16:])
+        if uint64(len(buf)-20) < uint64(length) {
+            return nil, nil, false
+        }
+
+        replies[id] = append([]byte(nil), buf[20:20+length]...)
+        buf = buf[20+length:]
+    }
+
+    if len(buf) < 4 {
+        return nil, nil, false
+    }
+
+    n = binary.LittleEndian.Uint32(buf)
+    buf = buf[4:]
+
+    if uint64(len(buf)) < uint64(n)*16 {
+        return nil, nil, false
+    }
+
+    missed := make([]WatcherID, n)
+    for i := range missed {
+        missed[i] = WatcherID{
+            ClientID: binary.LittleEndian.Uint64(buf[16*i:]),
+            Cookie:   binary.LittleEndian.Uint64(buf[16*i+8:]),
+        }
+    }
+
+    return replies, missed, true
+}
+
+// Watch wraps the Context-based Watch function for the given object.
+func (o *Object) Watch(timeout time.Duration, handler WatchHandler, onError func(err error)) (*Watch, error) {
+    return o.c.Watch(o.name, timeout, handler, onError)
+}
+
+// Notify wraps the Context-based Notify function for the given object.
+func (o *Object) Notify(data []byte, timeout time.Duration) (map[WatcherID][]byte, error) {
+    return o.c.Notify(o.name, data, timeout)
+}
+
+// ListWatchers wraps the Context-based ListWatchers function for the given
+// object.
+func (o *Object) ListWatchers() ([]Watcher, error) {
+    return o.c.ListWatchers(o.name)
+}
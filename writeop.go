@@ -0,0 +1,375 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "stdint.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "time"
+    "unsafe"
+)
+
+// WriteOp is a compound write operation. The actions added to a WriteOp
+// are applied to a single object atomically when the operation is
+// performed (see Context.Operate()). The data and values passed to the
+// actions are read when the operation is performed, so they must not be
+// modified before.
+//
+// Unless it creates the object exclusively, an operation is guarded by the
+// seal of the object (see Object.Seal()), and fails with an error wrapping
+// ErrImmutable if the object is sealed.
+type WriteOp struct {
+    actions []func(cop C.rados_write_op_t)
+    size    int // Bytes of data written by the operation
+
+    cmem []unsafe.Pointer // C memory that must live as long as the operation
+    seal *C.int           // Result of the seal guard
+
+    exclusive bool // The operation creates the object exclusively
+    unguarded bool // The operation is not guarded by the seal
+}
+
+// CmpOp is a comparison operator used by the guards of write operations.
+type CmpOp int
+
+// Comparison operators for the guards of write operations.
+const (
+    CmpEq  CmpOp = C.LIBRADOS_CMPXATTR_OP_EQ
+    CmpNe  CmpOp = C.LIBRADOS_CMPXATTR_OP_NE
+    CmpGt  CmpOp = C.LIBRADOS_CMPXATTR_OP_GT
+    CmpGte CmpOp = C.LIBRADOS_CMPXATTR_OP_GTE
+    CmpLt  CmpOp = C.LIBRADOS_CMPXATTR_OP_LT
+    CmpLte CmpOp = C.LIBRADOS_CMPXATTR_OP_LTE
+)
+
+// NewWriteOp returns a new, empty write operation. The operation should be
+// released with Release() when it is no longer needed.
+func NewWriteOp() *WriteOp {
+    return &WriteOp{}
+}
+
+// Release frees the resources held by the write operation.
+func (op *WriteOp) Release() error {
+    for _, p := range op.cmem {
+        C.free(p)
+    }
+    op.cmem = nil
+    op.seal = nil
+    op.actions = nil
+
+    return nil
+}
+
+// add is a utility function that adds an action to the operation. The
+// action adds the corresponding librados action to cop when the operation
+// is performed.
+func (op *WriteOp) add(action func(cop C.rados_write_op_t)) {
+    op.actions = append(op.actions, action)
+}
+
+// newResult is a utility function that returns a result of the operation,
+// which is set when the operation is performed, so it lives in C memory.
+func (op *WriteOp) newResult() *C.int {
+    cprval := (*C.int)(C.malloc(C.size_t(unsafe.Sizeof(C.int(0)))))
+    *cprval = 0
+    op.cmem = append(op.cmem, unsafe.Pointer(cprval))
+
+    return cprval
+}
+
+// Create adds the creation of the object to the operation. If exclusive
+// is true the operation fails if the object already exists, otherwise
+// creating an existing object succeeds without changing its data.
+func (op *WriteOp) Create(exclusive bool) {
+    cexclusive := C.int(C.LIBRADOS_CREATE_IDEMPOTENT)
+    if exclusive {
+        cexclusive = C.LIBRADOS_CREATE_EXCLUSIVE
+        op.exclusive = true
+    }
+
+    op.add(func(cop C.rados_write_op_t) {
+        C.rados_write_op_create(cop, cexclusive, nil)
+    })
+}
+
+// AssertVersion adds a guard to the operation that makes it fail unless
+// the object is currently at version ver.
+func (op *WriteOp) AssertVersion(ver uint64) {
+    op.add(func(cop C.rados_write_op_t) {
+        C.rados_write_op_assert_version(cop, C.uint64_t(ver))
+    })
+}
+
+// Write adds a write of data at the byte offset off to the operation.
+func (op *WriteOp) Write(data []byte, off int64) {
+    op.add(func(cop C.rados_write_op_t) {
+        cdata, cdatalen := byteSliceToBuffer(data)
+        C.rados_write_op_write(cop, cdata, cdatalen, C.uint64_t(off))
+    })
+    op.size += len(data)
+}
+
+// WriteFull adds a write to the operation that replaces the entire
+// contents of the object with data.
+func (op *WriteOp) WriteFull(data []byte) {
+    op.add(func(cop C.rados_write_op_t) {
+        cdata, cdatalen := byteSliceToBuffer(data)
+        C.rados_write_op_write_full(cop, cdata, cdatalen)
+    })
+    op.size += len(data)
+}
+
+// append is a utility function that adds the appending of data to the
+// operation.
+func (op *WriteOp) append(data []byte) {
+    op.add(func(cop C.rados_write_op_t) {
+        cdata, cdatalen := byteSliceToBuffer(data)
+        C.rados_write_op_append(cop, cdata, cdatalen)
+    })
+    op.size += len(data)
+}
+
+// truncate is a utility function that adds the truncation of the object
+// to size bytes to the operation.
+func (op *WriteOp) truncate(size int64) {
+    op.add(func(cop C.rados_write_op_t) {
+        C.rados_write_op_truncate(cop, C.uint64_t(size))
+    })
+}
+
+// setFlags is a utility function that sets the given flags (e.g., access
+// hints) on the last action added to the operation.
+func (op *WriteOp) setFlags(flags FadviseFlags) {
+    if flags == 0 {
+        return
+    }
+
+    op.add(func(cop C.rados_write_op_t) {
+        C.rados_write_op_set_flags(cop, C.int(flags))
+    })
+}
+
+// Remove adds the removal of the object to the operation.
+func (op *WriteOp) Remove() {
+    op.add(func(cop C.rados_write_op_t) {
+        C.rados_write_op_remove(cop)
+    })
+}
+
+// SetXattr adds the setting of the extended attribute xattr to value to
+// the operation.
+func (op *WriteOp) SetXattr(xattr string, value []byte) {
+    op.add(func(cop C.rados_write_op_t) {
+        cxattr := C.CString(xattr)
+        defer C.free(unsafe.Pointer(cxattr))
+
+        cdata, cdatalen := byteSliceToBuffer(value)
+
+        C.rados_write_op_setxattr(cop, cxattr, cdata, cdatalen)
+    })
+}
+
+// RmXattr adds the removal of the extended attribute xattr to the
+// operation. The operation fails if the attribute is not set.
+func (op *WriteOp) RmXattr(xattr string) {
+    op.add(func(cop C.rados_write_op_t) {
+        cxattr := C.CString(xattr)
+        defer C.free(unsafe.Pointer(cxattr))
+
+        C.rados_write_op_rmxattr(cop, cxattr)
+    })
+}
+
+// OmapSet adds the setting of the given omap keys and values to the
+// operation. Existing keys not present in pairs are left untouched.
+func (op *WriteOp) OmapSet(pairs map[string][]byte) {
+    if len(pairs) == 0 {
+        return
+    }
+
+    op.add(func(cop C.rados_write_op_t) {
+        // librados copies the keys and values into the operation, so the
+        // C copies only need to live until rados_write_op_omap_set()
+        // returns.
+        ckeys := make([]*C.char, 0, len(pairs))
+        cvals := make([]*C.char, 0, len(pairs))
+        clens := make([]C.size_t, 0, len(pairs))
+
+        for key, val := range pairs {
+            ckey := C.CString(key)
+            defer C.free(unsafe.Pointer(ckey))
+            cval := (*C.char)(C.CBytes(val))
+            defer C.free(unsafe.Pointer(cval))
+
+            ckeys = append(ckeys, ckey)
+            cvals = append(cvals, cval)
+            clens = append(clens, C.size_t(len(val)))
+        }
+
+        C.rados_write_op_omap_set(cop, &ckeys[0], &cvals[0], &clens[0], C.size_t(len(pairs)))
+    })
+}
+
+// OmapRmKeys adds the removal of the given omap keys to the operation.
+// Keys that are not set are ignored.
+func (op *WriteOp) OmapRmKeys(keys []string) {
+    if len(keys) == 0 {
+        return
+    }
+
+    op.add(func(cop C.rados_write_op_t) {
+        // librados copies the keys into the operation, so the C copies
+        // only need to live until rados_write_op_omap_rm_keys() returns.
+        ckeys := make([]*C.char, len(keys))
+        for i, key := range keys {
+            ckeys[i] = C.CString(key)
+            defer C.free(unsafe.Pointer(ckeys[i]))
+        }
+
+        C.rados_write_op_omap_rm_keys(cop, &ckeys[0], C.size_t(len(keys)))
+    })
+}
+
+// OmapCmp adds a guard to the operation that makes it fail unless the
+// omap key of the object compares to value as specified by cmp (e.g., with
+// CmpEq, unless the key currently holds value). Values are compared
+// bytewise. The OSDs only support CmpEq, CmpGt and CmpLt for omap keys,
+// and the guard fails if the key is not set. A failed guard makes the
+// whole operation fail with an error wrapping ErrComparisonFailed, without
+// applying any of its actions.
+func (op *WriteOp) OmapCmp(key string, cmp CmpOp, value []byte) {
+    cprval := op.newResult()
+
+    op.add(func(cop C.rados_write_op_t) {
+        ckey := C.CString(key)
+        defer C.free(unsafe.Pointer(ckey))
+
+        cdata, cdatalen := byteSliceToBuffer(value)
+
+        C.rados_write_op_omap_cmp(cop, ckey, C.uint8_t(cmp), cdata, cdatalen, cprval)
+    })
+}
+
+// build is a utility function that returns a new librados write operation
+// with the actions of the operation. If guard is true, it is guarded by
+// the seal of the object. The guard comes last, so that it doesn't
+// take the flags set on the last action. It checks the tag of the
+// user-level version of the object through the version object class,
+// which treats missing objects as unversioned, and has its own result, so
+// that a failed seal guard can be told apart from the other guards.
+func (op *WriteOp) build(guard bool) C.rados_write_op_t {
+    cop := C.rados_create_write_op()
+
+    for _, action := range op.actions {
+        action(cop)
+    }
+
+    if guard {
+        if op.seal == nil {
+            op.seal = op.newResult()
+        }
+        *op.seal = 0
+
+        cclass := C.CString("version")
+        defer C.free(unsafe.Pointer(cclass))
+        cmethod := C.CString("check_conds")
+        defer C.free(unsafe.Pointer(cmethod))
+
+        cin, cinlen := byteSliceToBuffer(sealGuard)
+
+        C.rados_write_op_exec(cop, cclass, cmethod, cin, cinlen, op.seal)
+    }
+
+    return cop
+}
+
+// guarded is a utility function that reports whether the operation must
+// be guarded by the seal of the object. Operations creating the object
+// exclusively are not, since sealed objects exist.
+func (op *WriteOp) guarded() bool {
+    return !op.exclusive && !op.unguarded
+}
+
+// sealResult is a utility function that returns the result of a guarded
+// operation, as returned by librados, with cerrSealed instead of
+// -ECANCELED if the seal guard failed.
+func (op *WriteOp) sealResult(cerr C.int) C.int {
+    if cerr == -C.ECANCELED && op.seal != nil && *op.seal == -C.ECANCELED {
+        return cerrSealed
+    }
+
+    return cerr
+}
+
+// Operate performs the write operation on the named object in the pool
+// referenced by the given context.
+func (c *Context) Operate(name string, op *WriteOp) (err error) {
+    defer c.audit("operate", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    if cerr := c.operate(name, op, nil); cerr < 0 {
+        return c.writeError("operate", name, cerr)
+    }
+
+    return nil
+}
+
+// OperateWithMtime performs the write operation like Operate(), but
+// records mtime as the modification time of the object instead of the
+// current time. RADOS stores modification times with a resolution of
+// one second.
+func (c *Context) OperateWithMtime(name string, op *WriteOp, mtime time.Time) (err error) {
+    defer c.audit("operate", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    cmtime := C.time_t(mtime.Unix())
+
+    if cerr := c.operate(name, op, &cmtime); cerr < 0 {
+        return c.writeError("operate", name, cerr)
+    }
+
+    return nil
+}
+
+// operate is a utility function that performs the given write operation
+// on the named object and returns the raw librados result, or cerrSealed
+// if the object is sealed. A nil mtime means the current time is used.
+func (c *Context) operate(name string, op *WriteOp, mtime *C.time_t) C.int {
+    return c.operateKind(opWrite, name, op, mtime)
+}
+
+// operateKind is a utility function that performs the given write
+// operation like operate(), recording it as an operation of the given
+// kind.
+func (c *Context) operateKind(kind opKind, name string, op *WriteOp, mtime *C.time_t) C.int {
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    guard := op.guarded()
+
+    start := time.Now()
+    cop := op.build(guard)
+    cerr := c.call(kind, "rados_write_op_operate", name, func() C.int {
+        return C.rados_write_op_operate(cop, c.ctx, cname, mtime, C.int(c.opFlags))
+    })
+    C.rados_release_write_op(cop)
+
+    if guard {
+        cerr = op.sealResult(cerr)
+    }
+
+    c.record(kind, name, start, cerr, op.size)
+
+    return cerr
+}
@@ -0,0 +1,142 @@
+package rados
+
+/*
+#cgo LDFLAGS: -lrados
+#include "stdlib.h"
+#include "errno.h"
+#include "rados/librados.h"
+*/
+import "C"
+
+import (
+    "fmt"
+    "time"
+    "unsafe"
+)
+
+// GetXattr returns the value of the extended attribute xattr of the named
+// object in the pool referenced by the given context.
+func (c *Context) GetXattr(name string, xattr string) ([]byte, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    value, cerr := c.getXattr(name, xattr)
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS getxattr %s %s: %w", name, xattr, radosErrno(cerr))
+    }
+
+    return value, nil
+}
+
+// getXattr is a utility function that returns the value of the extended
+// attribute xattr of the named object along with the raw librados result.
+func (c *Context) getXattr(name string, xattr string) ([]byte, C.int) {
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+    cxattr := C.CString(xattr)
+    defer C.free(unsafe.Pointer(cxattr))
+
+    var buf []byte
+    bufSize := 256 // Initial guess at amount of space we need
+
+    // rados_getxattr() fails with ERANGE if the value doesn't fit in
+    // our buffer, in which case we retry with a bigger one.
+    for {
+        buf = make([]byte, bufSize)
+        cdata, cdatalen := byteSliceToBuffer(buf)
+
+        start := time.Now()
+        cerr := c.call(opXattr, "rados_getxattr", name, func() C.int {
+            return C.rados_getxattr(c.ctx, cname, cxattr, cdata, cdatalen)
+        })
+        c.record(opXattr, name, start, cerr, 0)
+
+        if cerr == -C.ERANGE {
+            bufSize *= 2
+            continue
+        } else if cerr < 0 {
+            return nil, cerr
+        }
+
+        return buf[:cerr], 0
+    }
+}
+
+// GetXattrs returns all the extended attributes of the named object in the
+// pool referenced by the given context.
+func (c *Context) GetXattrs(name string) (map[string][]byte, error) {
+    if err := checkName(name); err != nil {
+        return nil, err
+    }
+
+    cname := C.CString(name)
+    defer C.free(unsafe.Pointer(cname))
+
+    var citer C.rados_xattrs_iter_t
+
+    start := time.Now()
+    cerr := c.call(opXattr, "rados_getxattrs", name, func() C.int {
+        return C.rados_getxattrs(c.ctx, cname, &citer)
+    })
+    c.record(opXattr, name, start, cerr, 0)
+
+    if cerr < 0 {
+        return nil, fmt.Errorf("RADOS getxattrs %s: %w", name, radosErrno(cerr))
+    }
+    defer C.rados_getxattrs_end(citer)
+
+    xattrs := make(map[string][]byte)
+
+    for {
+        var cxattr, cvalue *C.char
+        var clen C.size_t
+
+        if cerr = C.rados_getxattrs_next(citer, &cxattr, &cvalue, &clen); cerr < 0 {
+            return nil, fmt.Errorf("RADOS getxattrs %s: %w", name, radosErrno(cerr))
+        }
+
+        if cxattr == nil {
+            return xattrs, nil
+        }
+
+        xattrs[C.GoString(cxattr)] = C.GoBytes(unsafe.Pointer(cvalue), C.int(clen))
+    }
+}
+
+// SetXattr sets the extended attribute xattr of the named object in the
+// pool referenced by the given context to value.
+func (c *Context) SetXattr(name string, xattr string, value []byte) (err error) {
+    defer c.audit("set xattr", name, &err)
+
+    if err := checkName(name); err != nil {
+        return err
+    }
+
+    op := NewWriteOp()
+    defer op.Release()
+
+    op.SetXattr(xattr, value)
+
+    if cerr := c.operateKind(opXattr, name, op, nil); cerr < 0 {
+        return fmt.Errorf("RADOS setxattr %s %s: %w", name, xattr, radosErrno(cerr))
+    }
+
+    return nil
+}
+
+// GetXattr wraps the Context-based GetXattr function for the given object.
+func (o *Object) GetXattr(xattr string) ([]byte, error) {
+    return o.c.GetXattr(o.name, xattr)
+}
+
+// GetXattrs wraps the Context-based GetXattrs function for the given object.
+func (o *Object) GetXattrs() (map[string][]byte, error) {
+    return o.c.GetXattrs(o.name)
+}
+
+// SetXattr wraps the Context-based SetXattr function for the given object.
+// It fails with ErrImmutable if the object has been sealed.
+func (o *Object) SetXattr(xattr string, value []byte) error {
+    return o.c.SetXattr(o.name, xattr, value)
+}